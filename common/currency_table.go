@@ -0,0 +1,410 @@
+// Code generated by tools/gencurrency from iso4217.csv. DO NOT EDIT.
+
+package common
+
+// ISO 4217 currency codes
+const (
+	CurrencyAED DevCurrency = 784 // UAE Dirham
+	CurrencyAFN DevCurrency = 971 // Afghani
+	CurrencyALL DevCurrency = 8   // Lek
+	CurrencyAMD DevCurrency = 51  // Armenian Dram
+	CurrencyANG DevCurrency = 532 // Netherlands Antillean Guilder
+	CurrencyAOA DevCurrency = 973 // Kwanza
+	CurrencyARS DevCurrency = 32  // Argentine Peso
+	CurrencyAUD DevCurrency = 36  // Australian Dollar
+	CurrencyAWG DevCurrency = 533 // Aruban Florin
+	CurrencyAZN DevCurrency = 944 // Azerbaijan Manat
+	CurrencyBAM DevCurrency = 977 // Convertible Mark
+	CurrencyBBD DevCurrency = 52  // Barbados Dollar
+	CurrencyBDT DevCurrency = 50  // Taka
+	CurrencyBGN DevCurrency = 975 // Bulgarian Lev
+	CurrencyBHD DevCurrency = 48  // Bahraini Dinar
+	CurrencyBIF DevCurrency = 108 // Burundi Franc
+	CurrencyBMD DevCurrency = 60  // Bermudian Dollar
+	CurrencyBND DevCurrency = 96  // Brunei Dollar
+	CurrencyBOB DevCurrency = 68  // Boliviano
+	CurrencyBOV DevCurrency = 984 // Mvdol
+	CurrencyBRL DevCurrency = 986 // Brazilian Real
+	CurrencyBSD DevCurrency = 44  // Bahamian Dollar
+	CurrencyBTN DevCurrency = 64  // Ngultrum
+	CurrencyBWP DevCurrency = 72  // Pula
+	CurrencyBYN DevCurrency = 933 // Belarusian Ruble
+	CurrencyBZD DevCurrency = 84  // Belize Dollar
+	CurrencyCAD DevCurrency = 124 // Canadian Dollar
+	CurrencyCDF DevCurrency = 976 // Congolese Franc
+	CurrencyCHE DevCurrency = 947 // WIR Euro
+	CurrencyCHF DevCurrency = 756 // Swiss Franc
+	CurrencyCHW DevCurrency = 948 // WIR Franc
+	CurrencyCLF DevCurrency = 990 // Unidad de Fomento
+	CurrencyCLP DevCurrency = 152 // Chilean Peso
+	CurrencyCNY DevCurrency = 156 // Yuan Renminbi
+	CurrencyCOP DevCurrency = 170 // Colombian Peso
+	CurrencyCOU DevCurrency = 970 // Unidad de Valor Real
+	CurrencyCRC DevCurrency = 188 // Costa Rican Colon
+	CurrencyCUP DevCurrency = 192 // Cuban Peso
+	CurrencyCVE DevCurrency = 132 // Cabo Verde Escudo
+	CurrencyCZK DevCurrency = 203 // Czech Koruna
+	CurrencyDJF DevCurrency = 262 // Djibouti Franc
+	CurrencyDKK DevCurrency = 208 // Danish Krone
+	CurrencyDOP DevCurrency = 214 // Dominican Peso
+	CurrencyDZD DevCurrency = 12  // Algerian Dinar
+	CurrencyEGP DevCurrency = 818 // Egyptian Pound
+	CurrencyERN DevCurrency = 232 // Nakfa
+	CurrencyETB DevCurrency = 230 // Ethiopian Birr
+	CurrencyEUR DevCurrency = 978 // Euro
+	CurrencyFJD DevCurrency = 242 // Fiji Dollar
+	CurrencyFKP DevCurrency = 238 // Falkland Islands Pound
+	CurrencyGBP DevCurrency = 826 // Pound Sterling
+	CurrencyGEL DevCurrency = 981 // Lari
+	CurrencyGHS DevCurrency = 936 // Ghana Cedi
+	CurrencyGIP DevCurrency = 292 // Gibraltar Pound
+	CurrencyGMD DevCurrency = 270 // Dalasi
+	CurrencyGNF DevCurrency = 324 // Guinean Franc
+	CurrencyGTQ DevCurrency = 320 // Quetzal
+	CurrencyGYD DevCurrency = 328 // Guyana Dollar
+	CurrencyHKD DevCurrency = 344 // Hong Kong Dollar
+	CurrencyHNL DevCurrency = 340 // Lempira
+	CurrencyHTG DevCurrency = 332 // Gourde
+	CurrencyHUF DevCurrency = 348 // Forint
+	CurrencyIDR DevCurrency = 360 // Rupiah
+	CurrencyILS DevCurrency = 376 // New Israeli Sheqel
+	CurrencyINR DevCurrency = 356 // Indian Rupee
+	CurrencyIQD DevCurrency = 368 // Iraqi Dinar
+	CurrencyIRR DevCurrency = 364 // Iranian Rial
+	CurrencyISK DevCurrency = 352 // Iceland Krona
+	CurrencyJMD DevCurrency = 388 // Jamaican Dollar
+	CurrencyJOD DevCurrency = 400 // Jordanian Dinar
+	CurrencyJPY DevCurrency = 392 // Yen
+	CurrencyKES DevCurrency = 404 // Kenyan Shilling
+	CurrencyKGS DevCurrency = 417 // Som
+	CurrencyKHR DevCurrency = 116 // Riel
+	CurrencyKMF DevCurrency = 174 // Comorian Franc
+	CurrencyKPW DevCurrency = 408 // North Korean Won
+	CurrencyKRW DevCurrency = 410 // Won
+	CurrencyKWD DevCurrency = 414 // Kuwaiti Dinar
+	CurrencyKYD DevCurrency = 136 // Cayman Islands Dollar
+	CurrencyKZT DevCurrency = 398 // Tenge
+	CurrencyLAK DevCurrency = 418 // Lao Kip
+	CurrencyLBP DevCurrency = 422 // Lebanese Pound
+	CurrencyLKR DevCurrency = 144 // Sri Lanka Rupee
+	CurrencyLRD DevCurrency = 430 // Liberian Dollar
+	CurrencyLSL DevCurrency = 426 // Loti
+	CurrencyLYD DevCurrency = 434 // Libyan Dinar
+	CurrencyMAD DevCurrency = 504 // Moroccan Dirham
+	CurrencyMDL DevCurrency = 498 // Moldovan Leu
+	CurrencyMGA DevCurrency = 969 // Malagasy Ariary
+	CurrencyMKD DevCurrency = 807 // Denar
+	CurrencyMMK DevCurrency = 104 // Kyat
+	CurrencyMNT DevCurrency = 496 // Tugrik
+	CurrencyMOP DevCurrency = 446 // Pataca
+	CurrencyMRU DevCurrency = 929 // Ouguiya
+	CurrencyMUR DevCurrency = 480 // Mauritius Rupee
+	CurrencyMVR DevCurrency = 462 // Rufiyaa
+	CurrencyMWK DevCurrency = 454 // Malawi Kwacha
+	CurrencyMXN DevCurrency = 484 // Mexican Peso
+	CurrencyMXV DevCurrency = 979 // Mexican Unidad de Inversion (UDI)
+	CurrencyMYR DevCurrency = 458 // Malaysian Ringgit
+	CurrencyMZN DevCurrency = 943 // Mozambique Metical
+	CurrencyNAD DevCurrency = 516 // Namibia Dollar
+	CurrencyNGN DevCurrency = 566 // Naira
+	CurrencyNIO DevCurrency = 558 // Cordoba Oro
+	CurrencyNOK DevCurrency = 578 // Norwegian Krone
+	CurrencyNPR DevCurrency = 524 // Nepalese Rupee
+	CurrencyNZD DevCurrency = 554 // New Zealand Dollar
+	CurrencyOMR DevCurrency = 512 // Rial Omani
+	CurrencyPAB DevCurrency = 590 // Balboa
+	CurrencyPEN DevCurrency = 604 // Sol
+	CurrencyPGK DevCurrency = 598 // Kina
+	CurrencyPHP DevCurrency = 608 // Philippine Peso
+	CurrencyPKR DevCurrency = 586 // Pakistan Rupee
+	CurrencyPLN DevCurrency = 985 // Zloty
+	CurrencyPYG DevCurrency = 600 // Guarani
+	CurrencyQAR DevCurrency = 634 // Qatari Rial
+	CurrencyRON DevCurrency = 946 // Romanian Leu
+	CurrencyRSD DevCurrency = 941 // Serbian Dinar
+	CurrencyRUB DevCurrency = 643 // Russian Ruble
+	CurrencyRWF DevCurrency = 646 // Rwanda Franc
+	CurrencySAR DevCurrency = 682 // Saudi Riyal
+	CurrencySBD DevCurrency = 90  // Solomon Islands Dollar
+	CurrencySCR DevCurrency = 690 // Seychelles Rupee
+	CurrencySDG DevCurrency = 938 // Sudanese Pound
+	CurrencySEK DevCurrency = 752 // Swedish Krona
+	CurrencySGD DevCurrency = 702 // Singapore Dollar
+	CurrencySHP DevCurrency = 654 // Saint Helena Pound
+	CurrencySLE DevCurrency = 925 // Leone
+	CurrencySOS DevCurrency = 706 // Somali Shilling
+	CurrencySRD DevCurrency = 968 // Surinam Dollar
+	CurrencySSP DevCurrency = 728 // South Sudanese Pound
+	CurrencySTN DevCurrency = 930 // Dobra
+	CurrencySVC DevCurrency = 222 // El Salvador Colon
+	CurrencySYP DevCurrency = 760 // Syrian Pound
+	CurrencySZL DevCurrency = 748 // Lilangeni
+	CurrencyTHB DevCurrency = 764 // Baht
+	CurrencyTJS DevCurrency = 972 // Somoni
+	CurrencyTMT DevCurrency = 934 // Turkmenistan New Manat
+	CurrencyTND DevCurrency = 788 // Tunisian Dinar
+	CurrencyTOP DevCurrency = 776 // Pa'anga
+	CurrencyTRY DevCurrency = 949 // Turkish Lira
+	CurrencyTTD DevCurrency = 780 // Trinidad and Tobago Dollar
+	CurrencyTWD DevCurrency = 901 // New Taiwan Dollar
+	CurrencyTZS DevCurrency = 834 // Tanzanian Shilling
+	CurrencyUAH DevCurrency = 980 // Hryvnia
+	CurrencyUGX DevCurrency = 800 // Uganda Shilling
+	CurrencyUSD DevCurrency = 840 // US Dollar
+	CurrencyUSN DevCurrency = 997 // US Dollar (Next day)
+	CurrencyUYI DevCurrency = 940 // Uruguay Peso en Unidades Indexadas (UI)
+	CurrencyUYU DevCurrency = 858 // Peso Uruguayo
+	CurrencyUYW DevCurrency = 927 // Unidad Previsional
+	CurrencyUZS DevCurrency = 860 // Uzbekistan Sum
+	CurrencyVED DevCurrency = 926 // Bolivar Soberano
+	CurrencyVES DevCurrency = 928 // Bolivar Soberano
+	CurrencyVND DevCurrency = 704 // Dong
+	CurrencyVUV DevCurrency = 548 // Vatu
+	CurrencyWST DevCurrency = 882 // Tala
+	CurrencyXAF DevCurrency = 950 // CFA Franc BEAC
+	CurrencyXAG DevCurrency = 961 // Silver
+	CurrencyXAU DevCurrency = 959 // Gold
+	CurrencyXBA DevCurrency = 955 // Bond Markets Unit European Composite Unit (EURCO)
+	CurrencyXBB DevCurrency = 956 // Bond Markets Unit European Monetary Unit (E.M.U.-6)
+	CurrencyXBC DevCurrency = 957 // Bond Markets Unit European Unit of Account 9 (E.U.A.-9)
+	CurrencyXBD DevCurrency = 958 // Bond Markets Unit European Unit of Account 17 (E.U.A.-17)
+	CurrencyXCD DevCurrency = 951 // East Caribbean Dollar
+	CurrencyXDR DevCurrency = 960 // SDR (Special Drawing Right)
+	CurrencyXOF DevCurrency = 952 // CFA Franc BCEAO
+	CurrencyXPD DevCurrency = 964 // Palladium
+	CurrencyXPF DevCurrency = 953 // CFP Franc
+	CurrencyXPT DevCurrency = 962 // Platinum
+	CurrencyXSU DevCurrency = 994 // Sucre
+	CurrencyXTS DevCurrency = 963 // Codes specifically reserved for testing purposes
+	CurrencyXUA DevCurrency = 965 // ADB Unit of Account
+	CurrencyXXX DevCurrency = 999 // No currency (Token)
+	CurrencyYER DevCurrency = 886 // Yemeni Rial
+	CurrencyZAR DevCurrency = 710 // Rand
+	CurrencyZMW DevCurrency = 967 // Zambian Kwacha
+	CurrencyZWG DevCurrency = 924 // Zimbabwe Gold
+	CurrencyATS DevCurrency = 40  // Austrian Schilling
+	CurrencyAZM DevCurrency = 31  // Azerbaijanian Manat
+	CurrencyBEF DevCurrency = 56  // Belgian Franc
+	CurrencyBYR DevCurrency = 974 // Belarusian Ruble
+	CurrencyCUC DevCurrency = 931 // Peso Convertible
+	CurrencyDEM DevCurrency = 276 // Deutsche Mark
+	CurrencyEEK DevCurrency = 233 // Kroon
+	CurrencyESP DevCurrency = 724 // Spanish Peseta
+	CurrencyFIM DevCurrency = 246 // Markka
+	CurrencyFRF DevCurrency = 250 // French Franc
+	CurrencyGRD DevCurrency = 300 // Drachma
+	CurrencyHRK DevCurrency = 191 // Kuna
+	CurrencyIEP DevCurrency = 372 // Irish Pound
+	CurrencyITL DevCurrency = 380 // Italian Lira
+	CurrencyLTL DevCurrency = 440 // Lithuanian Litas
+	CurrencyLVL DevCurrency = 428 // Latvian Lats
+	CurrencyNLG DevCurrency = 528 // Netherlands Guilder
+	CurrencyPTE DevCurrency = 620 // Portuguese Escudo
+	CurrencyROL DevCurrency = 642 // Romanian Leu
+	CurrencySKK DevCurrency = 703 // Slovak Koruna
+	CurrencySLL DevCurrency = 694 // Leone
+	CurrencyZWL DevCurrency = 932 // Zimbabwe Dollar
+)
+
+var currencyTable = []CurrencyInfo{
+	{CurrencyAED, "AED", "UAE Dirham", 2, false},
+	{CurrencyAFN, "AFN", "Afghani", 2, false},
+	{CurrencyALL, "ALL", "Lek", 2, false},
+	{CurrencyAMD, "AMD", "Armenian Dram", 2, false},
+	{CurrencyANG, "ANG", "Netherlands Antillean Guilder", 2, false},
+	{CurrencyAOA, "AOA", "Kwanza", 2, false},
+	{CurrencyARS, "ARS", "Argentine Peso", 2, false},
+	{CurrencyAUD, "AUD", "Australian Dollar", 2, false},
+	{CurrencyAWG, "AWG", "Aruban Florin", 2, false},
+	{CurrencyAZN, "AZN", "Azerbaijan Manat", 2, false},
+	{CurrencyBAM, "BAM", "Convertible Mark", 2, false},
+	{CurrencyBBD, "BBD", "Barbados Dollar", 2, false},
+	{CurrencyBDT, "BDT", "Taka", 2, false},
+	{CurrencyBGN, "BGN", "Bulgarian Lev", 2, false},
+	{CurrencyBHD, "BHD", "Bahraini Dinar", 3, false},
+	{CurrencyBIF, "BIF", "Burundi Franc", 0, false},
+	{CurrencyBMD, "BMD", "Bermudian Dollar", 2, false},
+	{CurrencyBND, "BND", "Brunei Dollar", 2, false},
+	{CurrencyBOB, "BOB", "Boliviano", 2, false},
+	{CurrencyBOV, "BOV", "Mvdol", 2, false},
+	{CurrencyBRL, "BRL", "Brazilian Real", 2, false},
+	{CurrencyBSD, "BSD", "Bahamian Dollar", 2, false},
+	{CurrencyBTN, "BTN", "Ngultrum", 2, false},
+	{CurrencyBWP, "BWP", "Pula", 2, false},
+	{CurrencyBYN, "BYN", "Belarusian Ruble", 2, false},
+	{CurrencyBZD, "BZD", "Belize Dollar", 2, false},
+	{CurrencyCAD, "CAD", "Canadian Dollar", 2, false},
+	{CurrencyCDF, "CDF", "Congolese Franc", 2, false},
+	{CurrencyCHE, "CHE", "WIR Euro", 2, false},
+	{CurrencyCHF, "CHF", "Swiss Franc", 2, false},
+	{CurrencyCHW, "CHW", "WIR Franc", 2, false},
+	{CurrencyCLF, "CLF", "Unidad de Fomento", 4, false},
+	{CurrencyCLP, "CLP", "Chilean Peso", 0, false},
+	{CurrencyCNY, "CNY", "Yuan Renminbi", 2, false},
+	{CurrencyCOP, "COP", "Colombian Peso", 2, false},
+	{CurrencyCOU, "COU", "Unidad de Valor Real", 2, false},
+	{CurrencyCRC, "CRC", "Costa Rican Colon", 2, false},
+	{CurrencyCUP, "CUP", "Cuban Peso", 2, false},
+	{CurrencyCVE, "CVE", "Cabo Verde Escudo", 2, false},
+	{CurrencyCZK, "CZK", "Czech Koruna", 2, false},
+	{CurrencyDJF, "DJF", "Djibouti Franc", 0, false},
+	{CurrencyDKK, "DKK", "Danish Krone", 2, false},
+	{CurrencyDOP, "DOP", "Dominican Peso", 2, false},
+	{CurrencyDZD, "DZD", "Algerian Dinar", 2, false},
+	{CurrencyEGP, "EGP", "Egyptian Pound", 2, false},
+	{CurrencyERN, "ERN", "Nakfa", 2, false},
+	{CurrencyETB, "ETB", "Ethiopian Birr", 2, false},
+	{CurrencyEUR, "EUR", "Euro", 2, false},
+	{CurrencyFJD, "FJD", "Fiji Dollar", 2, false},
+	{CurrencyFKP, "FKP", "Falkland Islands Pound", 2, false},
+	{CurrencyGBP, "GBP", "Pound Sterling", 2, false},
+	{CurrencyGEL, "GEL", "Lari", 2, false},
+	{CurrencyGHS, "GHS", "Ghana Cedi", 2, false},
+	{CurrencyGIP, "GIP", "Gibraltar Pound", 2, false},
+	{CurrencyGMD, "GMD", "Dalasi", 2, false},
+	{CurrencyGNF, "GNF", "Guinean Franc", 0, false},
+	{CurrencyGTQ, "GTQ", "Quetzal", 2, false},
+	{CurrencyGYD, "GYD", "Guyana Dollar", 2, false},
+	{CurrencyHKD, "HKD", "Hong Kong Dollar", 2, false},
+	{CurrencyHNL, "HNL", "Lempira", 2, false},
+	{CurrencyHTG, "HTG", "Gourde", 2, false},
+	{CurrencyHUF, "HUF", "Forint", 2, false},
+	{CurrencyIDR, "IDR", "Rupiah", 2, false},
+	{CurrencyILS, "ILS", "New Israeli Sheqel", 2, false},
+	{CurrencyINR, "INR", "Indian Rupee", 2, false},
+	{CurrencyIQD, "IQD", "Iraqi Dinar", 3, false},
+	{CurrencyIRR, "IRR", "Iranian Rial", 2, false},
+	{CurrencyISK, "ISK", "Iceland Krona", 0, false},
+	{CurrencyJMD, "JMD", "Jamaican Dollar", 2, false},
+	{CurrencyJOD, "JOD", "Jordanian Dinar", 3, false},
+	{CurrencyJPY, "JPY", "Yen", 0, false},
+	{CurrencyKES, "KES", "Kenyan Shilling", 2, false},
+	{CurrencyKGS, "KGS", "Som", 2, false},
+	{CurrencyKHR, "KHR", "Riel", 2, false},
+	{CurrencyKMF, "KMF", "Comorian Franc", 0, false},
+	{CurrencyKPW, "KPW", "North Korean Won", 2, false},
+	{CurrencyKRW, "KRW", "Won", 0, false},
+	{CurrencyKWD, "KWD", "Kuwaiti Dinar", 3, false},
+	{CurrencyKYD, "KYD", "Cayman Islands Dollar", 2, false},
+	{CurrencyKZT, "KZT", "Tenge", 2, false},
+	{CurrencyLAK, "LAK", "Lao Kip", 2, false},
+	{CurrencyLBP, "LBP", "Lebanese Pound", 2, false},
+	{CurrencyLKR, "LKR", "Sri Lanka Rupee", 2, false},
+	{CurrencyLRD, "LRD", "Liberian Dollar", 2, false},
+	{CurrencyLSL, "LSL", "Loti", 2, false},
+	{CurrencyLYD, "LYD", "Libyan Dinar", 3, false},
+	{CurrencyMAD, "MAD", "Moroccan Dirham", 2, false},
+	{CurrencyMDL, "MDL", "Moldovan Leu", 2, false},
+	{CurrencyMGA, "MGA", "Malagasy Ariary", 2, false},
+	{CurrencyMKD, "MKD", "Denar", 2, false},
+	{CurrencyMMK, "MMK", "Kyat", 2, false},
+	{CurrencyMNT, "MNT", "Tugrik", 2, false},
+	{CurrencyMOP, "MOP", "Pataca", 2, false},
+	{CurrencyMRU, "MRU", "Ouguiya", 2, false},
+	{CurrencyMUR, "MUR", "Mauritius Rupee", 2, false},
+	{CurrencyMVR, "MVR", "Rufiyaa", 2, false},
+	{CurrencyMWK, "MWK", "Malawi Kwacha", 2, false},
+	{CurrencyMXN, "MXN", "Mexican Peso", 2, false},
+	{CurrencyMXV, "MXV", "Mexican Unidad de Inversion (UDI)", 2, false},
+	{CurrencyMYR, "MYR", "Malaysian Ringgit", 2, false},
+	{CurrencyMZN, "MZN", "Mozambique Metical", 2, false},
+	{CurrencyNAD, "NAD", "Namibia Dollar", 2, false},
+	{CurrencyNGN, "NGN", "Naira", 2, false},
+	{CurrencyNIO, "NIO", "Cordoba Oro", 2, false},
+	{CurrencyNOK, "NOK", "Norwegian Krone", 2, false},
+	{CurrencyNPR, "NPR", "Nepalese Rupee", 2, false},
+	{CurrencyNZD, "NZD", "New Zealand Dollar", 2, false},
+	{CurrencyOMR, "OMR", "Rial Omani", 3, false},
+	{CurrencyPAB, "PAB", "Balboa", 2, false},
+	{CurrencyPEN, "PEN", "Sol", 2, false},
+	{CurrencyPGK, "PGK", "Kina", 2, false},
+	{CurrencyPHP, "PHP", "Philippine Peso", 2, false},
+	{CurrencyPKR, "PKR", "Pakistan Rupee", 2, false},
+	{CurrencyPLN, "PLN", "Zloty", 2, false},
+	{CurrencyPYG, "PYG", "Guarani", 0, false},
+	{CurrencyQAR, "QAR", "Qatari Rial", 2, false},
+	{CurrencyRON, "RON", "Romanian Leu", 2, false},
+	{CurrencyRSD, "RSD", "Serbian Dinar", 2, false},
+	{CurrencyRUB, "RUB", "Russian Ruble", 2, false},
+	{CurrencyRWF, "RWF", "Rwanda Franc", 0, false},
+	{CurrencySAR, "SAR", "Saudi Riyal", 2, false},
+	{CurrencySBD, "SBD", "Solomon Islands Dollar", 2, false},
+	{CurrencySCR, "SCR", "Seychelles Rupee", 2, false},
+	{CurrencySDG, "SDG", "Sudanese Pound", 2, false},
+	{CurrencySEK, "SEK", "Swedish Krona", 2, false},
+	{CurrencySGD, "SGD", "Singapore Dollar", 2, false},
+	{CurrencySHP, "SHP", "Saint Helena Pound", 2, false},
+	{CurrencySLE, "SLE", "Leone", 2, false},
+	{CurrencySOS, "SOS", "Somali Shilling", 2, false},
+	{CurrencySRD, "SRD", "Surinam Dollar", 2, false},
+	{CurrencySSP, "SSP", "South Sudanese Pound", 2, false},
+	{CurrencySTN, "STN", "Dobra", 2, false},
+	{CurrencySVC, "SVC", "El Salvador Colon", 2, false},
+	{CurrencySYP, "SYP", "Syrian Pound", 2, false},
+	{CurrencySZL, "SZL", "Lilangeni", 2, false},
+	{CurrencyTHB, "THB", "Baht", 2, false},
+	{CurrencyTJS, "TJS", "Somoni", 2, false},
+	{CurrencyTMT, "TMT", "Turkmenistan New Manat", 2, false},
+	{CurrencyTND, "TND", "Tunisian Dinar", 3, false},
+	{CurrencyTOP, "TOP", "Pa'anga", 2, false},
+	{CurrencyTRY, "TRY", "Turkish Lira", 2, false},
+	{CurrencyTTD, "TTD", "Trinidad and Tobago Dollar", 2, false},
+	{CurrencyTWD, "TWD", "New Taiwan Dollar", 2, false},
+	{CurrencyTZS, "TZS", "Tanzanian Shilling", 2, false},
+	{CurrencyUAH, "UAH", "Hryvnia", 2, false},
+	{CurrencyUGX, "UGX", "Uganda Shilling", 0, false},
+	{CurrencyUSD, "USD", "US Dollar", 2, false},
+	{CurrencyUSN, "USN", "US Dollar (Next day)", 2, false},
+	{CurrencyUYI, "UYI", "Uruguay Peso en Unidades Indexadas (UI)", 0, false},
+	{CurrencyUYU, "UYU", "Peso Uruguayo", 2, false},
+	{CurrencyUYW, "UYW", "Unidad Previsional", 4, false},
+	{CurrencyUZS, "UZS", "Uzbekistan Sum", 2, false},
+	{CurrencyVED, "VED", "Bolivar Soberano", 2, false},
+	{CurrencyVES, "VES", "Bolivar Soberano", 2, false},
+	{CurrencyVND, "VND", "Dong", 0, false},
+	{CurrencyVUV, "VUV", "Vatu", 0, false},
+	{CurrencyWST, "WST", "Tala", 2, false},
+	{CurrencyXAF, "XAF", "CFA Franc BEAC", 0, false},
+	{CurrencyXAG, "XAG", "Silver", 0, false},
+	{CurrencyXAU, "XAU", "Gold", 0, false},
+	{CurrencyXBA, "XBA", "Bond Markets Unit European Composite Unit (EURCO)", 0, false},
+	{CurrencyXBB, "XBB", "Bond Markets Unit European Monetary Unit (E.M.U.-6)", 0, false},
+	{CurrencyXBC, "XBC", "Bond Markets Unit European Unit of Account 9 (E.U.A.-9)", 0, false},
+	{CurrencyXBD, "XBD", "Bond Markets Unit European Unit of Account 17 (E.U.A.-17)", 0, false},
+	{CurrencyXCD, "XCD", "East Caribbean Dollar", 2, false},
+	{CurrencyXDR, "XDR", "SDR (Special Drawing Right)", 0, false},
+	{CurrencyXOF, "XOF", "CFA Franc BCEAO", 0, false},
+	{CurrencyXPD, "XPD", "Palladium", 0, false},
+	{CurrencyXPF, "XPF", "CFP Franc", 0, false},
+	{CurrencyXPT, "XPT", "Platinum", 0, false},
+	{CurrencyXSU, "XSU", "Sucre", 0, false},
+	{CurrencyXTS, "XTS", "Codes specifically reserved for testing purposes", 0, false},
+	{CurrencyXUA, "XUA", "ADB Unit of Account", 0, false},
+	{CurrencyXXX, "XXX", "No currency (Token)", 0, false},
+	{CurrencyYER, "YER", "Yemeni Rial", 2, false},
+	{CurrencyZAR, "ZAR", "Rand", 2, false},
+	{CurrencyZMW, "ZMW", "Zambian Kwacha", 2, false},
+	{CurrencyZWG, "ZWG", "Zimbabwe Gold", 2, false},
+	{CurrencyATS, "ATS", "Austrian Schilling", 2, true},
+	{CurrencyAZM, "AZM", "Azerbaijanian Manat", 2, true},
+	{CurrencyBEF, "BEF", "Belgian Franc", 0, true},
+	{CurrencyBYR, "BYR", "Belarusian Ruble", 0, true},
+	{CurrencyCUC, "CUC", "Peso Convertible", 2, true},
+	{CurrencyDEM, "DEM", "Deutsche Mark", 2, true},
+	{CurrencyEEK, "EEK", "Kroon", 2, true},
+	{CurrencyESP, "ESP", "Spanish Peseta", 0, true},
+	{CurrencyFIM, "FIM", "Markka", 2, true},
+	{CurrencyFRF, "FRF", "French Franc", 2, true},
+	{CurrencyGRD, "GRD", "Drachma", 0, true},
+	{CurrencyHRK, "HRK", "Kuna", 2, true},
+	{CurrencyIEP, "IEP", "Irish Pound", 2, true},
+	{CurrencyITL, "ITL", "Italian Lira", 0, true},
+	{CurrencyLTL, "LTL", "Lithuanian Litas", 2, true},
+	{CurrencyLVL, "LVL", "Latvian Lats", 2, true},
+	{CurrencyNLG, "NLG", "Netherlands Guilder", 2, true},
+	{CurrencyPTE, "PTE", "Portuguese Escudo", 0, true},
+	{CurrencyROL, "ROL", "Romanian Leu", 2, true},
+	{CurrencySKK, "SKK", "Slovak Koruna", 2, true},
+	{CurrencySLL, "SLL", "Leone", 2, true},
+	{CurrencyZWL, "ZWL", "Zimbabwe Dollar", 2, true},
+}
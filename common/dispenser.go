@@ -0,0 +1,72 @@
+package common
+
+import "fmt"
+
+const (
+	CmdCashDispensed  = "CashDispensed"
+	CmdDispenserStore = "DispenserStore"
+	CmdInitDispenser  = "InitDispenser"
+	CmdDispenseCash   = "DispenseCash"
+	CmdStopDispense   = "StopDispense"
+	CmdCheckDispenser = "CheckDispenser"
+)
+
+// DispenserQuery keeps Amount to pay out in minor units of Currency
+type DispenserQuery struct {
+	Currency DevCurrency `json:"currency"`
+	Amount   DevAmount   `json:"amount"`
+}
+
+func (dev *DispenserQuery) String() string {
+	if dev == nil {
+		return ""
+	}
+	str := fmt.Sprintf("Currency = %s, Amount = %s",
+		dev.Currency, dev.Amount.Format(dev.Currency))
+	return str
+}
+
+// DispenserPayout keeps Nominal, Amount and Unpaid in minor units of Currency
+type DispenserPayout struct {
+	Currency DevCurrency `json:"currency"`
+	Nominal  DevAmount   `json:"nominal"`
+	Count    DevCounter  `json:"count"`
+	Amount   DevAmount   `json:"amount"`
+	Unpaid   DevAmount   `json:"unpaid"`
+}
+
+func (dev *DispenserPayout) String() string {
+	if dev == nil {
+		return ""
+	}
+	str := fmt.Sprintf("Nominal: %7s, Count: %d, Amount: %7s, Unpaid: %7s, Currency: %d (%s)",
+		dev.Nominal.Format(dev.Currency), dev.Count, dev.Amount.Format(dev.Currency),
+		dev.Unpaid.Format(dev.Currency), dev.Currency, dev.Currency.IsoCode())
+	return str
+}
+
+type DispenserStore struct {
+	DeviceReply
+	DispenserPayout
+}
+
+func (dev *DispenserStore) String() string {
+	if dev == nil {
+		return ""
+	}
+	str := fmt.Sprintf("%s, %s",
+		dev.DeviceReply.String(), dev.DispenserPayout.String())
+	return str
+}
+
+type DispenserCallback interface {
+	CashDispensed(name string, reply *DispenserPayout) error
+	DispenserStore(name string, reply *DispenserStore) error
+}
+
+type DispenserManager interface {
+	InitDispenser(name string, query *DispenserQuery) error
+	DispenseCash(name string, query *DispenserQuery) error
+	StopDispense(name string, query *DispenserQuery) error
+	CheckDispenser(name string, query *DispenserQuery) error
+}
@@ -0,0 +1,204 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	errCurrencyMismatch = errors.New("currency mismatch")
+	errBadMoneyFormat   = errors.New("bad money format")
+	errMoneyOverflow    = errors.New("money value overflow")
+)
+
+// Format returns the decimal text of the amount for the currency exponent
+func (a DevAmount) Format(curr DevCurrency) string {
+	exp := curr.Exponent()
+	sign := ""
+	val := int64(a)
+	if val < 0 {
+		sign = "-"
+		val = -val
+	}
+	if exp == 0 {
+		return fmt.Sprintf("%s%d", sign, val)
+	}
+	scale := int64(curr.Scale())
+	return fmt.Sprintf("%s%d.%0*d", sign, val/scale, exp, val%scale)
+}
+
+// ParseAmount converts decimal text like "100.50" to minor units of the currency.
+// Fractional digits beyond the currency exponent are rejected, so no rounding happens.
+func ParseAmount(text string, curr DevCurrency) (DevAmount, error) {
+	str := strings.TrimSpace(text)
+	neg := false
+	if strings.HasPrefix(str, "-") {
+		neg = true
+		str = str[1:]
+	} else if strings.HasPrefix(str, "+") {
+		str = str[1:]
+	}
+	if str == "" {
+		return 0, errBadMoneyFormat
+	}
+	major, minor := str, ""
+	if pos := strings.IndexAny(str, ".,"); pos >= 0 {
+		major, minor = str[:pos], str[pos+1:]
+	}
+	exp := curr.Exponent()
+	minor = strings.TrimRight(minor, "0")
+	if len(minor) > exp {
+		return 0, errBadMoneyFormat
+	}
+	minor += strings.Repeat("0", exp-len(minor))
+	if major == "" {
+		major = "0"
+	}
+	var val int64
+	for _, ch := range major + minor {
+		if ch < '0' || ch > '9' {
+			return 0, errBadMoneyFormat
+		}
+		digit := int64(ch - '0')
+		if val > (1<<63-1-digit)/10 {
+			return 0, errMoneyOverflow
+		}
+		val = val*10 + digit
+	}
+	if neg {
+		val = -val
+	}
+	return DevAmount(val), nil
+}
+
+// DevMoney is a fixed-point money value in minor units that carries its currency
+type DevMoney struct {
+	Amount   DevAmount   `json:"amount" yaml:"amount"`
+	Currency DevCurrency `json:"currency" yaml:"currency"`
+}
+
+func NewDevMoney(amount DevAmount, curr DevCurrency) DevMoney {
+	return DevMoney{Amount: amount, Currency: curr}
+}
+
+// ParseMoney converts decimal text like "100.50" to money of the currency
+func ParseMoney(text string, curr DevCurrency) (DevMoney, error) {
+	amount, err := ParseAmount(text, curr)
+	return NewDevMoney(amount, curr), err
+}
+
+// IsZero checks that money value is zero
+func (m DevMoney) IsZero() bool {
+	return m.Amount == 0
+}
+
+// Add returns the sum of money values of the same currency
+func (m DevMoney) Add(v DevMoney) (DevMoney, error) {
+	if m.Currency != v.Currency {
+		return m, errCurrencyMismatch
+	}
+	return NewDevMoney(m.Amount+v.Amount, m.Currency), nil
+}
+
+// Sub returns the difference of money values of the same currency
+func (m DevMoney) Sub(v DevMoney) (DevMoney, error) {
+	if m.Currency != v.Currency {
+		return m, errCurrencyMismatch
+	}
+	return NewDevMoney(m.Amount-v.Amount, m.Currency), nil
+}
+
+// Mul returns the money value multiplied by counter
+func (m DevMoney) Mul(count DevCounter) DevMoney {
+	return NewDevMoney(m.Amount*DevAmount(count), m.Currency)
+}
+
+// Cmp compares money values of the same currency and returns -1, 0 or +1
+func (m DevMoney) Cmp(v DevMoney) (int, error) {
+	if m.Currency != v.Currency {
+		return 0, errCurrencyMismatch
+	}
+	switch {
+	case m.Amount < v.Amount:
+		return -1, nil
+	case m.Amount > v.Amount:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+// Decimal returns the decimal text of money value without currency code
+func (m DevMoney) Decimal() string {
+	return m.Amount.Format(m.Currency)
+}
+
+// String returns the decimal text of money value with currency code
+func (m DevMoney) String() string {
+	return m.Decimal() + " " + m.Currency.IsoCode()
+}
+
+// Number format conventions of the locale
+type moneyLocale struct {
+	group   string // Thousands separator
+	decimal string // Decimal separator
+	prefix  bool   // Currency code goes before amount
+}
+
+var moneyLocales = map[string]moneyLocale{
+	"en":    {",", ".", true},
+	"en-gb": {",", ".", true},
+	"uk":    {" ", ",", false},
+	"ru":    {" ", ",", false},
+	"be":    {" ", ",", false},
+	"kk":    {" ", ",", false},
+	"pl":    {" ", ",", false},
+	"cs":    {" ", ",", false},
+	"fr":    {" ", ",", false},
+	"de":    {".", ",", false},
+	"de-ch": {"'", ".", true},
+	"it":    {".", ",", false},
+	"es":    {".", ",", false},
+	"nl":    {".", ",", true},
+	"ja":    {",", ".", true},
+	"zh":    {",", ".", true},
+}
+
+func getMoneyLocale(locale string) moneyLocale {
+	name := strings.ToLower(strings.Replace(locale, "_", "-", -1))
+	if loc, ok := moneyLocales[name]; ok {
+		return loc
+	}
+	if pos := strings.Index(name, "-"); pos > 0 {
+		if loc, ok := moneyLocales[name[:pos]]; ok {
+			return loc
+		}
+	}
+	return moneyLocales["en"]
+}
+
+// FormatLocale returns the money text with digit grouping and currency code for the locale like "uk-UA"
+func (m DevMoney) FormatLocale(locale string) string {
+	loc := getMoneyLocale(locale)
+	text := m.Decimal()
+	sign := ""
+	if strings.HasPrefix(text, "-") {
+		sign, text = "-", text[1:]
+	}
+	major, minor := text, ""
+	if pos := strings.Index(text, "."); pos >= 0 {
+		major, minor = text[:pos], text[pos+1:]
+	}
+	for i := len(major) - 3; i > 0; i -= 3 {
+		major = major[:i] + loc.group + major[i:]
+	}
+	text = sign + major
+	if minor != "" {
+		text += loc.decimal + minor
+	}
+	if loc.prefix {
+		return m.Currency.IsoCode() + " " + text
+	}
+	return text + " " + m.Currency.IsoCode()
+}
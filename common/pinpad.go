@@ -3,12 +3,16 @@ package common
 import "fmt"
 
 const (
-	CmdPinPadReply   = "PinPadReply"
-	CmdReadPIN       = "ReadPIN"
-	CmdLoadMasterKey = "LoadMasterKey"
-	CmdLoadWorkKey   = "LoadWorkKey"
-	CmdTestMasterKey = "TestMasterKey"
-	CmdTestWorkKey   = "TestWorkKey"
+	CmdPinPadReply    = "PinPadReply"
+	CmdReadPIN        = "ReadPIN"
+	CmdLoadMasterKey  = "LoadMasterKey"
+	CmdLoadWorkKey    = "LoadWorkKey"
+	CmdTestMasterKey  = "TestMasterKey"
+	CmdTestWorkKey    = "TestWorkKey"
+	CmdLoadDukptKey   = "LoadDukptKey"
+	CmdGenerateMAC    = "GenerateMAC"
+	CmdReadData       = "ReadData"
+	CmdPinPadKeyPress = "PinPadKeyPress"
 )
 
 type EnumPinKeyType uint16
@@ -32,31 +36,101 @@ func (e EnumPinKeyType) String() string {
 	}
 }
 
+// Keys of PIN pad keyboard reported by key press event, timeout is reported as a key
+type EnumPadKey uint16
+
+const (
+	PadKeyDigit EnumPadKey = iota
+	PadKeyEnter
+	PadKeyClear
+	PadKeyCancel
+	PadKeyTimeout
+)
+
+func (e EnumPadKey) String() string {
+	switch e {
+	case PadKeyDigit:
+		return "Digit"
+	case PadKeyEnter:
+		return "Enter"
+	case PadKeyClear:
+		return "Clear"
+	case PadKeyCancel:
+		return "Cancel"
+	case PadKeyTimeout:
+		return "Timeout"
+	default:
+		return "Unknown"
+	}
+}
+
 type ReaderPinQuery struct {
 	//	UseMode  int16
-	KeyType  EnumPinKeyType `json:"key_type"`
-	KeyIndex uint16         `json:"key_index"`
-	KeyValue []byte         `json:"key_value"`
-	CardPan  string         `json:"card_pan"`
+	KeyType   EnumPinKeyType `json:"key_type"`
+	KeyIndex  uint16         `json:"key_index"`
+	KeyValue  []byte         `json:"key_value" redact:"drop"`
+	CardPan   string         `json:"card_pan" redact:"mask"`
+	KeySerial []byte         `json:"key_serial"` // Initial KSN of DUKPT key
+	MacData   []byte         `json:"mac_data" redact:"drop"`
 }
 
 type ReaderPinReply struct {
 	DeviceReply
 	PinLength uint16 `json:"pin_lenght"`
-	PinBlock  []byte `json:"pin_block"`
+	PinBlock  []byte `json:"pin_block" redact:"drop"`
+	KeyCheck  []byte `json:"key_check"`
+	KeySerial []byte `json:"key_serial"` // KSN of DUKPT transaction
+	MacBlock  []byte `json:"mac_block"`
+	EntryData string `json:"entry_data" redact:"drop"` // Clear data of ReadData command
 }
 
 func (dev *ReaderPinReply) String() string {
 	if dev == nil {
 		return ""
 	}
-	str := fmt.Sprintf("%s, PinLength = %d",
-		dev.DeviceReply.String(), dev.PinLength)
+	str := fmt.Sprintf("%s, PinLength = %d, KeyCheck = %X, KeySerial = %X",
+		dev.DeviceReply.String(), dev.PinLength, dev.KeyCheck, dev.KeySerial)
+	return str
+}
+
+// ReaderDataQuery starts clear data entry, allowed keys are digits of the set or all digits if it is empty
+type ReaderDataQuery struct {
+	MinLength uint16 `json:"min_length"`
+	MaxLength uint16 `json:"max_length"`
+	AllowKeys string `json:"allow_keys"`
+	Timeout   int32  `json:"timeout"` // Seconds, config value is used if zero
+}
+
+func (dev *ReaderDataQuery) String() string {
+	if dev == nil {
+		return ""
+	}
+	str := fmt.Sprintf("MinLength = %d, MaxLength = %d, AllowKeys = %s, Timeout = %d",
+		dev.MinLength, dev.MaxLength, dev.AllowKeys, dev.Timeout)
+	return str
+}
+
+// ReaderKeyPress is key press event of PIN or data entry command.
+// Display is asterisks for PIN entry and entered digits for data entry.
+type ReaderKeyPress struct {
+	Command string     `json:"command"`
+	Key     EnumPadKey `json:"key"`
+	Length  uint16     `json:"length"`
+	Display string     `json:"display"`
+}
+
+func (dev *ReaderKeyPress) String() string {
+	if dev == nil {
+		return ""
+	}
+	str := fmt.Sprintf("Command = %s, Key = %s, Length = %d, Display = %s",
+		dev.Command, dev.Key, dev.Length, dev.Display)
 	return str
 }
 
 type PinPadCallback interface {
 	PinPadReply(name string, reply *ReaderPinReply) error
+	PinPadKeyPress(name string, value *ReaderKeyPress) error
 }
 
 type PinPadManager interface {
@@ -65,4 +139,7 @@ type PinPadManager interface {
 	LoadWorkKey(name string, query *ReaderPinQuery) error
 	TestMasterKey(name string, query *ReaderPinQuery) error
 	TestWorkKey(name string, query *ReaderPinQuery) error
+	LoadDukptKey(name string, query *ReaderPinQuery) error
+	GenerateMAC(name string, query *ReaderPinQuery) error
+	ReadData(name string, query *ReaderDataQuery) error
 }
@@ -1,9 +1,12 @@
 package common
 
+import "fmt"
+
 const (
 	CmdInitPrinter     = "InitPrinter"
 	CmdPrintText       = "PrintText"
 	CmdPrinterProgress = "PrinterProgress"
+	CmdPrintDocument   = "PrintDocument"
 )
 
 type PrinterQuery struct {
@@ -22,6 +25,142 @@ type PrinterProgress struct {
 	PagesAll int32  `json:"pages_all"`
 }
 
+func (dev *PrinterProgress) String() string {
+	if dev == nil {
+		return ""
+	}
+	str := fmt.Sprintf("Document %s, Page %d of %d",
+		dev.DocName, dev.PageDone, dev.PagesAll)
+	return str
+}
+
+type EnumDocItem int16
+
+const (
+	DocItemText      EnumDocItem = iota // Text line
+	DocItemColumns                      // Columns spread over the line, the last one is right aligned
+	DocItemSeparator                    // Line of repeated character
+	DocItemImage                        // Image file, printer logo for empty file name
+	DocItemBarcode                      // Barcode with human readable text
+	DocItemQRCode                       // QR code
+	DocItemFeed                         // Empty lines
+	DocItemPage                         // Page break with paper cut
+)
+
+func (e EnumDocItem) String() string {
+	switch e {
+	case DocItemText:
+		return "Text"
+	case DocItemColumns:
+		return "Columns"
+	case DocItemSeparator:
+		return "Separator"
+	case DocItemImage:
+		return "Image"
+	case DocItemBarcode:
+		return "Barcode"
+	case DocItemQRCode:
+		return "QRCode"
+	case DocItemFeed:
+		return "Feed"
+	case DocItemPage:
+		return "Page"
+	default:
+		return "Unknown"
+	}
+}
+
+type EnumDocAlign int16
+
+const (
+	DocAlignLeft EnumDocAlign = iota
+	DocAlignCenter
+	DocAlignRight
+)
+
+func (e EnumDocAlign) String() string {
+	switch e {
+	case DocAlignLeft:
+		return "Left"
+	case DocAlignCenter:
+		return "Center"
+	case DocAlignRight:
+		return "Right"
+	default:
+		return "Unknown"
+	}
+}
+
+type EnumDocBarcode int16
+
+const (
+	DocBarcodeCODE128 EnumDocBarcode = iota
+	DocBarcodeEAN13
+	DocBarcodeCODE39
+)
+
+func (e EnumDocBarcode) String() string {
+	switch e {
+	case DocBarcodeCODE128:
+		return "CODE128"
+	case DocBarcodeEAN13:
+		return "EAN13"
+	case DocBarcodeCODE39:
+		return "CODE39"
+	default:
+		return "Unknown"
+	}
+}
+
+// DocumentItem is one element of the printed document, zero width and height are for printer defaults
+type DocumentItem struct {
+	Kind      EnumDocItem    `json:"kind"`
+	Text      string         `json:"text,omitempty"`    // Text, separator character, image file or code data
+	Columns   []string       `json:"columns,omitempty"` // Texts of columns item
+	Align     EnumDocAlign   `json:"align"`
+	Bold      bool           `json:"bold,omitempty"`
+	Underline bool           `json:"underline,omitempty"`
+	Width     int32          `json:"width,omitempty"`  // Character width multiplier, QR module size or feed lines
+	Height    int32          `json:"height,omitempty"` // Character height multiplier or barcode height in dots
+	Barcode   EnumDocBarcode `json:"barcode"`
+}
+
+// PrinterDocument is the structured document rendered by the printer driver
+type PrinterDocument struct {
+	DocName string          `json:"doc_name"`
+	Items   []*DocumentItem `json:"items"`
+}
+
+func (dev *PrinterDocument) String() string {
+	if dev == nil {
+		return ""
+	}
+	str := fmt.Sprintf("Document %s, Items %d, Pages %d",
+		dev.DocName, len(dev.Items), len(dev.GetPages()))
+	return str
+}
+
+// GetPages splits document items by page breaks, empty trailing page is dropped
+func (dev *PrinterDocument) GetPages() [][]*DocumentItem {
+	pages := make([][]*DocumentItem, 0, 1)
+	page := make([]*DocumentItem, 0, len(dev.Items))
+	for _, item := range dev.Items {
+		if item == nil {
+			continue
+		}
+		if item.Kind == DocItemPage {
+			pages = append(pages, page)
+			page = make([]*DocumentItem, 0)
+			continue
+		}
+		page = append(page, item)
+	}
+	if len(page) > 0 || len(pages) == 0 {
+		pages = append(pages, page)
+	}
+	return pages
+}
+
 type PrinterCallback interface {
 	PrinterProgress(name string, reply *PrinterProgress) error
 }
@@ -29,4 +168,5 @@ type PrinterCallback interface {
 type PrinterManager interface {
 	InitPrinter(name string, query *PrinterSetup) error
 	PrintText(name string, query *PrinterQuery) error
+	PrintDocument(name string, query *PrinterDocument) error
 }
@@ -1,5 +1,10 @@
 package common
 
+import (
+	"fmt"
+	"github.com/iftsoft/device/core"
+)
+
 const (
 	CmdCardPosition    = "CardPosition"
 	CmdCardDescription = "CardDescription"
@@ -13,29 +18,47 @@ const (
 	CmdChipCommand     = "ChipCommand"
 )
 
+// Card positions of CardPosition callback
+const (
+	CardPositionNone int16 = iota
+	CardPositionFront
+	CardPositionInside
+	CardPositionCaptured
+)
+
 type ReaderCardPos struct {
 	Position int16 `json:"position"`
 }
 
 type ReaderCardInfo struct {
-	Track1  string `json:"track1"`
-	Track2  string `json:"track2"`
-	Track3  string `json:"track3"`
-	RawData string `json:"raw_data"`
-	CardPan string `json:"card_pan"`
+	Track1  string `json:"track1" redact:"drop"`
+	Track2  string `json:"track2" redact:"drop"`
+	Track3  string `json:"track3" redact:"drop"`
+	RawData string `json:"raw_data" redact:"drop"`
+	CardPan string `json:"card_pan" redact:"mask"`
 	ExpDate string `json:"exp_date"`
 	Holder  string `json:"holder"`
+	SvcCode string `json:"svc_code"`
+}
+
+func (dev *ReaderCardInfo) String() string {
+	if dev == nil {
+		return ""
+	}
+	str := fmt.Sprintf("CardPan = %s, ExpDate = %s, Holder = %s, SvcCode = %s",
+		core.MaskPan(dev.CardPan), dev.ExpDate, dev.Holder, dev.SvcCode)
+	return str
 }
 
 type ReaderChipQuery struct {
 	Protocol int16  `json:"protocol"`
-	Query    []byte `json:"query"`
+	Query    []byte `json:"query" redact:"drop"`
 }
 
 type ReaderChipReply struct {
 	DeviceReply
 	Protocol int16  `json:"protocol"`
-	Reply    []byte `json:"reply"`
+	Reply    []byte `json:"reply" redact:"drop"`
 }
 
 type ReaderCallback interface {
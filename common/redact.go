@@ -0,0 +1,14 @@
+package common
+
+import "github.com/iftsoft/device/core"
+
+// Structures with sensitive fields marked by redact tag
+func init() {
+	core.RegisterRedaction(
+		ReaderCardInfo{},
+		ReaderChipQuery{},
+		ReaderChipReply{},
+		ReaderPinQuery{},
+		ReaderPinReply{},
+	)
+}
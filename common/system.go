@@ -60,8 +60,8 @@ type SystemQuery struct {
 }
 
 type SystemConfig struct {
-	LinkType  uint16 `json:"link_type"`  // 0-none, 1-COM, 2-USB
-	PortName  string `json:"port_name"`  // Serial port name
+	LinkType  uint16 `json:"link_type"`  // 0-none, 1-COM, 2-USB, 3-TCP
+	PortName  string `json:"port_name"`  // Serial port name or host:port
 	VendorID  uint16 `json:"vendor_id"`  // Device Vendor ID
 	ProductID uint16 `json:"product_id"` // Device Product ID
 }
@@ -74,12 +74,12 @@ type SystemReply struct {
 }
 
 type SystemMetrics struct {
-	Uptime   int64              `json:"uptime"`
-	DevError EnumDevError       `json:"dev_error"`
-	DevState EnumDevState       `json:"dev_state"`
-	Counts   map[string]uint32  `json:"counts"`
-	Totals   map[string]float32 `json:"totals"`
-	Topics   map[string]string  `json:"topics"`
+	Uptime   int64               `json:"uptime"`
+	DevError EnumDevError        `json:"dev_error"`
+	DevState EnumDevState        `json:"dev_state"`
+	Counts   map[string]uint32   `json:"counts"`
+	Totals   map[string]DevMoney `json:"totals"`
+	Topics   map[string]string   `json:"topics"`
 }
 
 type SystemHealth struct {
@@ -99,7 +99,7 @@ func NewSystemHealth() *SystemHealth {
 			DevError: 0,
 			DevState: 0,
 			Counts:   make(map[string]uint32),
-			Totals:   make(map[string]float32),
+			Totals:   make(map[string]DevMoney),
 			Topics:   make(map[string]string),
 		},
 	}
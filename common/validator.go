@@ -18,6 +18,7 @@ const (
 
 type ValidNoteList []*ValidatorNote
 
+// ValidatorNote keeps Nominal and Amount in minor units of Currency
 type ValidatorNote struct {
 	Device   string      `json:"device"`
 	Currency DevCurrency `json:"currency"`
@@ -50,10 +51,12 @@ func (e BatchState) String() string {
 	}
 }
 
+// ValidatorBatch keeps in Count the number of notes stacked to the cassette
 type ValidatorBatch struct {
 	Notes   ValidNoteList `json:"notes"`
 	BatchId int64         `json:"batch_id"`
 	State   BatchState    `json:"state"`
+	Count   DevCounter    `json:"count"`
 	Detail  string        `json:"detail"`
 }
 
@@ -61,8 +64,8 @@ func (dev *ValidatorBatch) String() string {
 	if dev == nil {
 		return ""
 	}
-	str := fmt.Sprintf("Batch Id=%d, State=%s, Detail=%s, %s",
-		dev.BatchId, dev.State.String(), dev.Detail, dev.Notes.String())
+	str := fmt.Sprintf("Batch Id=%d, State=%s, Count=%d, Detail=%s, %s",
+		dev.BatchId, dev.State.String(), dev.Count, dev.Detail, dev.Notes.String())
 	return str
 }
 
@@ -70,11 +73,20 @@ func (vn *ValidatorNote) String() string {
 	if vn == nil {
 		return ""
 	}
-	str := fmt.Sprintf("%s Note %7.2f * %3d = %9.2f of %3d (%s) - %s",
-		vn.Device, vn.Nominal, vn.Count, vn.Amount, vn.Currency, vn.Currency.IsoCode(), vn.Currency.String())
+	str := fmt.Sprintf("%s Note %7s * %3d = %9s of %3d (%s) - %s",
+		vn.Device, vn.Nominal.Format(vn.Currency), vn.Count, vn.Amount.Format(vn.Currency),
+		vn.Currency, vn.Currency.IsoCode(), vn.Currency.String())
 	return str
 }
 
+func (vn *ValidatorNote) GetNominal() DevMoney {
+	return NewDevMoney(vn.Nominal, vn.Currency)
+}
+
+func (vn *ValidatorNote) GetAmount() DevMoney {
+	return NewDevMoney(vn.Amount, vn.Currency)
+}
+
 func (vl ValidNoteList) String() string {
 	str := "Validator Note List:"
 	for i, note := range vl {
@@ -99,33 +111,69 @@ func (dev *ValidatorStore) String() string {
 	return str
 }
 
+// Escrow decision made by validator policy
+type EnumNoteDecision int16
+
+const (
+	NoteDecisionHost   EnumNoteDecision = iota // Host must accept or return the note
+	NoteDecisionAccept                         // Note is stacked by policy
+	NoteDecisionReturn                         // Note is returned by policy
+)
+
+func (e EnumNoteDecision) String() string {
+	switch e {
+	case NoteDecisionHost:
+		return "Host"
+	case NoteDecisionAccept:
+		return "Accept"
+	case NoteDecisionReturn:
+		return "Return"
+	default:
+		return "Unknown"
+	}
+}
+
+// ValidatorAccept keeps Nominal and Amount in minor units of Currency
 type ValidatorAccept struct {
-	Currency DevCurrency `json:"currency"`
-	Nominal  DevAmount   `json:"nominal"`
-	Count    DevCounter  `json:"count"`
-	Amount   DevAmount   `json:"amount"`
+	Currency DevCurrency      `json:"currency"`
+	Nominal  DevAmount        `json:"nominal"`
+	Count    DevCounter       `json:"count"`
+	Amount   DevAmount        `json:"amount"`
+	Decision EnumNoteDecision `json:"decision"`
+	Reason   string           `json:"reason"`
 }
 
 func (dev *ValidatorAccept) String() string {
 	if dev == nil {
 		return ""
 	}
-	str := fmt.Sprintf("Nominal: %7.2f, Count: %d, Amount: %7.2f, Currency: %d (%s) %s",
-		dev.Nominal, dev.Count, dev.Amount, dev.Currency, dev.Currency.IsoCode(), dev.Currency.String())
+	str := fmt.Sprintf("Nominal: %7s, Count: %d, Amount: %7s, Currency: %d (%s) %s, Decision: %s (%s)",
+		dev.Nominal.Format(dev.Currency), dev.Count, dev.Amount.Format(dev.Currency),
+		dev.Currency, dev.Currency.IsoCode(), dev.Currency.String(), dev.Decision, dev.Reason)
 	return str
 }
 
+func (dev *ValidatorAccept) GetNominal() DevMoney {
+	return NewDevMoney(dev.Nominal, dev.Currency)
+}
+
+func (dev *ValidatorAccept) GetAmount() DevMoney {
+	return NewDevMoney(dev.Amount, dev.Currency)
+}
+
+// ValidatorQuery keeps session Target in minor units of Currency, zero target is not limited
 type ValidatorQuery struct {
 	Currency  DevCurrency `json:"currency"`
 	Operation int64       `json:"operation"`
+	Target    DevAmount   `json:"target"`
 }
 
 func (dev *ValidatorQuery) String() string {
 	if dev == nil {
 		return ""
 	}
-	str := fmt.Sprintf("Currency = %s, Operation = %d",
-		dev.Currency, dev.Operation)
+	str := fmt.Sprintf("Currency = %s, Operation = %d, Target = %s",
+		dev.Currency, dev.Operation, dev.Target.Format(dev.Currency))
 	return str
 }
 
@@ -3,6 +3,9 @@ package config
 import (
 	"fmt"
 	"github.com/iftsoft/device/common"
+	"net"
+	"strconv"
+	"strings"
 )
 
 type CommonConfig struct {
@@ -33,13 +36,24 @@ type PrinterConfig struct {
 	PaperPath   EnumPaperPath	`yaml:"paper_path"`
 	ShowImage   EnumShowImage	`yaml:"show_image"`
 	ImageFile   string			`yaml:"image_file"`
-	EjectLength int32			`yaml:"eject_length"`
+	ImageDir    string			`yaml:"image_dir"`		// Directory of document images, logo only is printed without it
+	EjectLength int32			`yaml:"eject_length"`	// Paper feed before cut in lines
+	CodePage    string			`yaml:"code_page"`		// Code page of the text: cp437, cp866, cp1125 (default), cp1251
+	PaperWidth  int32			`yaml:"paper_width"`	// Printable width in dots
+	Resolution  int32			`yaml:"resolution"`		// Print resolution in DPI
+	OutputDir   string			`yaml:"output_dir"`		// Directory of virtual printer files
+	OutputType  string			`yaml:"output_type"`	// File type of virtual printer: png, pdf
+	PaperLimit  int32			`yaml:"paper_limit"`	// Receipt count before virtual paper out, zero is for no limit
 }
 func (cfg *PrinterConfig) String() string {
 	if cfg == nil { return "" }
 	str := fmt.Sprintf("\n\tPrinter config: " +
-		"PrintName = %s, Landscape = %t, PaperPath = %s, ShowImage = %s, ImageFile = %s.",
-		cfg.PrintName, cfg.Landscape, cfg.PaperPath, cfg.ShowImage, cfg.ImageFile)
+		"PrintName = %s, Landscape = %t, PaperPath = %s, ShowImage = %s, ImageFile = %s, ImageDir = %s, " +
+		"EjectLength = %d, CodePage = %s, PaperWidth = %d, Resolution = %d, " +
+		"OutputDir = %s, OutputType = %s, PaperLimit = %d.",
+		cfg.PrintName, cfg.Landscape, cfg.PaperPath, cfg.ShowImage, cfg.ImageFile, cfg.ImageDir,
+		cfg.EjectLength, cfg.CodePage, cfg.PaperWidth, cfg.Resolution,
+		cfg.OutputDir, cfg.OutputType, cfg.PaperLimit)
 	return str
 }
 func GetDefaultPrinterConfig() *PrinterConfig {
@@ -52,12 +66,14 @@ func GetDefaultPrinterConfig() *PrinterConfig {
 type ReaderConfig struct {
 	SkipPrefix	EnumSkipPrefix	`yaml:"skip_prefix"`
 	CardAccept	EnumCardAccept	`yaml:"card_accept"`
+	CardDeck	string			`yaml:"card_deck"`		// YAML file of simulated cards
+	WaitTimeout	int32			`yaml:"wait_timeout"`	// Seconds to wait for card insert or take
 }
 func (cfg *ReaderConfig) String() string {
 	if cfg == nil { return "" }
 	str := fmt.Sprintf("\n\tReader config: " +
-		"SkipPrefix = %s, CardAccept = %s.",
-		cfg.SkipPrefix, cfg.CardAccept)
+		"SkipPrefix = %s, CardAccept = %s, CardDeck = %s, WaitTimeout = %d.",
+		cfg.SkipPrefix, cfg.CardAccept, cfg.CardDeck, cfg.WaitTimeout)
 	return str
 }
 func GetDefaultReaderConfig() *ReaderConfig {
@@ -68,14 +84,17 @@ func GetDefaultReaderConfig() *ReaderConfig {
 
 
 type PinPadConfig struct {
-	NeedEnter bool   `yaml:"need_enter"`
-	PinDigits uint16 `yaml:"pin_digits"`
+	NeedEnter		bool		`yaml:"need_enter"`
+	PinDigits		uint16		`yaml:"pin_digits"`
+	PinFormat		uint16		`yaml:"pin_format"`		// ISO 9564 PIN block format: 0, 1, 3 or 4 (AES keys)
+	KeyScript		[]string	`yaml:"key_script"`		// Key presses of simulated customer, one entry per line
+	EntryTimeout	int32		`yaml:"entry_timeout"`	// Seconds to wait for PIN or data entry
 }
 func (cfg *PinPadConfig) String() string {
 	if cfg == nil { return "" }
 	str := fmt.Sprintf("\n\tPIN pad config: " +
-		"NeedEnter = %t, PinDigits = %d.",
-		cfg.NeedEnter, cfg.PinDigits)
+		"NeedEnter = %t, PinDigits = %d, PinFormat = %d, KeyScript = %d entries, EntryTimeout = %d.",
+		cfg.NeedEnter, cfg.PinDigits, cfg.PinFormat, len(cfg.KeyScript), cfg.EntryTimeout)
 	return str
 }
 func GetDefaultPinPadConfig() *PinPadConfig {
@@ -85,19 +104,69 @@ func GetDefaultPinPadConfig() *PinPadConfig {
 }
 
 
+// NoteTableConfig lists note denominations of the currency in major units ("1", "0.50")
+type NoteTableConfig struct {
+	Currency	common.DevCurrency `yaml:"currency"`
+	Nominals	[]string           `yaml:"nominals"`
+}
+func (cfg *NoteTableConfig) String() string {
+	if cfg == nil { return "" }
+	str := fmt.Sprintf("\n\t\tNote table: " +
+		"Currency = %s, Nominals = %s.",
+		cfg.Currency.IsoCode(), strings.Join(cfg.Nominals, ", "))
+	return str
+}
+
+// GetNoteList converts the table to validator note list in minor units
+func (cfg *NoteTableConfig) GetNoteList() (common.ValidNoteList, error) {
+	if common.GetCurrencyInfo(cfg.Currency) == nil {
+		return nil, fmt.Errorf("unknown note table currency %d", cfg.Currency)
+	}
+	if len(cfg.Nominals) == 0 || len(cfg.Nominals) > 64 {
+		return nil, fmt.Errorf("note table %s has %d nominals", cfg.Currency.IsoCode(), len(cfg.Nominals))
+	}
+	list := make(common.ValidNoteList, 0, len(cfg.Nominals))
+	for _, text := range cfg.Nominals {
+		nominal, err := common.ParseAmount(text, cfg.Currency)
+		if err != nil || nominal <= 0 {
+			return nil, fmt.Errorf("note table %s has bad nominal %s", cfg.Currency.IsoCode(), text)
+		}
+		for _, note := range list {
+			if note.Nominal == nominal {
+				return nil, fmt.Errorf("note table %s has duplicate nominal %s", cfg.Currency.IsoCode(), text)
+			}
+		}
+		list = append(list, &common.ValidatorNote{Currency: cfg.Currency, Nominal: nominal})
+	}
+	return list, nil
+}
+
 type ValidatorConfig struct {
 	NotesMask	int64              `yaml:"notes_mask"`
 	NoteAlert	int32              `yaml:"note_alert"`
 	NoteLimit	int32              `yaml:"note_limit"`
 	ActDefault	EnumBillAction	   `yaml:"act_default"`
-	StoreWait	int32              `yaml:"store_wait"`
+	StoreWait	int32              `yaml:"store_wait"`	// Seconds to wait host decision, zero waits forever
 	CurrCode	common.DevCurrency `yaml:"curr_code"`
+	MaxAmount	string             `yaml:"max_amount"`
+	NoteTables	[]*NoteTableConfig `yaml:"note_tables"`
+	CountryCodes	map[uint8]common.DevCurrency `yaml:"country_codes"`	// ID-003 country code to currency, unknown one is CurrCode
+	ScaleFactor	uint16             `yaml:"scale_factor"`	// MDB scaling factor, zero is for device setup
+	DecimalPlaces	uint8          `yaml:"decimal_places"`	// MDB decimal places, used with scaling factor only
 }
 func (cfg *ValidatorConfig) String() string {
 	if cfg == nil { return "" }
 	str := fmt.Sprintf("\n\tValidator config: " +
-		"NotesMask = %d, NoteAlert = %d, NoteLimit = %d, ActDefault = %s, StoreWait = %d, CurrCode = %d.",
-		cfg.NotesMask, cfg.NoteAlert, cfg.NoteLimit, cfg.ActDefault, cfg.StoreWait, cfg.CurrCode)
+		"NotesMask = %d, NoteAlert = %d, NoteLimit = %d, ActDefault = %s, StoreWait = %d, CurrCode = %d, MaxAmount = %s, " +
+		"ScaleFactor = %d, DecimalPlaces = %d.",
+		cfg.NotesMask, cfg.NoteAlert, cfg.NoteLimit, cfg.ActDefault, cfg.StoreWait, cfg.CurrCode, cfg.MaxAmount,
+		cfg.ScaleFactor, cfg.DecimalPlaces)
+	if len(cfg.CountryCodes) > 0 {
+		str += fmt.Sprintf(" CountryCodes = %v.", cfg.CountryCodes)
+	}
+	for _, table := range cfg.NoteTables {
+		str += table.String()
+	}
 	return str
 }
 func GetDefaultValidatorConfig() *ValidatorConfig {
@@ -107,16 +176,19 @@ func GetDefaultValidatorConfig() *ValidatorConfig {
 }
 
 
+// DispenserConfig keeps Nominal of the dispensed item in major units ("1", "0.50")
 type DispenserConfig struct {
-	OutputDir EnumOutputDir `yaml:"output_dir"`
-	UseDivert EnumUnitUsage `yaml:"use_divert"`
-	UseEscrow EnumUnitUsage `yaml:"use_escrow"`
+	OutputDir EnumOutputDir      `yaml:"output_dir"`
+	UseDivert EnumUnitUsage      `yaml:"use_divert"`
+	UseEscrow EnumUnitUsage      `yaml:"use_escrow"`
+	CurrCode  common.DevCurrency `yaml:"curr_code"`
+	Nominal   string             `yaml:"nominal"`
 }
 func (cfg *DispenserConfig) String() string {
 	if cfg == nil { return "" }
 	str := fmt.Sprintf("\n\tDispenser config: " +
-		"OutputDir = %s, UseDivert = %s, UseEscrow = %s.",
-		cfg.OutputDir.String(), cfg.UseDivert, cfg.UseEscrow)
+		"OutputDir = %s, UseDivert = %s, UseEscrow = %s, CurrCode = %d, Nominal = %s.",
+		cfg.OutputDir.String(), cfg.UseDivert, cfg.UseEscrow, cfg.CurrCode, cfg.Nominal)
 	return str
 }
 func GetDefaultDispenserConfig() *DispenserConfig {
@@ -183,6 +255,17 @@ func (cfg *DeviceConfig) OverwriteConfig(data *common.SystemConfig) {
 			cfg.Linker.HidUsb.ProductID = data.ProductID
 		}
 	}
+	if cfg.Linker.LinkType == LinkTypeTcp && data.PortName != "" {
+		if cfg.Linker.Tcp == nil {
+			cfg.Linker.Tcp = &TcpConfig{}
+		}
+		// Port name is host:port of serial device server
+		host, port, err := net.SplitHostPort(data.PortName)
+		if num, e := strconv.ParseUint(port, 10, 16); err == nil && e == nil {
+			cfg.Linker.Tcp.Host = host
+			cfg.Linker.Tcp.Port = uint16(num)
+		}
+	}
 }
 
 
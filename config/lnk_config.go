@@ -11,6 +11,7 @@ const (
 	LinkTypeNone EnumLinkType = iota
 	LinkTypeSerial
 	LinkTypeHidUsb
+	LinkTypeTcp
 )
 
 // SysStop bits types
@@ -34,6 +35,7 @@ func (e EnumLinkType) String() string {
 	case LinkTypeNone:			return "Off-line"
 	case LinkTypeSerial:		return "Serial"
 	case LinkTypeHidUsb:		return "HID/USB"
+	case LinkTypeTcp:			return "TCP/IP"
 	default:					return "Undefined"
 	}
 }
@@ -89,18 +91,64 @@ func (cfg *HidUsbConfig) String() string {
 	return str
 }
 
+type TcpConfig struct {
+	Host      string `yaml:"host"`
+	Port      uint16 `yaml:"port"`
+	Telnet    bool   `yaml:"telnet"`    // RFC 2217 control of remote serial port
+	Reconnect uint16 `yaml:"reconnect"` // Delay (ms) between reconnect attempts
+}
+
+func (cfg *TcpConfig) String() string {
+	if cfg == nil { return "" }
+	str := fmt.Sprintf("\n\tTCP config: " +
+		"Host = %s, Port = %d, Telnet = %t, Reconnect = %d.",
+		cfg.Host, cfg.Port, cfg.Telnet, cfg.Reconnect)
+	return str
+}
+
+// ChaosConfig sets faults that are injected into received data for resilience testing
+type ChaosConfig struct {
+	DropRate    float64 `yaml:"drop_rate"`    // Probability to lose a byte
+	DupRate     float64 `yaml:"dup_rate"`     // Probability to repeat a byte
+	CorruptRate float64 `yaml:"corrupt_rate"` // Probability to flip bits of a byte
+	SplitRate   float64 `yaml:"split_rate"`   // Probability to split a chunk in two reads
+	Latency     uint16  `yaml:"latency"`      // Max delay (ms) of a chunk
+	SilenceRate float64 `yaml:"silence_rate"` // Probability to start silence period on a chunk
+	Silence     uint16  `yaml:"silence"`      // Duration (ms) of silence when all data are lost
+	OpenFail    float64 `yaml:"open_fail"`    // Probability that Open fails as if port disappeared
+	Seed        int64   `yaml:"seed"`         // Random seed, time based if zero
+}
+
+func (cfg *ChaosConfig) String() string {
+	if cfg == nil { return "" }
+	str := fmt.Sprintf("\n\tChaos config: " +
+		"DropRate = %g, DupRate = %g, CorruptRate = %g, SplitRate = %g, Latency = %d, " +
+		"SilenceRate = %g, Silence = %d, OpenFail = %g, Seed = %d.",
+		cfg.DropRate, cfg.DupRate, cfg.CorruptRate, cfg.SplitRate, cfg.Latency,
+		cfg.SilenceRate, cfg.Silence, cfg.OpenFail, cfg.Seed)
+	return str
+}
+
 type LinkerConfig struct {
 	LinkType EnumLinkType  `yaml:"link_type"`
 	Timeout  uint16        `yaml:"timeout"`
+	Address  uint8         `yaml:"address"`  	// Device address on multi-drop bus
+	Checksum string        `yaml:"checksum"` 	// Checksum name of the protocol
+	CryptKey string        `yaml:"crypt_key"`	// Fixed encryption key (hex) of the protocol
 	Serial   *SerialConfig `yaml:"serial"`
 	HidUsb   *HidUsbConfig `yaml:"hid_usb"`
+	Tcp      *TcpConfig    `yaml:"tcp"`
+	Record   string        `yaml:"record"`	// Trace file to record port traffic, refused for card reader and PIN pad
+	Replay   string        `yaml:"replay"`	// Trace file to replay instead of the port
+	Chaos    *ChaosConfig  `yaml:"chaos"` 	// Fault injection, disabled if not set
 }
 
 func (cfg *LinkerConfig) String() string {
 	if cfg == nil { return "" }
 	str := fmt.Sprintf("\n\tLinker config: " +
-		"LinkType = %s, Timeout = %d, %s %s",
-		cfg.LinkType, cfg.Timeout, cfg.Serial, cfg.HidUsb)
+		"LinkType = %s, Timeout = %d, Address = %d, Checksum = %s, CryptKey = %t, Record = %s, Replay = %s, %s %s %s %s",
+		cfg.LinkType, cfg.Timeout, cfg.Address, cfg.Checksum, cfg.CryptKey != "", cfg.Record, cfg.Replay,
+		cfg.Serial, cfg.HidUsb, cfg.Tcp, cfg.Chaos)
 	return str
 }
 
@@ -116,6 +164,12 @@ func GetDefaultLinkerConfig() *LinkerConfig {
 			Parity:   NoParity,
 		},
 		HidUsb: &HidUsbConfig{},
+		Tcp: &TcpConfig{
+			Host:      "",
+			Port:      0,
+			Telnet:    false,
+			Reconnect: 1000,
+		},
 	}
 	return lnkCfg
 }
@@ -132,6 +132,7 @@ func (log *LogAgent) Panic(format string, args ...interface{}) {
 }
 
 func (log *LogAgent) formatLine(level EnumLogLevel, text string) {
+	text = ScrubText(text)
 	t := time.Now()
 	moment := t.Format("2006-01-02 15:04:05.999999")
 	for size := len(moment); size < 26; size++ {
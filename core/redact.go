@@ -0,0 +1,184 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Redaction modes of struct fields marked by redact tag
+const (
+	RedactMask = "mask" // Keep first six and last four digits of PAN
+	RedactDrop = "drop" // Replace whole value
+)
+
+const redactedValue = "[redacted]"
+
+// Redaction rules of registered structure, json object is taken as the structure
+// when all its keys are json names of the structure fields
+type redactRule struct {
+	fields map[string]bool
+	modes  map[string]string
+}
+
+var (
+	redactMutex sync.RWMutex
+	redactRules []*redactRule
+)
+
+// RegisterRedaction collects json names of fields marked by redact tag for every given structure
+func RegisterRedaction(list ...interface{}) {
+	redactMutex.Lock()
+	defer redactMutex.Unlock()
+	for _, item := range list {
+		rule := &redactRule{fields: map[string]bool{}, modes: map[string]string{}}
+		rule.addFields(reflect.TypeOf(item))
+		if len(rule.modes) > 0 {
+			redactRules = append(redactRules, rule)
+		}
+	}
+}
+
+// Fields of embedded structure are put to json object of the outer one
+func (rr *redactRule) addFields(t reflect.Type) {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			rr.addFields(field.Type)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		rr.fields[name] = true
+		if mode := field.Tag.Get("redact"); mode == RedactMask || mode == RedactDrop {
+			rr.modes[name] = mode
+		}
+	}
+}
+
+func (rr *redactRule) isMatched(obj map[string]interface{}) bool {
+	if len(obj) == 0 {
+		return false
+	}
+	for key := range obj {
+		if !rr.fields[key] {
+			return false
+		}
+	}
+	return true
+}
+
+// Rules of structures that json object may stand for, drop wins if a field is marked differently
+func findRedactModes(obj map[string]interface{}) map[string]string {
+	var modes map[string]string
+	for _, rule := range redactRules {
+		if !rule.isMatched(obj) {
+			continue
+		}
+		if modes == nil {
+			modes = map[string]string{}
+		}
+		for name, mode := range rule.modes {
+			if modes[name] != RedactDrop {
+				modes[name] = mode
+			}
+		}
+	}
+	return modes
+}
+
+// MaskPan keeps first six and last four digits of PAN, short PAN is replaced as a whole
+func MaskPan(pan string) string {
+	if pan == "" {
+		return pan
+	}
+	if len(pan) <= 10 {
+		return redactedValue
+	}
+	return pan[:6] + strings.Repeat("*", len(pan)-10) + pan[len(pan)-4:]
+}
+
+// ScrubText masks every digit sequence of text that looks like valid card number
+func ScrubText(text string) string {
+	var out strings.Builder
+	start := -1
+	for i := 0; i <= len(text); i++ {
+		if i < len(text) && text[i] >= '0' && text[i] <= '9' {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			digits := text[start:i]
+			if len(digits) >= 13 && len(digits) <= 19 && CheckCardPan(digits) {
+				digits = MaskPan(digits)
+			}
+			out.WriteString(digits)
+			start = -1
+		}
+		if i < len(text) {
+			out.WriteByte(text[i])
+		}
+	}
+	return out.String()
+}
+
+// RedactJson returns json dump with sensitive fields masked or dropped
+func RedactJson(dump []byte) string {
+	var data interface{}
+	decoder := json.NewDecoder(bytes.NewReader(dump))
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil {
+		return ScrubText(string(dump))
+	}
+	redactMutex.RLock()
+	data = redactValue(data)
+	redactMutex.RUnlock()
+	out, err := json.Marshal(data)
+	if err != nil {
+		return redactedValue
+	}
+	return ScrubText(string(out))
+}
+
+func redactValue(data interface{}) interface{} {
+	switch val := data.(type) {
+	case map[string]interface{}:
+		modes := findRedactModes(val)
+		for key, item := range val {
+			switch modes[key] {
+			case RedactDrop:
+				if item != nil && item != "" {
+					val[key] = redactedValue
+				}
+			case RedactMask:
+				if str, ok := item.(string); ok {
+					val[key] = MaskPan(str)
+				} else if item != nil {
+					val[key] = redactedValue
+				}
+			default:
+				val[key] = redactValue(item)
+			}
+		}
+	case []interface{}:
+		for i, item := range val {
+			val[i] = redactValue(item)
+		}
+	}
+	return data
+}
@@ -0,0 +1,158 @@
+package core_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/core"
+	"github.com/iftsoft/device/duplex"
+	"github.com/iftsoft/device/handler"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	testPan    = "4111111111111111"
+	testMasked = "411111******1111"
+	testTrack2 = "4111111111111111=25121010000012300000"
+)
+
+var (
+	testPinBlock = []byte{0x04, 0x12, 0x34, 0xFE, 0xDC, 0xBA, 0x98, 0x76}
+	testKeyValue = []byte{0x6A, 0xC2, 0x92, 0xFA, 0xA1, 0x31, 0x5B, 0x4D, 0x85, 0x8A, 0xB3, 0xA3, 0xD7, 0xD5, 0x93, 0x3A}
+)
+
+func getTestCard() *common.ReaderCardInfo {
+	return &common.ReaderCardInfo{
+		Track1:  "B" + testPan + "^CARDHOLDER/TEST^2512101",
+		Track2:  testTrack2,
+		CardPan: testPan,
+		ExpDate: "2512",
+		Holder:  "CARDHOLDER/TEST",
+	}
+}
+
+// Values that must not appear in logs
+func getSecrets() []string {
+	return []string{
+		testPan,
+		testTrack2,
+		base64.StdEncoding.EncodeToString(testPinBlock),
+		base64.StdEncoding.EncodeToString(testKeyValue),
+	}
+}
+
+func checkRedacted(t *testing.T, name string, text string) {
+	t.Helper()
+	for _, secret := range getSecrets() {
+		if strings.Contains(text, secret) {
+			t.Errorf("%s leaks %s: %s", name, secret, text)
+		}
+	}
+}
+
+func TestMaskPan(t *testing.T) {
+	if pan := core.MaskPan(testPan); pan != testMasked {
+		t.Errorf("MaskPan %s, want %s", pan, testMasked)
+	}
+	for _, short := range []string{"1234", "4111111111"} {
+		if pan := core.MaskPan(short); strings.Contains(pan, short[len(short)-4:]) {
+			t.Errorf("short PAN %s is masked to %s", short, pan)
+		}
+	}
+	if pan := core.MaskPan(""); pan != "" {
+		t.Errorf("empty PAN is masked to %s", pan)
+	}
+}
+
+func TestScrubText(t *testing.T) {
+	text := core.ScrubText("card " + testPan + ", order 1234567890123")
+	if text != "card "+testMasked+", order 1234567890123" {
+		t.Errorf("ScrubText %s", text)
+	}
+}
+
+func TestRedactJson(t *testing.T) {
+	query := &common.ReaderPinQuery{KeyValue: testKeyValue, CardPan: testPan}
+	reply := &common.ReaderPinReply{PinLength: 4, PinBlock: testPinBlock}
+	for _, item := range []interface{}{getTestCard(), query, reply} {
+		dump, err := json.Marshal(item)
+		if err != nil {
+			t.Fatal(err)
+		}
+		text := core.RedactJson(dump)
+		checkRedacted(t, "RedactJson", text)
+		if strings.Contains(string(dump), testPan) && !strings.Contains(text, testMasked) {
+			t.Errorf("RedactJson has no masked PAN: %s", text)
+		}
+	}
+	// Fields of the same name are kept in other structures
+	type other struct {
+		Query   string `json:"query"`
+		CardPan string `json:"card_pan"`
+		Page    int    `json:"page"`
+	}
+	dump, _ := json.Marshal(&other{Query: "status", CardPan: "n/a", Page: 2})
+	if text := core.RedactJson(dump); !strings.Contains(text, `"query":"status"`) || !strings.Contains(text, `"card_pan":"n/a"`) {
+		t.Errorf("RedactJson changes other structure: %s", text)
+	}
+	// Nested structure is found by its fields, short PAN is not shown
+	dump, _ = json.Marshal(map[string]interface{}{"card": &common.ReaderCardInfo{CardPan: "4111111111"}})
+	if text := core.RedactJson(dump); strings.Contains(text, "4111111111") {
+		t.Errorf("RedactJson leaks short PAN: %s", text)
+	}
+	if text := core.RedactJson([]byte("not json " + testPan)); strings.Contains(text, testPan) {
+		t.Errorf("RedactJson leaks PAN of broken json: %s", text)
+	}
+}
+
+// Log file must not have card and PIN data written by packets, handlers and log agents
+func TestLogRedaction(t *testing.T) {
+	dir := t.TempDir()
+	core.StartFileLogger(&core.LogConfig{
+		LogPath:   dir,
+		LogFile:   "redact",
+		LogLevel:  core.LogLevelTrace,
+		ConsLevel: core.LogLevelEmpty,
+	})
+	log := core.GetLogAgent(core.LogLevelTrace, "Redact")
+
+	card := getTestCard()
+	reply := &common.ReaderPinReply{PinLength: 4, PinBlock: testPinBlock}
+	for _, item := range []interface{}{card, reply} {
+		dump, err := json.Marshal(item)
+		if err != nil {
+			t.Fatal(err)
+		}
+		duplex.NewPacket(duplex.ScopeReader, "reader", common.CmdCardDescription, dump).Print(log, "Test")
+	}
+	dh := handler.NewDeviceHandler("reader", nil, log)
+	_ = dh.CardDescription("reader", card)
+
+	log.Trace("Trace card %s", card)
+	log.Dump("Dump card %+v", card)
+	log.Debug("Debug PAN %s", card.CardPan)
+	log.Info("Info reply %s", reply)
+	log.Warn("Warn PAN %s", testPan)
+	log.Error("Error PAN %s", testPan)
+	log.Panic("Panic PAN %s", testPan)
+	core.StopFileLogger()
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.log"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no log file in %s: %v", dir, err)
+	}
+	for _, name := range files {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		text := string(data)
+		checkRedacted(t, "log", text)
+		if !strings.Contains(text, testMasked) {
+			t.Errorf("log has no masked PAN: %s", text)
+		}
+	}
+}
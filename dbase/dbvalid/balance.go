@@ -13,9 +13,9 @@ const (
 	id INTEGER PRIMARY KEY AUTOINCREMENT,
 	batch_id INTEGER NOT NULL,
     currency INTEGER NOT NULL DEFAULT 0,
-    nominal REAL NOT NULL DEFAULT 0,
+    nominal INTEGER NOT NULL DEFAULT 0,
     count INTEGER NOT NULL DEFAULT 0,
-    amount REAL NOT NULL DEFAULT 0,
+    amount INTEGER NOT NULL DEFAULT 0,
     created VARCHAR(64),
     FOREIGN KEY (batch_id) REFERENCES valid_batch (id) ON UPDATE RESTRICT ON DELETE RESTRICT
 );`
@@ -40,8 +40,8 @@ func (d *ObjBalance) String() string {
 	if d == nil {
 		return ""
 	}
-	str := fmt.Sprintf("Deposit Id:%d, Batch:%d, Note %7.2f * %3d = %9.2f of %3d (%s) - %s, Created:%s",
-		d.Id, d.BatchId, d.Nominal, d.Count, d.Amount, d.Currency, d.Currency.IsoCode(), d.Currency.String(), d.Created)
+	str := fmt.Sprintf("Deposit Id:%d, Batch:%d, Note %7s * %3d = %9s of %3d (%s) - %s, Created:%s",
+		d.Id, d.BatchId, d.Nominal.Format(d.Currency), d.Count, d.Amount.Format(d.Currency), d.Currency, d.Currency.IsoCode(), d.Currency.String(), d.Created)
 	return str
 }
 
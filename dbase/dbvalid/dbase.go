@@ -61,6 +61,9 @@ func (db *DBaseValidator) CreateAllTables() error {
 		if err == nil {
 			err = qry.CreateTableBalance()
 		}
+		if err == nil {
+			err = qry.MigrateTables()
+		}
 		if err == nil {
 			err = db.linker.Commit()
 		} else {
@@ -120,6 +123,7 @@ func (db *DBaseValidator) tryCloseBatch(data *common.ValidatorBatch) error {
 	}
 	data.BatchId = batch.Id
 	data.State   = batch.State
+	data.Count   = batch.Count
 	data.Notes, err = qryNt.doSearch(db.device)
 	if err != nil {
 		return err
@@ -145,6 +149,11 @@ func (db *DBaseValidator) tryCloseBatch(data *common.ValidatorBatch) error {
 	if err != nil {
 		return err
 	}
+	// Cassette is removed, so stacker counters start from zero
+	_, err = qryNt.doReset(db.device)
+	if err != nil {
+		return err
+	}
 	err = qryBt.makeNewBranch(db.device, batch)
 	return err
 }
@@ -210,6 +219,7 @@ func (db *DBaseValidator) tryReadNoteList(data *common.ValidatorBatch) error {
 	}
 	data.BatchId = batch.Id
 	data.State = batch.State
+	data.Count = batch.Count
 	data.Notes, err = qryNt.doSearch(db.device)
 	return err
 }
@@ -219,7 +229,7 @@ func (db *DBaseValidator) DepositNote(extraId int64, data *common.ValidatorAccep
 	if data == nil {
 		return errors.New(errParamIsNil)
 	}
-	db.log.Debug("Validator database - DepositNote %7.2f %s", data.Amount, data.Currency.IsoCode())
+	db.log.Debug("Validator database - DepositNote %s", data.GetAmount().String())
 	err := db.linker.Begin()
 	if err == nil {
 		err = db.tryDepositNote(extraId, data)
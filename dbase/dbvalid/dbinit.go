@@ -81,15 +81,16 @@ func checkBatch(notes common.ValidNoteList, depos ObjDepositList) (common.BatchS
 		item.depo_sum += depo.Amount
 	}
 
-	// Compare items data
+	// Compare items data, amounts are exact minor units
 	state := common.StateCorrect
 	brief := ""
 	for curr, item := range set {
 		if  item.note_cnt != item.depo_cnt ||
 			item.note_sum != item.depo_sum {
 			state = common.StateMismatch
-			brief += fmt.Sprintf("Missmatch currency %3d (%s): Notes %4d /%9.2f != Depos %4d /%9.2f - %s; ",
-				curr, curr.IsoCode(), item.note_cnt, item.note_sum, item.depo_cnt, item.depo_sum, curr.String() )
+			brief += fmt.Sprintf("Missmatch currency %3d (%s): Notes %4d /%9s != Depos %4d /%9s - %s; ",
+				curr, curr.IsoCode(), item.note_cnt, item.note_sum.Format(curr),
+				item.depo_cnt, item.depo_sum.Format(curr), curr.String() )
 		}
 	}
 	return state, brief
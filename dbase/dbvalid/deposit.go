@@ -14,9 +14,9 @@ const (
 	batch_id INTEGER NOT NULL,
 	extra_id INTEGER NOT NULL DEFAULT 0,
     currency INTEGER NOT NULL DEFAULT 0,
-    nominal REAL NOT NULL DEFAULT 0,
+    nominal INTEGER NOT NULL DEFAULT 0,
     count INTEGER NOT NULL DEFAULT 0,
-    amount REAL NOT NULL DEFAULT 0,
+    amount INTEGER NOT NULL DEFAULT 0,
     created VARCHAR(64),
     FOREIGN KEY (batch_id) REFERENCES valid_batch (id) ON UPDATE RESTRICT ON DELETE RESTRICT
 );`
@@ -42,8 +42,8 @@ func (d *ObjDeposit) String() string {
 	if d == nil {
 		return ""
 	}
-	str := fmt.Sprintf("Deposit Id:%d, Batch:%d, Extra:%d, Note %7.2f * %3d = %9.2f of %3d (%s) - %s, Created:%s",
-		d.Id, d.BatchId, d.ExtraId, d.Nominal, d.Count, d.Amount, d.Currency, d.Currency.IsoCode(), d.Currency.String(), d.Created)
+	str := fmt.Sprintf("Deposit Id:%d, Batch:%d, Extra:%d, Note %7s * %3d = %9s of %3d (%s) - %s, Created:%s",
+		d.Id, d.BatchId, d.ExtraId, d.Nominal.Format(d.Currency), d.Count, d.Amount.Format(d.Currency), d.Currency, d.Currency.IsoCode(), d.Currency.String(), d.Created)
 	return str
 }
 
@@ -0,0 +1,95 @@
+package dbvalid
+
+import (
+	"fmt"
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/dbase"
+	"strings"
+)
+
+// Schema version is kept in sqlite user_version header field
+const (
+	schemaVersionMoney = 1 // Money columns are stored in minor units as INTEGER
+	schemaVersionLast  = schemaVersionMoney
+
+	sqlVersionSelect = `PRAGMA user_version;`
+	sqlVersionUpdate = `PRAGMA user_version = %d;`
+	sqlTableRename   = `ALTER TABLE %s RENAME TO %s_old;`
+	sqlTableDrop     = `DROP TABLE %s_old;`
+	sqlMoneyCopy     = `INSERT INTO %s (%s) SELECT %s FROM %s_old;`
+	sqlMoneyCast     = `CAST(ROUND(%s * %s) AS INTEGER)`
+)
+
+type objVersion struct {
+	UserVersion int64
+}
+
+type moneyTable struct {
+	name    string
+	create  string
+	columns []string
+}
+
+var moneyTables = []moneyTable{
+	{"valid_note", sqlNoteCreate, []string{"device", "currency", "nominal", "count", "amount"}},
+	{"valid_deposit", sqlDepositCreate, []string{"id", "batch_id", "extra_id", "currency", "nominal", "count", "amount", "created"}},
+	{"valid_balance", sqlBalanceCreate, []string{"id", "batch_id", "currency", "nominal", "count", "amount", "created"}},
+}
+
+// MigrateTables upgrades validator tables to the last schema version
+func (dao *QueryValidator) MigrateTables() error {
+	ver := &objVersion{}
+	err := dao.RunSelectSql(sqlVersionSelect, make(dbase.ParamList, 0), ver)
+	if err != nil {
+		return err
+	}
+	if ver.UserVersion < schemaVersionMoney {
+		for _, table := range moneyTables {
+			err = dao.migrateMoneyTable(table)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if ver.UserVersion < schemaVersionLast {
+		err = dao.RunCommandSql(fmt.Sprintf(sqlVersionUpdate, schemaVersionLast), make(dbase.ParamList, 0))
+	}
+	return err
+}
+
+// Rebuild table with INTEGER money columns converting REAL major units to minor units
+func (dao *QueryValidator) migrateMoneyTable(table moneyTable) error {
+	param := make(dbase.ParamList, 0)
+	scale := getScaleExpression()
+	values := make([]string, len(table.columns))
+	for i, col := range table.columns {
+		values[i] = col
+		if col == "nominal" || col == "amount" {
+			values[i] = fmt.Sprintf(sqlMoneyCast, col, scale)
+		}
+	}
+	err := dao.RunCommandSql(fmt.Sprintf(sqlTableRename, table.name, table.name), param)
+	if err == nil {
+		err = dao.RunCommandSql(table.create, param)
+	}
+	if err == nil {
+		copySql := fmt.Sprintf(sqlMoneyCopy, table.name,
+			strings.Join(table.columns, ", "), strings.Join(values, ", "), table.name)
+		err = dao.RunCommandSql(copySql, param)
+	}
+	if err == nil {
+		err = dao.RunCommandSql(fmt.Sprintf(sqlTableDrop, table.name), param)
+	}
+	return err
+}
+
+// Build SQL expression of minor units scale by currency column
+func getScaleExpression() string {
+	expr := "CASE currency"
+	for code := common.DevCurrency(0); code < 1000; code++ {
+		if code.Exponent() != 2 {
+			expr += fmt.Sprintf(" WHEN %d THEN %d", code, code.Scale())
+		}
+	}
+	return expr + " ELSE 100 END"
+}
@@ -10,9 +10,9 @@ const (
 	sqlNoteCreate = `CREATE TABLE IF NOT EXISTS valid_note (
 	device VARCHAR(64) NOT NULL,
     currency INTEGER NOT NULL DEFAULT 0,
-    nominal REAL NOT NULL DEFAULT 0,
+    nominal INTEGER NOT NULL DEFAULT 0,
     count INTEGER NOT NULL DEFAULT 0,
-    amount REAL NOT NULL DEFAULT 0,
+    amount INTEGER NOT NULL DEFAULT 0,
     UNIQUE (device, currency, nominal)
 );`
 	sqlNoteDelete = `DELETE FROM valid_note WHERE device = ?;`
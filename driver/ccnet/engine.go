@@ -0,0 +1,299 @@
+package ccnet
+
+import (
+	"fmt"
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/config"
+	"github.com/iftsoft/device/driver/validator"
+	"time"
+)
+
+const (
+	ccnetHoldPeriod = 5 * time.Second // Validator returns escrowed bill in 10 seconds without HOLD
+	ccnetResetWait  = 50              // Poll count to wait for validator initialization
+)
+
+type CcnetEngine struct {
+	validator.CashEngine
+	protocol *CcnetProtocol
+	bills    *BillTable
+	status   ccnetStatus
+	holdTime time.Time
+}
+
+func (ce *CcnetEngine) initEngine(cfg *config.DeviceConfig) *CcnetEngine {
+	ce.InitCashEngine(cfg)
+	ce.protocol = GetCcnetProtocol(ce.GetLinkerConfig())
+	return ce
+}
+
+// Currency by config or the first one of bill table
+func (ce *CcnetEngine) getDefaultCurrency() common.DevCurrency {
+	if curr := ce.GetValidatorConfig().CurrCode; curr != common.CurrencyNOT {
+		return curr
+	}
+	if ce.bills != nil {
+		for _, bill := range ce.bills {
+			if bill != nil {
+				return bill.Currency
+			}
+		}
+	}
+	return common.CurrencyNOT
+}
+
+// Read bill table from validator and rebuild note tables and escrow policy
+func (ce *CcnetEngine) loadBillTable() error {
+	bills, err := ce.protocol.GetBillTable(ce.GetValidatorConfig().CurrCode)
+	if err != nil {
+		return err
+	}
+	ce.Log.Debug(bills.String())
+	tables := validator.NoteTables{}
+	for _, bill := range bills {
+		if bill != nil && tables[bill.Currency] == nil {
+			tables[bill.Currency] = bills.GetNoteList(bill.Currency)
+		}
+	}
+	// Notes mask is applied to bill types by validator itself
+	err = ce.SetNoteTables(tables)
+	if err == nil {
+		ce.bills = bills
+	}
+	return err
+}
+
+// Reset validator and wait until initialization is over
+func (ce *CcnetEngine) resetValidator() error {
+	err := ce.protocol.Reset()
+	for i := 0; err == nil && i < ccnetResetWait; i++ {
+		time.Sleep(200 * time.Millisecond)
+		var data []byte
+		data, err = ce.protocol.Poll()
+		if err != nil {
+			break
+		}
+		ce.processStatus(newCcnetStatus(data))
+		switch ce.status.code {
+		case stPowerUp, stPowerUpBillVal, stPowerUpBillStack, stInitialize, stDeviceBusy:
+			continue
+		}
+		return ce.getStatusError()
+	}
+	if err == nil {
+		err = common.NewError(common.DevErrorWaitTimeout, "validator initialization timeout")
+	}
+	return err
+}
+
+func (ce *CcnetEngine) getStatusError() error {
+	if code := ce.status.GetError(); code != common.DevErrorSuccess {
+		return common.NewError(code, ce.status.String())
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////////////
+
+func (ce *CcnetEngine) DevStartup() error {
+	err := ce.protocol.OpenLink()
+	if err == nil {
+		err = ce.resetValidator()
+	}
+	if err == nil {
+		var ident *CcnetIdent
+		ident, err = ce.protocol.Identification()
+		ce.Log.Info("CCNET validator %s", ident.String())
+	}
+	if err == nil {
+		err = ce.loadBillTable()
+	}
+	if err == nil {
+		err = ce.DevCheckBatch()
+	}
+	ce.Accept.Currency = ce.getDefaultCurrency()
+	return err
+}
+
+func (ce *CcnetEngine) DevCleanup() error {
+	if ce.status.code != 0 {
+		_ = ce.protocol.EnableBills(0, 0)
+	}
+	return ce.protocol.CloseLink()
+}
+
+func (ce *CcnetEngine) DevReset() error {
+	ce.EscrowWait = time.Time{}
+	return ce.resetValidator()
+}
+
+func (ce *CcnetEngine) DevStatus() error {
+	return ce.getStatusError()
+}
+
+func (ce *CcnetEngine) DevEnableBills(curr common.DevCurrency, target common.DevAmount) error {
+	if curr == common.CurrencyNOT {
+		curr = ce.Accept.Currency
+	}
+	ce.Log.Debug("CcnetEngine Set currency %d - %s, target %s", curr, curr.String(), target.Format(curr))
+	if ce.bills == nil {
+		return common.NewError(common.DevErrorNotInitialized, "bill table is not loaded")
+	}
+	mask := ce.bills.GetMask(curr, ce.GetValidatorConfig().NotesMask)
+	if mask == 0 {
+		return common.NewError(common.DevErrorNoCurrency,
+			fmt.Sprintf("no enabled bills for currency %d (%s)", curr, curr.IsoCode()))
+	}
+	err := ce.CheckSession(target)
+	if err == nil {
+		err = ce.protocol.EnableBills(mask, mask)
+	}
+	if err == nil {
+		ce.StartSession(curr, target)
+	}
+	return err
+}
+
+func (ce *CcnetEngine) DevDisableBills() error {
+	if ce.CancelEscrow() {
+		_ = ce.protocol.Return()
+	}
+	return ce.protocol.EnableBills(0, 0)
+}
+
+func (ce *CcnetEngine) DevInitBillList(curr common.DevCurrency) error {
+	err := ce.loadBillTable()
+	if err != nil {
+		return err
+	}
+	if curr == common.CurrencyNOT {
+		curr = ce.getDefaultCurrency()
+	}
+	return ce.InitNoteList(curr)
+}
+
+func (ce *CcnetEngine) DevNoteAccept() error {
+	err := ce.HostDecision(common.NoteDecisionAccept, "accepted by host")
+	if err == nil {
+		err = ce.protocol.Stack()
+	}
+	return err
+}
+
+func (ce *CcnetEngine) DevNoteReturn() error {
+	err := ce.HostDecision(common.NoteDecisionReturn, "returned by host")
+	if err == nil {
+		err = ce.protocol.Return()
+	}
+	return err
+}
+
+////////////////////////////////////////////////////////////////
+
+// Poll validator and process its status
+func (ce *CcnetEngine) pollValidator() {
+	if !ce.protocol.IsOpen() {
+		return
+	}
+	data, err := ce.protocol.Poll()
+	if err != nil {
+		code, text := common.CheckError(err)
+		if code != ce.DevError {
+			_ = ce.RunExecuteError(code, text)
+		}
+		return
+	}
+	ce.processStatus(newCcnetStatus(data))
+	if ce.CheckEscrowWait() {
+		ce.applyDecision()
+	}
+}
+
+func (ce *CcnetEngine) processStatus(st ccnetStatus) {
+	prev := ce.status
+	ce.status = st
+	if st == prev {
+		if st.code == stEscrowPosition {
+			ce.holdEscrow()
+		}
+		return
+	}
+	ce.Log.Debug("CcnetEngine status %s", st.String())
+	_ = ce.RunStateChanged(st.GetState())
+	if prompt := st.GetPrompt(); prompt != ce.DevPrompt {
+		_ = ce.RunActionPrompt(prompt)
+	}
+	if code := st.GetError(); code != common.DevErrorSuccess {
+		_ = ce.RunExecuteError(code, st.String())
+	} else {
+		ce.DevError = common.DevErrorSuccess
+	}
+	switch st.code {
+	case stEscrowPosition:
+		ce.onBillEscrowed(int(st.extra))
+	case stBillStacked:
+		ce.onBillStacked(int(st.extra))
+	case stBillReturned:
+		ce.onBillReturned(int(st.extra))
+	}
+}
+
+func (ce *CcnetEngine) setAcceptBill(index int) bool {
+	if ce.bills == nil || index < 0 || index >= billTypes || ce.bills[index] == nil {
+		return false
+	}
+	bill := ce.bills[index]
+	ce.Accept.Currency = bill.Currency
+	ce.Accept.Nominal = bill.Nominal
+	ce.Accept.Count = 1
+	ce.Accept.Amount = bill.Nominal
+	return true
+}
+
+func (ce *CcnetEngine) onBillEscrowed(index int) {
+	if !ce.setAcceptBill(index) {
+		ce.ClearNote()
+	}
+	ce.holdTime = time.Now()
+	ce.DecideNote()
+	ce.applyDecision()
+}
+
+func (ce *CcnetEngine) applyDecision() {
+	var err error
+	switch ce.Accept.Decision {
+	case common.NoteDecisionAccept:
+		err = ce.protocol.Stack()
+	case common.NoteDecisionReturn:
+		err = ce.protocol.Return()
+	default:
+	}
+	if err != nil {
+		code, text := common.CheckError(err)
+		_ = ce.RunExecuteError(code, text)
+	}
+}
+
+// Keep bill in escrow while the host is deciding
+func (ce *CcnetEngine) holdEscrow() {
+	if ce.EscrowWait.IsZero() || time.Since(ce.holdTime) < ccnetHoldPeriod {
+		return
+	}
+	ce.holdTime = time.Now()
+	_ = ce.protocol.Hold()
+}
+
+func (ce *CcnetEngine) onBillStacked(index int) {
+	if ce.Accept.Nominal == 0 && !ce.setAcceptBill(index) {
+		ce.Log.Warn("CcnetEngine unknown bill type %d is stacked", index)
+		return
+	}
+	ce.StoreNote()
+}
+
+func (ce *CcnetEngine) onBillReturned(index int) {
+	if ce.Accept.Nominal == 0 {
+		ce.setAcceptBill(index)
+	}
+	ce.ReturnNote()
+}
@@ -0,0 +1,167 @@
+package ccnet
+
+import (
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/config"
+	"github.com/iftsoft/device/linker/linkertest"
+	"testing"
+)
+
+var tx, rx = linkertest.TX, linkertest.RX
+
+// Poll of validator that answers by status and gets ACK
+func poll(status ...byte) []*linkertest.Step {
+	return []*linkertest.Step{tx(cmdPoll), rx(status...), tx(cmdAck)}
+}
+
+// Bill table with 10, 20 and 50 UAH in bill types 0, 1 and 2
+func getTestBillTable(t *testing.T) *BillTable {
+	t.Helper()
+	data := make([]byte, billTableSize)
+	copy(data[0:], []byte{1, 'U', 'K', 'R', 1})
+	copy(data[5:], []byte{2, 'U', 'K', 'R', 1})
+	copy(data[10:], []byte{5, 'U', 'K', 'R', 1})
+	bills, err := parseBillTable(data, common.CurrencyNOT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bills
+}
+
+func newTestEngine(t *testing.T, path string, target common.DevAmount) (*CcnetEngine, *linkertest.ValidatorCallback) {
+	t.Helper()
+	ce := (&CcnetEngine{}).initEngine(&config.DeviceConfig{Linker: &config.LinkerConfig{Replay: path}})
+	cb := &linkertest.ValidatorCallback{}
+	ce.CbValidator = cb
+	ce.bills = getTestBillTable(t)
+	err := ce.SetNoteTables(map[common.DevCurrency]common.ValidNoteList{
+		common.CurrencyUAH: ce.bills.GetNoteList(common.CurrencyUAH),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	linkertest.OpenLink(t, ce.protocol.HalfDuplex)
+	ce.StartSession(common.CurrencyUAH, target)
+	return ce, cb
+}
+
+func TestParseBillTable(t *testing.T) {
+	bills := getTestBillTable(t)
+	for i, nominal := range []common.DevAmount{1000, 2000, 5000} {
+		if bills[i] == nil || bills[i].Currency != common.CurrencyUAH || bills[i].Nominal != nominal {
+			t.Errorf("bill type %d is %s, want %d UAH", i, bills[i], nominal)
+		}
+	}
+	if mask := bills.GetMask(common.CurrencyUAH, 0); mask != 0x07 {
+		t.Errorf("mask %06X, want 000007", mask)
+	}
+	if mask := bills.GetMask(common.CurrencyUAH, 0x04); mask != 0x04 {
+		t.Errorf("mask of 50 UAH %06X, want 000004", mask)
+	}
+}
+
+// Exponent of bill type must keep nominal in range of minor units
+func TestParseBillTableErrors(t *testing.T) {
+	for _, item := range [][]byte{
+		{255, 'U', 'K', 'R', 0x7F},
+		{1, 'U', 'K', 'R', 0x12},
+		{1, 'U', 'K', 'R', 0x83},
+	} {
+		data := make([]byte, billTableSize)
+		copy(data[5:], item)
+		_, err := parseBillTable(data, common.CurrencyNOT)
+		if code, _ := common.CheckError(err); code != common.DevErrorProtocolFault {
+			t.Errorf("bill type % X error %v", item, err)
+		}
+	}
+}
+
+// Bill that fits session target is stacked by the engine itself
+func TestEngineStacksBill(t *testing.T) {
+	path := linkertest.WriteTrace(t, newCcnetFramer(),
+		poll(stAccepting),
+		poll(stEscrowPosition, 2),
+		[]*linkertest.Step{tx(cmdStack), rx(cmdAck)},
+		poll(stStacking),
+		poll(stBillStacked, 2),
+		poll(stIdling),
+	)
+	ce, cb := newTestEngine(t, path, 10000)
+	for i := 0; i < 5; i++ {
+		ce.pollValidator()
+	}
+	linkertest.CheckEvents(t, &ce.BaseEngine, cb, "accepted 5000 Accept", "stored 5000")
+	if ce.Stacked != 1 || ce.Session.Total != 5000 {
+		t.Errorf("stacked %d, session total %d", ce.Stacked, ce.Session.Total)
+	}
+	if ce.status.code != stIdling {
+		t.Errorf("status %s", ce.status)
+	}
+}
+
+// Bill over session target is returned without asking the host
+func TestEngineReturnsBill(t *testing.T) {
+	path := linkertest.WriteTrace(t, newCcnetFramer(),
+		poll(stEscrowPosition, 2),
+		[]*linkertest.Step{tx(cmdReturn), rx(cmdAck)},
+		poll(stReturning),
+		poll(stBillReturned, 2),
+	)
+	ce, cb := newTestEngine(t, path, 2000)
+	for i := 0; i < 3; i++ {
+		ce.pollValidator()
+	}
+	linkertest.CheckEvents(t, &ce.BaseEngine, cb, "accepted 5000 Return", "returned 5000")
+	if ce.Stacked != 0 || ce.Session.Total != 0 {
+		t.Errorf("stacked %d, session total %d", ce.Stacked, ce.Session.Total)
+	}
+}
+
+// Session without target waits for the host, escrow is held until the host decides
+func TestEngineHostDecision(t *testing.T) {
+	path := linkertest.WriteTrace(t, newCcnetFramer(),
+		poll(stEscrowPosition, 1),
+		poll(stEscrowPosition, 1),
+		[]*linkertest.Step{tx(cmdStack), rx(cmdAck)},
+		poll(stBillStacked, 1),
+	)
+	ce, cb := newTestEngine(t, path, 0)
+	ce.pollValidator()
+	if ce.EscrowWait.IsZero() {
+		t.Fatal("engine does not wait for host decision")
+	}
+	ce.pollValidator()
+	if err := ce.DevNoteAccept(); err != nil {
+		t.Fatal(err)
+	}
+	ce.pollValidator()
+	linkertest.CheckEvents(t, &ce.BaseEngine, cb, "accepted 2000 Host", "stored 2000")
+	if err := ce.DevNoteAccept(); err == nil {
+		t.Error("host decision is taken while no bill waits for it")
+	}
+}
+
+// Rejected bill and full cassette reach device state and stacker metrics
+func TestEngineRejectAndFull(t *testing.T) {
+	path := linkertest.WriteTrace(t, newCcnetFramer(),
+		poll(stRejecting, 0x60),
+		poll(stCassetteFull),
+	)
+	ce, cb := newTestEngine(t, path, 0)
+	ce.pollValidator()
+	if ce.status.code != stRejecting || ce.DevError != common.DevErrorSuccess {
+		t.Errorf("status %s, error %s", ce.status, ce.DevError)
+	}
+	ce.pollValidator()
+	if ce.DevError != common.DevErrorStackerFull {
+		t.Errorf("error %s, want %s", ce.DevError, common.DevErrorStackerFull)
+	}
+	metrics := &common.SystemMetrics{Counts: map[string]uint32{}, Topics: map[string]string{}}
+	ce.CheckStacker(metrics)
+	if metrics.Topics["stacker"] != common.DevErrorStackerFull.String() {
+		t.Errorf("stacker topic %q", metrics.Topics["stacker"])
+	}
+	if len(cb.Events) != 0 {
+		t.Errorf("unexpected events %q", cb.Events)
+	}
+}
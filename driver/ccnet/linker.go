@@ -0,0 +1,26 @@
+package ccnet
+
+import (
+	"github.com/iftsoft/device/linker"
+)
+
+const (
+	ccnetSync     byte = 0x02 // Frame synchronization byte
+	ccnetAddrBill byte = 0x03 // Peripheral address of bill validator
+)
+
+////////////////////////////////////////////////////////////////
+// Data flow:  SYNC, ADR, LNG, CMD, []DATA, CRC16 (LSB first)
+// LNG is the full frame size including SYNC and CRC bytes
+
+func newCcnetFramer() linker.Framer {
+	return linker.NewSizeFramer(linker.CheckCRC16, ccnetSync, ccnetAddrBill)
+}
+
+// Validator answers at once, lost frames are repeated by the next poll
+func getCcnetOptions() *linker.HalfDuplexOptions {
+	opts := linker.GetDefaultHalfDuplexOptions()
+	opts.Retries = 0
+	opts.Timeout = 200
+	return opts
+}
@@ -0,0 +1,346 @@
+package ccnet
+
+import (
+	"fmt"
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/config"
+	"github.com/iftsoft/device/core"
+	"github.com/iftsoft/device/linker"
+	"math"
+	"strings"
+	"sync"
+)
+
+// CCNET controller commands and replies
+const (
+	cmdAck            byte = 0x00
+	cmdReset          byte = 0x30
+	cmdGetStatus      byte = 0x31
+	cmdSetSecurity    byte = 0x32
+	cmdPoll           byte = 0x33
+	cmdEnableBills    byte = 0x34
+	cmdStack          byte = 0x35
+	cmdReturn         byte = 0x36
+	cmdIdentification byte = 0x37
+	cmdHold           byte = 0x38
+	cmdGetBillTable   byte = 0x41
+	cmdNak            byte = 0xFF
+
+	replyIllegal byte = 0x30 // Illegal command reply
+	billTypes         = 24   // Count of bill types in bill table
+	billTableSize     = billTypes * 5
+)
+
+// BillType describes one bill table entry of the validator
+type BillType struct {
+	Index    int
+	Country  string
+	Currency common.DevCurrency
+	Nominal  common.DevAmount
+}
+
+func (bt *BillType) String() string {
+	if bt == nil {
+		return ""
+	}
+	return fmt.Sprintf("Bill type %2d: %9s %s (%s)",
+		bt.Index, bt.Nominal.Format(bt.Currency), bt.Currency.IsoCode(), bt.Country)
+}
+
+type BillTable [billTypes]*BillType
+
+func (bt *BillTable) String() string {
+	str := "CCNET bill table:"
+	for _, bill := range bt {
+		if bill != nil {
+			str += "\n    " + bill.String()
+		}
+	}
+	return str
+}
+
+// Identification reply of the validator
+type CcnetIdent struct {
+	PartNumber string
+	SerialNo   string
+	AssetNo    []byte
+}
+
+func (ci *CcnetIdent) String() string {
+	if ci == nil {
+		return ""
+	}
+	return fmt.Sprintf("Part number: %s, Serial number: %s, Asset number: %X",
+		ci.PartNumber, ci.SerialNo, ci.AssetNo)
+}
+
+type CcnetProtocol struct {
+	*linker.HalfDuplex
+	log  *core.LogAgent
+	lock sync.Mutex
+}
+
+func GetCcnetProtocol(cfg *config.LinkerConfig) *CcnetProtocol {
+	cp := &CcnetProtocol{
+		HalfDuplex: linker.NewHalfDuplex(cfg, newCcnetFramer(), getCcnetOptions(), "CCNET"),
+		log:        core.GetLogAgent(core.LogLevelDump, "CCNET"),
+	}
+	return cp
+}
+
+////////////////////////////////////////////////////////////////
+
+func (cp *CcnetProtocol) Reset() error {
+	err := cp.command(cmdReset, nil)
+	cp.logError("Reset", err)
+	return err
+}
+
+// Poll returns status code and its data of the validator
+func (cp *CcnetProtocol) Poll() ([]byte, error) {
+	back, err := cp.request(cmdPoll, nil)
+	if err == nil && len(back) == 0 {
+		err = common.NewError(common.DevErrorProtocolFault, "empty poll reply")
+	}
+	cp.logError("Poll", err)
+	return back, err
+}
+
+// GetStatus returns masks of enabled and high security bill types
+func (cp *CcnetProtocol) GetStatus() (enabled uint32, security uint32, err error) {
+	back, err := cp.request(cmdGetStatus, nil)
+	if err == nil && len(back) < 6 {
+		err = common.NewError(common.DevErrorProtocolFault, "short status reply")
+	}
+	if err == nil {
+		enabled = getBillMask(back[0:3])
+		security = getBillMask(back[3:6])
+	}
+	cp.logError("GetStatus", err)
+	return enabled, security, err
+}
+
+func (cp *CcnetProtocol) SetSecurity(mask uint32) error {
+	err := cp.command(cmdSetSecurity, putBillMask(mask))
+	cp.logError("SetSecurity", err)
+	return err
+}
+
+// EnableBills enables bill types and their escrow by bit masks, zero masks disable the validator
+func (cp *CcnetProtocol) EnableBills(enable uint32, escrow uint32) error {
+	data := append(putBillMask(enable), putBillMask(escrow)...)
+	err := cp.command(cmdEnableBills, data)
+	cp.logError("EnableBills", err)
+	return err
+}
+
+func (cp *CcnetProtocol) Stack() error {
+	err := cp.command(cmdStack, nil)
+	cp.logError("Stack", err)
+	return err
+}
+
+func (cp *CcnetProtocol) Return() error {
+	err := cp.command(cmdReturn, nil)
+	cp.logError("Return", err)
+	return err
+}
+
+// Hold keeps the bill in escrow for another 10 seconds
+func (cp *CcnetProtocol) Hold() error {
+	err := cp.command(cmdHold, nil)
+	cp.logError("Hold", err)
+	return err
+}
+
+func (cp *CcnetProtocol) Identification() (*CcnetIdent, error) {
+	back, err := cp.request(cmdIdentification, nil)
+	if err == nil && len(back) < 27 {
+		err = common.NewError(common.DevErrorProtocolFault, "short identification reply")
+	}
+	var ident *CcnetIdent
+	if err == nil {
+		ident = &CcnetIdent{
+			PartNumber: strings.TrimSpace(string(back[0:15])),
+			SerialNo:   strings.TrimSpace(string(back[15:27])),
+			AssetNo:    back[27:],
+		}
+	}
+	cp.logError("Identification", err)
+	return ident, err
+}
+
+// GetBillTable reads bill table, currency of unknown country is taken from defCurr
+func (cp *CcnetProtocol) GetBillTable(defCurr common.DevCurrency) (*BillTable, error) {
+	back, err := cp.request(cmdGetBillTable, nil)
+	if err == nil && len(back) < billTableSize {
+		err = common.NewError(common.DevErrorProtocolFault, "short bill table reply")
+	}
+	var table *BillTable
+	if err == nil {
+		table, err = parseBillTable(back, defCurr)
+	}
+	cp.logError("GetBillTable", err)
+	return table, err
+}
+
+////////////////////////////////////////////////////////////////
+
+func (cp *CcnetProtocol) logError(cmd string, err error) {
+	code, text := common.CheckError(err)
+	cp.log.Trace("CcnetProtocol.%s return: %d - %s", cmd, code, text)
+}
+
+// Send command that is answered by ACK
+func (cp *CcnetProtocol) command(cmd byte, data []byte) error {
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+	back, err := cp.exchange(cmd, data)
+	if err != nil {
+		return err
+	}
+	if len(back) != 1 || back[0] != cmdAck {
+		return common.NewError(common.DevErrorProtocolFault,
+			fmt.Sprintf("unexpected reply %s", core.GetBinaryDump(back)))
+	}
+	return nil
+}
+
+// Send command that is answered by data, data reply is confirmed by ACK
+func (cp *CcnetProtocol) request(cmd byte, data []byte) ([]byte, error) {
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+	back, err := cp.exchange(cmd, data)
+	if err != nil {
+		return nil, err
+	}
+	err = cp.Send([]byte{cmdAck})
+	return back, err
+}
+
+func (cp *CcnetProtocol) exchange(cmd byte, data []byte) ([]byte, error) {
+	cp.log.Dump("CcnetProtocol exchange data : %02X %s", cmd, core.GetBinaryDump(data))
+	back, err := cp.Exchange(append([]byte{cmd}, data...))
+	if err != nil {
+		return nil, err
+	}
+	if len(back) == 1 {
+		switch back[0] {
+		case cmdNak:
+			return nil, common.NewError(common.DevErrorProtocolFault, "frame is not acknowledged")
+		case replyIllegal:
+			return nil, common.NewError(common.DevErrorCommandFault,
+				fmt.Sprintf("illegal command %02X", cmd))
+		}
+	}
+	return back, nil
+}
+
+////////////////////////////////////////////////////////////////
+
+// Bill type bits go in 3 bytes, the most significant byte first
+func getBillMask(data []byte) uint32 {
+	return uint32(data[0])<<16 | uint32(data[1])<<8 | uint32(data[2])
+}
+
+func putBillMask(mask uint32) []byte {
+	return []byte{byte(mask >> 16), byte(mask >> 8), byte(mask)}
+}
+
+// Countries that validators report instead of currency code
+var countryCurrency = map[string]common.DevCurrency{
+	"RUS": common.CurrencyRUB,
+	"UKR": common.CurrencyUAH,
+	"USA": common.CurrencyUSD,
+	"KAZ": common.CurrencyKZT,
+	"BLR": common.CurrencyBYN,
+	"MDA": common.CurrencyMDL,
+	"GEO": common.CurrencyGEL,
+	"ARM": common.CurrencyAMD,
+	"AZE": common.CurrencyAZN,
+	"KGZ": common.CurrencyKGS,
+	"UZB": common.CurrencyUZS,
+	"TJK": common.CurrencyTJS,
+	"POL": common.CurrencyPLN,
+	"GBR": common.CurrencyGBP,
+}
+
+// Bill table entry: first digit, country code (3 chars), power of ten (bit 7 is negative sign)
+func parseBillTable(data []byte, defCurr common.DevCurrency) (*BillTable, error) {
+	table := &BillTable{}
+	for i := 0; i < billTypes; i++ {
+		item := data[i*5 : i*5+5]
+		if item[0] == 0 {
+			continue
+		}
+		bill := &BillType{
+			Index:   i,
+			Country: strings.TrimSpace(string(item[1:4])),
+		}
+		curr, ok := countryCurrency[bill.Country]
+		if !ok {
+			var err error
+			curr, err = common.ParseCurrency(bill.Country)
+			if err != nil || curr == common.CurrencyNOT {
+				curr = defCurr
+			}
+		}
+		bill.Currency = curr
+		nominal := common.DevAmount(item[0]) * curr.Scale()
+		power := int(item[4] & 0x7F)
+		for j := 0; j < power; j++ {
+			if item[4]&0x80 != 0 {
+				if nominal%10 != 0 {
+					return nil, common.NewError(common.DevErrorProtocolFault,
+						fmt.Sprintf("bill type %d is less than minor unit", i))
+				}
+				nominal /= 10
+			} else {
+				if nominal > math.MaxInt64/10 {
+					return nil, common.NewError(common.DevErrorProtocolFault,
+						fmt.Sprintf("bill type %d nominal is out of range", i))
+				}
+				nominal *= 10
+			}
+		}
+		bill.Nominal = nominal
+		table[i] = bill
+	}
+	return table, nil
+}
+
+// GetNoteList returns unique nominals of the currency in bill table order
+func (bt *BillTable) GetNoteList(curr common.DevCurrency) common.ValidNoteList {
+	list := make(common.ValidNoteList, 0)
+	for _, bill := range bt {
+		if bill == nil || bill.Currency != curr {
+			continue
+		}
+		dup := false
+		for _, note := range list {
+			dup = dup || note.Nominal == bill.Nominal
+		}
+		if !dup {
+			list = append(list, &common.ValidatorNote{Currency: curr, Nominal: bill.Nominal})
+		}
+	}
+	return list
+}
+
+// GetMask returns bill type mask of the currency filtered by notes mask, zero notes mask enables all.
+// Bits of notes mask stand for nominals of the currency note list as in validator note tables.
+func (bt *BillTable) GetMask(curr common.DevCurrency, notesMask int64) uint32 {
+	list := bt.GetNoteList(curr)
+	var mask uint32
+	for i, bill := range bt {
+		if bill == nil || bill.Currency != curr {
+			continue
+		}
+		for j, note := range list {
+			if note.Nominal == bill.Nominal && (notesMask == 0 || notesMask&(1<<uint(j)) != 0) {
+				mask |= 1 << uint(i)
+			}
+		}
+	}
+	return mask
+}
@@ -0,0 +1,197 @@
+package ccnet
+
+import (
+	"fmt"
+	"github.com/iftsoft/device/common"
+)
+
+// CCNET poll status codes
+const (
+	stPowerUp          byte = 0x10
+	stPowerUpBillVal   byte = 0x11
+	stPowerUpBillStack byte = 0x12
+	stInitialize       byte = 0x13
+	stIdling           byte = 0x14
+	stAccepting        byte = 0x15
+	stStacking         byte = 0x17
+	stReturning        byte = 0x18
+	stUnitDisabled     byte = 0x19
+	stHolding          byte = 0x1A
+	stDeviceBusy       byte = 0x1B
+	stRejecting        byte = 0x1C
+	stCassetteFull     byte = 0x41
+	stCassetteOut      byte = 0x42
+	stValidatorJammed  byte = 0x43
+	stCassetteJammed   byte = 0x44
+	stCheated          byte = 0x45
+	stPause            byte = 0x46
+	stFailure          byte = 0x47
+	stEscrowPosition   byte = 0x80
+	stBillStacked      byte = 0x81
+	stBillReturned     byte = 0x82
+)
+
+// Poll status of the validator with the extra data byte
+type ccnetStatus struct {
+	code  byte
+	extra byte
+}
+
+func newCcnetStatus(data []byte) ccnetStatus {
+	st := ccnetStatus{code: data[0]}
+	if len(data) > 1 {
+		st.extra = data[1]
+	}
+	return st
+}
+
+func (st ccnetStatus) String() string {
+	str := fmt.Sprintf("%02X - %s", st.code, getStatusText(st.code))
+	switch st.code {
+	case stRejecting:
+		str += ": " + getRejectText(st.extra)
+	case stFailure:
+		str += ": " + getFailureText(st.extra)
+	case stEscrowPosition, stBillStacked, stBillReturned:
+		str += fmt.Sprintf(": bill type %d", st.extra)
+	}
+	return str
+}
+
+// GetState maps the status code to device state
+func (st ccnetStatus) GetState() common.EnumDevState {
+	switch st.code {
+	case stPowerUp, stPowerUpBillVal, stPowerUpBillStack, stInitialize, stDeviceBusy, stPause:
+		return common.DevStateWorking
+	case stIdling:
+		return common.DevStateWaiting
+	case stAccepting:
+		return common.DevStateCashAccepting
+	case stStacking:
+		return common.DevStateCashStacking
+	case stReturning:
+		return common.DevStateCashReturning
+	case stUnitDisabled:
+		return common.DevStateStandby
+	case stHolding, stEscrowPosition:
+		return common.DevStateCashEscrowed
+	case stRejecting:
+		return common.DevStateCashRejecting
+	case stCassetteFull:
+		return common.DevStateCashStackerFull
+	case stCassetteOut, stFailure:
+		return common.DevStateHardError
+	case stValidatorJammed, stCassetteJammed:
+		return common.DevStateCashBillJammed
+	case stCheated:
+		return common.DevStateSoftError
+	case stBillStacked:
+		return common.DevStateCashStacked
+	case stBillReturned:
+		return common.DevStateCashReturned
+	default:
+		return common.DevStateUndefined
+	}
+}
+
+// GetError maps the status code to device error, success is returned for normal states
+func (st ccnetStatus) GetError() common.EnumDevError {
+	switch st.code {
+	case stCassetteFull:
+		return common.DevErrorStackerFull
+	case stCassetteOut:
+		return common.DevErrorCassetteMiss
+	case stValidatorJammed, stCassetteJammed:
+		return common.DevErrorBillJammed
+	case stCheated:
+		return common.DevErrorSecurityFault
+	case stFailure:
+		return common.DevErrorHardwareFault
+	default:
+		return common.DevErrorSuccess
+	}
+}
+
+// GetPrompt maps the status code to customer prompt
+func (st ccnetStatus) GetPrompt() common.EnumDevPrompt {
+	switch st.code {
+	case stIdling:
+		return common.DevPromptCashInsertBill
+	case stAccepting:
+		return common.DevPromptCashAccepting
+	case stHolding, stEscrowPosition:
+		return common.DevPromptCashEscrowed
+	case stStacking:
+		return common.DevPromptCashStacking
+	case stReturning, stRejecting:
+		return common.DevPromptCashReturning
+	case stCassetteFull:
+		return common.DevPromptCashStackerFull
+	case stValidatorJammed, stCassetteJammed:
+		return common.DevPromptCashBillJammed
+	case stCassetteOut, stCheated, stFailure:
+		return common.DevPromptCashFailure
+	default:
+		return common.DevPromptNone
+	}
+}
+
+func getStatusText(code byte) string {
+	switch code {
+	case stPowerUp:				return "Power up"
+	case stPowerUpBillVal:		return "Power up with bill in validator"
+	case stPowerUpBillStack:	return "Power up with bill in stacker"
+	case stInitialize:			return "Initialize"
+	case stIdling:				return "Idling"
+	case stAccepting:			return "Accepting"
+	case stStacking:			return "Stacking"
+	case stReturning:			return "Returning"
+	case stUnitDisabled:		return "Unit disabled"
+	case stHolding:				return "Holding"
+	case stDeviceBusy:			return "Device busy"
+	case stRejecting:			return "Rejecting"
+	case stCassetteFull:		return "Drop cassette full"
+	case stCassetteOut:			return "Drop cassette out of position"
+	case stValidatorJammed:		return "Validator jammed"
+	case stCassetteJammed:		return "Drop cassette jammed"
+	case stCheated:				return "Cheated"
+	case stPause:				return "Pause"
+	case stFailure:				return "Failure"
+	case stEscrowPosition:		return "Escrow position"
+	case stBillStacked:			return "Bill stacked"
+	case stBillReturned:		return "Bill returned"
+	default:					return "Unknown status"
+	}
+}
+
+func getRejectText(code byte) string {
+	switch code {
+	case 0x60:	return "Insertion error"
+	case 0x61:	return "Magnetic error"
+	case 0x62:	return "Bill remains in head"
+	case 0x63:	return "Multiplying error"
+	case 0x64:	return "Conveying error"
+	case 0x65:	return "Identification error"
+	case 0x66:	return "Verification error"
+	case 0x67:	return "Optic error"
+	case 0x68:	return "Inhibit error"
+	case 0x69:	return "Capacity error"
+	case 0x6A:	return "Operation error"
+	case 0x6C:	return "Length error"
+	default:	return "Unknown reason"
+	}
+}
+
+func getFailureText(code byte) string {
+	switch code {
+	case 0x50:	return "Stack motor failure"
+	case 0x51:	return "Transport motor speed failure"
+	case 0x52:	return "Transport motor failure"
+	case 0x53:	return "Aligning motor failure"
+	case 0x54:	return "Initial cassette status failure"
+	case 0x55:	return "Optic canal failure"
+	case 0x56:	return "Magnetic canal failure"
+	case 0x5F:	return "Capacitance canal failure"
+	default:	return "Unknown failure"
+	}
+}
@@ -0,0 +1,170 @@
+package cctalk
+
+import (
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/dbase"
+	"github.com/iftsoft/device/dbase/dbvalid"
+	"github.com/iftsoft/device/driver"
+	"time"
+)
+
+type CoinDriver struct {
+	CoinEngine
+	storage dbase.DBaseLinker
+	begTime int64
+}
+
+func NewCoinDriver() *CoinDriver {
+	cd := CoinDriver{}
+	return &cd
+}
+
+// Implementation of DeviceDriver interface
+func (cd *CoinDriver) InitDevice(context *driver.Context) error {
+	cd.initEngine(context.Config)
+	cd.DevName = context.DevName
+	cd.begTime = time.Now().Unix()
+	cd.Log.Debug("CoinDriver run cmd:%s", "InitDevice")
+
+	mask := common.ScopeFlagSystem
+	if device, ok := context.Manager.(common.DeviceCallback); ok {
+		cd.CbDevice = device
+		mask |= common.ScopeFlagDevice
+	}
+	if validator, ok := context.Manager.(common.ValidatorCallback); ok {
+		cd.CbValidator = validator
+		mask |= common.ScopeFlagValidator
+	}
+	if context.Storage != nil {
+		cd.storage = context.Storage
+		cd.Booker = dbvalid.NewDBaseValidator(cd.storage, cd.DevName)
+	}
+	if context.Greeting != nil {
+		context.Greeting.DevType = common.DevTypeCoinValidator
+		context.Greeting.Required = mask
+	}
+	return nil
+}
+
+func (cd *CoinDriver) StartDevice(query *common.SystemConfig) error {
+	cd.Log.Debug("CoinDriver run cmd:%s", "StartDeviceLoop")
+	var err error
+	if cd.Config != nil && query != nil {
+		cd.Config.OverwriteConfig(query)
+	}
+	if cd.storage != nil {
+		err = cd.storage.Open()
+	}
+	if err == nil {
+		err = cd.DevStartup()
+	}
+	return err
+}
+func (cd *CoinDriver) DeviceTimer(unix int64) error {
+	cd.Log.Trace("CoinDriver run cmd:%s", "DeviceTimer")
+	cd.pollAcceptor()
+	return nil
+}
+func (cd *CoinDriver) StopDevice() error {
+	cd.Log.Debug("CoinDriver run cmd:%s", "StopDeviceLoop")
+	err := cd.DevCleanup()
+	if cd.storage != nil {
+		_ = cd.storage.Close()
+	}
+	return err
+}
+func (cd *CoinDriver) CheckDevice(metrics *common.SystemMetrics) error {
+	cd.Log.Debug("CoinDriver run cmd:%s", "CheckDevice")
+	if metrics != nil {
+		metrics.Uptime = time.Now().Unix() - cd.begTime
+		metrics.DevState = cd.DevState
+		metrics.DevError = cd.DevError
+		cd.CheckStacker(metrics)
+		cd.protocol.ReportMetrics(metrics)
+	}
+	return nil
+}
+
+// Implementation of common.DeviceManager
+//
+func (cd *CoinDriver) Cancel(name string, query *common.DeviceQuery) error {
+	err := cd.DevDisableBills()
+	cd.DevError, cd.DevReply = common.CheckError(err)
+	return cd.RunDeviceReply(common.CmdDeviceCancel)
+}
+func (cd *CoinDriver) Reset(name string, query *common.DeviceQuery) error {
+	err := cd.DevReset()
+	cd.DevError, cd.DevReply = common.CheckError(err)
+	return cd.RunDeviceReply(common.CmdDeviceReset)
+}
+func (cd *CoinDriver) Status(name string, query *common.DeviceQuery) error {
+	err := cd.DevStatus()
+	cd.DevError, cd.DevReply = common.CheckError(err)
+	return cd.RunDeviceReply(common.CmdDeviceStatus)
+}
+func (cd *CoinDriver) RunAction(name string, query *common.DeviceQuery) error {
+	err := cd.DevEnableBills(common.CurrencyNOT, 0)
+	if err == nil {
+		err = cd.DevStatus()
+	}
+	cd.DevError, cd.DevReply = common.CheckError(err)
+	return cd.RunDeviceReply(common.CmdRunAction)
+}
+func (cd *CoinDriver) StopAction(name string, query *common.DeviceQuery) error {
+	err := cd.DevDisableBills()
+	if err == nil {
+		err = cd.DevStatus()
+	}
+	cd.DevError, cd.DevReply = common.CheckError(err)
+	return cd.RunDeviceReply(common.CmdStopAction)
+}
+
+// Implementation of common.ValidatorManager
+//
+func (cd *CoinDriver) InitValidator(name string, query *common.ValidatorQuery) error {
+	err := cd.DevReset()
+	if err == nil {
+		err = cd.DevInitBillList(query.Currency)
+	}
+	cd.DevError, cd.DevReply = common.CheckError(err)
+	return cd.RunValidatorStore(common.CmdInitValidator)
+}
+func (cd *CoinDriver) DoValidate(name string, query *common.ValidatorQuery) error {
+	err := cd.DevEnableBills(query.Currency, query.Target)
+	if err == nil {
+		err = cd.DevStatus()
+	}
+	cd.DevError, cd.DevReply = common.CheckError(err)
+	return cd.RunValidatorStore(common.CmdDoValidate)
+}
+func (cd *CoinDriver) NoteAccept(name string, query *common.ValidatorQuery) error {
+	err := cd.DevNoteAccept()
+	cd.DevError, cd.DevReply = common.CheckError(err)
+	return err
+}
+func (cd *CoinDriver) NoteReturn(name string, query *common.ValidatorQuery) error {
+	err := cd.DevNoteReturn()
+	cd.DevError, cd.DevReply = common.CheckError(err)
+	return err
+}
+func (cd *CoinDriver) StopValidate(name string, query *common.ValidatorQuery) error {
+	err := cd.DevDisableBills()
+	if err == nil {
+		err = cd.DevStatus()
+	}
+	cd.DevError, cd.DevReply = common.CheckError(err)
+	return cd.RunValidatorStore(common.CmdStopValidate)
+}
+func (cd *CoinDriver) CheckValidator(name string, query *common.ValidatorQuery) error {
+	err := cd.DevCheckBatch()
+	cd.DevError, cd.DevReply = common.CheckError(err)
+	return cd.RunValidatorStore(common.CmdCheckValidator)
+}
+func (cd *CoinDriver) ClearValidator(name string, query *common.ValidatorQuery) error {
+	err := cd.DevClearBatch()
+	if err == nil {
+		err = cd.DevCheckBatch()
+	}
+	cd.DevError, cd.DevReply = common.CheckError(err)
+	return cd.RunValidatorStore(common.CmdClearValidator)
+}
@@ -0,0 +1,282 @@
+package cctalk
+
+import (
+	"fmt"
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/config"
+	"github.com/iftsoft/device/driver/validator"
+)
+
+const coinAcceptorAddr byte = 2 // Default address of coin acceptor
+
+type CoinEngine struct {
+	validator.CashEngine
+	protocol *CctalkProtocol
+	coins    *CoinTable
+	event    byte
+	enabled  bool
+}
+
+func (ce *CoinEngine) initEngine(cfg *config.DeviceConfig) *CoinEngine {
+	ce.InitCashEngine(cfg)
+	ce.protocol = GetCctalkProtocol(ce.GetLinkerConfig(), coinAcceptorAddr)
+	return ce
+}
+
+// Currency by config or the first one of coin table
+func (ce *CoinEngine) getDefaultCurrency() common.DevCurrency {
+	if curr := ce.GetValidatorConfig().CurrCode; curr != common.CurrencyNOT {
+		return curr
+	}
+	if ce.coins != nil {
+		for _, coin := range ce.coins {
+			if coin != nil {
+				return coin.Currency
+			}
+		}
+	}
+	return common.CurrencyNOT
+}
+
+// Read coin identifiers of all positions and rebuild note tables
+func (ce *CoinEngine) loadCoinTable() error {
+	coins := &CoinTable{}
+	for i := range coins {
+		coinId, err := ce.protocol.RequestCoinId(i + 1)
+		if err != nil {
+			return err
+		}
+		coin, err := parseCoinId(i+1, coinId, ce.GetValidatorConfig().CurrCode)
+		if err != nil {
+			ce.Log.Debug("CoinEngine skip position %d: %s", i+1, err)
+			continue
+		}
+		coins[i] = coin
+	}
+	ce.Log.Debug(coins.String())
+	tables := validator.NoteTables{}
+	for _, coin := range coins {
+		if coin != nil && tables[coin.Currency] == nil {
+			tables[coin.Currency] = coins.GetNoteList(coin.Currency)
+		}
+	}
+	if len(tables) == 0 {
+		return common.NewError(common.DevErrorNoCurrency, "no coins are programmed")
+	}
+	ce.coins, ce.NoteTables = coins, tables
+	return nil
+}
+
+// Reset acceptor, its event counter goes to zero
+func (ce *CoinEngine) resetAcceptor() error {
+	err := ce.protocol.ResetDevice()
+	if err == nil {
+		ce.enabled = false
+		ce.event, _, err = ce.protocol.ReadBufferedCredit()
+	}
+	return err
+}
+
+func (ce *CoinEngine) setEnabled(enable bool) error {
+	err := ce.protocol.ModifyMasterInhibit(enable)
+	if err == nil {
+		ce.enabled = enable
+		if enable {
+			_ = ce.RunStateChanged(common.DevStateWaiting)
+		} else {
+			_ = ce.RunStateChanged(common.DevStateStandby)
+		}
+	}
+	return err
+}
+
+////////////////////////////////////////////////////////////////
+
+func (ce *CoinEngine) DevStartup() error {
+	err := ce.protocol.OpenLink()
+	if err == nil {
+		err = ce.protocol.SimplePoll()
+	}
+	if err == nil {
+		var ident *CctalkIdent
+		ident, err = ce.protocol.Identification()
+		ce.Log.Info("ccTalk coin acceptor %s", ident.String())
+	}
+	if err == nil {
+		err = ce.resetAcceptor()
+	}
+	if err == nil {
+		err = ce.loadCoinTable()
+	}
+	if err == nil {
+		err = ce.DevCheckBatch()
+	}
+	ce.Accept.Currency = ce.getDefaultCurrency()
+	return err
+}
+
+func (ce *CoinEngine) DevCleanup() error {
+	if ce.enabled {
+		_ = ce.protocol.ModifyMasterInhibit(false)
+	}
+	return ce.protocol.CloseLink()
+}
+
+func (ce *CoinEngine) DevReset() error {
+	return ce.resetAcceptor()
+}
+
+func (ce *CoinEngine) DevStatus() error {
+	return ce.protocol.SimplePoll()
+}
+
+func (ce *CoinEngine) DevEnableBills(curr common.DevCurrency, target common.DevAmount) error {
+	if curr == common.CurrencyNOT {
+		curr = ce.Accept.Currency
+	}
+	ce.Log.Debug("CoinEngine Set currency %d - %s, target %s", curr, curr.String(), target.Format(curr))
+	if ce.coins == nil {
+		return common.NewError(common.DevErrorNotInitialized, "coin table is not loaded")
+	}
+	mask := ce.coins.GetMask(curr, ce.GetValidatorConfig().NotesMask)
+	if mask == 0 {
+		return common.NewError(common.DevErrorNoCurrency,
+			fmt.Sprintf("no enabled coins for currency %d (%s)", curr, curr.IsoCode()))
+	}
+	err := ce.CheckSession(target)
+	if err == nil {
+		err = ce.protocol.ModifyInhibitStatus(mask)
+	}
+	if err == nil {
+		err = ce.setEnabled(true)
+	}
+	if err == nil {
+		ce.StartSession(curr, target)
+	}
+	return err
+}
+
+func (ce *CoinEngine) DevDisableBills() error {
+	return ce.setEnabled(false)
+}
+
+func (ce *CoinEngine) DevInitBillList(curr common.DevCurrency) error {
+	err := ce.loadCoinTable()
+	if err != nil {
+		return err
+	}
+	if curr == common.CurrencyNOT {
+		curr = ce.getDefaultCurrency()
+	}
+	return ce.InitNoteList(curr)
+}
+
+// Coins have no escrow, they are credited as soon as they pass the acceptor
+func (ce *CoinEngine) DevNoteAccept() error {
+	return common.NewError(common.DevErrorNotAccepted, "coin acceptor has no escrow")
+}
+
+func (ce *CoinEngine) DevNoteReturn() error {
+	return common.NewError(common.DevErrorNotAccepted, "coin acceptor has no escrow")
+}
+
+////////////////////////////////////////////////////////////////
+
+// Read credit buffer and process new events
+func (ce *CoinEngine) pollAcceptor() {
+	if !ce.protocol.IsOpen() {
+		return
+	}
+	counter, events, err := ce.protocol.ReadBufferedCredit()
+	if err != nil {
+		code, text := common.CheckError(err)
+		if code != ce.DevError {
+			_ = ce.RunExecuteError(code, text)
+		}
+		return
+	}
+	// Link is restored after timeout
+	if ce.DevError == common.DevErrorLinkerTimeout {
+		ce.DevError = common.DevErrorSuccess
+	}
+	ce.processEvents(counter, events)
+}
+
+func (ce *CoinEngine) processEvents(counter byte, events []CreditEvent) {
+	if counter == ce.event {
+		return
+	}
+	if counter == 0 {
+		// Acceptor was powered up, inhibits are reset to defaults
+		ce.Log.Warn("CoinEngine acceptor event counter is reset")
+		ce.event = 0
+		if ce.enabled {
+			_ = ce.DevEnableBills(ce.Session.Currency, ce.Session.Target)
+		}
+		return
+	}
+	count := getEventCount(ce.event, counter)
+	ce.event = counter
+	if count > len(events) {
+		_ = ce.RunExecuteError(common.DevErrorCounterFault,
+			fmt.Sprintf("%d credit events are lost", count-len(events)))
+		count = len(events)
+	}
+	// The newest event goes first in credit buffer
+	for i := count - 1; i >= 0; i-- {
+		ce.processEvent(events[i])
+	}
+}
+
+// Event counter goes from 255 to 1, zero is used after reset only
+func getEventCount(prev, next byte) int {
+	if next >= prev {
+		return int(next) - int(prev)
+	}
+	return int(next) + 255 - int(prev)
+}
+
+func (ce *CoinEngine) processEvent(ev CreditEvent) {
+	if ev.Credit == 0 {
+		event := coinEvent{code: ev.Code}
+		if event.code == evNull {
+			return
+		}
+		ce.Log.Debug("CoinEngine event %s", event.String())
+		if code := event.GetError(); code != common.DevErrorSuccess {
+			_ = ce.RunExecuteError(code, event.String())
+		}
+		return
+	}
+	coin := ce.coins.GetCoin(ev.Credit)
+	if coin == nil {
+		ce.Log.Warn("CoinEngine unknown coin position %d is credited", ev.Credit)
+		return
+	}
+	ce.onCoinCredited(coin)
+}
+
+func (ce *CoinEngine) onCoinCredited(coin *CoinType) {
+	ce.CreditCoin(coin.Currency, coin.Nominal)
+	ce.checkSession()
+}
+
+// Stop accepting when session target is reached or cash box is full
+func (ce *CoinEngine) checkSession() {
+	if !ce.enabled {
+		return
+	}
+	over, err := ce.IsSessionOver()
+	if !over {
+		return
+	}
+	_ = ce.setEnabled(false)
+	if err != nil {
+		code, text := common.CheckError(err)
+		_ = ce.RunStateChanged(common.DevStateCashStackerFull)
+		_ = ce.RunExecuteError(code, text)
+	} else {
+		ce.Log.Debug("CoinEngine session target %s is reached",
+			ce.Session.Target.Format(ce.Session.Currency))
+	}
+}
@@ -0,0 +1,126 @@
+package cctalk
+
+import (
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/config"
+	"github.com/iftsoft/device/linker/linkertest"
+	"testing"
+)
+
+func TestParseCoinId(t *testing.T) {
+	tests := []struct {
+		coinId  string
+		curr    common.DevCurrency
+		nominal common.DevAmount
+	}{
+		{"UA050A", common.CurrencyUAH, 50},
+		{"EU200A", common.CurrencyEUR, 200},
+		{"GB2K5A", common.CurrencyGBP, 2500},
+		{"US025A", common.CurrencyUSD, 25},
+		{"XX010A", common.CurrencyMDL, 10},
+		{"......", common.CurrencyNOT, 0},
+		{"UA050", common.CurrencyNOT, 0},
+		{"UA000A", common.CurrencyNOT, 0},
+		{"UA1.5A", common.CurrencyNOT, 0},
+		{"UAX10A", common.CurrencyNOT, 0},
+	}
+	for _, tt := range tests {
+		coin, err := parseCoinId(1, tt.coinId, common.CurrencyMDL)
+		if tt.curr == common.CurrencyNOT {
+			if err == nil {
+				t.Errorf("bad coin id '%s' is parsed to %s", tt.coinId, coin)
+			}
+			continue
+		}
+		if err != nil || coin.Currency != tt.curr || coin.Nominal != tt.nominal {
+			t.Errorf("coin id '%s' is parsed to %s, want %d %s: %v", tt.coinId, coin, tt.nominal, tt.curr.IsoCode(), err)
+		}
+	}
+	if _, err := parseCoinId(1, "XX010A", common.CurrencyNOT); err == nil {
+		t.Error("coin of unknown country is parsed without default currency")
+	}
+}
+
+func TestGetEventCount(t *testing.T) {
+	tests := []struct {
+		prev, next byte
+		count      int
+	}{
+		{0, 1, 1},
+		{1, 4, 3},
+		{254, 255, 1},
+		{255, 1, 1},
+		{253, 2, 4},
+	}
+	for _, tt := range tests {
+		if count := getEventCount(tt.prev, tt.next); count != tt.count {
+			t.Errorf("events from %d to %d: %d, want %d", tt.prev, tt.next, count, tt.count)
+		}
+	}
+}
+
+// Read of credit buffer that is answered by event counter and events, the newest goes first
+func credit(counter byte, events ...byte) []*linkertest.Step {
+	reply := make([]byte, 2+2*creditEvents)
+	reply[1] = counter
+	copy(reply[2:], events)
+	tx, _ := newCctalkFramer(nil, coinAcceptorAddr).Encode([]byte{hdrReadBufferedCredit})
+	rx, _ := newCctalkFramer(nil, cctalkHostAddr).Encode(reply)
+	return []*linkertest.Step{linkertest.TX(tx...), linkertest.RX(rx...)}
+}
+
+func newTestEngine(t *testing.T, counter byte, steps ...[]*linkertest.Step) (*CoinEngine, *linkertest.ValidatorCallback) {
+	t.Helper()
+	path := linkertest.WriteTrace(t, linkertest.RawFramer{}, steps...)
+	ce := (&CoinEngine{}).initEngine(&config.DeviceConfig{Linker: &config.LinkerConfig{Replay: path}})
+	cb := &linkertest.ValidatorCallback{}
+	ce.CbValidator = cb
+	ce.coins = &CoinTable{}
+	for i, coinId := range []string{"UA100A", "UA2K5A"} {
+		coin, err := parseCoinId(i+1, coinId, common.CurrencyNOT)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ce.coins[i] = coin
+	}
+	ce.event = counter
+	ce.StartSession(common.CurrencyUAH, 0)
+	linkertest.OpenLink(t, ce.protocol.HalfDuplex)
+	return ce, cb
+}
+
+func TestCoinEngineCredits(t *testing.T) {
+	ce, cb := newTestEngine(t, 1,
+		credit(3, 2, 0, 1, 0, 0, 0),
+		credit(3, 2, 0, 1, 0, 0, 0),
+		// Rejected coin is not an error
+		credit(4, 0, evRejectCoin, 2, 0, 1, 0),
+		// Unknown position is skipped
+		credit(5, 3, 0, 0, evRejectCoin, 2, 0),
+	)
+	for i := 0; i < 4; i++ {
+		ce.pollAcceptor()
+	}
+	linkertest.CheckEvents(t, &ce.BaseEngine, cb,
+		"accepted 100 Accept", "stored 100", "accepted 2500 Accept", "stored 2500")
+	if ce.event != 5 || ce.Session.Total != 2600 {
+		t.Errorf("event counter %d, session total %d", ce.event, ce.Session.Total)
+	}
+}
+
+func TestCoinEngineErrors(t *testing.T) {
+	ce, cb := newTestEngine(t, 1,
+		credit(2, 0, evCosActivated),
+		credit(254, 1, 0),
+	)
+	ce.pollAcceptor()
+	if ce.DevError != common.DevErrorSecurityFault {
+		t.Errorf("engine error %s for coin on string event", ce.DevError)
+	}
+	// Events that do not fit credit buffer are lost, the rest is processed
+	ce.event = 240
+	ce.pollAcceptor()
+	if ce.DevError != common.DevErrorCounterFault || len(cb.Events) != 2 {
+		t.Errorf("engine error %s, events %q for lost events", ce.DevError, cb.Events)
+	}
+}
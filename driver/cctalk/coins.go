@@ -0,0 +1,158 @@
+package cctalk
+
+import (
+	"fmt"
+	"github.com/iftsoft/device/common"
+	"strconv"
+	"strings"
+)
+
+// CoinType describes the coin position of the acceptor
+type CoinType struct {
+	Position int
+	CoinId   string
+	Currency common.DevCurrency
+	Nominal  common.DevAmount
+}
+
+func (ct *CoinType) String() string {
+	if ct == nil {
+		return ""
+	}
+	return fmt.Sprintf("Position %2d: %9s %s (%s)",
+		ct.Position, ct.Nominal.Format(ct.Currency), ct.Currency.IsoCode(), ct.CoinId)
+}
+
+type CoinTable [coinPositions]*CoinType
+
+func (ct *CoinTable) String() string {
+	str := "ccTalk coin table:"
+	for _, coin := range ct {
+		if coin != nil {
+			str += "\n    " + coin.String()
+		}
+	}
+	return str
+}
+
+// GetCoin returns coin type of the position 1..16 or nil
+func (ct *CoinTable) GetCoin(position byte) *CoinType {
+	if position < 1 || int(position) > coinPositions {
+		return nil
+	}
+	return ct[position-1]
+}
+
+// GetNoteList returns unique nominals of the currency in position order
+func (ct *CoinTable) GetNoteList(curr common.DevCurrency) common.ValidNoteList {
+	list := make(common.ValidNoteList, 0)
+	for _, coin := range ct {
+		if coin == nil || coin.Currency != curr {
+			continue
+		}
+		dup := false
+		for _, note := range list {
+			dup = dup || note.Nominal == coin.Nominal
+		}
+		if !dup {
+			list = append(list, &common.ValidatorNote{Currency: curr, Nominal: coin.Nominal})
+		}
+	}
+	return list
+}
+
+// GetMask returns inhibit mask of the currency filtered by notes mask, zero notes mask enables all
+func (ct *CoinTable) GetMask(curr common.DevCurrency, notesMask int64) uint16 {
+	var mask uint16
+	for i, coin := range ct {
+		if coin == nil || coin.Currency != curr {
+			continue
+		}
+		if notesMask == 0 || notesMask&(1<<uint(i)) != 0 {
+			mask |= 1 << uint(i)
+		}
+	}
+	return mask
+}
+
+// Currencies of ccTalk country codes
+var countryCurrency = map[string]common.DevCurrency{
+	"EU": common.CurrencyEUR,
+	"GB": common.CurrencyGBP,
+	"US": common.CurrencyUSD,
+	"CA": common.CurrencyCAD,
+	"AU": common.CurrencyAUD,
+	"NZ": common.CurrencyNZD,
+	"CH": common.CurrencyCHF,
+	"SE": common.CurrencySEK,
+	"NO": common.CurrencyNOK,
+	"DK": common.CurrencyDKK,
+	"PL": common.CurrencyPLN,
+	"CZ": common.CurrencyCZK,
+	"HU": common.CurrencyHUF,
+	"RO": common.CurrencyRON,
+	"TR": common.CurrencyTRY,
+	"UA": common.CurrencyUAH,
+	"RU": common.CurrencyRUB,
+	"KZ": common.CurrencyKZT,
+	"MD": common.CurrencyMDL,
+	"GE": common.CurrencyGEL,
+	"ZA": common.CurrencyZAR,
+}
+
+// Coin identifier: country code (2 chars), value (3 chars), issue code (1 char).
+// Value is in minor units, 'K' and 'M' are multipliers in place of decimal point ("2K5").
+func parseCoinId(position int, coinId string, defCurr common.DevCurrency) (*CoinType, error) {
+	if len(coinId) != 6 || coinId[0:2] == ".." {
+		return nil, fmt.Errorf("coin id '%s' is not programmed", coinId)
+	}
+	curr, ok := countryCurrency[coinId[0:2]]
+	if !ok {
+		curr = defCurr
+	}
+	if curr == common.CurrencyNOT {
+		return nil, fmt.Errorf("unknown country of coin id '%s'", coinId)
+	}
+	value, err := parseCoinValue(coinId[2:5])
+	if err != nil || value <= 0 {
+		return nil, fmt.Errorf("bad value of coin id '%s'", coinId)
+	}
+	coin := &CoinType{
+		Position: position,
+		CoinId:   coinId,
+		Currency: curr,
+		Nominal:  value,
+	}
+	return coin, nil
+}
+
+func parseCoinValue(text string) (common.DevAmount, error) {
+	mult := int64(1)
+	point := strings.IndexAny(text, ".KM")
+	if point < 0 {
+		value, err := strconv.ParseInt(text, 10, 64)
+		return common.DevAmount(value), err
+	}
+	switch text[point] {
+	case 'K':
+		mult = 1000
+	case 'M':
+		mult = 1000000
+	}
+	whole, frac := text[:point], text[point+1:]
+	if whole == "" {
+		whole = "0"
+	}
+	value, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	div := int64(1)
+	for range frac {
+		div *= 10
+	}
+	if value*mult%div != 0 {
+		return 0, fmt.Errorf("value %s is less than minor unit", text)
+	}
+	return common.DevAmount(value * mult / div), nil
+}
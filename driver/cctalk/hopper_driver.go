@@ -0,0 +1,124 @@
+package cctalk
+
+import (
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/driver"
+	"time"
+)
+
+type HopperDriver struct {
+	HopperEngine
+	begTime int64
+}
+
+func NewHopperDriver() *HopperDriver {
+	hd := HopperDriver{}
+	return &hd
+}
+
+// Implementation of DeviceDriver interface
+func (hd *HopperDriver) InitDevice(context *driver.Context) error {
+	hd.initEngine(context.Config)
+	hd.DevName = context.DevName
+	hd.begTime = time.Now().Unix()
+	hd.Log.Debug("HopperDriver run cmd:%s", "InitDevice")
+
+	mask := common.ScopeFlagSystem
+	if device, ok := context.Manager.(common.DeviceCallback); ok {
+		hd.CbDevice = device
+		mask |= common.ScopeFlagDevice
+	}
+	if dispenser, ok := context.Manager.(common.DispenserCallback); ok {
+		hd.CbDispenser = dispenser
+		mask |= common.ScopeFlagDispenser
+	}
+	if context.Greeting != nil {
+		context.Greeting.DevType = common.DevTypeCoinDispenser
+		context.Greeting.Required = mask
+	}
+	return nil
+}
+
+func (hd *HopperDriver) StartDevice(query *common.SystemConfig) error {
+	hd.Log.Debug("HopperDriver run cmd:%s", "StartDeviceLoop")
+	if hd.config != nil && query != nil {
+		hd.config.OverwriteConfig(query)
+	}
+	return hd.DevStartup()
+}
+func (hd *HopperDriver) DeviceTimer(unix int64) error {
+	hd.Log.Trace("HopperDriver run cmd:%s", "DeviceTimer")
+	hd.pollHopper()
+	return nil
+}
+func (hd *HopperDriver) StopDevice() error {
+	hd.Log.Debug("HopperDriver run cmd:%s", "StopDeviceLoop")
+	return hd.DevCleanup()
+}
+func (hd *HopperDriver) CheckDevice(metrics *common.SystemMetrics) error {
+	hd.Log.Debug("HopperDriver run cmd:%s", "CheckDevice")
+	if metrics != nil {
+		metrics.Uptime = time.Now().Unix() - hd.begTime
+		metrics.DevState = hd.DevState
+		metrics.DevError = hd.DevError
+		hd.protocol.ReportMetrics(metrics)
+	}
+	return nil
+}
+
+// Implementation of common.DeviceManager
+//
+func (hd *HopperDriver) Cancel(name string, query *common.DeviceQuery) error {
+	err := hd.DevStopDispense()
+	hd.DevError, hd.DevReply = common.CheckError(err)
+	return hd.RunDeviceReply(common.CmdDeviceCancel)
+}
+func (hd *HopperDriver) Reset(name string, query *common.DeviceQuery) error {
+	err := hd.DevReset()
+	hd.DevError, hd.DevReply = common.CheckError(err)
+	return hd.RunDeviceReply(common.CmdDeviceReset)
+}
+func (hd *HopperDriver) Status(name string, query *common.DeviceQuery) error {
+	err := hd.DevStatus()
+	hd.DevError, hd.DevReply = common.CheckError(err)
+	return hd.RunDeviceReply(common.CmdDeviceStatus)
+}
+func (hd *HopperDriver) RunAction(name string, query *common.DeviceQuery) error {
+	err := hd.DevEnableHopper(true)
+	if err == nil {
+		err = hd.DevStatus()
+	}
+	hd.DevError, hd.DevReply = common.CheckError(err)
+	return hd.RunDeviceReply(common.CmdRunAction)
+}
+func (hd *HopperDriver) StopAction(name string, query *common.DeviceQuery) error {
+	err := hd.DevStopDispense()
+	if err == nil {
+		err = hd.DevEnableHopper(false)
+	}
+	hd.DevError, hd.DevReply = common.CheckError(err)
+	return hd.RunDeviceReply(common.CmdStopAction)
+}
+
+// Implementation of common.DispenserManager
+//
+func (hd *HopperDriver) InitDispenser(name string, query *common.DispenserQuery) error {
+	err := hd.DevInitDispenser(query.Currency)
+	hd.DevError, hd.DevReply = common.CheckError(err)
+	return hd.RunDispenserStore(common.CmdInitDispenser)
+}
+func (hd *HopperDriver) DispenseCash(name string, query *common.DispenserQuery) error {
+	err := hd.DevDispenseCash(query.Currency, query.Amount)
+	hd.DevError, hd.DevReply = common.CheckError(err)
+	return hd.RunDispenserStore(common.CmdDispenseCash)
+}
+func (hd *HopperDriver) StopDispense(name string, query *common.DispenserQuery) error {
+	err := hd.DevStopDispense()
+	hd.DevError, hd.DevReply = common.CheckError(err)
+	return hd.RunDispenserStore(common.CmdStopDispense)
+}
+func (hd *HopperDriver) CheckDispenser(name string, query *common.DispenserQuery) error {
+	err := hd.DevStatus()
+	hd.DevError, hd.DevReply = common.CheckError(err)
+	return hd.RunDispenserStore(common.CmdCheckDispenser)
+}
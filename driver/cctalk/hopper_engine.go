@@ -0,0 +1,261 @@
+package cctalk
+
+import (
+	"fmt"
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/config"
+	"github.com/iftsoft/device/core"
+	"github.com/iftsoft/device/driver/generic"
+)
+
+const (
+	hopperAddr     byte = 3   // Default address of payout hopper
+	hopperMaxCoins      = 255 // Coin count limit of one dispense command
+)
+
+type HopperEngine struct {
+	generic.BaseDispenser
+	config   *config.DeviceConfig
+	protocol *CctalkProtocol
+	serial   [3]byte
+	flags    hopperFlags
+	event    byte
+	payout   bool
+	stopped  bool
+	pending  common.DevCounter
+	request  common.DevAmount
+}
+
+func (he *HopperEngine) initEngine(cfg *config.DeviceConfig) *HopperEngine {
+	he.config = cfg
+	he.Log = core.GetLogAgent(core.LogLevelTrace, "Engine")
+	var lnkCfg *config.LinkerConfig
+	if cfg != nil {
+		lnkCfg = cfg.Linker
+	}
+	he.protocol = GetCctalkProtocol(lnkCfg, hopperAddr)
+	return he
+}
+
+func (he *HopperEngine) getDispenserConfig() *config.DispenserConfig {
+	if he.config != nil && he.config.Dispenser != nil {
+		return he.config.Dispenser
+	}
+	return config.GetDefaultDispenserConfig()
+}
+
+// Load currency and coin nominal of the hopper from config
+func (he *HopperEngine) loadCoinConfig(curr common.DevCurrency) error {
+	dspCfg := he.getDispenserConfig()
+	if curr == common.CurrencyNOT {
+		curr = dspCfg.CurrCode
+	}
+	if curr != dspCfg.CurrCode || common.GetCurrencyInfo(curr) == nil {
+		return common.NewError(common.DevErrorNoCurrency,
+			fmt.Sprintf("hopper has no coins of currency %d (%s)", curr, curr.IsoCode()))
+	}
+	nominal, err := common.ParseAmount(dspCfg.Nominal, curr)
+	if err != nil || nominal <= 0 {
+		return common.NewError(common.DevErrorConfigFault,
+			fmt.Sprintf("bad hopper coin nominal '%s'", dspCfg.Nominal))
+	}
+	he.Payout = common.DispenserPayout{Currency: curr, Nominal: nominal}
+	return nil
+}
+
+// Read hopper test register and report new faults
+func (he *HopperEngine) testHopper() error {
+	data, err := he.protocol.TestHopper()
+	if err != nil {
+		return err
+	}
+	flags := newHopperFlags(data)
+	if flags != he.flags {
+		he.Log.Debug("HopperEngine flags: %s", flags.String())
+	}
+	he.flags = flags
+	if code := flags.GetError(); code != common.DevErrorSuccess {
+		return common.NewError(code, flags.String())
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////////////
+
+func (he *HopperEngine) DevStartup() error {
+	err := he.protocol.OpenLink()
+	if err == nil {
+		err = he.protocol.SimplePoll()
+	}
+	if err == nil {
+		var ident *CctalkIdent
+		ident, err = he.protocol.Identification()
+		he.Log.Info("ccTalk hopper %s", ident.String())
+		he.serial = ident.SerialNumber
+	}
+	if err == nil {
+		err = he.loadCoinConfig(common.CurrencyNOT)
+	}
+	if err == nil {
+		err = he.testHopper()
+	}
+	if err == nil {
+		_ = he.RunStateChanged(common.DevStateStandby)
+	}
+	return err
+}
+
+func (he *HopperEngine) DevCleanup() error {
+	if he.payout {
+		_, _ = he.protocol.EmergencyStop()
+	}
+	return he.protocol.CloseLink()
+}
+
+func (he *HopperEngine) DevReset() error {
+	if he.payout {
+		return common.NewError(common.DevErrorExecuteFault, "payout is in progress")
+	}
+	err := he.protocol.ResetDevice()
+	if err == nil {
+		err = he.testHopper()
+	}
+	return err
+}
+
+func (he *HopperEngine) DevStatus() error {
+	return he.testHopper()
+}
+
+func (he *HopperEngine) DevInitDispenser(curr common.DevCurrency) error {
+	err := he.DevReset()
+	if err == nil {
+		err = he.loadCoinConfig(curr)
+	}
+	return err
+}
+
+func (he *HopperEngine) DevEnableHopper(enable bool) error {
+	return he.protocol.EnableHopper(enable)
+}
+
+func (he *HopperEngine) DevDispenseCash(curr common.DevCurrency, amount common.DevAmount) error {
+	if he.payout {
+		return common.NewError(common.DevErrorExecuteFault, "payout is in progress")
+	}
+	if curr == common.CurrencyNOT {
+		curr = he.Payout.Currency
+	}
+	nominal := he.Payout.Nominal
+	if curr != he.Payout.Currency || nominal <= 0 {
+		return common.NewError(common.DevErrorNoCurrency,
+			fmt.Sprintf("hopper has no coins of currency %d (%s)", curr, curr.IsoCode()))
+	}
+	if amount <= 0 || amount%nominal != 0 {
+		return common.NewError(common.DevErrorBadArgument,
+			fmt.Sprintf("amount %s is not a multiple of coin %s", amount.Format(curr), nominal.Format(curr)))
+	}
+	err := he.testHopper()
+	if err == nil {
+		err = he.protocol.EnableHopper(true)
+	}
+	if err != nil {
+		return err
+	}
+	he.Payout = common.DispenserPayout{Currency: curr, Nominal: nominal}
+	he.request = amount
+	he.pending = common.DevCounter(amount / nominal)
+	he.stopped = false
+	err = he.dispenseCoins()
+	if err == nil {
+		_ = he.RunStateChanged(common.DevStateDispDispensing)
+	}
+	return err
+}
+
+func (he *HopperEngine) DevStopDispense() error {
+	if !he.payout {
+		return nil
+	}
+	unpaid, err := he.protocol.EmergencyStop()
+	if err == nil {
+		he.Log.Debug("HopperEngine payout is stopped, %d coins are not paid", unpaid)
+		he.stopped = true
+		he.pending = 0
+	}
+	return err
+}
+
+// Send dispense command for the next portion of coins
+func (he *HopperEngine) dispenseCoins() error {
+	status, err := he.protocol.RequestHopperStatus()
+	if err != nil {
+		return err
+	}
+	count := he.pending
+	if count > hopperMaxCoins {
+		count = hopperMaxCoins
+	}
+	err = he.protocol.DispenseHopperCoins(he.serial, byte(count))
+	if err == nil {
+		he.event = status.Event
+		he.pending -= count
+		he.payout = true
+	}
+	return err
+}
+
+////////////////////////////////////////////////////////////////
+
+// Read hopper status while payout is in progress
+func (he *HopperEngine) pollHopper() {
+	if !he.payout || !he.protocol.IsOpen() {
+		return
+	}
+	status, err := he.protocol.RequestHopperStatus()
+	if err != nil {
+		code, text := common.CheckError(err)
+		if code != he.DevError {
+			_ = he.RunExecuteError(code, text)
+		}
+		return
+	}
+	// Dispense command changes event counter, payout is over when no coins remain
+	if status.Event == he.event || status.Remaining > 0 {
+		return
+	}
+	he.Log.Debug("HopperEngine payout status %s", status.String())
+	he.Payout.Count += common.DevCounter(status.Paid)
+	he.Payout.Amount = he.Payout.Nominal * common.DevAmount(he.Payout.Count)
+	if status.Unpaid == 0 && he.pending > 0 {
+		if err = he.dispenseCoins(); err == nil {
+			return
+		}
+		code, text := common.CheckError(err)
+		he.finishPayout(code, text)
+		return
+	}
+	code, reason := common.DevErrorSuccess, ""
+	if status.Unpaid > 0 && !he.stopped {
+		code, reason = common.DevErrorCantDispense, fmt.Sprintf("%d coins are not paid", status.Unpaid)
+		if he.testHopper() != nil {
+			code, reason = he.flags.GetError(), he.flags.String()
+		}
+	}
+	he.finishPayout(code, reason)
+}
+
+func (he *HopperEngine) finishPayout(code common.EnumDevError, reason string) {
+	he.payout = false
+	he.pending = 0
+	he.Payout.Unpaid = he.request - he.Payout.Amount
+	_ = he.protocol.EnableHopper(false)
+	_ = he.RunStateChanged(common.DevStateDispDispensed)
+	if he.Payout.Count > 0 {
+		_ = he.RunActionPrompt(common.DevPromptDispTakeCoin)
+	}
+	if code != common.DevErrorSuccess {
+		_ = he.RunExecuteError(code, reason)
+	}
+	_ = he.RunCashDispensed(&he.Payout)
+}
@@ -0,0 +1,120 @@
+package cctalk
+
+import (
+	"fmt"
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/config"
+	"github.com/iftsoft/device/linker"
+	"github.com/iftsoft/device/linker/checksum"
+)
+
+const (
+	cctalkHostAddr byte = 1   // Address of the host on the bus
+	cctalkMinSize       = 5   // DEST, LNG, SRC, HEADER, CHK
+	cctalkMaxData       = 252 // Data byte count limit
+
+	checksumSimple = "simple" // Sum of all frame bytes is zero
+	checksumCRC16  = "crc16"  // CRC-16/XModem, LSB goes in place of source address
+)
+
+// cctalkFramer takes header and data of the message as payload.
+// Simple checksum or CRC16 is selected by config, echo of own messages on single wire bus is skipped.
+type cctalkFramer struct {
+	address byte
+	useCRC  bool
+}
+
+func newCctalkFramer(cfg *config.LinkerConfig, address byte) *cctalkFramer {
+	f := &cctalkFramer{address: address}
+	if cfg != nil {
+		if cfg.Address != 0 {
+			f.address = cfg.Address
+		}
+		f.useCRC = cfg.Checksum == checksumCRC16
+	}
+	return f
+}
+
+////////////////////////////////////////////////////////////////
+// Data flow:  DEST, LNG, SRC, HEADER, []DATA, CHK
+// LNG is the data byte count, CRC16 mode puts CRC LSB to SRC and CRC MSB to CHK
+
+func (f *cctalkFramer) Encode(data []byte) ([]byte, error) {
+	if len(data) > cctalkMaxData+1 {
+		return nil, common.NewError(common.DevErrorProtocolFault, "frame is too long")
+	}
+	pack := []byte{f.address, byte(len(data) - 1), cctalkHostAddr}
+	pack = append(pack, data...)
+	if f.useCRC {
+		crc := calcFrameCRC(pack)
+		pack[2] = byte(crc)
+		pack = append(pack, byte(crc>>8))
+	} else {
+		pack = append(pack, calcSimpleChecksum(pack))
+	}
+	return pack, nil
+}
+
+func (f *cctalkFramer) Decode(dump []byte) ([]byte, int, error) {
+	// Checking header size
+	size := len(dump)
+	if size < 2 {
+		return nil, 0, nil
+	}
+	// Checking full size
+	sz := int(dump[1]) + cctalkMinSize
+	if size < sz {
+		return nil, 0, nil
+	}
+	// Checking checksum, mismatch shifts frame search by one byte
+	if f.useCRC {
+		crc1 := calcFrameCRC(dump[0 : sz-1])
+		crc2 := uint16(dump[2]) | uint16(dump[sz-1])<<8
+		if crc1 != crc2 {
+			return nil, 1, common.NewError(common.DevErrorProtocolFault,
+				fmt.Sprintf("CRC mismatch - come:%04X, calc:%04X", crc2, crc1))
+		}
+	} else if sum := calcSimpleChecksum(dump[0:sz]); sum != 0 {
+		return nil, 1, common.NewError(common.DevErrorProtocolFault,
+			fmt.Sprintf("checksum mismatch - sum:%02X", sum))
+	}
+	// Single wire bus returns our own message as echo
+	if dump[0] != cctalkHostAddr {
+		return nil, sz, nil
+	}
+	data := make([]byte, sz-4)
+	data[0] = dump[3]
+	copy(data[1:], dump[4:sz-1])
+	return data, sz, nil
+}
+
+// Device answers at once, commands are not repeated as they may change device state
+func getCctalkOptions() *linker.HalfDuplexOptions {
+	opts := linker.GetDefaultHalfDuplexOptions()
+	opts.Retries = 0
+	opts.Timeout = 200
+	return opts
+}
+
+////////////////////////////////////////////////////////////////
+
+// Simple checksum makes the sum of all frame bytes zero
+func calcSimpleChecksum(data []byte) byte {
+	var sum byte
+	for _, b := range data {
+		sum += b
+	}
+	return -sum
+}
+
+var frameCRC, _ = checksum.Get("crc16-xmodem")
+
+// CRC16 is calculated over DEST, LNG, HEADER and DATA bytes
+func calcFrameCRC(pack []byte) uint16 {
+	h := frameCRC.New()
+	_, _ = h.Write(pack[:2])
+	if len(pack) > 3 {
+		_, _ = h.Write(pack[3:])
+	}
+	return uint16(h.Sum32())
+}
@@ -0,0 +1,75 @@
+package cctalk
+
+import (
+	"bytes"
+	"github.com/iftsoft/device/config"
+	"testing"
+)
+
+func TestCctalkFramer(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  *config.LinkerConfig
+		addr byte
+		data []byte
+		pack []byte
+	}{
+		// Simple poll of coin acceptor and hopper of ccTalk specification
+		{"simple poll", nil, 2, []byte{hdrSimplePoll}, []byte{0x02, 0x00, 0x01, 0xFE, 0xFF}},
+		{"address by config", &config.LinkerConfig{Address: 3}, 2, []byte{hdrSimplePoll}, []byte{0x03, 0x00, 0x01, 0xFE, 0xFE}},
+		{"inhibit status", nil, 2, []byte{hdrModifyInhibitStatus, 0xFF, 0x00}, []byte{0x02, 0x02, 0x01, 0xE7, 0xFF, 0x00, 0x15}},
+		// CRC16 of 28 00 FE is 21B6, LSB goes to source address
+		{"CRC16 poll", &config.LinkerConfig{Checksum: checksumCRC16}, 40, []byte{hdrSimplePoll}, []byte{0x28, 0x00, 0xB6, 0xFE, 0x21}},
+	}
+	for _, tt := range tests {
+		framer := newCctalkFramer(tt.cfg, tt.addr)
+		pack, err := framer.Encode(tt.data)
+		if err != nil || !bytes.Equal(pack, tt.pack) {
+			t.Errorf("%s: frame % X, want % X: %v", tt.name, pack, tt.pack, err)
+		}
+		// Own message comes back as echo on single wire bus and is skipped
+		if back, count, err := framer.Decode(pack); back != nil || count != len(pack) || err != nil {
+			t.Errorf("%s: echo is decoded to % X, %d: %v", tt.name, back, count, err)
+		}
+	}
+	if _, err := newCctalkFramer(nil, 2).Encode(make([]byte, cctalkMaxData+2)); err == nil {
+		t.Error("too long frame is encoded")
+	}
+}
+
+func TestCctalkDecode(t *testing.T) {
+	framer := newCctalkFramer(nil, 2)
+	// ACK of coin acceptor to host
+	reply := []byte{0x01, 0x00, 0x02, 0x00, 0xFD}
+	credit := []byte{0x01, 0x03, 0x02, 0x00, 0x05, 0x01, 0x00, 0xF4}
+	tests := []struct {
+		name  string
+		dump  []byte
+		data  []byte
+		count int
+		bad   bool
+	}{
+		{"reply", reply, []byte{hdrReply}, 5, false},
+		{"reply with data", credit, []byte{hdrReply, 0x05, 0x01, 0x00}, 8, false},
+		{"partial", credit[:7], nil, 0, false},
+		{"size only", reply[:1], nil, 0, false},
+		{"bad checksum", []byte{0x01, 0x00, 0x02, 0x00, 0xFE}, nil, 1, true},
+	}
+	for _, tt := range tests {
+		data, count, err := framer.Decode(tt.dump)
+		if !bytes.Equal(data, tt.data) || count != tt.count || (err != nil) != tt.bad {
+			t.Errorf("%s: % X, %d: %v", tt.name, data, count, err)
+		}
+	}
+
+	crc := newCctalkFramer(&config.LinkerConfig{Checksum: checksumCRC16}, 40)
+	pack, _ := crc.Encode([]byte{hdrReply, 0x41})
+	pack[0] = cctalkHostAddr
+	if _, _, err := crc.Decode(pack); err == nil {
+		t.Error("CRC of changed frame is not checked")
+	}
+	pack, _ = newCctalkFramer(&config.LinkerConfig{Checksum: checksumCRC16}, cctalkHostAddr).Encode([]byte{hdrReply, 0x41})
+	if data, count, err := crc.Decode(pack); err != nil || count != len(pack) || !bytes.Equal(data, []byte{hdrReply, 0x41}) {
+		t.Errorf("CRC16 reply % X, %d: %v", data, count, err)
+	}
+}
@@ -0,0 +1,260 @@
+package cctalk
+
+import (
+	"fmt"
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/config"
+	"github.com/iftsoft/device/core"
+	"github.com/iftsoft/device/linker"
+	"strings"
+	"sync"
+)
+
+// ccTalk message headers
+const (
+	hdrReply                byte = 0
+	hdrResetDevice          byte = 1
+	hdrNak                  byte = 5
+	hdrBusy                 byte = 6
+	hdrTestHopper           byte = 163
+	hdrEnableHopper         byte = 164
+	hdrRequestHopperStatus  byte = 166
+	hdrDispenseHopperCoins  byte = 167
+	hdrEmergencyStop        byte = 172
+	hdrRequestCoinId        byte = 184
+	hdrModifyMasterInhibit  byte = 228
+	hdrReadBufferedCredit   byte = 229
+	hdrModifyInhibitStatus  byte = 231
+	hdrRequestSerialNumber  byte = 242
+	hdrRequestProductCode   byte = 244
+	hdrRequestEquipCategory byte = 245
+	hdrRequestManufacturer  byte = 246
+	hdrSimplePoll           byte = 254
+
+	coinPositions = 16 // Count of coin positions in inhibit mask
+	creditEvents  = 5  // Count of events in credit buffer
+)
+
+// CreditEvent is the result A and B bytes of credit buffer
+type CreditEvent struct {
+	Credit byte // Coin position 1..16 or zero for error event
+	Code   byte // Sorter path of the coin or error code
+}
+
+// HopperStatus is the reply of hopper status request
+type HopperStatus struct {
+	Event     byte
+	Remaining byte
+	Paid      byte
+	Unpaid    byte
+}
+
+func (hs *HopperStatus) String() string {
+	if hs == nil {
+		return ""
+	}
+	return fmt.Sprintf("Event %d, Remaining %d, Paid %d, Unpaid %d",
+		hs.Event, hs.Remaining, hs.Paid, hs.Unpaid)
+}
+
+type CctalkIdent struct {
+	Category     string
+	Manufacturer string
+	ProductCode  string
+	SerialNumber [3]byte
+}
+
+func (ci *CctalkIdent) String() string {
+	if ci == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s %s %s, serial %d", ci.Manufacturer, ci.ProductCode, ci.Category,
+		uint32(ci.SerialNumber[0])|uint32(ci.SerialNumber[1])<<8|uint32(ci.SerialNumber[2])<<16)
+}
+
+type CctalkProtocol struct {
+	*linker.HalfDuplex
+	log  *core.LogAgent
+	lock sync.Mutex
+}
+
+func GetCctalkProtocol(cfg *config.LinkerConfig, address byte) *CctalkProtocol {
+	framer := newCctalkFramer(cfg, address)
+	cp := &CctalkProtocol{
+		HalfDuplex: linker.NewHalfDuplex(cfg, framer, getCctalkOptions(), "ccTalk"),
+		log:        core.GetLogAgent(core.LogLevelDump, "ccTalk"),
+	}
+	return cp
+}
+
+////////////////////////////////////////////////////////////////
+
+func (cp *CctalkProtocol) SimplePoll() error {
+	_, err := cp.request(hdrSimplePoll, nil)
+	cp.logError("SimplePoll", err)
+	return err
+}
+
+func (cp *CctalkProtocol) ResetDevice() error {
+	_, err := cp.request(hdrResetDevice, nil)
+	cp.logError("ResetDevice", err)
+	return err
+}
+
+// Identification reads category, manufacturer, product code and serial number
+func (cp *CctalkProtocol) Identification() (*CctalkIdent, error) {
+	ident := &CctalkIdent{}
+	var err error
+	ident.Category, err = cp.requestText(hdrRequestEquipCategory)
+	if err == nil {
+		ident.Manufacturer, err = cp.requestText(hdrRequestManufacturer)
+	}
+	if err == nil {
+		ident.ProductCode, err = cp.requestText(hdrRequestProductCode)
+	}
+	if err == nil {
+		var back []byte
+		back, err = cp.request(hdrRequestSerialNumber, nil)
+		if err == nil && len(back) != 3 {
+			err = common.NewError(common.DevErrorProtocolFault, "wrong serial number reply")
+		}
+		if err == nil {
+			copy(ident.SerialNumber[:], back)
+		}
+	}
+	cp.logError("Identification", err)
+	return ident, err
+}
+
+// ReadBufferedCredit returns event counter and credit buffer, the newest event goes first
+func (cp *CctalkProtocol) ReadBufferedCredit() (byte, []CreditEvent, error) {
+	back, err := cp.request(hdrReadBufferedCredit, nil)
+	if err == nil && len(back) != 1+2*creditEvents {
+		err = common.NewError(common.DevErrorProtocolFault, "wrong credit buffer reply")
+	}
+	if err != nil {
+		cp.logError("ReadBufferedCredit", err)
+		return 0, nil, err
+	}
+	events := make([]CreditEvent, creditEvents)
+	for i := range events {
+		events[i] = CreditEvent{Credit: back[1+2*i], Code: back[2+2*i]}
+	}
+	return back[0], events, nil
+}
+
+// ModifyInhibitStatus enables coin positions by mask, bit 0 is coin position 1
+func (cp *CctalkProtocol) ModifyInhibitStatus(mask uint16) error {
+	_, err := cp.request(hdrModifyInhibitStatus, []byte{byte(mask), byte(mask >> 8)})
+	cp.logError("ModifyInhibitStatus", err)
+	return err
+}
+
+// ModifyMasterInhibit stops accepting of all coins when enable is false
+func (cp *CctalkProtocol) ModifyMasterInhibit(enable bool) error {
+	data := []byte{0x00}
+	if enable {
+		data[0] = 0x01
+	}
+	_, err := cp.request(hdrModifyMasterInhibit, data)
+	cp.logError("ModifyMasterInhibit", err)
+	return err
+}
+
+// RequestCoinId returns coin identifier of the position 1..16, empty one is not programmed
+func (cp *CctalkProtocol) RequestCoinId(position int) (string, error) {
+	back, err := cp.request(hdrRequestCoinId, []byte{byte(position)})
+	var text string
+	if err == nil {
+		text = strings.TrimSpace(strings.Trim(string(back), "\x00"))
+	}
+	cp.logError("RequestCoinId", err)
+	return text, err
+}
+
+// EnableHopper allows dispensing of coins
+func (cp *CctalkProtocol) EnableHopper(enable bool) error {
+	data := []byte{0x00}
+	if enable {
+		data[0] = 0xA5
+	}
+	_, err := cp.request(hdrEnableHopper, data)
+	cp.logError("EnableHopper", err)
+	return err
+}
+
+// TestHopper returns hopper status register bytes
+func (cp *CctalkProtocol) TestHopper() ([]byte, error) {
+	back, err := cp.request(hdrTestHopper, nil)
+	if err == nil && len(back) == 0 {
+		err = common.NewError(common.DevErrorProtocolFault, "empty hopper test reply")
+	}
+	cp.logError("TestHopper", err)
+	return back, err
+}
+
+// DispenseHopperCoins starts payout, serial number of the hopper protects the command
+func (cp *CctalkProtocol) DispenseHopperCoins(serial [3]byte, count byte) error {
+	_, err := cp.request(hdrDispenseHopperCoins, []byte{serial[0], serial[1], serial[2], count})
+	cp.logError("DispenseHopperCoins", err)
+	return err
+}
+
+func (cp *CctalkProtocol) RequestHopperStatus() (*HopperStatus, error) {
+	back, err := cp.request(hdrRequestHopperStatus, nil)
+	if err == nil && len(back) != 4 {
+		err = common.NewError(common.DevErrorProtocolFault, "wrong hopper status reply")
+	}
+	if err != nil {
+		cp.logError("RequestHopperStatus", err)
+		return nil, err
+	}
+	return &HopperStatus{Event: back[0], Remaining: back[1], Paid: back[2], Unpaid: back[3]}, nil
+}
+
+// EmergencyStop halts payout and returns the count of coins not paid
+func (cp *CctalkProtocol) EmergencyStop() (byte, error) {
+	back, err := cp.request(hdrEmergencyStop, nil)
+	var unpaid byte
+	if err == nil && len(back) > 0 {
+		unpaid = back[0]
+	}
+	cp.logError("EmergencyStop", err)
+	return unpaid, err
+}
+
+////////////////////////////////////////////////////////////////
+
+func (cp *CctalkProtocol) logError(cmd string, err error) {
+	code, text := common.CheckError(err)
+	cp.log.Trace("CctalkProtocol.%s return: %d - %s", cmd, code, text)
+}
+
+func (cp *CctalkProtocol) requestText(header byte) (string, error) {
+	back, err := cp.request(header, nil)
+	return strings.TrimSpace(string(back)), err
+}
+
+// Send command and wait for reply header, data of the reply is returned
+func (cp *CctalkProtocol) request(header byte, data []byte) ([]byte, error) {
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+	cp.log.Dump("CctalkProtocol writeData header %d, data : %s", header, core.GetBinaryDump(data))
+	back, err := cp.Exchange(append([]byte{header}, data...))
+	if err != nil {
+		return nil, err
+	}
+	switch back[0] {
+	case hdrReply:
+		return back[1:], nil
+	case hdrNak:
+		return nil, common.NewError(common.DevErrorCommandFault,
+			fmt.Sprintf("header %d is not acknowledged", header))
+	case hdrBusy:
+		return nil, common.NewError(common.DevErrorExecuteFault,
+			fmt.Sprintf("device is busy on header %d", header))
+	default:
+		return nil, common.NewError(common.DevErrorProtocolFault,
+			fmt.Sprintf("unexpected reply header %d", back[0]))
+	}
+}
@@ -0,0 +1,184 @@
+package cctalk
+
+import (
+	"fmt"
+	"github.com/iftsoft/device/common"
+	"strings"
+)
+
+// Error codes of coin acceptor credit buffer
+const (
+	evNull           byte = 0
+	evRejectCoin     byte = 1
+	evInhibitedCoin  byte = 2
+	evMultipleWindow byte = 3
+	evCosActivated   byte = 20
+	evRejectSlug     byte = 25
+	evInhibitedFirst byte = 128 // Inhibited coin of position 1
+	evInhibitedLast  byte = 159 // Inhibited coin of position 32
+	evReturnActive   byte = 254
+)
+
+// Hopper test register flags
+const (
+	hopMaxCurrent    uint16 = 1 << iota // Absolute maximum current exceeded
+	hopPayoutTimeout                    // Payout timeout occurred
+	hopMotorReversed                    // Motor reversed during last payout to clear a jam
+	hopFraudBlocked                     // Opto fraud attempt, path blocked during idle
+	hopFraudShort                       // Opto fraud attempt, short-circuit during idle
+	hopOptoBlocked                      // Opto blocked permanently during payout
+	hopPowerUp                          // Power-up detected
+	hopPayoutDisabled                   // Payout disabled
+	hopFraudPayout                      // Opto fraud attempt, short-circuit during payout
+	hopSingleCoin                       // Single coin payout mode
+	hopUseOther                         // Use other hopper for change
+	hopFraudFinger                      // Opto fraud attempt, finger mis-sensing
+	hopReverseLimit                     // Motor reverse limit reached
+	hopCoilFault                        // Inductive coil fault
+	hopMemoryError                      // NV memory checksum error
+	hopPinMechanism                     // PIN number mechanism
+)
+
+// Coin acceptor event that was reported in credit buffer
+type coinEvent struct {
+	code byte
+}
+
+func (ce coinEvent) String() string {
+	return fmt.Sprintf("%d - %s", ce.code, getCoinEventText(ce.code))
+}
+
+// IsReject is true for events where the coin is returned to customer
+func (ce coinEvent) IsReject() bool {
+	switch {
+	case ce.code >= evInhibitedFirst && ce.code <= evInhibitedLast:
+		return true
+	case ce.code == evRejectCoin, ce.code == evInhibitedCoin, ce.code == evMultipleWindow,
+		ce.code == evRejectSlug, ce.code >= 5 && ce.code <= 8, ce.code >= 16 && ce.code <= 19,
+		ce.code == 12, ce.code == 23, ce.code == 24:
+		return true
+	}
+	return false
+}
+
+// GetError maps the event to device error, success is returned for coin rejects
+func (ce coinEvent) GetError() common.EnumDevError {
+	switch {
+	case ce.code == evNull, ce.code == evReturnActive, ce.IsReject():
+		return common.DevErrorSuccess
+	case ce.code == evCosActivated, ce.code == 22:
+		return common.DevErrorSecurityFault
+	default:
+		return common.DevErrorHardwareFault
+	}
+}
+
+func getCoinEventText(code byte) string {
+	if code >= evInhibitedFirst && code <= evInhibitedLast {
+		return fmt.Sprintf("Inhibited coin of position %d", code-evInhibitedFirst+1)
+	}
+	switch code {
+	case evNull:			return "Null event"
+	case evRejectCoin:		return "Reject coin"
+	case evInhibitedCoin:	return "Inhibited coin"
+	case evMultipleWindow:	return "Multiple window"
+	case 4:					return "Wake-up timeout"
+	case 5:					return "Validation timeout"
+	case 6:					return "Credit sensor timeout"
+	case 7:					return "Sorter opto timeout"
+	case 8:					return "2nd close coin error"
+	case 9:					return "Accept gate not ready"
+	case 10:				return "Credit sensor not ready"
+	case 11:				return "Sorter not ready"
+	case 12:				return "Reject coin not cleared"
+	case 13:				return "Validation sensor not ready"
+	case 14:				return "Credit sensor blocked"
+	case 15:				return "Sorter opto blocked"
+	case 16:				return "Credit sequence error"
+	case 17:				return "Coin going backwards"
+	case 18:				return "Coin too fast"
+	case 19:				return "Coin too slow"
+	case evCosActivated:	return "C.O.S. mechanism activated"
+	case 21:				return "DCE opto timeout"
+	case 22:				return "DCE opto not seen"
+	case 23:				return "Credit sensor reached too early"
+	case 24:				return "Reject coin (repeated sequential trip)"
+	case evRejectSlug:		return "Reject slug"
+	case 26:				return "Reject sensor blocked"
+	case 27:				return "Games overload"
+	case 28:				return "Max. coin meter pulses exceeded"
+	case 29:				return "Accept gate open not closed"
+	case 30:				return "Accept gate closed not open"
+	case evReturnActive:	return "Coin return mechanism activated"
+	default:				return "Unknown event"
+	}
+}
+
+// Hopper test register bytes
+type hopperFlags uint16
+
+func newHopperFlags(data []byte) hopperFlags {
+	var flags hopperFlags
+	if len(data) > 0 {
+		flags = hopperFlags(data[0])
+	}
+	if len(data) > 1 {
+		flags |= hopperFlags(data[1]) << 8
+	}
+	return flags
+}
+
+func (hf hopperFlags) has(flag uint16) bool {
+	return uint16(hf)&flag != 0
+}
+
+func (hf hopperFlags) String() string {
+	list := make([]string, 0)
+	for i := 0; i < 16; i++ {
+		if hf.has(1 << uint(i)) {
+			list = append(list, getHopperFlagText(1<<uint(i)))
+		}
+	}
+	if len(list) == 0 {
+		return "No flags"
+	}
+	return strings.Join(list, ", ")
+}
+
+// GetError maps the fault flags to device error, success is returned for normal state
+func (hf hopperFlags) GetError() common.EnumDevError {
+	switch {
+	case hf.has(hopFraudBlocked | hopFraudShort | hopFraudPayout | hopFraudFinger):
+		return common.DevErrorSecurityFault
+	case hf.has(hopMaxCurrent | hopCoilFault | hopMemoryError):
+		return common.DevErrorHardwareFault
+	case hf.has(hopOptoBlocked | hopReverseLimit):
+		return common.DevErrorPickFault
+	case hf.has(hopPayoutTimeout):
+		return common.DevErrorStackerEmpty
+	default:
+		return common.DevErrorSuccess
+	}
+}
+
+func getHopperFlagText(flag uint16) string {
+	switch flag {
+	case hopMaxCurrent:		return "Absolute maximum current exceeded"
+	case hopPayoutTimeout:	return "Payout timeout occurred"
+	case hopMotorReversed:	return "Motor reversed to clear a jam"
+	case hopFraudBlocked:	return "Opto path blocked during idle"
+	case hopFraudShort:		return "Opto short-circuit during idle"
+	case hopOptoBlocked:	return "Opto blocked permanently during payout"
+	case hopPowerUp:		return "Power-up detected"
+	case hopPayoutDisabled:	return "Payout disabled"
+	case hopFraudPayout:	return "Opto short-circuit during payout"
+	case hopSingleCoin:		return "Single coin payout mode"
+	case hopUseOther:		return "Use other hopper for change"
+	case hopFraudFinger:	return "Opto finger mis-sensing"
+	case hopReverseLimit:	return "Motor reverse limit reached"
+	case hopCoilFault:		return "Inductive coil fault"
+	case hopMemoryError:	return "NV memory checksum error"
+	case hopPinMechanism:	return "PIN number mechanism"
+	default:				return "Unknown flag"
+	}
+}
@@ -27,6 +27,7 @@ type SystemDevice struct {
 	reader    *proxy.ReaderClient
 	validator *proxy.ValidatorClient
 	pinpad    *proxy.PinPadClient
+	dispenser *proxy.DispenserClient
 	storage   *dbase.DBaseStore
 	log       *core.LogAgent
 	done      chan struct{}
@@ -52,6 +53,7 @@ func NewSystemDevice(cfg *config.AppConfig) *SystemDevice {
 		reader:    proxy.NewReaderClient(),
 		validator: proxy.NewValidatorClient(),
 		pinpad:    proxy.NewPinPadClient(),
+		dispenser: proxy.NewDispenserClient(),
 		storage:   dbase.GetNewDBaseStore(cfg.Storage),
 		log:       core.GetLogAgent(core.LogLevelTrace, "Device"),
 		done:      make(chan struct{}),
@@ -98,6 +100,17 @@ func (sd *SystemDevice) InitDevice(worker interface{}) error {
 		sd.duplex.AddDispatcher(duplex.ScopePinPad, sd.pinpad.GetDispatcher())
 		sd.greeting.Supported |= common.ScopeFlagPinPad
 	}
+	// Setup Dispenser scope interface
+	if dispenser, ok := worker.(common.DispenserManager); ok {
+		sd.dispenser.Init(dispenser, sd.log)
+		sd.duplex.AddDispatcher(duplex.ScopeDispenser, sd.dispenser.GetDispatcher())
+		sd.greeting.Supported |= common.ScopeFlagDispenser
+	}
+	// Raw traffic of card reader and PIN pad has card data and PIN blocks, it is never recorded
+	if sd.greeting.Supported&(common.ScopeFlagReader|common.ScopeFlagPinPad) != 0 &&
+		sd.config != nil && sd.config.Linker != nil && sd.config.Linker.Record != "" {
+		return errors.New("traffic of card reader or PIN pad can't be recorded")
+	}
 	// Setup Device driver interface
 	if drv, ok := worker.(DeviceDriver); ok {
 		sd.driver = drv
@@ -331,6 +344,17 @@ func (sd *SystemDevice) ValidatorStore(name string, reply *common.ValidatorStore
 func (sd *SystemDevice) PinPadReply(name string, reply *common.ReaderPinReply) error {
 	return sd.encodeReply(duplex.ScopePinPad, common.CmdPinPadReply, reply)
 }
+func (sd *SystemDevice) PinPadKeyPress(name string, value *common.ReaderKeyPress) error {
+	return sd.encodeReply(duplex.ScopePinPad, common.CmdPinPadKeyPress, value)
+}
+
+// Implementation of common.DispenserCallback
+func (sd *SystemDevice) CashDispensed(name string, reply *common.DispenserPayout) error {
+	return sd.encodeReply(duplex.ScopeDispenser, common.CmdCashDispensed, reply)
+}
+func (sd *SystemDevice) DispenserStore(name string, reply *common.DispenserStore) error {
+	return sd.encodeReply(duplex.ScopeDispenser, common.CmdDispenserStore, reply)
+}
 
 // Common function for reply encoding
 func (sd *SystemDevice) encodeReply(scope duplex.PacketScope, cmd string, reply interface{}) error {
@@ -340,7 +364,7 @@ func (sd *SystemDevice) encodeReply(scope duplex.PacketScope, cmd string, reply
 	}
 	if sd.log != nil {
 		sd.log.Dump("SystemDevice dev:%s send scope:%s, cmd:%s pack:%s",
-			sd.devName, duplex.GetScopeName(scope), cmd, string(dump))
+			sd.devName, duplex.GetScopeName(scope), cmd, core.RedactJson(dump))
 	}
 	pack := duplex.NewPacket(scope, sd.devName, cmd, dump)
 	if sd.duplex != nil {
@@ -0,0 +1,32 @@
+package driver_test
+
+import (
+	"github.com/iftsoft/device/config"
+	"github.com/iftsoft/device/driver"
+	"github.com/iftsoft/device/driver/pinpad"
+	"github.com/iftsoft/device/driver/reader"
+	"github.com/iftsoft/device/driver/virtual"
+	"path/filepath"
+	"testing"
+)
+
+func newRecordedDevice(t *testing.T) *driver.SystemDevice {
+	t.Helper()
+	appCfg := config.GetDefaultAppConfig(&config.DeviceConfig{
+		Linker: &config.LinkerConfig{Record: filepath.Join(t.TempDir(), "device.trace")},
+	})
+	appCfg.Duplex.DevName = "Device"
+	return driver.NewSystemDevice(appCfg)
+}
+
+func TestRecordRefused(t *testing.T) {
+	if err := newRecordedDevice(t).InitDevice(pinpad.NewPinPadDriver()); err == nil {
+		t.Error("PIN pad traffic is recorded")
+	}
+	if err := newRecordedDevice(t).InitDevice(reader.NewReaderDriver()); err == nil {
+		t.Error("card reader traffic is recorded")
+	}
+	if err := newRecordedDevice(t).InitDevice(virtual.NewVirtualDriver()); err != nil {
+		t.Errorf("printer traffic is not recorded: %v", err)
+	}
+}
@@ -0,0 +1,188 @@
+package escpos
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/driver/printer"
+)
+
+const (
+	escEsc byte = 0x1B
+	escGs  byte = 0x1D
+	escDle byte = 0x10
+	escEot byte = 0x04
+	escLf  byte = 0x0A
+
+	rasterBand     = 128 // Row count of one raster image command
+	barcodeMaxData = 255 // Data byte count limit of GS k command
+)
+
+type EnumAlign byte
+
+// Text alignment of ESC a command
+const (
+	AlignLeft EnumAlign = iota
+	AlignCenter
+	AlignRight
+)
+
+type EnumBarcode byte
+
+// Barcode systems of GS k command
+const (
+	BarcodeEAN13   EnumBarcode = 67
+	BarcodeCODE39  EnumBarcode = 69
+	BarcodeCODE128 EnumBarcode = 73
+)
+
+// EscposBuilder collects ESC/POS commands of the print job
+type EscposBuilder struct {
+	buf  bytes.Buffer
+	page *CodePage
+}
+
+func NewEscposBuilder(page *CodePage) *EscposBuilder {
+	eb := &EscposBuilder{page: page}
+	return eb
+}
+
+// Bytes returns commands collected so far
+func (eb *EscposBuilder) Bytes() []byte {
+	return eb.buf.Bytes()
+}
+
+func (eb *EscposBuilder) Len() int {
+	return eb.buf.Len()
+}
+
+func (eb *EscposBuilder) Reset() {
+	eb.buf.Reset()
+}
+
+// Init resets printer modes and selects code page
+func (eb *EscposBuilder) Init() *EscposBuilder {
+	eb.buf.Write([]byte{escEsc, '@'})
+	if eb.page != nil {
+		eb.buf.Write([]byte{escEsc, 't', eb.page.Select})
+	}
+	return eb
+}
+
+func (eb *EscposBuilder) Align(align EnumAlign) *EscposBuilder {
+	eb.buf.Write([]byte{escEsc, 'a', byte(align)})
+	return eb
+}
+
+func (eb *EscposBuilder) Bold(on bool) *EscposBuilder {
+	eb.buf.Write([]byte{escEsc, 'E', flag(on)})
+	return eb
+}
+
+func (eb *EscposBuilder) Underline(on bool) *EscposBuilder {
+	eb.buf.Write([]byte{escEsc, '-', flag(on)})
+	return eb
+}
+
+// Size sets character width and height multipliers 1..8
+func (eb *EscposBuilder) Size(width, height int) *EscposBuilder {
+	eb.buf.Write([]byte{escGs, '!', byte(clamp(width, 1, 8)-1)<<4 | byte(clamp(height, 1, 8)-1)})
+	return eb
+}
+
+// Text writes text in the code page without line feed
+func (eb *EscposBuilder) Text(text string) *EscposBuilder {
+	if eb.page != nil {
+		eb.buf.Write(eb.page.Encode(text))
+	} else {
+		eb.buf.WriteString(text)
+	}
+	return eb
+}
+
+// Line writes text with line feed
+func (eb *EscposBuilder) Line(text string) *EscposBuilder {
+	eb.Text(text)
+	eb.buf.WriteByte(escLf)
+	return eb
+}
+
+// Feed prints buffer and feeds paper by lines
+func (eb *EscposBuilder) Feed(lines int) *EscposBuilder {
+	if lines > 0 {
+		eb.buf.Write([]byte{escEsc, 'd', byte(clamp(lines, 0, 255))})
+	}
+	return eb
+}
+
+// Cut feeds paper by lines and makes partial cut
+func (eb *EscposBuilder) Cut(lines int) *EscposBuilder {
+	eb.Feed(lines)
+	eb.buf.Write([]byte{escGs, 'V', 1})
+	return eb
+}
+
+// QRCode prints QR code of model 2 with module size 1..16 and error correction level M
+func (eb *EscposBuilder) QRCode(data string, size int) *EscposBuilder {
+	eb.buf.Write([]byte{escGs, '(', 'k', 4, 0, '1', 'A', '2', 0})
+	eb.buf.Write([]byte{escGs, '(', 'k', 3, 0, '1', 'C', byte(clamp(size, 1, 16))})
+	eb.buf.Write([]byte{escGs, '(', 'k', 3, 0, '1', 'E', '1'})
+	size = len(data) + 3
+	eb.buf.Write([]byte{escGs, '(', 'k', byte(size), byte(size >> 8), '1', 'P', '0'})
+	eb.buf.WriteString(data)
+	eb.buf.Write([]byte{escGs, '(', 'k', 3, 0, '1', 'Q', '0'})
+	return eb
+}
+
+// Barcode prints barcode with human readable text below it, data that does not fit the command is an error
+func (eb *EscposBuilder) Barcode(kind EnumBarcode, data string, height int) error {
+	if kind == BarcodeCODE128 {
+		data = "{B" + data
+	}
+	if len(data) > barcodeMaxData {
+		return common.NewError(common.DevErrorBadArgument,
+			fmt.Sprintf("barcode data of %d bytes is too long", len(data)))
+	}
+	eb.buf.Write([]byte{escGs, 'H', 2})
+	eb.buf.Write([]byte{escGs, 'h', byte(clamp(height, 1, 255))})
+	eb.buf.Write([]byte{escGs, 'w', 2})
+	eb.buf.Write([]byte{escGs, 'k', byte(kind), byte(len(data))})
+	eb.buf.WriteString(data)
+	return nil
+}
+
+// Image prints raster image by bands
+func (eb *EscposBuilder) Image(img *printer.RasterImage) *EscposBuilder {
+	if img == nil {
+		return eb
+	}
+	for row := 0; row < img.Height; row += rasterBand {
+		rows := img.Height - row
+		if rows > rasterBand {
+			rows = rasterBand
+		}
+		eb.buf.Write([]byte{escGs, 'v', '0', 0,
+			byte(img.Stride), byte(img.Stride >> 8), byte(rows), byte(rows >> 8)})
+		eb.buf.Write(img.Data[row*img.Stride : (row+rows)*img.Stride])
+	}
+	return eb
+}
+
+////////////////////////////////////////////////////////////////
+
+func flag(on bool) byte {
+	if on {
+		return 1
+	}
+	return 0
+}
+
+func clamp(value, min, max int) int {
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
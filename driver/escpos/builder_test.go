@@ -0,0 +1,146 @@
+package escpos
+
+import (
+	"bytes"
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/driver/printer"
+	"strings"
+	"testing"
+)
+
+func checkBytes(t *testing.T, name string, data []byte, want ...byte) {
+	t.Helper()
+	if !bytes.Equal(data, want) {
+		t.Errorf("%s: % X, want % X", name, data, want)
+	}
+}
+
+func TestBarcode(t *testing.T) {
+	eb := NewEscposBuilder(nil)
+	if err := eb.Barcode(BarcodeEAN13, "4820000000001", 300); err != nil {
+		t.Fatal(err)
+	}
+	checkBytes(t, "EAN13", eb.Bytes(), escGs, 'H', 2, escGs, 'h', 255, escGs, 'w', 2,
+		escGs, 'k', 67, 13, '4', '8', '2', '0', '0', '0', '0', '0', '0', '0', '0', '0', '1')
+	// CODE128 data goes with code set B
+	eb.Reset()
+	if err := eb.Barcode(BarcodeCODE128, "A1", 0); err != nil {
+		t.Fatal(err)
+	}
+	checkBytes(t, "CODE128", eb.Bytes(), escGs, 'H', 2, escGs, 'h', 1, escGs, 'w', 2,
+		escGs, 'k', 73, 4, '{', 'B', 'A', '1')
+
+	// Length byte can not keep longer data
+	eb.Reset()
+	if err := eb.Barcode(BarcodeCODE39, strings.Repeat("1", 255), 80); err != nil || eb.Len() != 9+4+255 {
+		t.Errorf("barcode of 255 bytes takes %d bytes: %v", eb.Len(), err)
+	}
+	eb.Reset()
+	err := eb.Barcode(BarcodeCODE128, strings.Repeat("1", 254), 80)
+	if code, _ := common.CheckError(err); code != common.DevErrorBadArgument || eb.Len() != 0 {
+		t.Errorf("too long barcode takes %d bytes: %v", eb.Len(), err)
+	}
+}
+
+func TestBuilderText(t *testing.T) {
+	page, _ := GetCodePage("")
+	eb := NewEscposBuilder(page)
+	eb.Init().Align(AlignCenter).Bold(true).Underline(true).Size(2, 3).Line("Ok").
+		Bold(false).Underline(false).Size(0, 9).Text("Ґрн").Feed(2).Cut(3)
+	checkBytes(t, "text", eb.Bytes(),
+		escEsc, '@', escEsc, 't', 44,
+		escEsc, 'a', 1, escEsc, 'E', 1, escEsc, '-', 1, escGs, '!', 0x12, 'O', 'k', escLf,
+		escEsc, 'E', 0, escEsc, '-', 0, escGs, '!', 0x07, 0xF2, 0xE0, 0xAD,
+		escEsc, 'd', 2, escEsc, 'd', 3, escGs, 'V', 1)
+	// Zero feed writes nothing
+	eb.Reset()
+	eb.Feed(0).Cut(0)
+	checkBytes(t, "cut", eb.Bytes(), escGs, 'V', 1)
+	// Builder without code page writes text as is
+	eb = NewEscposBuilder(nil)
+	eb.Init().Text("A")
+	checkBytes(t, "no code page", eb.Bytes(), escEsc, '@', 'A')
+}
+
+func TestCodePage(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []byte
+	}{
+		{"cp1125", "Їжак і ґ", []byte{0xF8, 0xA6, 0xA0, 0xAA, ' ', 0xF7, ' ', 0xF3}},
+		{"cp866", "Їжак і ґ", []byte{0xF4, 0xA6, 0xA0, 0xAA, ' ', 'i', ' ', 0xA3}},
+		{"cp1251", "Їжак і ґ", []byte{0xAF, 0xE6, 0xE0, 0xEA, ' ', 0xB3, ' ', 0xB4}},
+		{"CP437", "Ok €", []byte{'O', 'k', ' ', '?'}},
+	}
+	for _, tt := range tests {
+		page, err := GetCodePage(tt.name)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if data := page.Encode(tt.text); !bytes.Equal(data, tt.want) {
+			t.Errorf("%s: % X, want % X", tt.name, data, tt.want)
+		}
+	}
+	if _, err := GetCodePage("cp999"); err == nil {
+		t.Error("unknown code page is taken")
+	}
+}
+
+func TestBuilderCodes(t *testing.T) {
+	eb := NewEscposBuilder(nil)
+	eb.QRCode("AB", 20)
+	checkBytes(t, "QR code", eb.Bytes(),
+		escGs, '(', 'k', 4, 0, '1', 'A', '2', 0,
+		escGs, '(', 'k', 3, 0, '1', 'C', 16,
+		escGs, '(', 'k', 3, 0, '1', 'E', '1',
+		escGs, '(', 'k', 5, 0, '1', 'P', '0', 'A', 'B',
+		escGs, '(', 'k', 3, 0, '1', 'Q', '0')
+
+	// Image of 200 rows goes by bands of 128 and 72 rows
+	img := &printer.RasterImage{Width: 16, Height: 200, Stride: 2, Data: make([]byte, 400)}
+	for i := range img.Data {
+		img.Data[i] = byte(i)
+	}
+	eb.Reset()
+	eb.Image(img).Image(nil)
+	data := eb.Bytes()
+	if len(data) != 2*8+len(img.Data) {
+		t.Fatalf("image takes %d bytes", len(data))
+	}
+	checkBytes(t, "first band", data[:8], escGs, 'v', '0', 0, 2, 0, 128, 0)
+	checkBytes(t, "second band", data[8+256:8+256+8], escGs, 'v', '0', 0, 2, 0, 72, 0)
+	if !bytes.Equal(data[8:8+256], img.Data[:256]) || !bytes.Equal(data[8+256+8:], img.Data[256:]) {
+		t.Error("image rows are not written in order")
+	}
+}
+
+func TestRenderPage(t *testing.T) {
+	page, _ := GetCodePage("cp1125")
+	ee := &EscposEngine{page: page}
+	items := []*common.DocumentItem{
+		{Kind: common.DocItemText, Text: "Receipt", Align: common.DocAlignCenter, Bold: true, Width: 2},
+		{Kind: common.DocItemSeparator, Text: "-"},
+		{Kind: common.DocItemFeed, Width: 2},
+	}
+	eb, err := ee.renderPage(items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{escEsc, '@', escEsc, 't', 44,
+		escEsc, 'a', 1, escEsc, 'E', 1, escEsc, '-', 0, escGs, '!', 0x10}
+	want = append(want, "Receipt"...)
+	want = append(want, escLf, escEsc, 'E', 0, escEsc, '-', 0, escGs, '!', 0,
+		escEsc, 'a', 0, escEsc, 'E', 0, escEsc, '-', 0, escGs, '!', 0)
+	// 48 characters of font A on 80 mm paper
+	want = append(want, strings.Repeat("-", 48)...)
+	want = append(want, escLf, escEsc, 'E', 0, escEsc, '-', 0, escGs, '!', 0,
+		escEsc, 'a', 0, escEsc, 'd', 2, escGs, 'V', 1)
+	checkBytes(t, "page", eb.Bytes(), want...)
+
+	items = append(items, &common.DocumentItem{Kind: common.DocItemBarcode, Text: strings.Repeat("1", 300)})
+	if _, err = ee.renderPage(items); err == nil {
+		t.Error("page with too long barcode is rendered")
+	}
+}
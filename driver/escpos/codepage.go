@@ -0,0 +1,103 @@
+package escpos
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CodePage converts text to single byte code page of the printer
+type CodePage struct {
+	Name   string
+	Select byte          // Code table number of ESC t command
+	upper  map[rune]byte // Characters above ASCII
+}
+
+var codePages = map[string]*CodePage{
+	"cp437":  {Name: "cp437", Select: 0, upper: map[rune]byte{}},
+	"cp866":  {Name: "cp866", Select: 17, upper: makeCp866()},
+	"cp1125": {Name: "cp1125", Select: 44, upper: makeCp1125()},
+	"cp1251": {Name: "cp1251", Select: 46, upper: makeCp1251()},
+}
+
+// Ukrainian letters that are missed in some code pages are printed as similar ones
+var fallbackChars = map[rune]rune{
+	'І': 'I', 'і': 'i', 'Ґ': 'Г', 'ґ': 'г',
+}
+
+// GetCodePage returns code page by its name, empty name is for cp1125
+func GetCodePage(name string) (*CodePage, error) {
+	if name == "" {
+		name = "cp1125"
+	}
+	page, ok := codePages[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown code page '%s'", name)
+	}
+	return page, nil
+}
+
+// Encode converts text to the code page, unknown characters are replaced by '?'
+func (cp *CodePage) Encode(text string) []byte {
+	data := make([]byte, 0, len(text))
+	for _, r := range text {
+		if _, ok := cp.upper[r]; !ok {
+			if alt, ok := fallbackChars[r]; ok {
+				r = alt
+			}
+		}
+		switch {
+		case r < 0x80:
+			data = append(data, byte(r))
+		default:
+			if b, ok := cp.upper[r]; ok {
+				data = append(data, b)
+			} else {
+				data = append(data, '?')
+			}
+		}
+	}
+	return data
+}
+
+func makeCp866() map[rune]byte {
+	table := map[rune]byte{
+		'Ё': 0xF0, 'ё': 0xF1, 'Є': 0xF2, 'є': 0xF3, 'Ї': 0xF4, 'ї': 0xF5, 'Ў': 0xF6, 'ў': 0xF7,
+		'°': 0xF8, '·': 0xFA, '№': 0xFC, '¤': 0xFD,
+	}
+	for r := 'А'; r <= 'п'; r++ {
+		table[r] = byte(0x80 + r - 'А')
+	}
+	for r := 'р'; r <= 'я'; r++ {
+		table[r] = byte(0xE0 + r - 'р')
+	}
+	return table
+}
+
+func makeCp1125() map[rune]byte {
+	table := makeCp866()
+	for _, r := range []rune{'Ў', 'ў', '°'} {
+		delete(table, r)
+	}
+	chars := []rune{'Ё', 'ё', 'Ґ', 'ґ', 'Є', 'є', 'І', 'і', 'Ї', 'ї', '·', '√', '№', '¤', '■', '\u00A0'}
+	for i, r := range chars {
+		table[r] = byte(0xF0 + i)
+	}
+	return table
+}
+
+func makeCp1251() map[rune]byte {
+	table := map[rune]byte{
+		'Ђ': 0x80, 'Ѓ': 0x81, '‚': 0x82, 'ѓ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+		'€': 0x88, '‰': 0x89, 'Љ': 0x8A, '‹': 0x8B, 'Њ': 0x8C, 'Ќ': 0x8D, 'Ћ': 0x8E, 'Џ': 0x8F,
+		'ђ': 0x90, '‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+		'™': 0x99, 'љ': 0x9A, '›': 0x9B, 'њ': 0x9C, 'ќ': 0x9D, 'ћ': 0x9E, 'џ': 0x9F,
+		'\u00A0': 0xA0, 'Ў': 0xA1, 'ў': 0xA2, 'Ј': 0xA3, '¤': 0xA4, 'Ґ': 0xA5, '¦': 0xA6, '§': 0xA7,
+		'Ё': 0xA8, '©': 0xA9, 'Є': 0xAA, '«': 0xAB, '¬': 0xAC, '\u00AD': 0xAD, '®': 0xAE, 'Ї': 0xAF,
+		'°': 0xB0, '±': 0xB1, 'І': 0xB2, 'і': 0xB3, 'ґ': 0xB4, 'µ': 0xB5, '¶': 0xB6, '·': 0xB7,
+		'ё': 0xB8, '№': 0xB9, 'є': 0xBA, '»': 0xBB, 'ј': 0xBC, 'Ѕ': 0xBD, 'ѕ': 0xBE, 'ї': 0xBF,
+	}
+	for r := 'А'; r <= 'я'; r++ {
+		table[r] = byte(0xC0 + r - 'А')
+	}
+	return table
+}
@@ -0,0 +1,102 @@
+package escpos
+
+import (
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/driver/printer"
+)
+
+const (
+	escposCharWidth     = 12 // Dot width of font A character
+	escposBarcodeHeight = 80
+	escposQRCodeSize    = 6
+)
+
+// Render page items of the document to printer commands
+func (ee *EscposEngine) renderPage(items []*common.DocumentItem) (*EscposBuilder, error) {
+	eb := NewEscposBuilder(ee.page)
+	eb.Init()
+	for _, item := range items {
+		err := ee.renderItem(eb, item)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return eb.Cut(int(ee.getPrinterConfig().EjectLength)), nil
+}
+
+func (ee *EscposEngine) renderItem(eb *EscposBuilder, item *common.DocumentItem) error {
+	width, height := int(item.Width), int(item.Height)
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+	// Characters per line of the item size
+	chars := ee.getPaperWidth() / escposCharWidth / width
+	eb.Align(getAlign(item.Align))
+	switch item.Kind {
+	case common.DocItemText, common.DocItemColumns, common.DocItemSeparator:
+		eb.Bold(item.Bold).Underline(item.Underline).Size(width, height)
+		switch item.Kind {
+		case common.DocItemColumns:
+			eb.Line(printer.FormatColumns(item.Columns, chars))
+		case common.DocItemSeparator:
+			eb.Line(printer.Separator(item.Text, chars))
+		default:
+			eb.Line(printer.CutText(item.Text, chars))
+		}
+		eb.Bold(false).Underline(false).Size(1, 1)
+	case common.DocItemImage:
+		img := ee.logo
+		if item.Text != "" {
+			prnCfg := ee.getPrinterConfig()
+			path, err := printer.ImagePath(item.Text, prnCfg.ImageDir, prnCfg.ImageFile)
+			if err == nil {
+				img, err = printer.LoadImage(path, ee.getPaperWidth())
+			}
+			if err != nil {
+				return common.NewError(common.DevErrorBadArgument, err.Error())
+			}
+		}
+		eb.Image(img)
+	case common.DocItemBarcode:
+		if item.Height > 0 {
+			height = int(item.Height)
+		} else {
+			height = escposBarcodeHeight
+		}
+		return eb.Barcode(getBarcode(item.Barcode), item.Text, height)
+	case common.DocItemQRCode:
+		size := int(item.Width)
+		if size == 0 {
+			size = escposQRCodeSize
+		}
+		eb.QRCode(item.Text, size)
+	case common.DocItemFeed:
+		eb.Feed(width)
+	}
+	return nil
+}
+
+func getAlign(align common.EnumDocAlign) EnumAlign {
+	switch align {
+	case common.DocAlignCenter:
+		return AlignCenter
+	case common.DocAlignRight:
+		return AlignRight
+	default:
+		return AlignLeft
+	}
+}
+
+func getBarcode(kind common.EnumDocBarcode) EnumBarcode {
+	switch kind {
+	case common.DocBarcodeEAN13:
+		return BarcodeEAN13
+	case common.DocBarcodeCODE39:
+		return BarcodeCODE39
+	default:
+		return BarcodeCODE128
+	}
+}
@@ -0,0 +1,118 @@
+package escpos
+
+import (
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/driver"
+	"time"
+)
+
+type EscposDriver struct {
+	EscposEngine
+	begTime int64
+}
+
+func NewEscposDriver() *EscposDriver {
+	ed := EscposDriver{}
+	return &ed
+}
+
+// Implementation of DeviceDriver interface
+func (ed *EscposDriver) InitDevice(context *driver.Context) error {
+	ed.initEngine(context.Config)
+	ed.DevName = context.DevName
+	ed.begTime = time.Now().Unix()
+	ed.Log.Debug("EscposDriver run cmd:%s", "InitDevice")
+
+	mask := common.ScopeFlagSystem
+	if device, ok := context.Manager.(common.DeviceCallback); ok {
+		ed.CbDevice = device
+		mask |= common.ScopeFlagDevice
+	}
+	if printer, ok := context.Manager.(common.PrinterCallback); ok {
+		ed.CbPrinter = printer
+		mask |= common.ScopeFlagPrinter
+	}
+	if context.Greeting != nil {
+		context.Greeting.DevType = common.DevTypePrinter
+		context.Greeting.Required = mask
+	}
+	return nil
+}
+
+func (ed *EscposDriver) StartDevice(query *common.SystemConfig) error {
+	ed.Log.Debug("EscposDriver run cmd:%s", "StartDeviceLoop")
+	if ed.config != nil && query != nil {
+		ed.config.OverwriteConfig(query)
+	}
+	return ed.DevStartup()
+}
+func (ed *EscposDriver) DeviceTimer(unix int64) error {
+	ed.Log.Trace("EscposDriver run cmd:%s", "DeviceTimer")
+	ed.pollPrinter()
+	return nil
+}
+func (ed *EscposDriver) StopDevice() error {
+	ed.Log.Debug("EscposDriver run cmd:%s", "StopDeviceLoop")
+	return ed.DevCleanup()
+}
+func (ed *EscposDriver) CheckDevice(metrics *common.SystemMetrics) error {
+	ed.Log.Debug("EscposDriver run cmd:%s", "CheckDevice")
+	if metrics != nil {
+		metrics.Uptime = time.Now().Unix() - ed.begTime
+		metrics.DevState = ed.DevState
+		metrics.DevError = ed.DevError
+		ed.checkPaper(metrics)
+	}
+	return nil
+}
+
+// Implementation of common.DeviceManager
+//
+func (ed *EscposDriver) Cancel(name string, query *common.DeviceQuery) error {
+	err := ed.DevStatus()
+	ed.DevError, ed.DevReply = common.CheckError(err)
+	return ed.RunDeviceReply(common.CmdDeviceCancel)
+}
+func (ed *EscposDriver) Reset(name string, query *common.DeviceQuery) error {
+	err := ed.DevInitPrinter(nil)
+	ed.DevError, ed.DevReply = common.CheckError(err)
+	return ed.RunDeviceReply(common.CmdDeviceReset)
+}
+func (ed *EscposDriver) Status(name string, query *common.DeviceQuery) error {
+	err := ed.DevStatus()
+	ed.DevError, ed.DevReply = common.CheckError(err)
+	return ed.RunDeviceReply(common.CmdDeviceStatus)
+}
+func (ed *EscposDriver) RunAction(name string, query *common.DeviceQuery) error {
+	err := ed.DevStatus()
+	ed.DevError, ed.DevReply = common.CheckError(err)
+	return ed.RunDeviceReply(common.CmdRunAction)
+}
+func (ed *EscposDriver) StopAction(name string, query *common.DeviceQuery) error {
+	err := ed.DevStatus()
+	ed.DevError, ed.DevReply = common.CheckError(err)
+	return ed.RunDeviceReply(common.CmdStopAction)
+}
+
+// Implementation of common.PrinterManager
+//
+func (ed *EscposDriver) InitPrinter(name string, query *common.PrinterSetup) error {
+	err := ed.DevInitPrinter(query)
+	ed.DevError, ed.DevReply = common.CheckError(err)
+	return ed.RunDeviceReply(common.CmdInitPrinter)
+}
+func (ed *EscposDriver) PrintText(name string, query *common.PrinterQuery) error {
+	var err error
+	if query == nil {
+		err = common.NewError(common.DevErrorBadArgument, "print query is empty")
+	} else {
+		err = ed.DevPrintText(query.Text)
+	}
+	ed.DevError, ed.DevReply = common.CheckError(err)
+	return ed.RunDeviceReply(common.CmdPrintText)
+}
+func (ed *EscposDriver) PrintDocument(name string, query *common.PrinterDocument) error {
+	err := ed.DevPrintDocument(query)
+	ed.DevError, ed.DevReply = common.CheckError(err)
+	return ed.RunDeviceReply(common.CmdPrintDocument)
+}
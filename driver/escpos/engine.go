@@ -0,0 +1,225 @@
+package escpos
+
+import (
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/config"
+	"github.com/iftsoft/device/core"
+	"github.com/iftsoft/device/driver/generic"
+	"github.com/iftsoft/device/driver/printer"
+	"strings"
+	"time"
+)
+
+const (
+	escposPaperWidth = 576 // Printable width of 80 mm paper in dots
+	escposPollPeriod = time.Second
+)
+
+type EscposEngine struct {
+	generic.BasePrinter
+	config    *config.DeviceConfig
+	protocol  *EscposProtocol
+	page      *CodePage
+	logo      *printer.RasterImage
+	showImage config.EnumShowImage
+	status    *PrinterStatus
+	lastPoll  time.Time
+	printing  bool
+}
+
+func (ee *EscposEngine) initEngine(cfg *config.DeviceConfig) *EscposEngine {
+	ee.config = cfg
+	ee.Log = core.GetLogAgent(core.LogLevelTrace, "Engine")
+	var lnkCfg *config.LinkerConfig
+	if cfg != nil {
+		lnkCfg = cfg.Linker
+	}
+	ee.protocol = GetEscposProtocol(lnkCfg)
+	return ee
+}
+
+func (ee *EscposEngine) getPrinterConfig() *config.PrinterConfig {
+	if ee.config != nil && ee.config.Printer != nil {
+		return ee.config.Printer
+	}
+	return config.GetDefaultPrinterConfig()
+}
+
+func (ee *EscposEngine) getPaperWidth() int {
+	if width := ee.getPrinterConfig().PaperWidth; width > 0 {
+		return int(width)
+	}
+	return escposPaperWidth
+}
+
+// Put paper sensor alert to device metrics
+func (ee *EscposEngine) checkPaper(metrics *common.SystemMetrics) {
+	if ee.status == nil {
+		return
+	}
+	switch {
+	case ee.status.IsPaperOut():
+		metrics.Topics["paper"] = common.DevErrorPaperOut.String()
+	case ee.status.IsNearEnd():
+		metrics.Topics["paper"] = "Paper is near end"
+	}
+}
+
+// Select code page and load logo image by printer config
+func (ee *EscposEngine) setupPrinter(showImage config.EnumShowImage) error {
+	prnCfg := ee.getPrinterConfig()
+	page, err := GetCodePage(prnCfg.CodePage)
+	if err != nil {
+		return common.NewError(common.DevErrorConfigFault, err.Error())
+	}
+	ee.page, ee.logo, ee.showImage = page, nil, showImage
+	if showImage != config.ShowImageNone && prnCfg.ImageFile != "" {
+		ee.logo, err = printer.LoadImage(prnCfg.ImageFile, ee.getPaperWidth())
+		if err != nil {
+			return common.NewError(common.DevErrorConfigFault, err.Error())
+		}
+		ee.Log.Debug("EscposEngine logo %s loaded %dx%d", prnCfg.ImageFile, ee.logo.Width, ee.logo.Height)
+	}
+	return ee.protocol.WriteData(NewEscposBuilder(ee.page).Init().Bytes())
+}
+
+// Read printer status and report its changes, error is returned if printer is not ready
+func (ee *EscposEngine) checkStatus() error {
+	ee.lastPoll = time.Now()
+	status, err := ee.protocol.ReadStatus()
+	if err != nil {
+		code, text := common.CheckError(err)
+		if code != ee.DevError {
+			_ = ee.RunExecuteError(code, text)
+		}
+		return err
+	}
+	if ee.status == nil || *ee.status != *status {
+		ee.Log.Debug("EscposEngine status %s", status.String())
+		if status.IsNearEnd() && (ee.status == nil || !ee.status.IsNearEnd()) {
+			ee.Log.Warn("EscposEngine paper is near end")
+		}
+	}
+	ee.status = status
+	_ = ee.RunStateChanged(status.GetState())
+	if code := status.GetError(); code != common.DevErrorSuccess {
+		if code != ee.DevError {
+			_ = ee.RunExecuteError(code, status.GetText())
+		}
+		return common.NewError(code, status.GetText())
+	}
+	ee.DevError = common.DevErrorSuccess
+	return nil
+}
+
+////////////////////////////////////////////////////////////////
+
+func (ee *EscposEngine) DevStartup() error {
+	err := ee.protocol.OpenLink()
+	if err == nil {
+		err = ee.setupPrinter(ee.getPrinterConfig().ShowImage)
+	}
+	if err == nil {
+		err = ee.checkStatus()
+	}
+	return err
+}
+
+func (ee *EscposEngine) DevCleanup() error {
+	return ee.protocol.CloseLink()
+}
+
+func (ee *EscposEngine) DevStatus() error {
+	return ee.checkStatus()
+}
+
+// DevInitPrinter resets printer, show image of the setup overrides the config
+func (ee *EscposEngine) DevInitPrinter(setup *common.PrinterSetup) error {
+	showImage := ee.getPrinterConfig().ShowImage
+	if setup != nil && setup.ShowImage > 0 {
+		showImage = config.EnumShowImage(setup.ShowImage)
+	}
+	err := ee.setupPrinter(showImage)
+	if err == nil {
+		err = ee.checkStatus()
+	}
+	return err
+}
+
+// DevPrintText prints the text, form feed splits it to pages cut one by one
+func (ee *EscposEngine) DevPrintText(text string) error {
+	if ee.page == nil {
+		return common.NewError(common.DevErrorNotInitialized, "printer is not initialized")
+	}
+	texts := strings.Split(strings.TrimRight(text, "\f"), "\f")
+	pages := make([]*EscposBuilder, 0, len(texts))
+	for i, page := range texts {
+		pages = append(pages, ee.buildPage(page, i == 0))
+	}
+	return ee.printPages(common.CmdPrintText, pages)
+}
+
+// DevPrintDocument renders the document to printer commands and prints it
+func (ee *EscposEngine) DevPrintDocument(doc *common.PrinterDocument) error {
+	if ee.page == nil {
+		return common.NewError(common.DevErrorNotInitialized, "printer is not initialized")
+	}
+	if doc == nil {
+		return common.NewError(common.DevErrorBadArgument, "document is empty")
+	}
+	pages := make([]*EscposBuilder, 0)
+	for _, items := range doc.GetPages() {
+		page, err := ee.renderPage(items)
+		if err != nil {
+			return err
+		}
+		pages = append(pages, page)
+	}
+	return ee.printPages(doc.DocName, pages)
+}
+
+// Send pages to printer one by one and report progress
+func (ee *EscposEngine) printPages(docName string, pages []*EscposBuilder) error {
+	err := ee.checkStatus()
+	if err != nil {
+		return err
+	}
+	ee.printing = true
+	defer func() { ee.printing = false }()
+	_ = ee.RunActionPrompt(common.DevPromptPrintText)
+	_ = ee.RunStateChanged(common.DevStateWorking)
+	ee.Progress = common.PrinterProgress{DocName: docName, PagesAll: int32(len(pages))}
+	for i, page := range pages {
+		err = ee.protocol.WriteData(page.Bytes())
+		if err == nil {
+			err = ee.checkStatus()
+		}
+		if err != nil {
+			return err
+		}
+		ee.Progress.PageDone = int32(i + 1)
+		_ = ee.RunPrinterProgress(&ee.Progress)
+	}
+	_ = ee.RunActionPrompt(common.DevPromptNone)
+	return nil
+}
+
+func (ee *EscposEngine) buildPage(text string, first bool) *EscposBuilder {
+	eb := NewEscposBuilder(ee.page)
+	if ee.logo != nil && (ee.showImage == config.ShowImagePage ||
+		(ee.showImage == config.ShowImageOnes && first)) {
+		eb.Align(AlignCenter).Image(ee.logo).Align(AlignLeft)
+	}
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		eb.Line(strings.TrimRight(line, "\r"))
+	}
+	return eb.Cut(int(ee.getPrinterConfig().EjectLength))
+}
+
+// Poll printer status when it is idle
+func (ee *EscposEngine) pollPrinter() {
+	if ee.printing || !ee.protocol.port.IsOpen() || time.Since(ee.lastPoll) < escposPollPeriod {
+		return
+	}
+	_ = ee.checkStatus()
+}
@@ -0,0 +1,83 @@
+package escpos
+
+import (
+	"errors"
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/config"
+	"github.com/iftsoft/device/core"
+	"github.com/iftsoft/device/linker"
+)
+
+type EscposLinker struct {
+	config *config.LinkerConfig
+	log    *core.LogAgent
+	port   linker.PortLinker
+	reply  chan byte
+}
+
+func (el *EscposLinker) InitLinker(cfg *config.LinkerConfig) {
+	el.config = cfg
+	el.port = linker.GetPortLinker(cfg, el)
+	el.reply = make(chan byte, 1)
+	el.log = core.GetLogAgent(core.LogLevelDump, "ESCPOS")
+}
+
+func (el *EscposLinker) OpenLink() error {
+	if el.port == nil {
+		return common.NewError(common.DevErrorConfigFault, "port not set")
+	}
+	err := el.port.Open()
+	el.log.Trace("EscposLinker OpenLink return : %s", core.GetErrorText(err))
+	return common.ExtendError(common.DevErrorLinkerFault, err)
+}
+
+func (el *EscposLinker) CloseLink() error {
+	if el.port == nil {
+		return common.NewError(common.DevErrorConfigFault, "port not set")
+	}
+	err := el.port.Close()
+	el.log.Trace("EscposLinker CloseLink return : %s", core.GetErrorText(err))
+	return common.ExtendError(common.DevErrorLinkerFault, err)
+}
+
+////////////////////////////////////////////////////////////////
+// ESC/POS printer has no framing, the host sends command stream
+// and the printer answers single status byte to DLE EOT n request.
+
+func (el *EscposLinker) writeToPort(data []byte) error {
+	if el.port == nil {
+		return errors.New("port not set")
+	}
+	el.log.Dump("EscposLinker writeToPort data : %s", core.GetBinaryDump(data))
+	n, err := el.port.Write(data)
+	if err == nil && n != len(data) {
+		return common.NewError(common.DevErrorLinkerFault, "wrong byte count")
+	}
+	return common.ExtendError(common.DevErrorLinkerFault, err)
+}
+
+// Drop status bytes that came after timeout of previous request
+func (el *EscposLinker) clearReply() {
+	for {
+		select {
+		case b := <-el.reply:
+			el.log.Warn("EscposLinker drop late status : %02X", b)
+		default:
+			return
+		}
+	}
+}
+
+// implementation of PortReader interface
+
+func (el *EscposLinker) OnRead(dump []byte) int {
+	el.log.Dump("EscposLinker OnRead data : %s", core.GetBinaryDump(dump))
+	for _, b := range dump {
+		select {
+		case el.reply <- b:
+		default:
+			el.log.Warn("EscposLinker OnRead unexpected status : %02X", b)
+		}
+	}
+	return len(dump)
+}
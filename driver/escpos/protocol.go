@@ -0,0 +1,107 @@
+package escpos
+
+import (
+	"fmt"
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/config"
+	"sync"
+	"time"
+)
+
+// Status kinds of DLE EOT n request
+const (
+	statusPrinter byte = 1
+	statusOffline byte = 2
+	statusError   byte = 3
+	statusPaper   byte = 4
+)
+
+type EscposProtocol struct {
+	EscposLinker
+	timeout uint16
+	lock    sync.Mutex
+}
+
+func GetEscposProtocol(cfg *config.LinkerConfig) *EscposProtocol {
+	ep := &EscposProtocol{
+		EscposLinker: EscposLinker{},
+		timeout:      0,
+	}
+	ep.InitLinker(cfg)
+	if cfg != nil {
+		ep.timeout = cfg.Timeout
+	}
+	if ep.timeout == 0 {
+		ep.timeout = 200
+	}
+	return ep
+}
+
+////////////////////////////////////////////////////////////////
+
+// WriteData sends command stream to the printer
+func (ep *EscposProtocol) WriteData(data []byte) error {
+	ep.lock.Lock()
+	defer ep.lock.Unlock()
+	err := ep.writeToPort(data)
+	ep.logError("WriteData", err)
+	return err
+}
+
+// RealtimeStatus requests status byte of the kind
+func (ep *EscposProtocol) RealtimeStatus(kind byte) (byte, error) {
+	ep.lock.Lock()
+	defer ep.lock.Unlock()
+	ep.clearReply()
+	err := ep.writeToPort([]byte{escDle, escEot, kind})
+	var status byte
+	if err == nil {
+		status, err = ep.readData(ep.timeout)
+	}
+	// Fixed bits of status byte: bit 1 and 4 are set, bit 0 and 7 are clear
+	if err == nil && status&0x93 != 0x12 {
+		err = common.NewError(common.DevErrorProtocolFault, fmt.Sprintf("wrong status byte %02X", status))
+	}
+	ep.logError("RealtimeStatus", err)
+	return status, err
+}
+
+// ReadStatus requests all status kinds
+func (ep *EscposProtocol) ReadStatus() (*PrinterStatus, error) {
+	status := &PrinterStatus{}
+	var err error
+	status.Printer, err = ep.RealtimeStatus(statusPrinter)
+	if err == nil {
+		status.Offline, err = ep.RealtimeStatus(statusOffline)
+	}
+	if err == nil {
+		status.Error, err = ep.RealtimeStatus(statusError)
+	}
+	if err == nil {
+		status.Paper, err = ep.RealtimeStatus(statusPaper)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+////////////////////////////////////////////////////////////////
+
+func (ep *EscposProtocol) logError(cmd string, err error) {
+	code, text := common.CheckError(err)
+	ep.log.Trace("EscposProtocol.%s return: %d - %s", cmd, code, text)
+}
+
+func (ep *EscposProtocol) readData(timeout uint16) (byte, error) {
+	timer := time.NewTimer(time.Duration(timeout) * time.Millisecond)
+	defer timer.Stop()
+	select {
+	case b := <-ep.reply:
+		ep.log.Dump("EscposProtocol check status : %02X", b)
+		return b, nil
+	case <-timer.C:
+		ep.log.Warn("EscposProtocol timeout (ms): %d", timeout)
+		return 0, common.NewError(common.DevErrorLinkerTimeout, "linker timeout")
+	}
+}
@@ -0,0 +1,100 @@
+package escpos
+
+import (
+	"fmt"
+	"github.com/iftsoft/device/common"
+)
+
+// Bits of realtime status bytes
+const (
+	bitOffline     byte = 0x08 // Printer status
+	bitCoverOpen   byte = 0x04 // Offline status
+	bitPaperStop   byte = 0x20
+	bitErrorStop   byte = 0x40
+	bitCutterError byte = 0x08 // Error status
+	bitHardError   byte = 0x20
+	bitAutoError   byte = 0x40
+	bitsNearEnd    byte = 0x0C // Paper sensor status
+	bitsPaperEnd   byte = 0x60
+)
+
+// PrinterStatus keeps status bytes of DLE EOT 1..4 requests
+type PrinterStatus struct {
+	Printer byte
+	Offline byte
+	Error   byte
+	Paper   byte
+}
+
+func (ps *PrinterStatus) String() string {
+	if ps == nil {
+		return ""
+	}
+	return fmt.Sprintf("Printer %02X, Offline %02X, Error %02X, Paper %02X - %s",
+		ps.Printer, ps.Offline, ps.Error, ps.Paper, ps.GetText())
+}
+
+func (ps *PrinterStatus) IsNearEnd() bool {
+	return ps.Paper&bitsNearEnd != 0
+}
+
+func (ps *PrinterStatus) IsPaperOut() bool {
+	return ps.Paper&bitsPaperEnd != 0 || ps.Offline&bitPaperStop != 0
+}
+
+// GetState maps status bytes to device state
+func (ps *PrinterStatus) GetState() common.EnumDevState {
+	switch {
+	case ps.Error&bitHardError != 0:
+		return common.DevStateHardError
+	case ps.Offline&bitCoverOpen != 0:
+		return common.DevStatePrnCoverOpen
+	case ps.IsPaperOut():
+		return common.DevStatePrnPaperOut
+	case ps.Error&bitCutterError != 0:
+		return common.DevStatePrnPaperJam
+	case ps.Error&bitAutoError != 0, ps.Printer&bitOffline != 0:
+		return common.DevStateSoftError
+	default:
+		return common.DevStateReady
+	}
+}
+
+// GetError maps status bytes to device error, success is for printer ready to print
+func (ps *PrinterStatus) GetError() common.EnumDevError {
+	switch {
+	case ps.Error&bitHardError != 0:
+		return common.DevErrorHardwareFault
+	case ps.Offline&bitCoverOpen != 0:
+		return common.DevErrorHardwareFault
+	case ps.IsPaperOut():
+		return common.DevErrorPaperOut
+	case ps.Error&bitCutterError != 0:
+		return common.DevErrorPaperJam
+	case ps.Error&bitAutoError != 0, ps.Printer&bitOffline != 0:
+		return common.DevErrorHardwareFault
+	default:
+		return common.DevErrorSuccess
+	}
+}
+
+func (ps *PrinterStatus) GetText() string {
+	switch {
+	case ps.Error&bitHardError != 0:
+		return "Unrecoverable error"
+	case ps.Offline&bitCoverOpen != 0:
+		return "Cover is open"
+	case ps.IsPaperOut():
+		return "Paper end"
+	case ps.Error&bitCutterError != 0:
+		return "Autocutter error"
+	case ps.Error&bitAutoError != 0:
+		return "Auto-recoverable error"
+	case ps.Printer&bitOffline != 0:
+		return "Printer is offline"
+	case ps.IsNearEnd():
+		return "Paper near end"
+	default:
+		return "Printer is ready"
+	}
+}
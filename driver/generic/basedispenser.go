@@ -0,0 +1,41 @@
+package generic
+
+import (
+	"github.com/iftsoft/device/common"
+)
+
+type BaseDispenser struct {
+	BaseEngine
+	Payout      common.DispenserPayout
+	CbDispenser common.DispenserCallback
+}
+
+
+
+func (bd *BaseDispenser) RunDispenserStore(cmd string) error {
+	var err error
+	reply := &common.DispenserStore{}
+	reply.Command  = cmd
+	reply.DevState = bd.DevState
+	reply.ErrCode  = bd.DevError
+	reply.ErrText  = bd.DevReply
+	reply.DispenserPayout = bd.Payout
+	if bd.CbDispenser != nil {
+		err = bd.CbDispenser.DispenserStore(bd.DevName, reply)
+	}
+	if bd.Log != nil {
+		bd.Log.Debug("Callback DispenserStore: %s", reply.String())
+	}
+	return err
+}
+
+func (bd *BaseDispenser) RunCashDispensed(value *common.DispenserPayout) error {
+	var err error
+	if bd.CbDispenser != nil {
+		err = bd.CbDispenser.CashDispensed(bd.DevName, value)
+	}
+	if bd.Log != nil {
+		bd.Log.Debug("Callback CashDispensed: %s", value.String())
+	}
+	return err
+}
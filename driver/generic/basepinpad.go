@@ -0,0 +1,37 @@
+package generic
+
+import (
+	"github.com/iftsoft/device/common"
+)
+
+type BasePinPad struct {
+	BaseEngine
+	CbPinPad common.PinPadCallback
+}
+
+// RunPinPadReply sends the reply with PIN block, key check value, KSN and MAC set by caller
+func (bp *BasePinPad) RunPinPadReply(cmd string, reply *common.ReaderPinReply) error {
+	var err error
+	reply.Command  = cmd
+	reply.DevState = bp.DevState
+	reply.ErrCode  = bp.DevError
+	reply.ErrText  = bp.DevReply
+	if bp.CbPinPad != nil {
+		err = bp.CbPinPad.PinPadReply(bp.DevName, reply)
+	}
+	if bp.Log != nil {
+		bp.Log.Debug("Callback PinPadReply: %s", reply.String())
+	}
+	return err
+}
+
+func (bp *BasePinPad) RunPinPadKeyPress(value *common.ReaderKeyPress) error {
+	var err error
+	if bp.CbPinPad != nil {
+		err = bp.CbPinPad.PinPadKeyPress(bp.DevName, value)
+	}
+	if bp.Log != nil {
+		bp.Log.Debug("Callback PinPadKeyPress: %s", value.String())
+	}
+	return err
+}
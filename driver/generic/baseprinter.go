@@ -0,0 +1,22 @@
+package generic
+
+import (
+	"github.com/iftsoft/device/common"
+)
+
+type BasePrinter struct {
+	BaseEngine
+	Progress  common.PrinterProgress
+	CbPrinter common.PrinterCallback
+}
+
+func (bp *BasePrinter) RunPrinterProgress(value *common.PrinterProgress) error {
+	var err error
+	if bp.CbPrinter != nil {
+		err = bp.CbPrinter.PrinterProgress(bp.DevName, value)
+	}
+	if bp.Log != nil {
+		bp.Log.Debug("Callback PrinterProgress: %s", value.String())
+	}
+	return err
+}
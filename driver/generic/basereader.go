@@ -0,0 +1,52 @@
+package generic
+
+import (
+	"github.com/iftsoft/device/common"
+)
+
+type BaseReader struct {
+	BaseEngine
+	CardInfo common.ReaderCardInfo
+	CbReader common.ReaderCallback
+}
+
+func (br *BaseReader) RunCardPosition(position int16) error {
+	var err error
+	value := &common.ReaderCardPos{Position: position}
+	if br.CbReader != nil {
+		err = br.CbReader.CardPosition(br.DevName, value)
+	}
+	if br.Log != nil {
+		br.Log.Debug("Callback CardPosition: %d", position)
+	}
+	return err
+}
+
+func (br *BaseReader) RunCardDescription(value *common.ReaderCardInfo) error {
+	var err error
+	if br.CbReader != nil {
+		err = br.CbReader.CardDescription(br.DevName, value)
+	}
+	if br.Log != nil {
+		br.Log.Debug("Callback CardDescription: %s", value.String())
+	}
+	return err
+}
+
+func (br *BaseReader) RunChipResponse(cmd string, protocol int16, data []byte) error {
+	var err error
+	reply := &common.ReaderChipReply{}
+	reply.Command  = cmd
+	reply.DevState = br.DevState
+	reply.ErrCode  = br.DevError
+	reply.ErrText  = br.DevReply
+	reply.Protocol = protocol
+	reply.Reply    = data
+	if br.CbReader != nil {
+		err = br.CbReader.ChipResponse(br.DevName, reply)
+	}
+	if br.Log != nil {
+		br.Log.Debug("Callback ChipResponse: %s, Reply %d bytes", reply.DeviceReply.String(), len(data))
+	}
+	return err
+}
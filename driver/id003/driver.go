@@ -0,0 +1,170 @@
+package id003
+
+import (
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/dbase"
+	"github.com/iftsoft/device/dbase/dbvalid"
+	"github.com/iftsoft/device/driver"
+	"time"
+)
+
+type Id003Driver struct {
+	Id003Engine
+	storage dbase.DBaseLinker
+	begTime int64
+}
+
+func NewId003Driver() *Id003Driver {
+	id := Id003Driver{}
+	return &id
+}
+
+// Implementation of DeviceDriver interface
+func (id *Id003Driver) InitDevice(context *driver.Context) error {
+	id.initEngine(context.Config)
+	id.DevName = context.DevName
+	id.begTime = time.Now().Unix()
+	id.Log.Debug("Id003Driver run cmd:%s", "InitDevice")
+
+	mask := common.ScopeFlagSystem
+	if device, ok := context.Manager.(common.DeviceCallback); ok {
+		id.CbDevice = device
+		mask |= common.ScopeFlagDevice
+	}
+	if validator, ok := context.Manager.(common.ValidatorCallback); ok {
+		id.CbValidator = validator
+		mask |= common.ScopeFlagValidator
+	}
+	if context.Storage != nil {
+		id.storage = context.Storage
+		id.Booker = dbvalid.NewDBaseValidator(id.storage, id.DevName)
+	}
+	if context.Greeting != nil {
+		context.Greeting.DevType = common.DevTypeCashValidator
+		context.Greeting.Required = mask
+	}
+	return nil
+}
+
+func (id *Id003Driver) StartDevice(query *common.SystemConfig) error {
+	id.Log.Debug("Id003Driver run cmd:%s", "StartDeviceLoop")
+	var err error
+	if id.Config != nil && query != nil {
+		id.Config.OverwriteConfig(query)
+	}
+	if id.storage != nil {
+		err = id.storage.Open()
+	}
+	if err == nil {
+		err = id.DevStartup()
+	}
+	return err
+}
+func (id *Id003Driver) DeviceTimer(unix int64) error {
+	id.Log.Trace("Id003Driver run cmd:%s", "DeviceTimer")
+	id.pollValidator()
+	return nil
+}
+func (id *Id003Driver) StopDevice() error {
+	id.Log.Debug("Id003Driver run cmd:%s", "StopDeviceLoop")
+	err := id.DevCleanup()
+	if id.storage != nil {
+		_ = id.storage.Close()
+	}
+	return err
+}
+func (id *Id003Driver) CheckDevice(metrics *common.SystemMetrics) error {
+	id.Log.Debug("Id003Driver run cmd:%s", "CheckDevice")
+	if metrics != nil {
+		metrics.Uptime = time.Now().Unix() - id.begTime
+		metrics.DevState = id.DevState
+		metrics.DevError = id.DevError
+		id.CheckStacker(metrics)
+		id.protocol.ReportMetrics(metrics)
+	}
+	return nil
+}
+
+// Implementation of common.DeviceManager
+//
+func (id *Id003Driver) Cancel(name string, query *common.DeviceQuery) error {
+	err := id.DevDisableBills()
+	id.DevError, id.DevReply = common.CheckError(err)
+	return id.RunDeviceReply(common.CmdDeviceCancel)
+}
+func (id *Id003Driver) Reset(name string, query *common.DeviceQuery) error {
+	err := id.DevReset()
+	id.DevError, id.DevReply = common.CheckError(err)
+	return id.RunDeviceReply(common.CmdDeviceReset)
+}
+func (id *Id003Driver) Status(name string, query *common.DeviceQuery) error {
+	err := id.DevStatus()
+	id.DevError, id.DevReply = common.CheckError(err)
+	return id.RunDeviceReply(common.CmdDeviceStatus)
+}
+func (id *Id003Driver) RunAction(name string, query *common.DeviceQuery) error {
+	err := id.DevEnableBills(common.CurrencyNOT, 0)
+	if err == nil {
+		err = id.DevStatus()
+	}
+	id.DevError, id.DevReply = common.CheckError(err)
+	return id.RunDeviceReply(common.CmdRunAction)
+}
+func (id *Id003Driver) StopAction(name string, query *common.DeviceQuery) error {
+	err := id.DevDisableBills()
+	if err == nil {
+		err = id.DevStatus()
+	}
+	id.DevError, id.DevReply = common.CheckError(err)
+	return id.RunDeviceReply(common.CmdStopAction)
+}
+
+// Implementation of common.ValidatorManager
+//
+func (id *Id003Driver) InitValidator(name string, query *common.ValidatorQuery) error {
+	err := id.DevReset()
+	if err == nil {
+		err = id.DevInitBillList(query.Currency)
+	}
+	id.DevError, id.DevReply = common.CheckError(err)
+	return id.RunValidatorStore(common.CmdInitValidator)
+}
+func (id *Id003Driver) DoValidate(name string, query *common.ValidatorQuery) error {
+	err := id.DevEnableBills(query.Currency, query.Target)
+	if err == nil {
+		err = id.DevStatus()
+	}
+	id.DevError, id.DevReply = common.CheckError(err)
+	return id.RunValidatorStore(common.CmdDoValidate)
+}
+func (id *Id003Driver) NoteAccept(name string, query *common.ValidatorQuery) error {
+	err := id.DevNoteAccept()
+	id.DevError, id.DevReply = common.CheckError(err)
+	return err
+}
+func (id *Id003Driver) NoteReturn(name string, query *common.ValidatorQuery) error {
+	err := id.DevNoteReturn()
+	id.DevError, id.DevReply = common.CheckError(err)
+	return err
+}
+func (id *Id003Driver) StopValidate(name string, query *common.ValidatorQuery) error {
+	err := id.DevDisableBills()
+	if err == nil {
+		err = id.DevStatus()
+	}
+	id.DevError, id.DevReply = common.CheckError(err)
+	return id.RunValidatorStore(common.CmdStopValidate)
+}
+func (id *Id003Driver) CheckValidator(name string, query *common.ValidatorQuery) error {
+	err := id.DevCheckBatch()
+	id.DevError, id.DevReply = common.CheckError(err)
+	return id.RunValidatorStore(common.CmdCheckValidator)
+}
+func (id *Id003Driver) ClearValidator(name string, query *common.ValidatorQuery) error {
+	err := id.DevClearBatch()
+	if err == nil {
+		err = id.DevCheckBatch()
+	}
+	id.DevError, id.DevReply = common.CheckError(err)
+	return id.RunValidatorStore(common.CmdClearValidator)
+}
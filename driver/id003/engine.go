@@ -0,0 +1,334 @@
+package id003
+
+import (
+	"fmt"
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/config"
+	"github.com/iftsoft/device/driver/validator"
+	"time"
+)
+
+const (
+	id003HoldPeriod = 5 * time.Second // Acceptor returns escrowed bill in 10 seconds without HOLD
+	id003ResetWait  = 50              // Status request count to wait for acceptor initialization
+)
+
+type Id003Engine struct {
+	validator.CashEngine
+	protocol *Id003Protocol
+	denoms   *DenomTable
+	status   id003Status
+	holdTime time.Time
+}
+
+func (ie *Id003Engine) initEngine(cfg *config.DeviceConfig) *Id003Engine {
+	ie.InitCashEngine(cfg)
+	ie.protocol = GetId003Protocol(ie.GetLinkerConfig())
+	return ie
+}
+
+// Currency by config or the first one of denomination table
+func (ie *Id003Engine) getDefaultCurrency() common.DevCurrency {
+	if curr := ie.GetValidatorConfig().CurrCode; curr != common.CurrencyNOT {
+		return curr
+	}
+	if ie.denoms != nil {
+		for _, denom := range ie.denoms {
+			if denom != nil {
+				return denom.Currency
+			}
+		}
+	}
+	return common.CurrencyNOT
+}
+
+// Read denominations from acceptor, the note table of config is used if acceptor can't assign currency
+func (ie *Id003Engine) loadDenomTable() error {
+	valCfg := ie.GetValidatorConfig()
+	curr := valCfg.CurrCode
+	if curr == common.CurrencyNOT {
+		curr = common.CurrencyUAH
+	}
+	denoms, err := ie.protocol.CurrencyAssign(valCfg.CountryCodes, curr)
+	if code, _ := common.CheckError(err); code == common.DevErrorCommandFault {
+		denoms, err = ie.getConfigDenoms(curr)
+	}
+	if err != nil {
+		return err
+	}
+	ie.Log.Debug(denoms.String())
+	tables := validator.NoteTables{}
+	for _, denom := range denoms {
+		if denom != nil && tables[denom.Currency] == nil {
+			tables[denom.Currency] = denoms.GetNoteList(denom.Currency)
+		}
+	}
+	// Notes mask is applied to escrow codes by acceptor itself
+	err = ie.SetNoteTables(tables)
+	if err == nil {
+		ie.denoms = denoms
+	}
+	return err
+}
+
+func (ie *Id003Engine) getConfigDenoms(curr common.DevCurrency) (*DenomTable, error) {
+	for _, table := range ie.GetValidatorConfig().NoteTables {
+		if table == nil || table.Currency != curr {
+			continue
+		}
+		list, err := table.GetNoteList()
+		if err != nil {
+			return nil, common.ExtendError(common.DevErrorConfigFault, err)
+		}
+		return makeDenomTable(list), nil
+	}
+	return nil, common.NewError(common.DevErrorConfigFault,
+		fmt.Sprintf("no note table for currency %s", curr.IsoCode()))
+}
+
+// Reset acceptor and wait until initialization is over
+func (ie *Id003Engine) resetValidator() error {
+	err := ie.protocol.Reset()
+	for i := 0; err == nil && i < id003ResetWait; i++ {
+		time.Sleep(200 * time.Millisecond)
+		var data []byte
+		data, err = ie.protocol.StatusRequest()
+		if err != nil {
+			break
+		}
+		ie.processStatus(newId003Status(data))
+		switch ie.status.code {
+		case stPowerUp, stPowerUpAcceptor, stPowerUpStacker, stInitialize:
+			continue
+		}
+		return ie.getStatusError()
+	}
+	if err == nil {
+		err = common.NewError(common.DevErrorWaitTimeout, "acceptor initialization timeout")
+	}
+	return err
+}
+
+func (ie *Id003Engine) getStatusError() error {
+	if code := ie.status.GetError(); code != common.DevErrorSuccess {
+		return common.NewError(code, ie.status.String())
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////////////
+
+func (ie *Id003Engine) DevStartup() error {
+	err := ie.protocol.OpenLink()
+	if err == nil {
+		err = ie.resetValidator()
+	}
+	if err == nil {
+		var version string
+		version, err = ie.protocol.VersionRequest()
+		ie.Log.Info("ID-003 acceptor version %s", version)
+	}
+	if err == nil {
+		err = ie.loadDenomTable()
+	}
+	if err == nil {
+		err = ie.protocol.SetInhibit(true)
+	}
+	if err == nil {
+		err = ie.DevCheckBatch()
+	}
+	ie.Accept.Currency = ie.getDefaultCurrency()
+	return err
+}
+
+func (ie *Id003Engine) DevCleanup() error {
+	if ie.status.code != 0 {
+		_ = ie.protocol.SetInhibit(true)
+	}
+	return ie.protocol.CloseLink()
+}
+
+func (ie *Id003Engine) DevReset() error {
+	ie.EscrowWait = time.Time{}
+	err := ie.resetValidator()
+	if err == nil {
+		err = ie.protocol.SetInhibit(true)
+	}
+	return err
+}
+
+func (ie *Id003Engine) DevStatus() error {
+	return ie.getStatusError()
+}
+
+func (ie *Id003Engine) DevEnableBills(curr common.DevCurrency, target common.DevAmount) error {
+	if curr == common.CurrencyNOT {
+		curr = ie.Accept.Currency
+	}
+	ie.Log.Debug("Id003Engine Set currency %d - %s, target %s", curr, curr.String(), target.Format(curr))
+	if ie.denoms == nil {
+		return common.NewError(common.DevErrorNotInitialized, "denomination table is not loaded")
+	}
+	mask := ie.denoms.GetMask(curr, ie.GetValidatorConfig().NotesMask)
+	if mask == 0 {
+		return common.NewError(common.DevErrorNoCurrency,
+			fmt.Sprintf("no enabled bills for currency %d (%s)", curr, curr.IsoCode()))
+	}
+	err := ie.CheckSession(target)
+	if err == nil {
+		err = ie.protocol.SetEnable(mask)
+	}
+	if err == nil {
+		err = ie.protocol.SetInhibit(false)
+	}
+	if err == nil {
+		ie.StartSession(curr, target)
+	}
+	return err
+}
+
+func (ie *Id003Engine) DevDisableBills() error {
+	if ie.CancelEscrow() {
+		_ = ie.protocol.Return()
+	}
+	return ie.protocol.SetInhibit(true)
+}
+
+func (ie *Id003Engine) DevInitBillList(curr common.DevCurrency) error {
+	err := ie.loadDenomTable()
+	if err != nil {
+		return err
+	}
+	if curr == common.CurrencyNOT {
+		curr = ie.getDefaultCurrency()
+	}
+	return ie.InitNoteList(curr)
+}
+
+func (ie *Id003Engine) DevNoteAccept() error {
+	err := ie.HostDecision(common.NoteDecisionAccept, "accepted by host")
+	if err == nil {
+		err = ie.protocol.Stack()
+	}
+	return err
+}
+
+func (ie *Id003Engine) DevNoteReturn() error {
+	err := ie.HostDecision(common.NoteDecisionReturn, "returned by host")
+	if err == nil {
+		err = ie.protocol.Return()
+	}
+	return err
+}
+
+////////////////////////////////////////////////////////////////
+
+// Request acceptor status and process it
+func (ie *Id003Engine) pollValidator() {
+	if !ie.protocol.IsOpen() {
+		return
+	}
+	data, err := ie.protocol.StatusRequest()
+	if err != nil {
+		code, text := common.CheckError(err)
+		if code != ie.DevError {
+			_ = ie.RunExecuteError(code, text)
+		}
+		return
+	}
+	ie.processStatus(newId003Status(data))
+	if ie.CheckEscrowWait() {
+		ie.applyDecision()
+	}
+}
+
+func (ie *Id003Engine) processStatus(st id003Status) {
+	prev := ie.status
+	ie.status = st
+	if st == prev {
+		switch st.code {
+		case stEscrow:
+			ie.holdEscrow()
+		case stVendValid:
+			// Acceptor repeats VEND VALID until ACK is received
+			_ = ie.protocol.Ack()
+		}
+		return
+	}
+	ie.Log.Debug("Id003Engine status %s", st.String())
+	_ = ie.RunStateChanged(st.GetState())
+	if prompt := st.GetPrompt(); prompt != ie.DevPrompt {
+		_ = ie.RunActionPrompt(prompt)
+	}
+	if code := st.GetError(); code != common.DevErrorSuccess {
+		_ = ie.RunExecuteError(code, st.String())
+	} else {
+		ie.DevError = common.DevErrorSuccess
+	}
+	// Acceptor has no returned status, returning is over with any other status
+	if prev.code == stReturning && ie.Accept.Nominal != 0 {
+		ie.ReturnNote()
+	}
+	switch st.code {
+	case stEscrow:
+		ie.onBillEscrowed(st.extra)
+	case stVendValid:
+		ie.onVendValid()
+	}
+}
+
+func (ie *Id003Engine) setAcceptBill(escrow byte) bool {
+	index := int(escrow) - int(escrowFirst)
+	if ie.denoms == nil || index < 0 || index >= escrowCodes || ie.denoms[index] == nil {
+		return false
+	}
+	denom := ie.denoms[index]
+	ie.Accept.Currency = denom.Currency
+	ie.Accept.Nominal = denom.Nominal
+	ie.Accept.Count = 1
+	ie.Accept.Amount = denom.Nominal
+	return true
+}
+
+func (ie *Id003Engine) onBillEscrowed(escrow byte) {
+	if !ie.setAcceptBill(escrow) {
+		ie.ClearNote()
+	}
+	ie.holdTime = time.Now()
+	ie.DecideNote()
+	ie.applyDecision()
+}
+
+func (ie *Id003Engine) applyDecision() {
+	var err error
+	switch ie.Accept.Decision {
+	case common.NoteDecisionAccept:
+		err = ie.protocol.Stack()
+	case common.NoteDecisionReturn:
+		err = ie.protocol.Return()
+	default:
+	}
+	if err != nil {
+		code, text := common.CheckError(err)
+		_ = ie.RunExecuteError(code, text)
+	}
+}
+
+// Keep bill in escrow while the host is deciding
+func (ie *Id003Engine) holdEscrow() {
+	if ie.EscrowWait.IsZero() || time.Since(ie.holdTime) < id003HoldPeriod {
+		return
+	}
+	ie.holdTime = time.Now()
+	_ = ie.protocol.Hold()
+}
+
+// Bill is credited at VEND VALID, acceptor waits for ACK before it reports stacked
+func (ie *Id003Engine) onVendValid() {
+	_ = ie.protocol.Ack()
+	if ie.Accept.Nominal == 0 {
+		ie.Log.Warn("Id003Engine vend valid without escrowed bill")
+		return
+	}
+	ie.StoreNote()
+}
@@ -0,0 +1,178 @@
+package id003
+
+import (
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/config"
+	"github.com/iftsoft/device/linker/linkertest"
+	"testing"
+)
+
+var tx, rx = linkertest.TX, linkertest.RX
+
+// Status request that is answered by status
+func status(data ...byte) []*linkertest.Step {
+	return []*linkertest.Step{tx(cmdStatusRequest), rx(data...)}
+}
+
+// JCM country code that is mapped to AUD by config
+const testCountryAud = 0x10
+
+// Currency assign reply with 10, 20 and 50 of unknown country, 5 AUD and 1 of country
+// that collides with ISO 4217 numeric code of AUD
+var testAssign = []byte{cmdCurrencyAssign,
+	0x61, 0x00, 1, 1,
+	0x62, 0x00, 2, 1,
+	0x63, 0x00, 5, 1,
+	0x64, testCountryAud, 5, 0,
+	0x65, 0x00, 0, 0,
+	0x66, byte(common.CurrencyAUD), 1, 0,
+}
+
+func getTestConfig() *config.ValidatorConfig {
+	return &config.ValidatorConfig{CountryCodes: map[uint8]common.DevCurrency{testCountryAud: common.CurrencyAUD}}
+}
+
+// Engine loads denominations by currency assign exchange that starts the trace
+func newTestEngine(t *testing.T, valCfg *config.ValidatorConfig, steps ...[]*linkertest.Step) (*Id003Engine, *linkertest.ValidatorCallback) {
+	t.Helper()
+	path := linkertest.WriteTrace(t, newId003Framer(), steps...)
+	ie := (&Id003Engine{}).initEngine(&config.DeviceConfig{
+		Linker:    &config.LinkerConfig{Replay: path},
+		Validator: valCfg,
+	})
+	cb := &linkertest.ValidatorCallback{}
+	ie.CbValidator = cb
+	linkertest.OpenLink(t, ie.protocol.HalfDuplex)
+	if err := ie.loadDenomTable(); err != nil {
+		t.Fatal(err)
+	}
+	return ie, cb
+}
+
+func TestParseCurrencyAssign(t *testing.T) {
+	table, err := parseCurrencyAssign(testAssign[1:], getTestConfig().CountryCodes, common.CurrencyUAH)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		curr    common.DevCurrency
+		nominal common.DevAmount
+	}{
+		{common.CurrencyUAH, 1000},
+		{common.CurrencyUAH, 2000},
+		{common.CurrencyUAH, 5000},
+		{common.CurrencyAUD, 500},
+	}
+	for i, w := range want {
+		denom := table[i]
+		if denom == nil || denom.Escrow != escrowFirst+byte(i) || denom.Currency != w.curr || denom.Nominal != w.nominal {
+			t.Errorf("escrow %02X is %s, want %d %s", escrowFirst+byte(i), denom, w.nominal, w.curr.IsoCode())
+		}
+	}
+	if table[4] != nil {
+		t.Errorf("zero base value is taken as %s", table[4])
+	}
+	if denom := table[5]; denom == nil || denom.Currency != common.CurrencyUAH || denom.Nominal != 100 {
+		t.Errorf("unmapped country code is taken as %s", denom)
+	}
+	if mask := table.GetMask(common.CurrencyUAH, 0x0A); mask != 0x0022 {
+		t.Errorf("mask of 1 and 20 UAH %04X, want 0022", mask)
+	}
+}
+
+// Acceptor without currency assign command takes note table of config
+func TestEngineConfigDenoms(t *testing.T) {
+	valCfg := &config.ValidatorConfig{
+		CurrCode:   common.CurrencyUAH,
+		NoteTables: []*config.NoteTableConfig{{Currency: common.CurrencyUAH, Nominals: []string{"10", "50"}}},
+	}
+	ie, _ := newTestEngine(t, valCfg,
+		[]*linkertest.Step{tx(cmdCurrencyAssign), rx(replyInvalid)},
+	)
+	if ie.denoms[0] == nil || ie.denoms[0].Nominal != 1000 || ie.denoms[1] == nil || ie.denoms[1].Nominal != 5000 {
+		t.Errorf("config denominations %s", ie.denoms)
+	}
+	if len(ie.NoteTables[common.CurrencyUAH]) != 2 {
+		t.Errorf("note tables %v", ie.NoteTables)
+	}
+}
+
+// Bill that fits session target is stacked and credited at VEND VALID
+func TestEngineStacksBill(t *testing.T) {
+	ie, cb := newTestEngine(t, nil,
+		[]*linkertest.Step{tx(cmdCurrencyAssign), rx(testAssign...)},
+		status(stAccepting),
+		status(stEscrow, 0x63),
+		[]*linkertest.Step{tx(cmdStack1), rx(cmdAck)},
+		status(stStacking),
+		status(stVendValid),
+		[]*linkertest.Step{tx(cmdAck)},
+		status(stVendValid),
+		[]*linkertest.Step{tx(cmdAck)},
+		status(stStacked),
+		status(stIdling),
+	)
+	ie.StartSession(common.CurrencyUAH, 10000)
+	for i := 0; i < 7; i++ {
+		ie.pollValidator()
+	}
+	linkertest.CheckEvents(t, &ie.BaseEngine, cb, "accepted 5000 Accept", "stored 5000")
+	if ie.Stacked != 1 || ie.Session.Total != 5000 {
+		t.Errorf("stacked %d, session total %d", ie.Stacked, ie.Session.Total)
+	}
+}
+
+// Bill over session target is returned, returning is over with the next status
+func TestEngineReturnsBill(t *testing.T) {
+	ie, cb := newTestEngine(t, nil,
+		[]*linkertest.Step{tx(cmdCurrencyAssign), rx(testAssign...)},
+		status(stEscrow, 0x63),
+		[]*linkertest.Step{tx(cmdReturn), rx(cmdAck)},
+		status(stReturning),
+		status(stIdling),
+	)
+	ie.StartSession(common.CurrencyUAH, 2000)
+	for i := 0; i < 3; i++ {
+		ie.pollValidator()
+	}
+	linkertest.CheckEvents(t, &ie.BaseEngine, cb, "accepted 5000 Return", "returned 5000")
+	if ie.Stacked != 0 || ie.Session.Total != 0 {
+		t.Errorf("stacked %d, session total %d", ie.Stacked, ie.Session.Total)
+	}
+}
+
+// Bill of other currency than session one is returned
+func TestEngineReturnsOtherCurrency(t *testing.T) {
+	ie, cb := newTestEngine(t, getTestConfig(),
+		[]*linkertest.Step{tx(cmdCurrencyAssign), rx(testAssign...)},
+		status(stEscrow, 0x64),
+		[]*linkertest.Step{tx(cmdReturn), rx(cmdAck)},
+		status(stReturning),
+	)
+	ie.StartSession(common.CurrencyUAH, 0)
+	ie.pollValidator()
+	ie.pollValidator()
+	linkertest.CheckEvents(t, &ie.BaseEngine, cb, "accepted 500 Return")
+}
+
+// Session without target waits for the host decision
+func TestEngineHostDecision(t *testing.T) {
+	ie, cb := newTestEngine(t, nil,
+		[]*linkertest.Step{tx(cmdCurrencyAssign), rx(testAssign...)},
+		status(stEscrow, 0x62),
+		[]*linkertest.Step{tx(cmdReturn), rx(cmdAck)},
+		status(stReturning),
+		status(stIdling),
+	)
+	ie.StartSession(common.CurrencyUAH, 0)
+	ie.pollValidator()
+	if ie.EscrowWait.IsZero() {
+		t.Fatal("engine does not wait for host decision")
+	}
+	if err := ie.DevNoteReturn(); err != nil {
+		t.Fatal(err)
+	}
+	ie.pollValidator()
+	ie.pollValidator()
+	linkertest.CheckEvents(t, &ie.BaseEngine, cb, "accepted 2000 Host", "returned 2000")
+}
@@ -0,0 +1,23 @@
+package id003
+
+import (
+	"github.com/iftsoft/device/linker"
+)
+
+const id003Sync byte = 0xFC // Frame synchronization byte
+
+////////////////////////////////////////////////////////////////
+// Data flow:  SYNC, LNG, CMD, []DATA, CRC16 (LSB first)
+// LNG is the full frame size including SYNC and CRC bytes
+
+func newId003Framer() linker.Framer {
+	return linker.NewSizeFramer(linker.CheckCRC16, id003Sync)
+}
+
+// Acceptor answers at once, lost frames are repeated by the next status request
+func getId003Options() *linker.HalfDuplexOptions {
+	opts := linker.GetDefaultHalfDuplexOptions()
+	opts.Retries = 0
+	opts.Timeout = 200
+	return opts
+}
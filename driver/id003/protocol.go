@@ -0,0 +1,303 @@
+package id003
+
+import (
+	"fmt"
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/config"
+	"github.com/iftsoft/device/core"
+	"github.com/iftsoft/device/linker"
+	"strings"
+	"sync"
+)
+
+// ID-003 controller commands
+const (
+	cmdStatusRequest  byte = 0x11
+	cmdReset          byte = 0x40
+	cmdStack1         byte = 0x41
+	cmdStack2         byte = 0x42
+	cmdReturn         byte = 0x43
+	cmdHold           byte = 0x44
+	cmdWait           byte = 0x45
+	cmdAck            byte = 0x50
+	cmdSetEnable      byte = 0xC0
+	cmdSetSecurity    byte = 0xC1
+	cmdSetInhibit     byte = 0xC3
+	cmdVersionRequest byte = 0x88
+	cmdCurrencyAssign byte = 0x8A
+
+	replyInvalid byte = 0x4B // Invalid command reply
+	escrowFirst  byte = 0x61 // Escrow code of the first denomination
+	escrowCodes       = 16   // Count of denominations in enable mask
+)
+
+// Denomination describes the escrow code of the acceptor
+type Denomination struct {
+	Escrow   byte
+	Country  byte
+	Currency common.DevCurrency
+	Nominal  common.DevAmount
+}
+
+func (dn *Denomination) String() string {
+	if dn == nil {
+		return ""
+	}
+	return fmt.Sprintf("Escrow %02X: %9s %s (country %02X)",
+		dn.Escrow, dn.Nominal.Format(dn.Currency), dn.Currency.IsoCode(), dn.Country)
+}
+
+type DenomTable [escrowCodes]*Denomination
+
+func (dt *DenomTable) String() string {
+	str := "ID-003 denomination table:"
+	for _, denom := range dt {
+		if denom != nil {
+			str += "\n    " + denom.String()
+		}
+	}
+	return str
+}
+
+type Id003Protocol struct {
+	*linker.HalfDuplex
+	log  *core.LogAgent
+	lock sync.Mutex
+}
+
+func GetId003Protocol(cfg *config.LinkerConfig) *Id003Protocol {
+	ip := &Id003Protocol{
+		HalfDuplex: linker.NewHalfDuplex(cfg, newId003Framer(), getId003Options(), "ID003"),
+		log:        core.GetLogAgent(core.LogLevelDump, "ID003"),
+	}
+	return ip
+}
+
+////////////////////////////////////////////////////////////////
+
+// StatusRequest returns status code and its data of the acceptor
+func (ip *Id003Protocol) StatusRequest() ([]byte, error) {
+	back, err := ip.request(cmdStatusRequest, nil)
+	if err == nil && len(back) == 0 {
+		err = common.NewError(common.DevErrorProtocolFault, "empty status reply")
+	}
+	ip.logError("StatusRequest", err)
+	return back, err
+}
+
+// Ack confirms VEND VALID status, acceptor does not answer it
+func (ip *Id003Protocol) Ack() error {
+	ip.lock.Lock()
+	defer ip.lock.Unlock()
+	err := ip.Send([]byte{cmdAck})
+	ip.logError("Ack", err)
+	return err
+}
+
+func (ip *Id003Protocol) Reset() error {
+	err := ip.operation(cmdReset)
+	ip.logError("Reset", err)
+	return err
+}
+
+func (ip *Id003Protocol) Stack() error {
+	err := ip.operation(cmdStack1)
+	ip.logError("Stack", err)
+	return err
+}
+
+func (ip *Id003Protocol) Return() error {
+	err := ip.operation(cmdReturn)
+	ip.logError("Return", err)
+	return err
+}
+
+// Hold keeps the bill in escrow for another 10 seconds
+func (ip *Id003Protocol) Hold() error {
+	err := ip.operation(cmdHold)
+	ip.logError("Hold", err)
+	return err
+}
+
+// SetInhibit stops accepting of bills, acceptor goes to DISABLE state
+func (ip *Id003Protocol) SetInhibit(inhibit bool) error {
+	data := []byte{0x00}
+	if inhibit {
+		data[0] = 0x01
+	}
+	err := ip.setting(cmdSetInhibit, data)
+	ip.logError("SetInhibit", err)
+	return err
+}
+
+// SetEnable enables denominations by mask, bit 0 is escrow code 0x61
+func (ip *Id003Protocol) SetEnable(mask uint16) error {
+	// Bit set in command data disables the denomination
+	mask = ^mask
+	err := ip.setting(cmdSetEnable, []byte{byte(mask), byte(mask >> 8)})
+	ip.logError("SetEnable", err)
+	return err
+}
+
+func (ip *Id003Protocol) SetSecurity(mask uint16) error {
+	err := ip.setting(cmdSetSecurity, []byte{byte(mask), byte(mask >> 8)})
+	ip.logError("SetSecurity", err)
+	return err
+}
+
+func (ip *Id003Protocol) VersionRequest() (string, error) {
+	back, err := ip.request(cmdVersionRequest, nil)
+	var text string
+	if err == nil {
+		if len(back) < 1 || back[0] != cmdVersionRequest {
+			err = common.NewError(common.DevErrorProtocolFault, "wrong version reply")
+		} else {
+			text = strings.TrimSpace(string(back[1:]))
+		}
+	}
+	ip.logError("VersionRequest", err)
+	return text, err
+}
+
+// CurrencyAssign reads denominations of escrow codes, country codes are mapped to currencies by countries,
+// curr is used for unknown country codes
+func (ip *Id003Protocol) CurrencyAssign(countries map[uint8]common.DevCurrency, curr common.DevCurrency) (*DenomTable, error) {
+	back, err := ip.request(cmdCurrencyAssign, nil)
+	if err == nil && (len(back) < 1 || back[0] != cmdCurrencyAssign) {
+		err = common.NewError(common.DevErrorProtocolFault, "wrong currency assign reply")
+	}
+	var table *DenomTable
+	if err == nil {
+		table, err = parseCurrencyAssign(back[1:], countries, curr)
+	}
+	ip.logError("CurrencyAssign", err)
+	return table, err
+}
+
+////////////////////////////////////////////////////////////////
+
+func (ip *Id003Protocol) logError(cmd string, err error) {
+	code, text := common.CheckError(err)
+	ip.log.Trace("Id003Protocol.%s return: %d - %s", cmd, code, text)
+}
+
+// Send operation command that is answered by ACK
+func (ip *Id003Protocol) operation(cmd byte) error {
+	back, err := ip.request(cmd, nil)
+	if err == nil && (len(back) != 1 || back[0] != cmdAck) {
+		err = common.NewError(common.DevErrorProtocolFault,
+			fmt.Sprintf("unexpected reply %s", core.GetBinaryDump(back)))
+	}
+	return err
+}
+
+// Send setting command that is answered by echo
+func (ip *Id003Protocol) setting(cmd byte, data []byte) error {
+	back, err := ip.request(cmd, data)
+	if err == nil && (len(back) < 1 || back[0] != cmd) {
+		err = common.NewError(common.DevErrorProtocolFault,
+			fmt.Sprintf("unexpected reply %s", core.GetBinaryDump(back)))
+	}
+	return err
+}
+
+func (ip *Id003Protocol) request(cmd byte, data []byte) ([]byte, error) {
+	ip.lock.Lock()
+	defer ip.lock.Unlock()
+	ip.log.Dump("Id003Protocol request data : %02X %s", cmd, core.GetBinaryDump(data))
+	back, err := ip.Exchange(append([]byte{cmd}, data...))
+	if err != nil {
+		return nil, err
+	}
+	if len(back) == 1 && back[0] == replyInvalid {
+		return nil, common.NewError(common.DevErrorCommandFault,
+			fmt.Sprintf("invalid command %02X", cmd))
+	}
+	return back, nil
+}
+
+////////////////////////////////////////////////////////////////
+
+// Currency assign entry: escrow code, country code, base value, exponent of ten
+func parseCurrencyAssign(data []byte, countries map[uint8]common.DevCurrency, curr common.DevCurrency) (*DenomTable, error) {
+	table := &DenomTable{}
+	for i := 0; i+4 <= len(data); i += 4 {
+		item := data[i : i+4]
+		index := int(item[0]) - int(escrowFirst)
+		if index < 0 || index >= escrowCodes || item[2] == 0 {
+			continue
+		}
+		code := getCountryCurrency(item[1], countries, curr)
+		nominal := common.DevAmount(item[2]) * code.Scale()
+		for j := 0; j < int(item[3]); j++ {
+			nominal *= 10
+		}
+		table[index] = &Denomination{
+			Escrow:   item[0],
+			Country:  item[1],
+			Currency: code,
+			Nominal:  nominal,
+		}
+	}
+	return table, nil
+}
+
+// JCM country code is not ISO 4217 one, it is mapped by config and unknown one falls back to configured currency
+func getCountryCurrency(country byte, countries map[uint8]common.DevCurrency, curr common.DevCurrency) common.DevCurrency {
+	if code, ok := countries[country]; ok && common.GetCurrencyInfo(code) != nil {
+		return code
+	}
+	return curr
+}
+
+// Make denomination table from note list, escrow codes go in list order
+func makeDenomTable(list common.ValidNoteList) *DenomTable {
+	table := &DenomTable{}
+	for i, note := range list {
+		if i >= escrowCodes {
+			break
+		}
+		table[i] = &Denomination{
+			Escrow:   escrowFirst + byte(i),
+			Currency: note.Currency,
+			Nominal:  note.Nominal,
+		}
+	}
+	return table
+}
+
+// GetNoteList returns unique nominals of the currency in escrow code order
+func (dt *DenomTable) GetNoteList(curr common.DevCurrency) common.ValidNoteList {
+	list := make(common.ValidNoteList, 0)
+	for _, denom := range dt {
+		if denom == nil || denom.Currency != curr {
+			continue
+		}
+		dup := false
+		for _, note := range list {
+			dup = dup || note.Nominal == denom.Nominal
+		}
+		if !dup {
+			list = append(list, &common.ValidatorNote{Currency: curr, Nominal: denom.Nominal})
+		}
+	}
+	return list
+}
+
+// GetMask returns enable mask of the currency filtered by notes mask, zero notes mask enables all.
+// Bits of notes mask stand for nominals of the currency note list as in validator note tables.
+func (dt *DenomTable) GetMask(curr common.DevCurrency, notesMask int64) uint16 {
+	list := dt.GetNoteList(curr)
+	var mask uint16
+	for i, denom := range dt {
+		if denom == nil || denom.Currency != curr {
+			continue
+		}
+		for j, note := range list {
+			if note.Nominal == denom.Nominal && (notesMask == 0 || notesMask&(1<<uint(j)) != 0) {
+				mask |= 1 << uint(i)
+			}
+		}
+	}
+	return mask
+}
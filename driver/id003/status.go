@@ -0,0 +1,199 @@
+package id003
+
+import (
+	"fmt"
+	"github.com/iftsoft/device/common"
+)
+
+// ID-003 status codes
+const (
+	stIdling           byte = 0x11
+	stAccepting        byte = 0x12
+	stEscrow           byte = 0x13
+	stStacking         byte = 0x14
+	stVendValid        byte = 0x15
+	stStacked          byte = 0x16
+	stRejecting        byte = 0x17
+	stReturning        byte = 0x18
+	stHolding          byte = 0x19
+	stDisable          byte = 0x1A
+	stInitialize       byte = 0x1B
+	stPowerUp          byte = 0x40
+	stPowerUpAcceptor  byte = 0x41
+	stPowerUpStacker   byte = 0x42
+	stStackerFull      byte = 0x43
+	stStackerOpen      byte = 0x44
+	stAcceptorJam      byte = 0x45
+	stStackerJam       byte = 0x46
+	stPause            byte = 0x47
+	stCheated          byte = 0x48
+	stFailure          byte = 0x49
+	stCommError        byte = 0x4A
+)
+
+// Status of the acceptor with the extra data byte
+type id003Status struct {
+	code  byte
+	extra byte
+}
+
+func newId003Status(data []byte) id003Status {
+	st := id003Status{code: data[0]}
+	if len(data) > 1 {
+		st.extra = data[1]
+	}
+	return st
+}
+
+func (st id003Status) String() string {
+	str := fmt.Sprintf("%02X - %s", st.code, getStatusText(st.code))
+	switch st.code {
+	case stRejecting:
+		str += ": " + getRejectText(st.extra)
+	case stFailure:
+		str += ": " + getFailureText(st.extra)
+	case stEscrow:
+		str += fmt.Sprintf(": escrow code %02X", st.extra)
+	}
+	return str
+}
+
+// GetState maps the status code to device state
+func (st id003Status) GetState() common.EnumDevState {
+	switch st.code {
+	case stPowerUp, stPowerUpAcceptor, stPowerUpStacker, stInitialize, stPause:
+		return common.DevStateWorking
+	case stIdling:
+		return common.DevStateWaiting
+	case stAccepting:
+		return common.DevStateCashAccepting
+	case stEscrow, stHolding:
+		return common.DevStateCashEscrowed
+	case stStacking, stVendValid:
+		return common.DevStateCashStacking
+	case stStacked:
+		return common.DevStateCashStacked
+	case stRejecting:
+		return common.DevStateCashRejecting
+	case stReturning:
+		return common.DevStateCashReturning
+	case stDisable:
+		return common.DevStateStandby
+	case stStackerFull:
+		return common.DevStateCashStackerFull
+	case stAcceptorJam, stStackerJam:
+		return common.DevStateCashBillJammed
+	case stStackerOpen, stFailure:
+		return common.DevStateHardError
+	case stCheated, stCommError:
+		return common.DevStateSoftError
+	default:
+		return common.DevStateUndefined
+	}
+}
+
+// GetError maps the status code to device error, success is returned for normal states
+func (st id003Status) GetError() common.EnumDevError {
+	switch st.code {
+	case stStackerFull:
+		return common.DevErrorStackerFull
+	case stStackerOpen:
+		return common.DevErrorCassetteMiss
+	case stAcceptorJam, stStackerJam:
+		return common.DevErrorBillJammed
+	case stCheated:
+		return common.DevErrorSecurityFault
+	case stFailure:
+		return common.DevErrorHardwareFault
+	case stCommError:
+		return common.DevErrorProtocolFault
+	default:
+		return common.DevErrorSuccess
+	}
+}
+
+// GetPrompt maps the status code to customer prompt
+func (st id003Status) GetPrompt() common.EnumDevPrompt {
+	switch st.code {
+	case stIdling:
+		return common.DevPromptCashInsertBill
+	case stAccepting:
+		return common.DevPromptCashAccepting
+	case stEscrow, stHolding:
+		return common.DevPromptCashEscrowed
+	case stStacking, stVendValid:
+		return common.DevPromptCashStacking
+	case stReturning, stRejecting:
+		return common.DevPromptCashReturning
+	case stStackerFull:
+		return common.DevPromptCashStackerFull
+	case stAcceptorJam, stStackerJam:
+		return common.DevPromptCashBillJammed
+	case stStackerOpen, stCheated, stFailure, stCommError:
+		return common.DevPromptCashFailure
+	default:
+		return common.DevPromptNone
+	}
+}
+
+func getStatusText(code byte) string {
+	switch code {
+	case stIdling:				return "Idling"
+	case stAccepting:			return "Accepting"
+	case stEscrow:				return "Escrow"
+	case stStacking:			return "Stacking"
+	case stVendValid:			return "Vend valid"
+	case stStacked:				return "Stacked"
+	case stRejecting:			return "Rejecting"
+	case stReturning:			return "Returning"
+	case stHolding:				return "Holding"
+	case stDisable:				return "Disable"
+	case stInitialize:			return "Initialize"
+	case stPowerUp:				return "Power up"
+	case stPowerUpAcceptor:		return "Power up with bill in acceptor"
+	case stPowerUpStacker:		return "Power up with bill in stacker"
+	case stStackerFull:			return "Stacker full"
+	case stStackerOpen:			return "Stacker open"
+	case stAcceptorJam:			return "Jam in acceptor"
+	case stStackerJam:			return "Jam in stacker"
+	case stPause:				return "Pause"
+	case stCheated:				return "Cheated"
+	case stFailure:				return "Failure"
+	case stCommError:			return "Communication error"
+	default:					return "Unknown status"
+	}
+}
+
+func getRejectText(code byte) string {
+	switch code {
+	case 0x71:	return "Insertion error"
+	case 0x72:	return "Mag pattern error"
+	case 0x73:	return "Return action due to residual bill"
+	case 0x74:	return "Calibration error"
+	case 0x75:	return "Conveying error"
+	case 0x76:	return "Discrimination error"
+	case 0x77:	return "Photo pattern error"
+	case 0x78:	return "Photo level error"
+	case 0x79:	return "Return by inhibit"
+	case 0x7B:	return "Operation error"
+	case 0x7C:	return "Return action due to residual bill"
+	case 0x7D:	return "Length error"
+	case 0x7E:	return "Photo pattern error"
+	default:	return "Unknown reason"
+	}
+}
+
+func getFailureText(code byte) string {
+	switch code {
+	case 0xA2:	return "Stack motor failure"
+	case 0xA5:	return "Transport motor speed failure"
+	case 0xA6:	return "Transport motor failure"
+	case 0xAB:	return "Cash box not ready"
+	case 0xAF:	return "Validator head remove"
+	case 0xB0:	return "Boot ROM failure"
+	case 0xB1:	return "External ROM failure"
+	case 0xB2:	return "ROM failure"
+	case 0xB3:	return "External ROM writing failure"
+	default:	return "Unknown failure"
+	}
+}
@@ -108,6 +108,7 @@ func (dd *LoopbackDriver) CheckDevice(metrics *common.SystemMetrics) error {
 		metrics.Uptime = time.Now().Unix() - dd.begTime
 		metrics.DevState = dd.devState
 		metrics.DevError = dd.devError
+		dd.protocol.ReportMetrics(metrics)
 	}
 	return nil
 }
@@ -168,6 +169,11 @@ func (dd *LoopbackDriver) PrintText(name string, query *common.PrinterQuery) err
 	dd.devError, dd.errorText = common.CheckError(err)
 	return dd.dummyDeviceReply(name, common.CmdPrintText, query)
 }
+func (dd *LoopbackDriver) PrintDocument(name string, query *common.PrinterDocument) error {
+	err := dd.protocol.CheckLink()
+	dd.devError, dd.errorText = common.CheckError(err)
+	return dd.dummyDeviceReply(name, common.CmdPrintDocument, query)
+}
 
 // Implementation of common.ReaderManager
 //
@@ -309,6 +315,21 @@ func (dd *LoopbackDriver) TestWorkKey(name string, query *common.ReaderPinQuery)
 	dd.devError, dd.errorText = common.CheckError(err)
 	return dd.dummyPinPadReply(name, common.CmdTestWorkKey, query)
 }
+func (dd *LoopbackDriver) LoadDukptKey(name string, query *common.ReaderPinQuery) error {
+	err := dd.protocol.CheckLink()
+	dd.devError, dd.errorText = common.CheckError(err)
+	return dd.dummyPinPadReply(name, common.CmdLoadDukptKey, query)
+}
+func (dd *LoopbackDriver) GenerateMAC(name string, query *common.ReaderPinQuery) error {
+	err := dd.protocol.CheckLink()
+	dd.devError, dd.errorText = common.CheckError(err)
+	return dd.dummyPinPadReply(name, common.CmdGenerateMAC, query)
+}
+func (dd *LoopbackDriver) ReadData(name string, query *common.ReaderDataQuery) error {
+	err := dd.protocol.CheckLink()
+	dd.devError, dd.errorText = common.CheckError(err)
+	return dd.dummyDeviceReply(name, common.CmdReadData, query)
+}
 
 func (dd *LoopbackDriver) dummyPinPadReply(name string, cmd string, query interface{}) error {
 	if dd.log != nil {
@@ -0,0 +1,170 @@
+package mdb
+
+import (
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/dbase"
+	"github.com/iftsoft/device/dbase/dbvalid"
+	"github.com/iftsoft/device/driver"
+	"time"
+)
+
+type BillDriver struct {
+	BillEngine
+	storage dbase.DBaseLinker
+	begTime int64
+}
+
+func NewBillDriver() *BillDriver {
+	bd := BillDriver{}
+	return &bd
+}
+
+// Implementation of DeviceDriver interface
+func (bd *BillDriver) InitDevice(context *driver.Context) error {
+	bd.initEngine(context.Config)
+	bd.DevName = context.DevName
+	bd.begTime = time.Now().Unix()
+	bd.Log.Debug("BillDriver run cmd:%s", "InitDevice")
+
+	mask := common.ScopeFlagSystem
+	if device, ok := context.Manager.(common.DeviceCallback); ok {
+		bd.CbDevice = device
+		mask |= common.ScopeFlagDevice
+	}
+	if validator, ok := context.Manager.(common.ValidatorCallback); ok {
+		bd.CbValidator = validator
+		mask |= common.ScopeFlagValidator
+	}
+	if context.Storage != nil {
+		bd.storage = context.Storage
+		bd.Booker = dbvalid.NewDBaseValidator(bd.storage, bd.DevName)
+	}
+	if context.Greeting != nil {
+		context.Greeting.DevType = common.DevTypeCashValidator
+		context.Greeting.Required = mask
+	}
+	return nil
+}
+
+func (bd *BillDriver) StartDevice(query *common.SystemConfig) error {
+	bd.Log.Debug("BillDriver run cmd:%s", "StartDeviceLoop")
+	var err error
+	if bd.Config != nil && query != nil {
+		bd.Config.OverwriteConfig(query)
+	}
+	if bd.storage != nil {
+		err = bd.storage.Open()
+	}
+	if err == nil {
+		err = bd.DevStartup()
+	}
+	return err
+}
+func (bd *BillDriver) DeviceTimer(unix int64) error {
+	bd.Log.Trace("BillDriver run cmd:%s", "DeviceTimer")
+	bd.pollValidator()
+	return nil
+}
+func (bd *BillDriver) StopDevice() error {
+	bd.Log.Debug("BillDriver run cmd:%s", "StopDeviceLoop")
+	err := bd.DevCleanup()
+	if bd.storage != nil {
+		_ = bd.storage.Close()
+	}
+	return err
+}
+func (bd *BillDriver) CheckDevice(metrics *common.SystemMetrics) error {
+	bd.Log.Debug("BillDriver run cmd:%s", "CheckDevice")
+	if metrics != nil {
+		metrics.Uptime = time.Now().Unix() - bd.begTime
+		metrics.DevState = bd.DevState
+		metrics.DevError = bd.DevError
+		bd.CheckStacker(metrics)
+		bd.protocol.ReportMetrics(metrics)
+	}
+	return nil
+}
+
+// Implementation of common.DeviceManager
+//
+func (bd *BillDriver) Cancel(name string, query *common.DeviceQuery) error {
+	err := bd.DevDisableBills()
+	bd.DevError, bd.DevReply = common.CheckError(err)
+	return bd.RunDeviceReply(common.CmdDeviceCancel)
+}
+func (bd *BillDriver) Reset(name string, query *common.DeviceQuery) error {
+	err := bd.DevReset()
+	bd.DevError, bd.DevReply = common.CheckError(err)
+	return bd.RunDeviceReply(common.CmdDeviceReset)
+}
+func (bd *BillDriver) Status(name string, query *common.DeviceQuery) error {
+	err := bd.DevStatus()
+	bd.DevError, bd.DevReply = common.CheckError(err)
+	return bd.RunDeviceReply(common.CmdDeviceStatus)
+}
+func (bd *BillDriver) RunAction(name string, query *common.DeviceQuery) error {
+	err := bd.DevEnableBills(common.CurrencyNOT, 0)
+	if err == nil {
+		err = bd.DevStatus()
+	}
+	bd.DevError, bd.DevReply = common.CheckError(err)
+	return bd.RunDeviceReply(common.CmdRunAction)
+}
+func (bd *BillDriver) StopAction(name string, query *common.DeviceQuery) error {
+	err := bd.DevDisableBills()
+	if err == nil {
+		err = bd.DevStatus()
+	}
+	bd.DevError, bd.DevReply = common.CheckError(err)
+	return bd.RunDeviceReply(common.CmdStopAction)
+}
+
+// Implementation of common.ValidatorManager
+//
+func (bd *BillDriver) InitValidator(name string, query *common.ValidatorQuery) error {
+	err := bd.DevReset()
+	if err == nil {
+		err = bd.DevInitBillList(query.Currency)
+	}
+	bd.DevError, bd.DevReply = common.CheckError(err)
+	return bd.RunValidatorStore(common.CmdInitValidator)
+}
+func (bd *BillDriver) DoValidate(name string, query *common.ValidatorQuery) error {
+	err := bd.DevEnableBills(query.Currency, query.Target)
+	if err == nil {
+		err = bd.DevStatus()
+	}
+	bd.DevError, bd.DevReply = common.CheckError(err)
+	return bd.RunValidatorStore(common.CmdDoValidate)
+}
+func (bd *BillDriver) NoteAccept(name string, query *common.ValidatorQuery) error {
+	err := bd.DevNoteAccept()
+	bd.DevError, bd.DevReply = common.CheckError(err)
+	return err
+}
+func (bd *BillDriver) NoteReturn(name string, query *common.ValidatorQuery) error {
+	err := bd.DevNoteReturn()
+	bd.DevError, bd.DevReply = common.CheckError(err)
+	return err
+}
+func (bd *BillDriver) StopValidate(name string, query *common.ValidatorQuery) error {
+	err := bd.DevDisableBills()
+	if err == nil {
+		err = bd.DevStatus()
+	}
+	bd.DevError, bd.DevReply = common.CheckError(err)
+	return bd.RunValidatorStore(common.CmdStopValidate)
+}
+func (bd *BillDriver) CheckValidator(name string, query *common.ValidatorQuery) error {
+	err := bd.DevCheckBatch()
+	bd.DevError, bd.DevReply = common.CheckError(err)
+	return bd.RunValidatorStore(common.CmdCheckValidator)
+}
+func (bd *BillDriver) ClearValidator(name string, query *common.ValidatorQuery) error {
+	err := bd.DevClearBatch()
+	if err == nil {
+		err = bd.DevCheckBatch()
+	}
+	bd.DevError, bd.DevReply = common.CheckError(err)
+	return bd.RunValidatorStore(common.CmdClearValidator)
+}
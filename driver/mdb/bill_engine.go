@@ -0,0 +1,297 @@
+package mdb
+
+import (
+	"fmt"
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/config"
+	"github.com/iftsoft/device/driver/validator"
+	"time"
+)
+
+const mdbResetWait = 20 // Poll count to wait for peripheral initialization
+
+type BillEngine struct {
+	validator.CashEngine
+	protocol *MdbProtocol
+	setup    *BillSetup
+	scale    MdbScale
+	bills    *CashTable
+	enabled  bool
+}
+
+func (be *BillEngine) initEngine(cfg *config.DeviceConfig) *BillEngine {
+	be.InitCashEngine(cfg)
+	be.protocol = GetMdbProtocol(be.GetLinkerConfig())
+	return be
+}
+
+// Read validator setup and rebuild bill table, note tables and escrow policy
+func (be *BillEngine) loadBillTable() error {
+	setup, err := be.protocol.BillSetup()
+	if err != nil {
+		return err
+	}
+	be.Log.Debug(setup.String())
+	scale, err := newMdbScale(setup.Country, setup.Scale, setup.Decimals, be.GetValidatorConfig())
+	if err != nil {
+		return err
+	}
+	bills := newCashTable(setup.Credits, scale)
+	be.Log.Debug(bills.String())
+	tables := validator.NoteTables{scale.Currency: bills.GetNoteList(scale.Currency)}
+	// Notes mask is applied to bill types by validator itself
+	err = be.SetNoteTables(tables)
+	if err == nil {
+		be.setup, be.scale, be.bills = setup, scale, bills
+	}
+	return err
+}
+
+// Reset validator and wait for its reset activity
+func (be *BillEngine) resetValidator() error {
+	be.enabled = false
+	be.EscrowWait = time.Time{}
+	err := be.protocol.Reset(cmdBillReset)
+	for i := 0; err == nil && i < mdbResetWait; i++ {
+		time.Sleep(100 * time.Millisecond)
+		var data []byte
+		data, err = be.protocol.Poll(cmdBillPoll)
+		if err != nil {
+			break
+		}
+		for _, act := range parseBillActivity(data) {
+			be.Log.Debug("BillEngine activity %s", act.String())
+			if byte(act) == bstWasReset {
+				return nil
+			}
+		}
+	}
+	if err == nil {
+		err = common.NewError(common.DevErrorWaitTimeout, "validator initialization timeout")
+	}
+	return err
+}
+
+////////////////////////////////////////////////////////////////
+
+func (be *BillEngine) DevStartup() error {
+	err := be.protocol.OpenLink()
+	if err == nil {
+		err = be.resetValidator()
+	}
+	if err == nil {
+		err = be.loadBillTable()
+	}
+	if err == nil {
+		var ident *MdbIdent
+		ident, err = be.protocol.Identification(cmdBillExpansion)
+		be.Log.Info("MDB bill validator %s", ident.String())
+	}
+	if err == nil {
+		err = be.DevCheckBatch()
+	}
+	if err == nil {
+		_ = be.RunStateChanged(common.DevStateStandby)
+	}
+	be.Accept.Currency = be.scale.Currency
+	return err
+}
+
+func (be *BillEngine) DevCleanup() error {
+	if be.enabled {
+		_ = be.protocol.BillType(0, 0)
+	}
+	return be.protocol.CloseLink()
+}
+
+func (be *BillEngine) DevReset() error {
+	return be.resetValidator()
+}
+
+// Status reads stacker state of the validator
+func (be *BillEngine) DevStatus() error {
+	full, count, err := be.protocol.BillStacker()
+	if err != nil {
+		return err
+	}
+	be.StackerFull = full
+	if full {
+		return common.NewError(common.DevErrorStackerFull, fmt.Sprintf("stacker is full with %d bills", count))
+	}
+	return nil
+}
+
+func (be *BillEngine) DevEnableBills(curr common.DevCurrency, target common.DevAmount) error {
+	if curr == common.CurrencyNOT {
+		curr = be.Accept.Currency
+	}
+	be.Log.Debug("BillEngine Set currency %d - %s, target %s", curr, curr.String(), target.Format(curr))
+	if be.bills == nil {
+		return common.NewError(common.DevErrorNotInitialized, "bill table is not loaded")
+	}
+	mask := be.bills.GetMask(curr, be.GetValidatorConfig().NotesMask)
+	if mask == 0 {
+		return common.NewError(common.DevErrorNoCurrency,
+			fmt.Sprintf("no enabled bills for currency %d (%s)", curr, curr.IsoCode()))
+	}
+	var escrow uint16
+	if be.setup.Escrow {
+		escrow = mask
+	}
+	err := be.CheckSession(target)
+	if err == nil {
+		err = be.protocol.BillType(mask, escrow)
+	}
+	if err == nil {
+		be.enabled = true
+		be.StartSession(curr, target)
+		_ = be.RunStateChanged(common.DevStateWaiting)
+	}
+	return err
+}
+
+func (be *BillEngine) DevDisableBills() error {
+	if be.CancelEscrow() {
+		_ = be.protocol.BillEscrow(false)
+	}
+	err := be.protocol.BillType(0, 0)
+	if err == nil {
+		be.enabled = false
+		_ = be.RunStateChanged(common.DevStateStandby)
+	}
+	return err
+}
+
+func (be *BillEngine) DevInitBillList(curr common.DevCurrency) error {
+	err := be.loadBillTable()
+	if err != nil {
+		return err
+	}
+	if curr == common.CurrencyNOT {
+		curr = be.scale.Currency
+	}
+	return be.InitNoteList(curr)
+}
+
+func (be *BillEngine) DevNoteAccept() error {
+	err := be.HostDecision(common.NoteDecisionAccept, "accepted by host")
+	if err == nil {
+		err = be.protocol.BillEscrow(true)
+	}
+	return err
+}
+
+func (be *BillEngine) DevNoteReturn() error {
+	err := be.HostDecision(common.NoteDecisionReturn, "returned by host")
+	if err == nil {
+		err = be.protocol.BillEscrow(false)
+	}
+	return err
+}
+
+////////////////////////////////////////////////////////////////
+
+// Poll validator and process its activities
+func (be *BillEngine) pollValidator() {
+	if !be.protocol.IsOpen() {
+		return
+	}
+	data, err := be.protocol.Poll(cmdBillPoll)
+	if err != nil {
+		code, text := common.CheckError(err)
+		if code != be.DevError {
+			_ = be.RunExecuteError(code, text)
+		}
+		return
+	}
+	failed := false
+	for _, act := range parseBillActivity(data) {
+		failed = be.processActivity(act) || failed
+	}
+	if !failed {
+		be.DevError = common.DevErrorSuccess
+	}
+	if be.CheckEscrowWait() {
+		be.applyDecision()
+	}
+}
+
+// Process the activity, true is returned for error activity
+func (be *BillEngine) processActivity(act billActivity) bool {
+	be.Log.Debug("BillEngine activity %s", act.String())
+	if state := act.GetState(); state != common.DevStateUndefined {
+		_ = be.RunStateChanged(state)
+	}
+	if prompt := act.GetPrompt(); prompt != common.DevPromptNone && prompt != be.DevPrompt {
+		_ = be.RunActionPrompt(prompt)
+	}
+	code := act.GetError()
+	if code != common.DevErrorSuccess && code != be.DevError {
+		_ = be.RunExecuteError(code, act.String())
+	}
+	if act.IsRouting() {
+		switch act.Route() {
+		case routeEscrow:
+			be.onBillEscrowed(act.Type())
+		case routeStacked:
+			be.onBillStacked(act.Type())
+		case routeReturned:
+			be.onBillReturned(act.Type())
+		}
+	} else if byte(act) == bstWasReset && be.enabled {
+		// Validator was reset by itself, bill types are disabled after reset
+		be.Log.Warn("BillEngine validator was reset")
+		_ = be.DevEnableBills(be.Session.Currency, be.Session.Target)
+	}
+	return code != common.DevErrorSuccess
+}
+
+func (be *BillEngine) setAcceptBill(index int) bool {
+	bill := be.bills.GetType(index)
+	if bill == nil {
+		return false
+	}
+	be.Accept.Currency = bill.Currency
+	be.Accept.Nominal = bill.Nominal
+	be.Accept.Count = 1
+	be.Accept.Amount = bill.Nominal
+	return true
+}
+
+func (be *BillEngine) onBillEscrowed(index int) {
+	if be.bills == nil || !be.setAcceptBill(index) {
+		be.ClearNote()
+	}
+	be.DecideNote()
+	be.applyDecision()
+}
+
+func (be *BillEngine) applyDecision() {
+	var err error
+	switch be.Accept.Decision {
+	case common.NoteDecisionAccept:
+		err = be.protocol.BillEscrow(true)
+	case common.NoteDecisionReturn:
+		err = be.protocol.BillEscrow(false)
+	default:
+	}
+	if err != nil {
+		code, text := common.CheckError(err)
+		_ = be.RunExecuteError(code, text)
+	}
+}
+
+func (be *BillEngine) onBillStacked(index int) {
+	if be.Accept.Nominal == 0 && (be.bills == nil || !be.setAcceptBill(index)) {
+		be.Log.Warn("BillEngine unknown bill type %d is stacked", index)
+		return
+	}
+	be.StoreNote()
+}
+
+func (be *BillEngine) onBillReturned(index int) {
+	if be.Accept.Nominal == 0 && be.bills != nil {
+		be.setAcceptBill(index)
+	}
+	be.ReturnNote()
+}
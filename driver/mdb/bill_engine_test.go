@@ -0,0 +1,85 @@
+package mdb
+
+import (
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/config"
+	"github.com/iftsoft/device/linker/linkertest"
+	"testing"
+)
+
+// Bill setup of level 2 validator: UAH by phone code, scale 100, 2 decimals, escrow, 10, 20 and 50 UAH
+var testBillSetup = []byte{0x02, 0x03, 0x80, 0x00, 0x64, 0x02, 0x01, 0xF4, 0x00, 0x00, 0xFF, 10, 20, 50}
+
+// Command that is answered by data block and the data is acknowledged by host
+func request(cmd []byte, reply ...byte) []*linkertest.Step {
+	pack, _ := (&mdbFramer{}).Encode(cmd)
+	if len(reply) == 0 {
+		return []*linkertest.Step{linkertest.TX(pack...), linkertest.RX(0x01, mdbAck)}
+	}
+	return []*linkertest.Step{linkertest.TX(pack...), linkertest.RX(peripheralBlock(reply...)...),
+		linkertest.TX(encodeWords([]byte{mdbAck}, false)...)}
+}
+
+func newTestEngine(t *testing.T, steps ...[]*linkertest.Step) (*BillEngine, *linkertest.ValidatorCallback) {
+	t.Helper()
+	path := linkertest.WriteTrace(t, linkertest.RawFramer{}, steps...)
+	be := (&BillEngine{}).initEngine(&config.DeviceConfig{Linker: &config.LinkerConfig{Replay: path}})
+	cb := &linkertest.ValidatorCallback{}
+	be.CbValidator = cb
+	linkertest.OpenLink(t, be.protocol.HalfDuplex)
+	if err := be.loadBillTable(); err != nil {
+		t.Fatal(err)
+	}
+	return be, cb
+}
+
+func TestBillEngineStacksBill(t *testing.T) {
+	be, cb := newTestEngine(t,
+		request([]byte{cmdBillSetup}, testBillSetup...),
+		request([]byte{cmdBillPoll}, 0x91),
+		request([]byte{cmdBillEscrow, 0x01}),
+		request([]byte{cmdBillPoll}, bstBusy),
+		request([]byte{cmdBillPoll}, 0x81),
+		request([]byte{cmdBillPoll}),
+	)
+	if bill := be.bills.GetType(1); bill == nil || bill.Nominal != 2000 || bill.Currency != common.CurrencyUAH {
+		t.Fatalf("bill type 1 is %s", bill)
+	}
+	be.StartSession(common.CurrencyUAH, 10000)
+	for i := 0; i < 4; i++ {
+		be.pollValidator()
+	}
+	linkertest.CheckEvents(t, &be.BaseEngine, cb, "accepted 2000 Accept", "stored 2000")
+}
+
+func TestBillEngineReturnsBill(t *testing.T) {
+	be, cb := newTestEngine(t,
+		request([]byte{cmdBillSetup}, testBillSetup...),
+		request([]byte{cmdBillPoll}, 0x92),
+		request([]byte{cmdBillEscrow, 0x00}),
+		// Status activity goes with routing one in the same reply
+		request([]byte{cmdBillPoll}, bstBusy, 0xA2),
+	)
+	// 50 UAH is over session target
+	be.StartSession(common.CurrencyUAH, 2000)
+	be.pollValidator()
+	be.pollValidator()
+	linkertest.CheckEvents(t, &be.BaseEngine, cb, "accepted 5000 Return", "returned 5000")
+}
+
+func TestBillEngineErrors(t *testing.T) {
+	be, _ := newTestEngine(t,
+		request([]byte{cmdBillSetup}, testBillSetup...),
+		request([]byte{cmdBillPoll}, bstJammed),
+		request([]byte{cmdBillPoll}),
+	)
+	be.pollValidator()
+	if be.DevError != common.DevErrorBillJammed || be.DevState != common.DevStateCashBillJammed {
+		t.Errorf("jammed validator error %s, state %s", be.DevError, be.DevState)
+	}
+	// Error is cleared by the next poll without error activity
+	be.pollValidator()
+	if be.DevError != common.DevErrorSuccess {
+		t.Errorf("engine error %s after jam", be.DevError)
+	}
+}
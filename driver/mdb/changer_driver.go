@@ -0,0 +1,200 @@
+package mdb
+
+import (
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/dbase"
+	"github.com/iftsoft/device/dbase/dbvalid"
+	"github.com/iftsoft/device/driver"
+	"time"
+)
+
+type ChangerDriver struct {
+	ChangerEngine
+	storage dbase.DBaseLinker
+	begTime int64
+}
+
+func NewChangerDriver() *ChangerDriver {
+	cd := ChangerDriver{}
+	return &cd
+}
+
+// Implementation of DeviceDriver interface
+func (cd *ChangerDriver) InitDevice(context *driver.Context) error {
+	cd.initEngine(context.Config)
+	cd.DevName = context.DevName
+	cd.begTime = time.Now().Unix()
+	cd.Log.Debug("ChangerDriver run cmd:%s", "InitDevice")
+
+	mask := common.ScopeFlagSystem
+	if device, ok := context.Manager.(common.DeviceCallback); ok {
+		cd.CbDevice = device
+		mask |= common.ScopeFlagDevice
+	}
+	if validator, ok := context.Manager.(common.ValidatorCallback); ok {
+		cd.CbValidator = validator
+		mask |= common.ScopeFlagValidator
+	}
+	if dispenser, ok := context.Manager.(common.DispenserCallback); ok {
+		cd.dispenser.CbDispenser = dispenser
+		mask |= common.ScopeFlagDispenser
+	}
+	if context.Storage != nil {
+		cd.storage = context.Storage
+		cd.Booker = dbvalid.NewDBaseValidator(cd.storage, cd.DevName)
+	}
+	if context.Greeting != nil {
+		context.Greeting.DevType = common.DevTypeCoinValidator | common.DevTypeCoinDispenser
+		context.Greeting.Required = mask
+	}
+	return nil
+}
+
+func (cd *ChangerDriver) StartDevice(query *common.SystemConfig) error {
+	cd.Log.Debug("ChangerDriver run cmd:%s", "StartDeviceLoop")
+	var err error
+	if cd.Config != nil && query != nil {
+		cd.Config.OverwriteConfig(query)
+	}
+	if cd.storage != nil {
+		err = cd.storage.Open()
+	}
+	if err == nil {
+		err = cd.DevStartup()
+	}
+	return err
+}
+func (cd *ChangerDriver) DeviceTimer(unix int64) error {
+	cd.Log.Trace("ChangerDriver run cmd:%s", "DeviceTimer")
+	cd.pollChanger()
+	return nil
+}
+func (cd *ChangerDriver) StopDevice() error {
+	cd.Log.Debug("ChangerDriver run cmd:%s", "StopDeviceLoop")
+	err := cd.DevCleanup()
+	if cd.storage != nil {
+		_ = cd.storage.Close()
+	}
+	return err
+}
+func (cd *ChangerDriver) CheckDevice(metrics *common.SystemMetrics) error {
+	cd.Log.Debug("ChangerDriver run cmd:%s", "CheckDevice")
+	if metrics != nil {
+		metrics.Uptime = time.Now().Unix() - cd.begTime
+		metrics.DevState = cd.DevState
+		metrics.DevError = cd.DevError
+		cd.checkStacker(metrics)
+		cd.protocol.ReportMetrics(metrics)
+	}
+	return nil
+}
+
+// Implementation of common.DeviceManager
+//
+func (cd *ChangerDriver) Cancel(name string, query *common.DeviceQuery) error {
+	err := cd.DevStopDispense()
+	if err == nil {
+		err = cd.DevDisableBills()
+	}
+	cd.DevError, cd.DevReply = common.CheckError(err)
+	return cd.RunDeviceReply(common.CmdDeviceCancel)
+}
+func (cd *ChangerDriver) Reset(name string, query *common.DeviceQuery) error {
+	err := cd.DevReset()
+	cd.DevError, cd.DevReply = common.CheckError(err)
+	return cd.RunDeviceReply(common.CmdDeviceReset)
+}
+func (cd *ChangerDriver) Status(name string, query *common.DeviceQuery) error {
+	err := cd.DevStatus()
+	cd.DevError, cd.DevReply = common.CheckError(err)
+	return cd.RunDeviceReply(common.CmdDeviceStatus)
+}
+func (cd *ChangerDriver) RunAction(name string, query *common.DeviceQuery) error {
+	err := cd.DevEnableBills(common.CurrencyNOT, 0)
+	if err == nil {
+		err = cd.DevStatus()
+	}
+	cd.DevError, cd.DevReply = common.CheckError(err)
+	return cd.RunDeviceReply(common.CmdRunAction)
+}
+func (cd *ChangerDriver) StopAction(name string, query *common.DeviceQuery) error {
+	err := cd.DevDisableBills()
+	if err == nil {
+		err = cd.DevStatus()
+	}
+	cd.DevError, cd.DevReply = common.CheckError(err)
+	return cd.RunDeviceReply(common.CmdStopAction)
+}
+
+// Implementation of common.ValidatorManager
+//
+func (cd *ChangerDriver) InitValidator(name string, query *common.ValidatorQuery) error {
+	err := cd.DevReset()
+	if err == nil {
+		err = cd.DevInitBillList(query.Currency)
+	}
+	cd.DevError, cd.DevReply = common.CheckError(err)
+	return cd.RunValidatorStore(common.CmdInitValidator)
+}
+func (cd *ChangerDriver) DoValidate(name string, query *common.ValidatorQuery) error {
+	err := cd.DevEnableBills(query.Currency, query.Target)
+	if err == nil {
+		err = cd.DevStatus()
+	}
+	cd.DevError, cd.DevReply = common.CheckError(err)
+	return cd.RunValidatorStore(common.CmdDoValidate)
+}
+func (cd *ChangerDriver) NoteAccept(name string, query *common.ValidatorQuery) error {
+	err := cd.DevNoteAccept()
+	cd.DevError, cd.DevReply = common.CheckError(err)
+	return err
+}
+func (cd *ChangerDriver) NoteReturn(name string, query *common.ValidatorQuery) error {
+	err := cd.DevNoteReturn()
+	cd.DevError, cd.DevReply = common.CheckError(err)
+	return err
+}
+func (cd *ChangerDriver) StopValidate(name string, query *common.ValidatorQuery) error {
+	err := cd.DevDisableBills()
+	if err == nil {
+		err = cd.DevStatus()
+	}
+	cd.DevError, cd.DevReply = common.CheckError(err)
+	return cd.RunValidatorStore(common.CmdStopValidate)
+}
+func (cd *ChangerDriver) CheckValidator(name string, query *common.ValidatorQuery) error {
+	err := cd.DevCheckBatch()
+	cd.DevError, cd.DevReply = common.CheckError(err)
+	return cd.RunValidatorStore(common.CmdCheckValidator)
+}
+func (cd *ChangerDriver) ClearValidator(name string, query *common.ValidatorQuery) error {
+	err := cd.DevClearBatch()
+	if err == nil {
+		err = cd.DevCheckBatch()
+	}
+	cd.DevError, cd.DevReply = common.CheckError(err)
+	return cd.RunValidatorStore(common.CmdClearValidator)
+}
+
+// Implementation of common.DispenserManager
+//
+func (cd *ChangerDriver) InitDispenser(name string, query *common.DispenserQuery) error {
+	err := cd.DevInitDispenser(query.Currency)
+	cd.DevError, cd.DevReply = common.CheckError(err)
+	return cd.runDispenserStore(common.CmdInitDispenser)
+}
+func (cd *ChangerDriver) DispenseCash(name string, query *common.DispenserQuery) error {
+	err := cd.DevDispenseCash(query.Currency, query.Amount)
+	cd.DevError, cd.DevReply = common.CheckError(err)
+	return cd.runDispenserStore(common.CmdDispenseCash)
+}
+func (cd *ChangerDriver) StopDispense(name string, query *common.DispenserQuery) error {
+	err := cd.DevStopDispense()
+	cd.DevError, cd.DevReply = common.CheckError(err)
+	return cd.runDispenserStore(common.CmdStopDispense)
+}
+func (cd *ChangerDriver) CheckDispenser(name string, query *common.DispenserQuery) error {
+	err := cd.DevStatus()
+	cd.DevError, cd.DevReply = common.CheckError(err)
+	return cd.runDispenserStore(common.CmdCheckDispenser)
+}
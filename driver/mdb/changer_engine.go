@@ -0,0 +1,443 @@
+package mdb
+
+import (
+	"fmt"
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/config"
+	"github.com/iftsoft/device/driver/generic"
+	"github.com/iftsoft/device/driver/validator"
+	"time"
+)
+
+const (
+	featureAltPayout uint32 = 0x00000001 // Alternative payout feature of level 3 changer
+	payoutMaxUnits          = 255        // Scaled unit limit of one payout command
+)
+
+// ChangerEngine accepts coins as validator and pays change out of its tubes as dispenser
+type ChangerEngine struct {
+	validator.CashEngine
+	dispenser generic.BaseDispenser
+	protocol  *MdbProtocol
+	setup     *CoinSetup
+	scale     MdbScale
+	coins     *CashTable
+	tubes     *TubeStatus
+	enabled   bool
+	canPayout bool
+	payout    bool
+	chunk     int64
+	pending   int64
+	request   common.DevAmount
+}
+
+func (ce *ChangerEngine) initEngine(cfg *config.DeviceConfig) *ChangerEngine {
+	ce.InitCashEngine(cfg)
+	ce.protocol = GetMdbProtocol(ce.GetLinkerConfig())
+	return ce
+}
+
+// Put cash box and tube counters to device metrics
+func (ce *ChangerEngine) checkStacker(metrics *common.SystemMetrics) {
+	ce.CheckStacker(metrics)
+	if ce.tubes != nil && ce.coins != nil {
+		for i, coin := range ce.coins {
+			if coin != nil && ce.setup.Routing&(1<<uint(i)) != 0 {
+				metrics.Counts[fmt.Sprintf("tube_%d", i)] = uint32(ce.tubes.Count[i])
+			}
+		}
+	}
+}
+
+// Run dispenser callbacks with the state of the engine
+func (ce *ChangerEngine) runDispenserStore(cmd string) error {
+	ce.dispenser.BaseEngine = ce.BaseEngine
+	return ce.dispenser.RunDispenserStore(cmd)
+}
+
+func (ce *ChangerEngine) runCashDispensed() error {
+	ce.dispenser.BaseEngine = ce.BaseEngine
+	return ce.dispenser.RunCashDispensed(&ce.dispenser.Payout)
+}
+
+// Read changer setup and rebuild coin table and note tables
+func (ce *ChangerEngine) loadCoinTable() error {
+	setup, err := ce.protocol.CoinSetup()
+	if err != nil {
+		return err
+	}
+	ce.Log.Debug(setup.String())
+	scale, err := newMdbScale(setup.Country, uint16(setup.Scale), setup.Decimals, ce.GetValidatorConfig())
+	if err != nil {
+		return err
+	}
+	coins := newCashTable(setup.Credits, scale)
+	ce.Log.Debug(coins.String())
+	ce.setup, ce.scale, ce.coins = setup, scale, coins
+	ce.NoteTables = validator.NoteTables{scale.Currency: coins.GetNoteList(scale.Currency)}
+	return nil
+}
+
+// Level 3 changer pays out the value by itself if the feature is enabled
+func (ce *ChangerEngine) enableFeatures() error {
+	ce.canPayout = false
+	if ce.setup.Level < 3 {
+		return nil
+	}
+	ident, err := ce.protocol.Identification(cmdCoinExpansion)
+	if err != nil {
+		return err
+	}
+	ce.Log.Info("MDB coin changer %s", ident.String())
+	if ident.Features&featureAltPayout == 0 {
+		return nil
+	}
+	err = ce.protocol.FeatureEnable(featureAltPayout)
+	ce.canPayout = err == nil
+	return err
+}
+
+// Reset changer and wait for its reset activity
+func (ce *ChangerEngine) resetChanger() error {
+	ce.enabled = false
+	err := ce.protocol.Reset(cmdCoinReset)
+	for i := 0; err == nil && i < mdbResetWait; i++ {
+		time.Sleep(100 * time.Millisecond)
+		var data []byte
+		data, err = ce.protocol.Poll(cmdCoinPoll)
+		if err != nil {
+			break
+		}
+		for _, act := range parseCoinActivity(data) {
+			ce.Log.Debug("ChangerEngine activity %s", act.String())
+			if act.code == cstWasReset {
+				return nil
+			}
+		}
+	}
+	if err == nil {
+		err = common.NewError(common.DevErrorWaitTimeout, "changer initialization timeout")
+	}
+	return err
+}
+
+func (ce *ChangerEngine) readTubes() error {
+	tubes, err := ce.protocol.TubeStatus()
+	if err == nil {
+		ce.tubes = tubes
+		ce.Log.Debug("ChangerEngine %s", tubes.String())
+	}
+	return err
+}
+
+////////////////////////////////////////////////////////////////
+
+func (ce *ChangerEngine) DevStartup() error {
+	err := ce.protocol.OpenLink()
+	if err == nil {
+		err = ce.resetChanger()
+	}
+	if err == nil {
+		err = ce.loadCoinTable()
+	}
+	if err == nil {
+		err = ce.enableFeatures()
+	}
+	if err == nil {
+		err = ce.readTubes()
+	}
+	if err == nil {
+		err = ce.DevCheckBatch()
+	}
+	if err == nil {
+		_ = ce.RunStateChanged(common.DevStateStandby)
+	}
+	ce.Accept.Currency = ce.scale.Currency
+	ce.dispenser.Payout.Currency = ce.scale.Currency
+	return err
+}
+
+func (ce *ChangerEngine) DevCleanup() error {
+	if ce.enabled {
+		_ = ce.protocol.CoinType(0, 0)
+	}
+	return ce.protocol.CloseLink()
+}
+
+func (ce *ChangerEngine) DevReset() error {
+	if ce.payout {
+		return common.NewError(common.DevErrorExecuteFault, "payout is in progress")
+	}
+	err := ce.resetChanger()
+	if err == nil {
+		err = ce.enableFeatures()
+	}
+	return err
+}
+
+// Status reads tube status of the changer
+func (ce *ChangerEngine) DevStatus() error {
+	return ce.readTubes()
+}
+
+func (ce *ChangerEngine) setEnabled(mask uint16) error {
+	err := ce.protocol.CoinType(mask, 0)
+	if err == nil {
+		ce.enabled = mask != 0
+		if ce.enabled {
+			_ = ce.RunStateChanged(common.DevStateWaiting)
+		} else {
+			_ = ce.RunStateChanged(common.DevStateStandby)
+		}
+	}
+	return err
+}
+
+func (ce *ChangerEngine) DevEnableBills(curr common.DevCurrency, target common.DevAmount) error {
+	if curr == common.CurrencyNOT {
+		curr = ce.Accept.Currency
+	}
+	ce.Log.Debug("ChangerEngine Set currency %d - %s, target %s", curr, curr.String(), target.Format(curr))
+	if ce.coins == nil {
+		return common.NewError(common.DevErrorNotInitialized, "coin table is not loaded")
+	}
+	mask := ce.coins.GetMask(curr, ce.GetValidatorConfig().NotesMask)
+	if mask == 0 {
+		return common.NewError(common.DevErrorNoCurrency,
+			fmt.Sprintf("no enabled coins for currency %d (%s)", curr, curr.IsoCode()))
+	}
+	err := ce.CheckSession(target)
+	if err == nil {
+		err = ce.setEnabled(mask)
+	}
+	if err == nil {
+		ce.StartSession(curr, target)
+	}
+	return err
+}
+
+func (ce *ChangerEngine) DevDisableBills() error {
+	return ce.setEnabled(0)
+}
+
+func (ce *ChangerEngine) DevInitBillList(curr common.DevCurrency) error {
+	err := ce.loadCoinTable()
+	if err != nil {
+		return err
+	}
+	if curr == common.CurrencyNOT {
+		curr = ce.scale.Currency
+	}
+	return ce.InitNoteList(curr)
+}
+
+// Coins have no escrow, they are credited as soon as they pass the acceptor
+func (ce *ChangerEngine) DevNoteAccept() error {
+	return common.NewError(common.DevErrorNotAccepted, "coin changer has no escrow")
+}
+
+func (ce *ChangerEngine) DevNoteReturn() error {
+	return common.NewError(common.DevErrorNotAccepted, "coin changer has no escrow")
+}
+
+////////////////////////////////////////////////////////////////
+
+func (ce *ChangerEngine) DevInitDispenser(curr common.DevCurrency) error {
+	err := ce.DevReset()
+	if err == nil {
+		err = ce.readTubes()
+	}
+	if err == nil && curr != common.CurrencyNOT && curr != ce.scale.Currency {
+		err = common.NewError(common.DevErrorNoCurrency,
+			fmt.Sprintf("changer has no coins of currency %d (%s)", curr, curr.IsoCode()))
+	}
+	return err
+}
+
+// Payout is paid by portions of scaled units, Nominal of the payout is zero as coins are mixed
+func (ce *ChangerEngine) DevDispenseCash(curr common.DevCurrency, amount common.DevAmount) error {
+	if ce.payout {
+		return common.NewError(common.DevErrorExecuteFault, "payout is in progress")
+	}
+	if !ce.canPayout {
+		return common.NewError(common.DevErrorCommandFault, "changer does not support alternative payout")
+	}
+	if curr == common.CurrencyNOT {
+		curr = ce.scale.Currency
+	}
+	if curr != ce.scale.Currency {
+		return common.NewError(common.DevErrorNoCurrency,
+			fmt.Sprintf("changer has no coins of currency %d (%s)", curr, curr.IsoCode()))
+	}
+	units, ok := ce.scale.ToUnits(amount)
+	if amount <= 0 || !ok {
+		return common.NewError(common.DevErrorBadArgument,
+			fmt.Sprintf("amount %s is not a multiple of %s", amount.Format(curr), ce.scale.ToAmount(1).Format(curr)))
+	}
+	ce.dispenser.Payout = common.DispenserPayout{Currency: curr}
+	ce.request = amount
+	ce.pending = units
+	err := ce.payoutUnits()
+	if err == nil {
+		_ = ce.RunStateChanged(common.DevStateDispDispensing)
+	}
+	return err
+}
+
+// Changer can not stop the portion in progress, the rest of payout is cancelled
+func (ce *ChangerEngine) DevStopDispense() error {
+	if ce.payout {
+		ce.Log.Debug("ChangerEngine payout is stopped, %d units are not paid", ce.pending)
+		ce.pending = 0
+	}
+	return nil
+}
+
+// Send payout command for the next portion of scaled units
+func (ce *ChangerEngine) payoutUnits() error {
+	chunk := ce.pending
+	if chunk > payoutMaxUnits {
+		chunk = payoutMaxUnits
+	}
+	err := ce.protocol.AlternativePayout(byte(chunk))
+	if err == nil {
+		ce.chunk = chunk
+		ce.pending -= chunk
+		ce.payout = true
+	}
+	return err
+}
+
+////////////////////////////////////////////////////////////////
+
+// Poll changer activities and payout status
+func (ce *ChangerEngine) pollChanger() {
+	if !ce.protocol.IsOpen() {
+		return
+	}
+	data, err := ce.protocol.Poll(cmdCoinPoll)
+	if err != nil {
+		code, text := common.CheckError(err)
+		if code != ce.DevError {
+			_ = ce.RunExecuteError(code, text)
+		}
+		return
+	}
+	failed := false
+	for _, act := range parseCoinActivity(data) {
+		failed = ce.processActivity(act) || failed
+	}
+	if !failed {
+		ce.DevError = common.DevErrorSuccess
+	}
+	if ce.payout {
+		ce.checkPayout()
+	}
+}
+
+// Process the activity, true is returned for error activity
+func (ce *ChangerEngine) processActivity(act coinActivity) bool {
+	ce.Log.Debug("ChangerEngine activity %s", act.String())
+	code := act.GetError()
+	if code != common.DevErrorSuccess && code != ce.DevError {
+		_ = ce.RunExecuteError(code, act.String())
+	}
+	switch {
+	case act.IsDeposited():
+		if act.Route() == coinToCashbox || act.Route() == coinToTube {
+			ce.onCoinCredited(act.Type())
+		}
+	case act.code == cstWasReset && ce.enabled:
+		// Changer was reset by itself, coin types are disabled after reset
+		ce.Log.Warn("ChangerEngine changer was reset")
+		_ = ce.DevEnableBills(ce.Session.Currency, ce.Session.Target)
+	case act.code == cstEscrowRequest:
+		ce.Log.Debug("ChangerEngine escrow lever is pressed")
+	}
+	return code != common.DevErrorSuccess
+}
+
+func (ce *ChangerEngine) onCoinCredited(index int) {
+	var coin *CashType
+	if ce.coins != nil {
+		coin = ce.coins.GetType(index)
+	}
+	if coin == nil {
+		ce.Log.Warn("ChangerEngine unknown coin type %d is credited", index)
+		return
+	}
+	ce.CreditCoin(coin.Currency, coin.Nominal)
+	ce.checkSession()
+}
+
+// Stop accepting when session target is reached or cash box is full
+func (ce *ChangerEngine) checkSession() {
+	if !ce.enabled {
+		return
+	}
+	over, err := ce.IsSessionOver()
+	if !over {
+		return
+	}
+	_ = ce.setEnabled(0)
+	if err != nil {
+		code, text := common.CheckError(err)
+		_ = ce.RunStateChanged(common.DevStateCashStackerFull)
+		_ = ce.RunExecuteError(code, text)
+	} else {
+		ce.Log.Debug("ChangerEngine session target %s is reached",
+			ce.Session.Target.Format(ce.Session.Currency))
+	}
+}
+
+// Payout status has coin counts by type when the portion is paid
+func (ce *ChangerEngine) checkPayout() {
+	counts, err := ce.protocol.PayoutStatus()
+	if err != nil {
+		code, text := common.CheckError(err)
+		if code != ce.DevError {
+			_ = ce.RunExecuteError(code, text)
+		}
+		return
+	}
+	if counts == nil {
+		return
+	}
+	var paid int64
+	for i, count := range counts {
+		if coin := ce.coins.GetType(i); coin != nil && count > 0 {
+			paid += coin.Units * int64(count)
+			ce.dispenser.Payout.Count += common.DevCounter(count)
+		}
+	}
+	ce.Log.Debug("ChangerEngine payout status %v, %d units of %d are paid", counts, paid, ce.chunk)
+	ce.dispenser.Payout.Amount += ce.scale.ToAmount(paid)
+	if paid < ce.chunk {
+		ce.finishPayout(common.DevErrorCantDispense,
+			fmt.Sprintf("%s is not paid", (ce.request-ce.dispenser.Payout.Amount).Format(ce.scale.Currency)))
+		return
+	}
+	if ce.pending > 0 {
+		if err = ce.payoutUnits(); err != nil {
+			code, text := common.CheckError(err)
+			ce.finishPayout(code, text)
+		}
+		return
+	}
+	ce.finishPayout(common.DevErrorSuccess, "")
+}
+
+func (ce *ChangerEngine) finishPayout(code common.EnumDevError, reason string) {
+	ce.payout = false
+	ce.pending = 0
+	ce.dispenser.Payout.Unpaid = ce.request - ce.dispenser.Payout.Amount
+	_ = ce.readTubes()
+	_ = ce.RunStateChanged(common.DevStateDispDispensed)
+	if ce.dispenser.Payout.Count > 0 {
+		_ = ce.RunActionPrompt(common.DevPromptDispTakeCoin)
+	}
+	if code != common.DevErrorSuccess {
+		_ = ce.RunExecuteError(code, reason)
+	}
+	_ = ce.runCashDispensed()
+}
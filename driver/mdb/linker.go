@@ -0,0 +1,97 @@
+package mdb
+
+import (
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/linker"
+)
+
+const (
+	mdbModeBit byte = 0x01 // Mode byte of the bridge word with the 9th bit set
+	mdbAck     byte = 0x00
+	mdbNak     byte = 0xFF
+	mdbMaxData      = 36 // Data byte count limit of the block
+)
+
+////////////////////////////////////////////////////////////////
+// MDB uses 9-bit words, the bridge sends each word as two bytes: MODE, DATA.
+// Host block:       ADDRESS|CMD (mode bit set), []DATA, CHK
+// Peripheral block: []DATA, CHK (mode bit set) or single ACK / NAK (mode bit set)
+// CHK is the sum of block bytes modulo 256.
+
+// mdbFramer takes command and its data as payload of host block.
+// Payload of peripheral block starts with ACK for data or ACK only reply, and with NAK for NAK reply.
+type mdbFramer struct{}
+
+func (f *mdbFramer) Encode(data []byte) ([]byte, error) {
+	if len(data) > mdbMaxData+1 {
+		return nil, common.NewError(common.DevErrorProtocolFault, "block is too long")
+	}
+	block := append(append([]byte{}, data...), calcMdbCheck(data))
+	return encodeWords(block, true), nil
+}
+
+func (f *mdbFramer) Decode(dump []byte) ([]byte, int, error) {
+	words := make([]byte, 0, mdbMaxData+2)
+	for used := 0; used+1 < len(dump); used += 2 {
+		words = append(words, dump[used+1])
+		if dump[used]&mdbModeBit != 0 {
+			data, err := decodeBlock(words)
+			return data, used + 2, err
+		}
+		if len(words) > mdbMaxData+1 {
+			return nil, used + 2, common.NewError(common.DevErrorProtocolFault, "block is too long")
+		}
+	}
+	return nil, 0, nil
+}
+
+// Peripheral answers at once, commands are repeated after timeout
+func getMdbOptions() *linker.HalfDuplexOptions {
+	opts := linker.GetDefaultHalfDuplexOptions()
+	opts.Retries = 2
+	opts.Timeout = 100
+	return opts
+}
+
+////////////////////////////////////////////////////////////////
+
+// Make bridge words of the block, mode bit is set for the first word of host block
+func encodeWords(block []byte, address bool) []byte {
+	pack := make([]byte, 0, 2*len(block))
+	for i, b := range block {
+		var mode byte
+		if address && i == 0 {
+			mode = mdbModeBit
+		}
+		pack = append(pack, mode, b)
+	}
+	return pack
+}
+
+// Parse peripheral block that ends by the word with mode bit
+func decodeBlock(words []byte) ([]byte, error) {
+	if len(words) == 1 {
+		switch words[0] {
+		case mdbAck:
+			return []byte{mdbAck}, nil
+		case mdbNak:
+			return []byte{mdbNak}, nil
+		}
+	}
+	size := len(words) - 1
+	if size < 1 {
+		return nil, common.NewError(common.DevErrorProtocolFault, "block has no data")
+	}
+	if calcMdbCheck(words[:size]) != words[size] {
+		return nil, common.NewError(common.DevErrorProtocolFault, "checksum mismatch")
+	}
+	return append([]byte{mdbAck}, words[:size]...), nil
+}
+
+func calcMdbCheck(data []byte) byte {
+	var sum byte
+	for _, b := range data {
+		sum += b
+	}
+	return sum
+}
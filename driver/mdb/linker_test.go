@@ -0,0 +1,49 @@
+package mdb
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Peripheral block of data and checksum, mode bit is set for the last word
+func peripheralBlock(data ...byte) []byte {
+	block := append(append([]byte{}, data...), calcMdbCheck(data))
+	pack := encodeWords(block, false)
+	pack[len(pack)-2] = mdbModeBit
+	return pack
+}
+
+func TestMdbFramer(t *testing.T) {
+	framer := &mdbFramer{}
+	pack, err := framer.Encode([]byte{cmdBillType, 0x00, 0x07, 0x00, 0x07})
+	want := []byte{0x01, 0x34, 0x00, 0x00, 0x00, 0x07, 0x00, 0x00, 0x00, 0x07, 0x00, 0x42}
+	if err != nil || !bytes.Equal(pack, want) {
+		t.Errorf("host block % X, want % X: %v", pack, want, err)
+	}
+	if _, err = framer.Encode(make([]byte, mdbMaxData+2)); err == nil {
+		t.Error("too long block is encoded")
+	}
+
+	tests := []struct {
+		name  string
+		dump  []byte
+		data  []byte
+		count int
+		bad   bool
+	}{
+		{"ACK", []byte{0x01, mdbAck}, []byte{mdbAck}, 2, false},
+		{"NAK", []byte{0x01, mdbNak}, []byte{mdbNak}, 2, false},
+		{"data", peripheralBlock(0x91, 0x06), []byte{mdbAck, 0x91, 0x06}, 6, false},
+		{"data and more", append(peripheralBlock(0x81), 0x01, mdbAck), []byte{mdbAck, 0x81}, 4, false},
+		{"partial", peripheralBlock(0x91, 0x06)[:5], nil, 0, false},
+		{"bad check", []byte{0x00, 0x91, 0x01, 0x92}, nil, 4, true},
+		{"no data", []byte{0x01, 0x05}, nil, 2, true},
+		{"too long", make([]byte, 2*(mdbMaxData+2)), nil, 2 * (mdbMaxData + 2), true},
+	}
+	for _, tt := range tests {
+		data, count, err := framer.Decode(tt.dump)
+		if !bytes.Equal(data, tt.data) || count != tt.count || (err != nil) != tt.bad {
+			t.Errorf("%s: % X, %d: %v", tt.name, data, count, err)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package mdb
+
+import (
+	"fmt"
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/config"
+	"github.com/iftsoft/device/core"
+	"github.com/iftsoft/device/linker"
+	"strings"
+	"sync"
+)
+
+// MDB commands of bill validator (address 0x30) and coin changer (address 0x08)
+const (
+	cmdBillReset     byte = 0x30
+	cmdBillSetup     byte = 0x31
+	cmdBillSecurity  byte = 0x32
+	cmdBillPoll      byte = 0x33
+	cmdBillType      byte = 0x34
+	cmdBillEscrow    byte = 0x35
+	cmdBillStacker   byte = 0x36
+	cmdBillExpansion byte = 0x37
+
+	cmdCoinReset      byte = 0x08
+	cmdCoinSetup      byte = 0x09
+	cmdCoinTubeStatus byte = 0x0A
+	cmdCoinPoll       byte = 0x0B
+	cmdCoinType       byte = 0x0C
+	cmdCoinDispense   byte = 0x0D
+	cmdCoinExpansion  byte = 0x0F
+
+	subIdentification  byte = 0x00
+	subFeatureEnable   byte = 0x01
+	subAlternativePay  byte = 0x02
+	subPayoutStatus    byte = 0x03
+	subPayoutValuePoll byte = 0x04
+
+	mdbCoinTypes = 16 // Count of coin and bill types
+)
+
+// MdbIdent is the reply of expansion identification
+type MdbIdent struct {
+	Manufacturer string
+	SerialNumber string
+	ModelNumber  string
+	Version      uint16
+	Features     uint32
+}
+
+func (mi *MdbIdent) String() string {
+	if mi == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s %s, serial %s, version %04X, features %08X",
+		mi.Manufacturer, mi.ModelNumber, mi.SerialNumber, mi.Version, mi.Features)
+}
+
+type MdbProtocol struct {
+	*linker.HalfDuplex
+	log  *core.LogAgent
+	lock sync.Mutex
+}
+
+func GetMdbProtocol(cfg *config.LinkerConfig) *MdbProtocol {
+	mp := &MdbProtocol{
+		HalfDuplex: linker.NewHalfDuplex(cfg, &mdbFramer{}, getMdbOptions(), "MDB"),
+		log:        core.GetLogAgent(core.LogLevelDump, "MDB"),
+	}
+	return mp
+}
+
+////////////////////////////////////////////////////////////////
+
+func (mp *MdbProtocol) Reset(cmd byte) error {
+	_, err := mp.request(cmd, nil, true)
+	mp.logError("Reset", err)
+	return err
+}
+
+func (mp *MdbProtocol) Poll(cmd byte) ([]byte, error) {
+	back, err := mp.request(cmd, nil, true)
+	if err != nil {
+		mp.logError("Poll", err)
+	}
+	return back, err
+}
+
+// Identification reads expansion identification of the peripheral
+func (mp *MdbProtocol) Identification(cmd byte) (*MdbIdent, error) {
+	back, err := mp.request(cmd, []byte{subIdentification}, true)
+	ident := &MdbIdent{}
+	if err == nil && len(back) < 29 {
+		err = common.NewError(common.DevErrorProtocolFault, "wrong identification reply")
+	}
+	if err == nil {
+		ident.Manufacturer = strings.TrimSpace(string(back[0:3]))
+		ident.SerialNumber = strings.TrimSpace(string(back[3:15]))
+		ident.ModelNumber = strings.TrimSpace(string(back[15:27]))
+		ident.Version = getUint16(back[27:29])
+		if len(back) >= 33 {
+			ident.Features = uint32(getUint16(back[29:31]))<<16 | uint32(getUint16(back[31:33]))
+		}
+	}
+	mp.logError("Identification", err)
+	return ident, err
+}
+
+// BillSetup reads setup of bill validator
+func (mp *MdbProtocol) BillSetup() (*BillSetup, error) {
+	back, err := mp.request(cmdBillSetup, nil, true)
+	var setup *BillSetup
+	if err == nil {
+		setup, err = parseBillSetup(back)
+	}
+	mp.logError("BillSetup", err)
+	return setup, err
+}
+
+// BillType enables bill types and escrow of them by masks, bit 0 is bill type 0
+func (mp *MdbProtocol) BillType(enable, escrow uint16) error {
+	data := []byte{byte(enable >> 8), byte(enable), byte(escrow >> 8), byte(escrow)}
+	_, err := mp.request(cmdBillType, data, true)
+	mp.logError("BillType", err)
+	return err
+}
+
+// BillEscrow stacks escrowed bill or returns it
+func (mp *MdbProtocol) BillEscrow(stack bool) error {
+	data := []byte{0x00}
+	if stack {
+		data[0] = 0x01
+	}
+	_, err := mp.request(cmdBillEscrow, data, true)
+	mp.logError("BillEscrow", err)
+	return err
+}
+
+// BillStacker returns stacker full flag and bill count
+func (mp *MdbProtocol) BillStacker() (bool, uint16, error) {
+	back, err := mp.request(cmdBillStacker, nil, true)
+	if err == nil && len(back) != 2 {
+		err = common.NewError(common.DevErrorProtocolFault, "wrong stacker reply")
+	}
+	mp.logError("BillStacker", err)
+	if err != nil {
+		return false, 0, err
+	}
+	value := getUint16(back)
+	return value&0x8000 != 0, value & 0x7FFF, nil
+}
+
+// CoinSetup reads setup of coin changer
+func (mp *MdbProtocol) CoinSetup() (*CoinSetup, error) {
+	back, err := mp.request(cmdCoinSetup, nil, true)
+	var setup *CoinSetup
+	if err == nil {
+		setup, err = parseCoinSetup(back)
+	}
+	mp.logError("CoinSetup", err)
+	return setup, err
+}
+
+// TubeStatus returns tube full flags and coin counts of tubes
+func (mp *MdbProtocol) TubeStatus() (*TubeStatus, error) {
+	back, err := mp.request(cmdCoinTubeStatus, nil, true)
+	if err == nil && len(back) < 2 {
+		err = common.NewError(common.DevErrorProtocolFault, "wrong tube status reply")
+	}
+	mp.logError("TubeStatus", err)
+	if err != nil {
+		return nil, err
+	}
+	status := &TubeStatus{Full: getUint16(back)}
+	copy(status.Count[:], back[2:])
+	return status, nil
+}
+
+// CoinType enables coin types and manual dispense of them by masks, bit 0 is coin type 0
+func (mp *MdbProtocol) CoinType(enable, dispense uint16) error {
+	data := []byte{byte(enable >> 8), byte(enable), byte(dispense >> 8), byte(dispense)}
+	_, err := mp.request(cmdCoinType, data, true)
+	mp.logError("CoinType", err)
+	return err
+}
+
+// FeatureEnable turns on optional features of level 3 changer
+func (mp *MdbProtocol) FeatureEnable(features uint32) error {
+	data := []byte{subFeatureEnable, byte(features >> 24), byte(features >> 16), byte(features >> 8), byte(features)}
+	_, err := mp.request(cmdCoinExpansion, data, true)
+	mp.logError("FeatureEnable", err)
+	return err
+}
+
+// AlternativePayout starts payout of the value in scaled units, it is not repeated
+func (mp *MdbProtocol) AlternativePayout(units byte) error {
+	_, err := mp.request(cmdCoinExpansion, []byte{subAlternativePay, units}, false)
+	mp.logError("AlternativePayout", err)
+	return err
+}
+
+// PayoutStatus returns coin counts paid by type, nil counts mean payout is in progress
+func (mp *MdbProtocol) PayoutStatus() ([]byte, error) {
+	back, err := mp.request(cmdCoinExpansion, []byte{subPayoutStatus}, true)
+	mp.logError("PayoutStatus", err)
+	if err != nil || len(back) == 0 {
+		return nil, err
+	}
+	return back, nil
+}
+
+////////////////////////////////////////////////////////////////
+
+func (mp *MdbProtocol) logError(cmd string, err error) {
+	code, text := common.CheckError(err)
+	mp.log.Trace("MdbProtocol.%s return: %d - %s", cmd, code, text)
+}
+
+// Send command block and wait for reply, data block is acknowledged by the host.
+// Command is repeated after timeout if retry is set.
+func (mp *MdbProtocol) request(cmd byte, data []byte, retry bool) ([]byte, error) {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+	mp.log.Dump("MdbProtocol writeData cmd %02X, data : %s", cmd, core.GetBinaryDump(data))
+	var back []byte
+	var err error
+	if retry {
+		back, err = mp.Exchange(append([]byte{cmd}, data...))
+	} else {
+		back, err = mp.ExchangeOnce(append([]byte{cmd}, data...))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if back[0] == mdbNak {
+		return nil, common.NewError(common.DevErrorCommandFault,
+			fmt.Sprintf("command %02X is not acknowledged", cmd))
+	}
+	if len(back) > 1 {
+		err = mp.WriteRaw(encodeWords([]byte{mdbAck}, false))
+	}
+	return back[1:], err
+}
+
+func getUint16(data []byte) uint16 {
+	return uint16(data[0])<<8 | uint16(data[1])
+}
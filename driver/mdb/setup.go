@@ -0,0 +1,282 @@
+package mdb
+
+import (
+	"fmt"
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/config"
+)
+
+// BillSetup is the reply of bill validator setup
+type BillSetup struct {
+	Level    byte
+	Country  uint16
+	Scale    uint16
+	Decimals byte
+	Capacity uint16
+	Security uint16
+	Escrow   bool
+	Credits  []byte
+}
+
+func (bs *BillSetup) String() string {
+	if bs == nil {
+		return ""
+	}
+	return fmt.Sprintf("MDB bill validator level %d, country %04X, scale %d, decimals %d, capacity %d, escrow %t",
+		bs.Level, bs.Country, bs.Scale, bs.Decimals, bs.Capacity, bs.Escrow)
+}
+
+// Bill setup: level, country (2 BCD), scaling factor (2), decimal places, stacker capacity (2),
+// security levels (2), escrow flag, bill type credits (up to 16)
+func parseBillSetup(data []byte) (*BillSetup, error) {
+	if len(data) < 11 {
+		return nil, common.NewError(common.DevErrorProtocolFault, "bill setup is too short")
+	}
+	setup := &BillSetup{
+		Level:    data[0],
+		Country:  getUint16(data[1:3]),
+		Scale:    getUint16(data[3:5]),
+		Decimals: data[5],
+		Capacity: getUint16(data[6:8]),
+		Security: getUint16(data[8:10]),
+		Escrow:   data[10] == 0xFF,
+		Credits:  data[11:],
+	}
+	if len(setup.Credits) > mdbCoinTypes {
+		setup.Credits = setup.Credits[:mdbCoinTypes]
+	}
+	return setup, nil
+}
+
+// CoinSetup is the reply of coin changer setup
+type CoinSetup struct {
+	Level    byte
+	Country  uint16
+	Scale    byte
+	Decimals byte
+	Routing  uint16
+	Credits  []byte
+}
+
+func (cs *CoinSetup) String() string {
+	if cs == nil {
+		return ""
+	}
+	return fmt.Sprintf("MDB coin changer level %d, country %04X, scale %d, decimals %d, tube coins %04X",
+		cs.Level, cs.Country, cs.Scale, cs.Decimals, cs.Routing)
+}
+
+// Coin setup: level, country (2 BCD), scaling factor, decimal places, coin type routing (2),
+// coin type credits (up to 16)
+func parseCoinSetup(data []byte) (*CoinSetup, error) {
+	if len(data) < 7 {
+		return nil, common.NewError(common.DevErrorProtocolFault, "coin setup is too short")
+	}
+	setup := &CoinSetup{
+		Level:    data[0],
+		Country:  getUint16(data[1:3]),
+		Scale:    data[3],
+		Decimals: data[4],
+		Routing:  getUint16(data[5:7]),
+		Credits:  data[7:],
+	}
+	if len(setup.Credits) > mdbCoinTypes {
+		setup.Credits = setup.Credits[:mdbCoinTypes]
+	}
+	return setup, nil
+}
+
+// TubeStatus is the reply of coin changer tube status
+type TubeStatus struct {
+	Full  uint16
+	Count [mdbCoinTypes]byte
+}
+
+func (ts *TubeStatus) String() string {
+	if ts == nil {
+		return ""
+	}
+	return fmt.Sprintf("Tube full %04X, counts %v", ts.Full, ts.Count)
+}
+
+////////////////////////////////////////////////////////////////
+
+// MdbScale converts values in scaled units of the peripheral to amounts in minor units
+type MdbScale struct {
+	Currency common.DevCurrency
+	Factor   uint16
+	Decimals byte
+}
+
+func (ms MdbScale) String() string {
+	return fmt.Sprintf("currency %s, scale %d, decimals %d", ms.Currency.IsoCode(), ms.Factor, ms.Decimals)
+}
+
+// Make scale of country code, scaling factor and decimal places of the setup.
+// Config values are used instead of the setup ones if scaling factor is set.
+func newMdbScale(country, factor uint16, decimals byte, valCfg *config.ValidatorConfig) (MdbScale, error) {
+	scale := MdbScale{Currency: parseCountryCode(country), Factor: factor, Decimals: decimals}
+	if valCfg.CurrCode != common.CurrencyNOT {
+		if scale.Currency != common.CurrencyNOT && scale.Currency != valCfg.CurrCode {
+			return scale, common.NewError(common.DevErrorConfigFault,
+				fmt.Sprintf("device currency %s is not %s", scale.Currency.IsoCode(), valCfg.CurrCode.IsoCode()))
+		}
+		scale.Currency = valCfg.CurrCode
+	}
+	if valCfg.ScaleFactor > 0 {
+		scale.Factor, scale.Decimals = valCfg.ScaleFactor, valCfg.DecimalPlaces
+	}
+	if scale.Currency == common.CurrencyNOT {
+		return scale, common.NewError(common.DevErrorNoCurrency,
+			fmt.Sprintf("unknown country code %04X", country))
+	}
+	if scale.Factor == 0 {
+		return scale, common.NewError(common.DevErrorConfigFault, "zero scaling factor")
+	}
+	return scale, nil
+}
+
+// ToAmount converts value in scaled units to amount in minor units of the currency
+func (ms MdbScale) ToAmount(units int64) common.DevAmount {
+	value := units * int64(ms.Factor)
+	for i := int(ms.Decimals); i < ms.Currency.Exponent(); i++ {
+		value *= 10
+	}
+	for i := ms.Currency.Exponent(); i < int(ms.Decimals); i++ {
+		value /= 10
+	}
+	return common.DevAmount(value)
+}
+
+// ToUnits converts amount to scaled units, false is returned if amount is not a multiple of the unit
+func (ms MdbScale) ToUnits(amount common.DevAmount) (int64, bool) {
+	unit := int64(ms.ToAmount(1))
+	if unit <= 0 {
+		return 0, false
+	}
+	return int64(amount) / unit, int64(amount)%unit == 0
+}
+
+// MDB country code is BCD telephone code or ISO 4217 numeric code with leading 1
+func parseCountryCode(code uint16) common.DevCurrency {
+	value := 0
+	for shift := 8; shift >= 0; shift -= 4 {
+		digit := int(code>>uint(shift)) & 0x0F
+		if digit > 9 {
+			return common.CurrencyNOT
+		}
+		value = value*10 + digit
+	}
+	if code>>12 == 1 {
+		if common.GetCurrencyInfo(common.DevCurrency(value)) != nil {
+			return common.DevCurrency(value)
+		}
+		return common.CurrencyNOT
+	}
+	if code>>12 != 0 {
+		return common.CurrencyNOT
+	}
+	return phoneCurrency[value]
+}
+
+var phoneCurrency = map[int]common.DevCurrency{
+	1:   common.CurrencyUSD,
+	7:   common.CurrencyRUB,
+	44:  common.CurrencyGBP,
+	48:  common.CurrencyPLN,
+	373: common.CurrencyMDL,
+	374: common.CurrencyAMD,
+	375: common.CurrencyBYN,
+	380: common.CurrencyUAH,
+	992: common.CurrencyTJS,
+	994: common.CurrencyAZN,
+	995: common.CurrencyGEL,
+	996: common.CurrencyKGS,
+	998: common.CurrencyUZS,
+}
+
+////////////////////////////////////////////////////////////////
+
+// CashType is bill or coin type of the peripheral with its nominal
+type CashType struct {
+	Type     int
+	Currency common.DevCurrency
+	Nominal  common.DevAmount
+	Units    int64
+}
+
+func (ct *CashType) String() string {
+	if ct == nil {
+		return ""
+	}
+	return fmt.Sprintf("Type %2d: %9s %s", ct.Type, ct.Nominal.Format(ct.Currency), ct.Currency.IsoCode())
+}
+
+type CashTable [mdbCoinTypes]*CashType
+
+// Make table of type credits, zero credit is for unused type and 0xFF is for token
+func newCashTable(credits []byte, scale MdbScale) *CashTable {
+	table := &CashTable{}
+	for i, credit := range credits {
+		if i >= mdbCoinTypes || credit == 0 || credit == 0xFF {
+			continue
+		}
+		table[i] = &CashType{
+			Type:     i,
+			Currency: scale.Currency,
+			Nominal:  scale.ToAmount(int64(credit)),
+			Units:    int64(credit),
+		}
+	}
+	return table
+}
+
+func (ct *CashTable) String() string {
+	str := "MDB cash types:"
+	for _, item := range ct {
+		if item != nil {
+			str += "\n    " + item.String()
+		}
+	}
+	return str
+}
+
+// GetType returns the type 0..15 or nil
+func (ct *CashTable) GetType(index int) *CashType {
+	if index < 0 || index >= mdbCoinTypes {
+		return nil
+	}
+	return ct[index]
+}
+
+// GetNoteList returns unique nominals of the currency in type order
+func (ct *CashTable) GetNoteList(curr common.DevCurrency) common.ValidNoteList {
+	list := make(common.ValidNoteList, 0)
+	for _, item := range ct {
+		if item == nil || item.Currency != curr {
+			continue
+		}
+		dup := false
+		for _, note := range list {
+			dup = dup || note.Nominal == item.Nominal
+		}
+		if !dup {
+			list = append(list, &common.ValidatorNote{Currency: curr, Nominal: item.Nominal})
+		}
+	}
+	return list
+}
+
+// GetMask returns type mask of the currency filtered by notes mask, zero notes mask enables all
+func (ct *CashTable) GetMask(curr common.DevCurrency, notesMask int64) uint16 {
+	var mask uint16
+	for i, item := range ct {
+		if item == nil || item.Currency != curr {
+			continue
+		}
+		if notesMask == 0 || notesMask&(1<<uint(i)) != 0 {
+			mask |= 1 << uint(i)
+		}
+	}
+	return mask
+}
@@ -0,0 +1,50 @@
+package mdb
+
+import (
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/config"
+	"testing"
+)
+
+func TestMdbScale(t *testing.T) {
+	tests := []struct {
+		country uint16
+		curr    common.DevCurrency
+	}{
+		{0x0380, common.CurrencyUAH},
+		{0x0001, common.CurrencyUSD},
+		{0x1980, common.CurrencyUAH},
+		{0x1978, common.CurrencyEUR},
+		{0x1111, common.CurrencyNOT},
+		{0x00A1, common.CurrencyNOT},
+		{0x2380, common.CurrencyNOT},
+	}
+	for _, tt := range tests {
+		if curr := parseCountryCode(tt.country); curr != tt.curr {
+			t.Errorf("country code %04X is %s, want %s", tt.country, curr.IsoCode(), tt.curr.IsoCode())
+		}
+	}
+
+	valCfg := &config.ValidatorConfig{}
+	scale, err := newMdbScale(0x0380, 5, 1, valCfg)
+	if err != nil || scale.ToAmount(3) != 150 {
+		t.Errorf("amount of 3 units by %s is %d: %v", scale, scale.ToAmount(3), err)
+	}
+	if units, ok := scale.ToUnits(250); !ok || units != 5 {
+		t.Errorf("units of 250 by %s are %d", scale, units)
+	}
+	if _, ok := scale.ToUnits(260); ok {
+		t.Errorf("260 is converted by %s", scale)
+	}
+	// Scale of config takes place of device one
+	valCfg = &config.ValidatorConfig{CurrCode: common.CurrencyUAH, ScaleFactor: 1, DecimalPlaces: 0}
+	if scale, err = newMdbScale(0xFFFF, 5, 1, valCfg); err != nil || scale.ToAmount(3) != 300 {
+		t.Errorf("amount of 3 units by %s is %d: %v", scale, scale.ToAmount(3), err)
+	}
+	if _, err = newMdbScale(0x0001, 5, 1, valCfg); err == nil {
+		t.Error("device currency that differs from config one is taken")
+	}
+	if _, err = newMdbScale(0x0380, 0, 2, &config.ValidatorConfig{}); err == nil {
+		t.Error("zero scaling factor is taken")
+	}
+}
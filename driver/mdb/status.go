@@ -0,0 +1,318 @@
+package mdb
+
+import (
+	"fmt"
+	"github.com/iftsoft/device/common"
+)
+
+// Bill routing of poll activity 1xxxyyyy, yyyy is bill type
+const (
+	routeStacked        byte = 0
+	routeEscrow         byte = 1
+	routeReturned       byte = 2
+	routeToRecycler     byte = 3
+	routeDisabledReject byte = 4
+	routeRecyclerFill   byte = 5
+	routeManualDispense byte = 6
+	routeToCashbox      byte = 7
+)
+
+// Bill validator status of poll activity 0000xxxx
+const (
+	bstDefectiveMotor byte = 0x01
+	bstSensorProblem  byte = 0x02
+	bstBusy           byte = 0x03
+	bstRomError       byte = 0x04
+	bstJammed         byte = 0x05
+	bstWasReset       byte = 0x06
+	bstBillRemoved    byte = 0x07
+	bstCashboxOut     byte = 0x08
+	bstDisabled       byte = 0x09
+	bstInvalidEscrow  byte = 0x0A
+	bstBillRejected   byte = 0x0B
+	bstCreditRemoval  byte = 0x0C
+)
+
+// Coin changer status of poll activity 0000xxxx
+const (
+	cstEscrowRequest     byte = 0x01
+	cstPayoutBusy        byte = 0x02
+	cstNoCredit          byte = 0x03
+	cstTubeSensor        byte = 0x04
+	cstDoubleArrival     byte = 0x05
+	cstAcceptorUnplugged byte = 0x06
+	cstTubeJam           byte = 0x07
+	cstRomError          byte = 0x08
+	cstRoutingError      byte = 0x09
+	cstBusy              byte = 0x0A
+	cstWasReset          byte = 0x0B
+	cstCoinJam           byte = 0x0C
+	cstCreditRemoval     byte = 0x0D
+)
+
+// Coin routing of deposited coin activity 01xxyyyy
+const (
+	coinToCashbox byte = 0
+	coinToTube    byte = 1
+	coinRejected  byte = 3
+)
+
+////////////////////////////////////////////////////////////////
+
+// Bill validator poll activity byte
+type billActivity byte
+
+func (ba billActivity) IsRouting() bool {
+	return ba&0x80 != 0
+}
+
+func (ba billActivity) IsStatus() bool {
+	return ba&0xF0 == 0
+}
+
+// Routing of bill activity
+func (ba billActivity) Route() byte {
+	return byte(ba>>4) & 0x07
+}
+
+// Bill type of routing activity
+func (ba billActivity) Type() int {
+	return int(ba & 0x0F)
+}
+
+func (ba billActivity) String() string {
+	switch {
+	case ba.IsRouting():
+		return fmt.Sprintf("%02X - %s: bill type %d", byte(ba), getRouteText(ba.Route()), ba.Type())
+	case ba.IsStatus():
+		return fmt.Sprintf("%02X - %s", byte(ba), getBillStatusText(byte(ba)))
+	case ba&0xE0 == 0x40:
+		return fmt.Sprintf("%02X - %d attempts to insert bill while disabled", byte(ba), byte(ba)&0x1F)
+	default:
+		return fmt.Sprintf("%02X - Unknown activity", byte(ba))
+	}
+}
+
+// GetState maps the activity to device state, undefined state is for activities that do not change it
+func (ba billActivity) GetState() common.EnumDevState {
+	if ba.IsRouting() {
+		switch ba.Route() {
+		case routeStacked, routeToCashbox:
+			return common.DevStateCashStacked
+		case routeEscrow:
+			return common.DevStateCashEscrowed
+		case routeReturned, routeDisabledReject:
+			return common.DevStateCashReturned
+		}
+		return common.DevStateUndefined
+	}
+	switch byte(ba) {
+	case bstDefectiveMotor, bstSensorProblem, bstRomError, bstCashboxOut:
+		return common.DevStateHardError
+	case bstJammed:
+		return common.DevStateCashBillJammed
+	case bstBusy:
+		return common.DevStateWorking
+	case bstDisabled:
+		return common.DevStateStandby
+	case bstBillRejected:
+		return common.DevStateCashRejecting
+	case bstBillRemoved, bstCreditRemoval:
+		return common.DevStateSoftError
+	default:
+		return common.DevStateUndefined
+	}
+}
+
+// GetError maps the activity to device error, success is returned for normal activities
+func (ba billActivity) GetError() common.EnumDevError {
+	if !ba.IsStatus() {
+		return common.DevErrorSuccess
+	}
+	switch byte(ba) {
+	case bstDefectiveMotor, bstSensorProblem, bstRomError:
+		return common.DevErrorHardwareFault
+	case bstJammed:
+		return common.DevErrorBillJammed
+	case bstCashboxOut:
+		return common.DevErrorCassetteMiss
+	case bstBillRemoved, bstCreditRemoval:
+		return common.DevErrorSecurityFault
+	case bstInvalidEscrow:
+		return common.DevErrorCommandFault
+	default:
+		return common.DevErrorSuccess
+	}
+}
+
+// GetPrompt maps the activity to customer prompt, none prompt is for activities that do not change it
+func (ba billActivity) GetPrompt() common.EnumDevPrompt {
+	if ba.IsRouting() {
+		switch ba.Route() {
+		case routeEscrow:
+			return common.DevPromptCashEscrowed
+		case routeReturned, routeDisabledReject:
+			return common.DevPromptCashReturning
+		}
+		return common.DevPromptNone
+	}
+	switch byte(ba) {
+	case bstJammed:
+		return common.DevPromptCashBillJammed
+	case bstDefectiveMotor, bstSensorProblem, bstRomError, bstCashboxOut:
+		return common.DevPromptCashFailure
+	default:
+		return common.DevPromptNone
+	}
+}
+
+////////////////////////////////////////////////////////////////
+
+// Coin changer poll activity, deposited and dispensed coins have second byte with tube count
+type coinActivity struct {
+	code  byte
+	extra byte
+}
+
+func (ca coinActivity) IsDispensed() bool {
+	return ca.code&0x80 != 0
+}
+
+func (ca coinActivity) IsDeposited() bool {
+	return ca.code&0xC0 == 0x40
+}
+
+func (ca coinActivity) IsStatus() bool {
+	return ca.code&0xF0 == 0
+}
+
+// Routing of deposited coin
+func (ca coinActivity) Route() byte {
+	return (ca.code >> 4) & 0x03
+}
+
+// Coin type of deposited or dispensed coins
+func (ca coinActivity) Type() int {
+	return int(ca.code & 0x0F)
+}
+
+func (ca coinActivity) String() string {
+	switch {
+	case ca.IsDispensed():
+		return fmt.Sprintf("%02X - %d coins of type %d dispensed manually, %d in tube",
+			ca.code, (ca.code>>4)&0x07, ca.Type(), ca.extra)
+	case ca.IsDeposited():
+		return fmt.Sprintf("%02X - Coin type %d deposited to %s, %d in tube",
+			ca.code, ca.Type(), getCoinRouteText(ca.Route()), ca.extra)
+	case ca.IsStatus():
+		return fmt.Sprintf("%02X - %s", ca.code, getCoinStatusText(ca.code))
+	case ca.code&0xE0 == 0x20:
+		return fmt.Sprintf("%02X - %d slugs", ca.code, ca.code&0x1F)
+	default:
+		return fmt.Sprintf("%02X - Unknown activity", ca.code)
+	}
+}
+
+// GetError maps the activity to device error, success is returned for normal activities
+func (ca coinActivity) GetError() common.EnumDevError {
+	if !ca.IsStatus() {
+		return common.DevErrorSuccess
+	}
+	switch ca.code {
+	case cstTubeSensor, cstAcceptorUnplugged, cstRomError, cstRoutingError:
+		return common.DevErrorHardwareFault
+	case cstTubeJam:
+		return common.DevErrorPickFault
+	case cstCoinJam:
+		return common.DevErrorBillJammed
+	case cstCreditRemoval:
+		return common.DevErrorSecurityFault
+	default:
+		return common.DevErrorSuccess
+	}
+}
+
+////////////////////////////////////////////////////////////////
+
+// Split bill poll reply to activities
+func parseBillActivity(data []byte) []billActivity {
+	list := make([]billActivity, 0, len(data))
+	for _, b := range data {
+		list = append(list, billActivity(b))
+	}
+	return list
+}
+
+// Split coin poll reply to activities, coin routing activities take two bytes
+func parseCoinActivity(data []byte) []coinActivity {
+	list := make([]coinActivity, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		act := coinActivity{code: data[i]}
+		if (act.IsDispensed() || act.IsDeposited()) && i+1 < len(data) {
+			i++
+			act.extra = data[i]
+		}
+		list = append(list, act)
+	}
+	return list
+}
+
+func getRouteText(route byte) string {
+	switch route {
+	case routeStacked:			return "Bill stacked"
+	case routeEscrow:			return "Escrow position"
+	case routeReturned:			return "Bill returned"
+	case routeToRecycler:		return "Bill to recycler"
+	case routeDisabledReject:	return "Disabled bill rejected"
+	case routeRecyclerFill:		return "Bill to recycler by manual fill"
+	case routeManualDispense:	return "Manual dispense"
+	case routeToCashbox:		return "Transferred from recycler to cashbox"
+	default:					return "Unknown routing"
+	}
+}
+
+func getBillStatusText(code byte) string {
+	switch code {
+	case bstDefectiveMotor:		return "Defective motor"
+	case bstSensorProblem:		return "Sensor problem"
+	case bstBusy:				return "Validator busy"
+	case bstRomError:			return "ROM checksum error"
+	case bstJammed:				return "Validator jammed"
+	case bstWasReset:			return "Validator was reset"
+	case bstBillRemoved:		return "Bill removed"
+	case bstCashboxOut:			return "Cash box out of position"
+	case bstDisabled:			return "Validator disabled"
+	case bstInvalidEscrow:		return "Invalid escrow request"
+	case bstBillRejected:		return "Bill rejected"
+	case bstCreditRemoval:		return "Possible credited bill removal"
+	default:					return "Unknown status"
+	}
+}
+
+func getCoinRouteText(route byte) string {
+	switch route {
+	case coinToCashbox:			return "cash box"
+	case coinToTube:			return "tube"
+	case coinRejected:			return "reject"
+	default:					return "unknown"
+	}
+}
+
+func getCoinStatusText(code byte) string {
+	switch code {
+	case cstEscrowRequest:		return "Escrow request"
+	case cstPayoutBusy:			return "Changer payout busy"
+	case cstNoCredit:			return "No credit"
+	case cstTubeSensor:			return "Defective tube sensor"
+	case cstDoubleArrival:		return "Double arrival"
+	case cstAcceptorUnplugged:	return "Acceptor unplugged"
+	case cstTubeJam:			return "Tube jam"
+	case cstRomError:			return "ROM checksum error"
+	case cstRoutingError:		return "Coin routing error"
+	case cstBusy:				return "Changer busy"
+	case cstWasReset:			return "Changer was reset"
+	case cstCoinJam:			return "Coin jam"
+	case cstCreditRemoval:		return "Possible credited coin removal"
+	default:					return "Unknown status"
+	}
+}
@@ -0,0 +1,29 @@
+package mdb
+
+import (
+	"github.com/iftsoft/device/common"
+	"testing"
+)
+
+func TestParseCoinActivity(t *testing.T) {
+	acts := parseCoinActivity([]byte{0x51, 0x07, cstPayoutBusy, 0x92, 0x03, 0x43})
+	if len(acts) != 4 {
+		t.Fatalf("activities %v", acts)
+	}
+	if !acts[0].IsDeposited() || acts[0].Route() != coinToTube || acts[0].Type() != 1 || acts[0].extra != 7 {
+		t.Errorf("deposited coin %s", acts[0])
+	}
+	if !acts[1].IsStatus() || acts[1].GetError() != common.DevErrorSuccess {
+		t.Errorf("payout busy %s", acts[1])
+	}
+	if !acts[2].IsDispensed() || acts[2].Type() != 2 || acts[2].extra != 3 {
+		t.Errorf("dispensed coins %s", acts[2])
+	}
+	// Deposit at the end of reply has no tube count
+	if !acts[3].IsDeposited() || acts[3].Type() != 3 || acts[3].extra != 0 {
+		t.Errorf("deposited coin %s", acts[3])
+	}
+	if act := (coinActivity{code: cstCoinJam}); act.GetError() != common.DevErrorBillJammed {
+		t.Errorf("coin jam error %s", act.GetError())
+	}
+}
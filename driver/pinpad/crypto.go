@@ -0,0 +1,72 @@
+package pinpad
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"github.com/iftsoft/device/common"
+)
+
+const kcvSize = 3 // Key check value is the first bytes of zero block encrypted by the key
+
+// NewKeyCipher makes TDES cipher of double or triple length key, or AES cipher for AES keys
+func NewKeyCipher(key []byte, isAes bool) (cipher.Block, error) {
+	if isAes {
+		switch len(key) {
+		case 16, 24, 32:
+			return aes.NewCipher(key)
+		}
+		return nil, common.NewError(common.DevErrorBadKeyValue, "AES key length is wrong")
+	}
+	switch len(key) {
+	case 16:
+		full := append(append([]byte{}, key...), key[:8]...)
+		return des.NewTripleDESCipher(full)
+	case 24:
+		return des.NewTripleDESCipher(key)
+	}
+	return nil, common.NewError(common.DevErrorBadKeyValue, "TDES key length is wrong")
+}
+
+// EncryptEcb encrypts data of whole blocks
+func EncryptEcb(block cipher.Block, data []byte) ([]byte, error) {
+	size := block.BlockSize()
+	if len(data) == 0 || len(data)%size != 0 {
+		return nil, common.NewError(common.DevErrorBadKeyValue, "data is not multiple of cipher block")
+	}
+	out := make([]byte, len(data))
+	for i := 0; i < len(data); i += size {
+		block.Encrypt(out[i:i+size], data[i:i+size])
+	}
+	return out, nil
+}
+
+// DecryptEcb decrypts data of whole blocks
+func DecryptEcb(block cipher.Block, data []byte) ([]byte, error) {
+	size := block.BlockSize()
+	if len(data) == 0 || len(data)%size != 0 {
+		return nil, common.NewError(common.DevErrorBadKeyValue, "data is not multiple of cipher block")
+	}
+	out := make([]byte, len(data))
+	for i := 0; i < len(data); i += size {
+		block.Decrypt(out[i:i+size], data[i:i+size])
+	}
+	return out, nil
+}
+
+// KeyCheckValue encrypts zero block by the key and returns its first three bytes
+func KeyCheckValue(key []byte, isAes bool) ([]byte, error) {
+	block, err := NewKeyCipher(key, isAes)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, block.BlockSize())
+	block.Encrypt(out, make([]byte, block.BlockSize()))
+	return out[:kcvSize], nil
+}
+
+func xorBytes(dst, a, b []byte) {
+	for i := range dst {
+		dst[i] = a[i] ^ b[i]
+	}
+}
@@ -0,0 +1,144 @@
+package pinpad
+
+import (
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/driver"
+	"time"
+)
+
+type PinPadDriver struct {
+	PinPadEngine
+	begTime int64
+}
+
+func NewPinPadDriver() *PinPadDriver {
+	pd := PinPadDriver{}
+	return &pd
+}
+
+// Implementation of DeviceDriver interface
+func (pd *PinPadDriver) InitDevice(context *driver.Context) error {
+	pd.initEngine(context.Config)
+	pd.DevName = context.DevName
+	pd.begTime = time.Now().Unix()
+	pd.Log.Debug("PinPadDriver run cmd:%s", "InitDevice")
+
+	mask := common.ScopeFlagSystem
+	if device, ok := context.Manager.(common.DeviceCallback); ok {
+		pd.CbDevice = device
+		mask |= common.ScopeFlagDevice
+	}
+	if pinpad, ok := context.Manager.(common.PinPadCallback); ok {
+		pd.CbPinPad = pinpad
+		mask |= common.ScopeFlagPinPad
+	}
+	if context.Greeting != nil {
+		context.Greeting.DevType = common.DevTypePINEntry
+		context.Greeting.Required = mask
+	}
+	return nil
+}
+
+func (pd *PinPadDriver) StartDevice(query *common.SystemConfig) error {
+	pd.Log.Debug("PinPadDriver run cmd:%s", "StartDeviceLoop")
+	if pd.config != nil && query != nil {
+		pd.config.OverwriteConfig(query)
+	}
+	return pd.DevStartup()
+}
+func (pd *PinPadDriver) DeviceTimer(unix int64) error {
+	pd.Log.Trace("PinPadDriver run cmd:%s", "DeviceTimer")
+	pd.NextMimicStage()
+	return nil
+}
+func (pd *PinPadDriver) StopDevice() error {
+	pd.Log.Debug("PinPadDriver run cmd:%s", "StopDeviceLoop")
+	return pd.DevCleanup()
+}
+func (pd *PinPadDriver) CheckDevice(metrics *common.SystemMetrics) error {
+	pd.Log.Debug("PinPadDriver run cmd:%s", "CheckDevice")
+	if metrics != nil {
+		metrics.Uptime = time.Now().Unix() - pd.begTime
+		metrics.DevState = pd.DevState
+		metrics.DevError = pd.DevError
+	}
+	return nil
+}
+
+// Implementation of common.DeviceManager
+//
+func (pd *PinPadDriver) Cancel(name string, query *common.DeviceQuery) error {
+	err := pd.DevCancel()
+	pd.DevError, pd.DevReply = common.CheckError(err)
+	return pd.RunDeviceReply(common.CmdDeviceCancel)
+}
+func (pd *PinPadDriver) Reset(name string, query *common.DeviceQuery) error {
+	err := pd.DevReset()
+	pd.DevError, pd.DevReply = common.CheckError(err)
+	return pd.RunDeviceReply(common.CmdDeviceReset)
+}
+func (pd *PinPadDriver) Status(name string, query *common.DeviceQuery) error {
+	err := pd.DevStatus()
+	pd.DevError, pd.DevReply = common.CheckError(err)
+	return pd.RunDeviceReply(common.CmdDeviceStatus)
+}
+func (pd *PinPadDriver) RunAction(name string, query *common.DeviceQuery) error {
+	err := pd.DevStatus()
+	pd.DevError, pd.DevReply = common.CheckError(err)
+	return pd.RunDeviceReply(common.CmdRunAction)
+}
+func (pd *PinPadDriver) StopAction(name string, query *common.DeviceQuery) error {
+	err := pd.DevCancel()
+	pd.DevError, pd.DevReply = common.CheckError(err)
+	return pd.RunDeviceReply(common.CmdStopAction)
+}
+
+
+// Implementation of common.PinPadManager
+//
+func (pd *PinPadDriver) ReadPIN(name string, query *common.ReaderPinQuery) error {
+	err := pd.DevReadPIN(query)
+	pd.DevError, pd.DevReply = common.CheckError(err)
+	return pd.RunDeviceReply(common.CmdReadPIN)
+}
+func (pd *PinPadDriver) ReadData(name string, query *common.ReaderDataQuery) error {
+	err := pd.DevReadData(query)
+	pd.DevError, pd.DevReply = common.CheckError(err)
+	return pd.RunDeviceReply(common.CmdReadData)
+}
+func (pd *PinPadDriver) LoadMasterKey(name string, query *common.ReaderPinQuery) error {
+	reply := &common.ReaderPinReply{}
+	err := pd.DevLoadMasterKey(query, reply)
+	pd.DevError, pd.DevReply = common.CheckError(err)
+	return pd.RunPinPadReply(common.CmdLoadMasterKey, reply)
+}
+func (pd *PinPadDriver) LoadWorkKey(name string, query *common.ReaderPinQuery) error {
+	reply := &common.ReaderPinReply{}
+	err := pd.DevLoadWorkKey(query, reply)
+	pd.DevError, pd.DevReply = common.CheckError(err)
+	return pd.RunPinPadReply(common.CmdLoadWorkKey, reply)
+}
+func (pd *PinPadDriver) TestMasterKey(name string, query *common.ReaderPinQuery) error {
+	reply := &common.ReaderPinReply{}
+	err := pd.DevTestMasterKey(query, reply)
+	pd.DevError, pd.DevReply = common.CheckError(err)
+	return pd.RunPinPadReply(common.CmdTestMasterKey, reply)
+}
+func (pd *PinPadDriver) TestWorkKey(name string, query *common.ReaderPinQuery) error {
+	reply := &common.ReaderPinReply{}
+	err := pd.DevTestWorkKey(query, reply)
+	pd.DevError, pd.DevReply = common.CheckError(err)
+	return pd.RunPinPadReply(common.CmdTestWorkKey, reply)
+}
+func (pd *PinPadDriver) LoadDukptKey(name string, query *common.ReaderPinQuery) error {
+	reply := &common.ReaderPinReply{}
+	err := pd.DevLoadDukptKey(query, reply)
+	pd.DevError, pd.DevReply = common.CheckError(err)
+	return pd.RunPinPadReply(common.CmdLoadDukptKey, reply)
+}
+func (pd *PinPadDriver) GenerateMAC(name string, query *common.ReaderPinQuery) error {
+	reply := &common.ReaderPinReply{}
+	err := pd.DevGenerateMAC(query, reply)
+	pd.DevError, pd.DevReply = common.CheckError(err)
+	return pd.RunPinPadReply(common.CmdGenerateMAC, reply)
+}
@@ -0,0 +1,369 @@
+package pinpad
+
+import (
+	"crypto/aes"
+	"crypto/des"
+	"encoding/binary"
+	"github.com/iftsoft/device/common"
+	"math/bits"
+	"sync"
+)
+
+// Key serial number and counter sizes of TDES (ANSI X9.24-1) and AES (ANSI X9.24-3) DUKPT
+const (
+	KsnSizeTdes = 10
+	KsnSizeAes  = 12
+
+	tdesCounterBits = 21
+	tdesCounterOnes = 10 // Counter with more ones is skipped
+	aesCounterBits  = 32
+	aesCounterOnes  = 16
+)
+
+// TDES DUKPT key masks and variants
+var (
+	tdesKeyMask    = []byte{0xC0, 0xC0, 0xC0, 0xC0, 0, 0, 0, 0, 0xC0, 0xC0, 0xC0, 0xC0, 0, 0, 0, 0}
+	tdesPinVariant = []byte{0, 0, 0, 0, 0, 0, 0, 0xFF, 0, 0, 0, 0, 0, 0, 0, 0xFF}
+	tdesMacVariant = []byte{0, 0, 0, 0, 0, 0, 0xFF, 0, 0, 0, 0, 0, 0, 0, 0xFF, 0}
+	tdesDatVariant = []byte{0, 0, 0, 0, 0, 0xFF, 0, 0, 0, 0, 0, 0, 0, 0xFF, 0, 0}
+)
+
+// AES DUKPT key usage and algorithm indicators of derivation data
+const (
+	aesUsagePinEncrypt    uint16 = 0x1000
+	aesUsageMacGenerate   uint16 = 0x2000
+	aesUsageDataBothWays  uint16 = 0x3002
+	aesUsageKeyDerivation uint16 = 0x8000
+	aesUsageInitialKey    uint16 = 0x8001
+
+	aesAlgorithm128 uint16 = 2
+	aesAlgorithm192 uint16 = 3
+	aesAlgorithm256 uint16 = 4
+)
+
+// DeriveInitialKey derives initial key of the device from base derivation key,
+// KSN of 10 bytes is for TDES DUKPT and KSN of 12 bytes is for AES DUKPT
+func DeriveInitialKey(bdk []byte, ksn []byte) ([]byte, error) {
+	switch len(ksn) {
+	case KsnSizeTdes:
+		if len(bdk) != 16 {
+			return nil, common.NewError(common.DevErrorBadKeyValue, "TDES BDK length is wrong")
+		}
+		// Left 64 bits of KSN with zero counter
+		reg := append(ksn[:2:2], make([]byte, 8)...)
+		binary.BigEndian.PutUint64(reg[2:], getTdesRegister(ksn, 0))
+		reg = reg[:8]
+		left, err := encryptTdes(bdk, reg)
+		if err != nil {
+			return nil, err
+		}
+		masked := make([]byte, 16)
+		xorBytes(masked, bdk, tdesKeyMask)
+		right, err := encryptTdes(masked, reg)
+		if err != nil {
+			return nil, err
+		}
+		return append(left, right...), nil
+	case KsnSizeAes:
+		return deriveAesKey(bdk, aesUsageInitialKey, len(bdk), ksn[:8])
+	}
+	return nil, common.NewError(common.DevErrorBadArgument, "KSN length is wrong")
+}
+
+// DeriveTransactionKey derives the key of KSN counter from initial key as the host does
+func DeriveTransactionKey(initialKey []byte, ksn []byte) ([]byte, error) {
+	scheme, err := newDukptScheme(ksn)
+	if err != nil {
+		return nil, err
+	}
+	counter := scheme.getCounter(ksn)
+	key := append([]byte{}, initialKey...)
+	var done uint32
+	for bit := scheme.counterBits - 1; bit >= 0; bit-- {
+		if counter&(1<<uint(bit)) == 0 {
+			continue
+		}
+		done |= 1 << uint(bit)
+		key, err = scheme.deriveStep(key, done)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// DeriveWorkingKey derives PIN, MAC or data key of the transaction key
+func DeriveWorkingKey(key []byte, ksn []byte, keyType common.EnumPinKeyType) ([]byte, error) {
+	scheme, err := newDukptScheme(ksn)
+	if err != nil {
+		return nil, err
+	}
+	return scheme.deriveWorking(key, scheme.getCounter(ksn), keyType)
+}
+
+////////////////////////////////////////////////////////////////
+
+// Dukpt is DUKPT originating device that keeps future keys for counter bits.
+// Future key of bit N is the key of the next counter that has N as its lowest bit,
+// initial key is not kept after the future keys are derived.
+type Dukpt struct {
+	scheme  *dukptScheme
+	counter uint32
+	future  [][]byte
+	mutex   sync.Mutex
+}
+
+// NewDukpt loads initial key and derives future keys, the counter of KSN is ignored
+func NewDukpt(initialKey []byte, ksn []byte) (*Dukpt, error) {
+	scheme, err := newDukptScheme(ksn)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = NewKeyCipher(initialKey, scheme.isAes); err != nil {
+		return nil, err
+	}
+	dk := &Dukpt{scheme: scheme, future: make([][]byte, scheme.counterBits)}
+	err = dk.fillFuture(initialKey, 0, scheme.counterBits)
+	if err != nil {
+		return nil, err
+	}
+	return dk, nil
+}
+
+func (dk *Dukpt) IsAes() bool {
+	return dk.scheme.isAes
+}
+
+// GetKsn returns KSN of the last transaction, counter is zero before the first one
+func (dk *Dukpt) GetKsn() []byte {
+	dk.mutex.Lock()
+	defer dk.mutex.Unlock()
+	return dk.scheme.makeKsn(dk.counter)
+}
+
+// NextKey moves to the next transaction and returns its working key of the type and its KSN
+func (dk *Dukpt) NextKey(keyType common.EnumPinKeyType) ([]byte, []byte, error) {
+	dk.mutex.Lock()
+	defer dk.mutex.Unlock()
+	limit := uint64(1) << uint(dk.scheme.counterBits)
+	counter := uint64(dk.counter) + 1
+	for bits.OnesCount64(counter) > dk.scheme.counterOnes {
+		// Skip the counter and erase its key
+		low := bits.TrailingZeros64(counter)
+		eraseKey(dk.future[low])
+		dk.future[low] = nil
+		counter += 1 << uint(low)
+	}
+	if counter >= limit {
+		return nil, nil, common.NewError(common.DevErrorSecurityFault, "DUKPT keys are exhausted")
+	}
+	low := bits.TrailingZeros64(counter)
+	key := dk.future[low]
+	if key == nil {
+		return nil, nil, common.NewError(common.DevErrorSecurityFault, "DUKPT future key is erased")
+	}
+	dk.counter = uint32(counter)
+	err := dk.fillFuture(key, dk.counter, low)
+	if err != nil {
+		return nil, nil, err
+	}
+	work, err := dk.scheme.deriveWorking(key, dk.counter, keyType)
+	eraseKey(key)
+	dk.future[low] = nil
+	if err != nil {
+		return nil, nil, err
+	}
+	return work, dk.scheme.makeKsn(dk.counter), nil
+}
+
+// Erase erases all future keys
+func (dk *Dukpt) Erase() {
+	dk.mutex.Lock()
+	defer dk.mutex.Unlock()
+	for i, key := range dk.future {
+		eraseKey(key)
+		dk.future[i] = nil
+	}
+}
+
+// Future keys of bits below the top are derived from the key of the counter
+func (dk *Dukpt) fillFuture(key []byte, counter uint32, top int) error {
+	for bit := top - 1; bit >= 0; bit-- {
+		next, err := dk.scheme.deriveStep(key, counter|1<<uint(bit))
+		if err != nil {
+			return err
+		}
+		eraseKey(dk.future[bit])
+		dk.future[bit] = next
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////////////
+
+// DUKPT scheme of the KSN with counter removed
+type dukptScheme struct {
+	isAes       bool
+	ksn         []byte
+	counterBits int
+	counterOnes int
+}
+
+func newDukptScheme(ksn []byte) (*dukptScheme, error) {
+	ds := &dukptScheme{}
+	switch len(ksn) {
+	case KsnSizeTdes:
+		ds.counterBits, ds.counterOnes = tdesCounterBits, tdesCounterOnes
+	case KsnSizeAes:
+		ds.isAes = true
+		ds.counterBits, ds.counterOnes = aesCounterBits, aesCounterOnes
+	default:
+		return nil, common.NewError(common.DevErrorBadArgument, "KSN length is wrong")
+	}
+	ds.ksn = ds.makeKsnOf(ksn, 0)
+	return ds, nil
+}
+
+func (ds *dukptScheme) getCounter(ksn []byte) uint32 {
+	if ds.isAes {
+		return binary.BigEndian.Uint32(ksn[8:])
+	}
+	return uint32(binary.BigEndian.Uint64(ksn[2:]) & (1<<tdesCounterBits - 1))
+}
+
+func (ds *dukptScheme) makeKsn(counter uint32) []byte {
+	return ds.makeKsnOf(ds.ksn, counter)
+}
+
+func (ds *dukptScheme) makeKsnOf(ksn []byte, counter uint32) []byte {
+	out := append([]byte{}, ksn...)
+	if ds.isAes {
+		binary.BigEndian.PutUint32(out[8:], counter)
+	} else {
+		binary.BigEndian.PutUint64(out[2:], getTdesRegister(ksn, counter))
+	}
+	return out
+}
+
+// One step of key derivation for the counter with one more bit set
+func (ds *dukptScheme) deriveStep(key []byte, counter uint32) ([]byte, error) {
+	if ds.isAes {
+		return deriveAesKey(key, aesUsageKeyDerivation, len(key), ds.makeAesData(counter))
+	}
+	return deriveTdesKey(key, getTdesRegister(ds.ksn, counter))
+}
+
+func (ds *dukptScheme) deriveWorking(key []byte, counter uint32, keyType common.EnumPinKeyType) ([]byte, error) {
+	if ds.isAes {
+		usage := aesUsagePinEncrypt
+		switch keyType {
+		case common.PinPadKeyMAC:
+			usage = aesUsageMacGenerate
+		case common.PinPadKeyData:
+			usage = aesUsageDataBothWays
+		}
+		return deriveAesKey(key, usage, len(key), ds.makeAesData(counter))
+	}
+	work := make([]byte, 16)
+	switch keyType {
+	case common.PinPadKeyMAC:
+		xorBytes(work, key, tdesMacVariant)
+	case common.PinPadKeyData:
+		// Data key variant is encrypted by itself
+		xorBytes(work, key, tdesDatVariant)
+		left, err := encryptTdes(work, work[:8])
+		if err != nil {
+			return nil, err
+		}
+		right, err := encryptTdes(work, work[8:])
+		if err != nil {
+			return nil, err
+		}
+		work = append(left, right...)
+	default:
+		xorBytes(work, key, tdesPinVariant)
+	}
+	return work, nil
+}
+
+// Derivation ID of AES KSN and the counter
+func (ds *dukptScheme) makeAesData(counter uint32) []byte {
+	data := append([]byte{}, ds.ksn[4:8]...)
+	return append(data, byte(counter>>24), byte(counter>>16), byte(counter>>8), byte(counter))
+}
+
+// Right 64 bits of TDES KSN with the counter
+func getTdesRegister(ksn []byte, counter uint32) uint64 {
+	reg := binary.BigEndian.Uint64(ksn[2:])
+	return reg&^(1<<tdesCounterBits-1) | uint64(counter)
+}
+
+// Non-reversible key generation process of TDES DUKPT
+func deriveTdesKey(key []byte, reg uint64) ([]byte, error) {
+	if len(key) != 16 {
+		return nil, common.NewError(common.DevErrorBadKeyValue, "TDES DUKPT key length is wrong")
+	}
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, reg)
+	masked := make([]byte, 16)
+	xorBytes(masked, key, tdesKeyMask)
+	left, err := encryptDesHalf(masked, data)
+	if err != nil {
+		return nil, err
+	}
+	right, err := encryptDesHalf(key, data)
+	if err != nil {
+		return nil, err
+	}
+	return append(left, right...), nil
+}
+
+// Data XOR right half is encrypted by left half and XOR right half
+func encryptDesHalf(key []byte, data []byte) ([]byte, error) {
+	block, err := des.NewCipher(key[:8])
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 8)
+	xorBytes(out, data, key[8:])
+	block.Encrypt(out, out)
+	xorBytes(out, out, key[8:])
+	return out, nil
+}
+
+func encryptTdes(key []byte, data []byte) ([]byte, error) {
+	block, err := NewKeyCipher(key, false)
+	if err != nil {
+		return nil, err
+	}
+	return EncryptEcb(block, data)
+}
+
+// Key derivation of ANSI X9.24-3, the key of the length is derived by AES blocks of derivation data
+func deriveAesKey(key []byte, usage uint16, length int, tail []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, common.NewError(common.DevErrorBadKeyValue, "AES DUKPT key length is wrong")
+	}
+	algorithm := aesAlgorithm128
+	switch length {
+	case 24:
+		algorithm = aesAlgorithm192
+	case 32:
+		algorithm = aesAlgorithm256
+	}
+	data := make([]byte, 16)
+	data[0] = 0x01
+	binary.BigEndian.PutUint16(data[2:], usage)
+	binary.BigEndian.PutUint16(data[4:], algorithm)
+	binary.BigEndian.PutUint16(data[6:], uint16(length*8))
+	copy(data[8:], tail)
+	out := make([]byte, 0, 32)
+	for i := 1; len(out) < length; i++ {
+		data[1] = byte(i)
+		part := make([]byte, 16)
+		block.Encrypt(part, data)
+		out = append(out, part...)
+	}
+	return out[:length], nil
+}
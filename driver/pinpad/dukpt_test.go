@@ -0,0 +1,129 @@
+package pinpad
+
+import (
+	"bytes"
+	"encoding/hex"
+	"github.com/iftsoft/device/common"
+	"testing"
+)
+
+// Test keys of ANSI X9.24-1 (TDES) and ANSI X9.24-3 (AES) DUKPT
+const (
+	testTdesBdk = "0123456789ABCDEFFEDCBA9876543210"
+	testTdesKsn = "FFFF9876543210E00000"
+	testTdesIk  = "6AC292FAA1315B4D858AB3A3D7D5933A"
+	testAesBdk  = "FEDCBA9876543210F1F1F1F1F1F1F1F1"
+	testAesKsn  = "123456789012345600000000"
+	testAesIk   = "1273671EA26AC29AFA4D1084127652A1"
+)
+
+func mustHex(t *testing.T, text string) []byte {
+	t.Helper()
+	data, err := hex.DecodeString(text)
+	if err != nil {
+		t.Fatalf("bad hex %s: %s", text, err)
+	}
+	return data
+}
+
+func TestDeriveInitialKey(t *testing.T) {
+	tests := []struct {
+		name string
+		bdk  string
+		ksn  string
+		ik   string
+	}{
+		{"TDES", testTdesBdk, testTdesKsn, testTdesIk},
+		{"AES", testAesBdk, testAesKsn, testAesIk},
+	}
+	for _, tt := range tests {
+		key, err := DeriveInitialKey(mustHex(t, tt.bdk), mustHex(t, tt.ksn))
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		if !bytes.Equal(key, mustHex(t, tt.ik)) {
+			t.Errorf("%s: initial key %X, want %s", tt.name, key, tt.ik)
+		}
+	}
+	if _, err := DeriveInitialKey(mustHex(t, testTdesBdk), mustHex(t, "FFFF98765432")); err == nil {
+		t.Error("short KSN is accepted")
+	}
+	if _, err := DeriveInitialKey(mustHex(t, testTdesBdk)[:8], mustHex(t, testTdesKsn)); err == nil {
+		t.Error("short TDES BDK is accepted")
+	}
+}
+
+func TestDukptFirstKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		ik      string
+		ksn     string
+		keyType common.EnumPinKeyType
+		key     string
+		next    string
+	}{
+		{"TDES PIN", testTdesIk, testTdesKsn, common.PinPadKeyPIN, "042666B49184CF5C68DE9628D0397B36", "FFFF9876543210E00001"},
+		{"TDES MAC", testTdesIk, testTdesKsn, common.PinPadKeyMAC, "042666B4918430A368DE9628D03984C9", "FFFF9876543210E00001"},
+		{"AES PIN", testAesIk, testAesKsn, common.PinPadKeyPIN, "AF8CB133A78F8DC2D1359F18527593FB", "123456789012345600000001"},
+	}
+	for _, tt := range tests {
+		dk, err := NewDukpt(mustHex(t, tt.ik), mustHex(t, tt.ksn))
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		key, ksn, err := dk.NextKey(tt.keyType)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		if !bytes.Equal(key, mustHex(t, tt.key)) {
+			t.Errorf("%s: key %X, want %s", tt.name, key, tt.key)
+		}
+		if !bytes.Equal(ksn, mustHex(t, tt.next)) || !bytes.Equal(dk.GetKsn(), ksn) {
+			t.Errorf("%s: KSN %X, want %s", tt.name, ksn, tt.next)
+		}
+	}
+}
+
+// Keys of originating device must match keys that the host derives of KSN
+func TestDukptMatchesHost(t *testing.T) {
+	types := []common.EnumPinKeyType{common.PinPadKeyPIN, common.PinPadKeyMAC, common.PinPadKeyData}
+	for _, tt := range []struct{ ik, ksn string }{{testTdesIk, testTdesKsn}, {testAesIk, testAesKsn}} {
+		ik := mustHex(t, tt.ik)
+		dk, err := NewDukpt(ik, mustHex(t, tt.ksn))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 1; i <= 40; i++ {
+			keyType := types[i%len(types)]
+			key, ksn, err := dk.NextKey(keyType)
+			if err != nil {
+				t.Fatalf("counter %d: %s", i, err)
+			}
+			if counter := int(ksn[len(ksn)-1]); counter != i {
+				t.Fatalf("KSN %X has counter %d, want %d", ksn, counter, i)
+			}
+			tk, err := DeriveTransactionKey(ik, ksn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := DeriveWorkingKey(tk, ksn, keyType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(key, want) {
+				t.Errorf("KSN %X %s: key %X, host has %X", ksn, keyType, key, want)
+			}
+		}
+	}
+}
+
+func TestDukptErase(t *testing.T) {
+	dk, err := NewDukpt(mustHex(t, testTdesIk), mustHex(t, testTdesKsn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dk.Erase()
+	if _, _, err = dk.NextKey(common.PinPadKeyPIN); err == nil {
+		t.Error("key is derived after erase")
+	}
+}
@@ -0,0 +1,472 @@
+package pinpad
+
+import (
+	"bytes"
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/config"
+	"github.com/iftsoft/device/core"
+	"github.com/iftsoft/device/driver/generic"
+	"strings"
+	"time"
+)
+
+const (
+	entryTimeout  = 30 // Seconds for customer to enter PIN or data
+	dataMaxLength = 32 // Max length of data entry
+	pinKeyDelay   = 3  // Timer ticks between key presses of simulated customer
+	allDigits     = "0123456789"
+)
+
+// Running PIN or data entry, digits are erased when the entry is done
+type padEntry struct {
+	command   string // ReadPIN or ReadData
+	keyIndex  uint16
+	cardPan   string
+	minLength int
+	maxLength int
+	needEnter bool
+	allowKeys string
+	digits    []byte
+	ticks     int
+}
+
+func (e *padEntry) isPin() bool {
+	return e.command == common.CmdReadPIN
+}
+
+// Text for customer display, PIN digits are shown as asterisks
+func (e *padEntry) getDisplay() string {
+	if e.isPin() {
+		return strings.Repeat("*", len(e.digits))
+	}
+	return string(e.digits)
+}
+
+type PinPadEngine struct {
+	generic.BasePinPad
+	generic.Simulator
+	config    *config.DeviceConfig
+	keys      *KeyStore
+	keypad    *ScriptKeypad
+	entry     *padEntry
+	entryWait time.Time
+}
+
+func (pe *PinPadEngine) initEngine(cfg *config.DeviceConfig) *PinPadEngine {
+	pe.config = cfg
+	pe.Log    = core.GetLogAgent(core.LogLevelTrace, "Engine")
+	return pe
+}
+
+func (pe *PinPadEngine) getPinPadConfig() *config.PinPadConfig {
+	if pe.config != nil && pe.config.Pinpad != nil {
+		return pe.config.Pinpad
+	}
+	return config.GetDefaultPinPadConfig()
+}
+
+func (pe *PinPadEngine) getPinFormat() uint16 {
+	return pe.getPinPadConfig().PinFormat
+}
+
+// Max PIN length, entry is done on the max length if Enter key is not needed
+func (pe *PinPadEngine) getPinDigits() int {
+	digits := int(pe.getPinPadConfig().PinDigits)
+	if digits < pinMinLength || digits > pinMaxLength {
+		if pe.getPinPadConfig().NeedEnter {
+			return pinMaxLength
+		}
+		return pinMinLength
+	}
+	return digits
+}
+
+func (pe *PinPadEngine) getEntryTimeout(seconds int32) time.Duration {
+	if seconds <= 0 {
+		seconds = pe.getPinPadConfig().EntryTimeout
+	}
+	if seconds <= 0 {
+		seconds = entryTimeout
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// Start PIN or data entry, the keypad takes the next script of simulated customer
+func (pe *PinPadEngine) startEntry(entry *padEntry, timeout time.Duration) {
+	pe.entry = entry
+	pe.entryWait = time.Now().Add(timeout)
+	pe.keypad.StartEntry()
+	if entry.isPin() {
+		pe.SetupMimic(ppdEntrySteps)
+	} else {
+		pe.SetupMimic(ppdDataEntrySteps)
+	}
+}
+
+// Process key of the customer, entry is done on Enter or on max length if Enter is not needed.
+// Keys that are not allowed or over max length are ignored.
+func (pe *PinPadEngine) processKey(key byte) {
+	entry := pe.entry
+	switch {
+	case key >= '0' && key <= '9':
+		if len(entry.digits) >= entry.maxLength || strings.IndexByte(entry.allowKeys, key) < 0 {
+			return
+		}
+		entry.digits = append(entry.digits, key)
+		pe.reportKey(common.PadKeyDigit)
+		if !entry.needEnter && len(entry.digits) == entry.maxLength {
+			pe.finishEntry()
+		}
+	case key == KeyEnter:
+		pe.reportKey(common.PadKeyEnter)
+		if len(entry.digits) >= entry.minLength {
+			pe.finishEntry()
+		}
+	case key == KeyClear:
+		eraseKey(entry.digits)
+		entry.digits = entry.digits[:0]
+		pe.reportKey(common.PadKeyClear)
+	case key == KeyCancel:
+		pe.reportKey(common.PadKeyCancel)
+		pe.stopEntry(ppdEntryCancelSteps)
+	default:
+	}
+}
+
+// Report key press event with text for customer display
+func (pe *PinPadEngine) reportKey(key common.EnumPadKey) {
+	value := &common.ReaderKeyPress{
+		Command: pe.entry.command,
+		Key:     key,
+		Length:  uint16(len(pe.entry.digits)),
+		Display: pe.entry.getDisplay(),
+	}
+	_ = pe.RunPinPadKeyPress(value)
+}
+
+// Make PIN block or take entered data and reply it to the host
+func (pe *PinPadEngine) finishEntry() {
+	entry := pe.entry
+	reply := &common.ReaderPinReply{PinLength: uint16(len(entry.digits))}
+	var err error
+	if entry.isPin() {
+		reply.PinBlock, reply.KeySerial, err = pe.makePinBlock(entry.keyIndex, string(entry.digits), entry.cardPan)
+	} else {
+		reply.EntryData = string(entry.digits)
+	}
+	pe.clearEntry()
+	pe.SetupMimic(ppdEntryDoneSteps)
+	_ = pe.ProcessStage(&pe.BaseEngine, pe.GetMimicStep())
+	pe.DevError, pe.DevReply = common.CheckError(err)
+	_ = pe.RunPinPadReply(entry.command, reply)
+}
+
+// Stop entry with error of the steps and reply it to the host
+func (pe *PinPadEngine) stopEntry(steps generic.MimicSteps) {
+	command := pe.entry.command
+	pe.clearEntry()
+	pe.SetupMimic(steps)
+	_ = pe.ProcessStage(&pe.BaseEngine, pe.GetMimicStep())
+	_ = pe.RunPinPadReply(command, &common.ReaderPinReply{})
+}
+
+func (pe *PinPadEngine) clearEntry() {
+	if pe.entry != nil {
+		eraseKey(pe.entry.digits)
+	}
+	pe.entry = nil
+	pe.entryWait = time.Time{}
+	pe.keypad.StopEntry()
+}
+
+// PIN block is encrypted by DUKPT PIN key of the slot if it is loaded, by PIN working key otherwise.
+// KSN of DUKPT transaction is returned with the block.
+func (pe *PinPadEngine) makePinBlock(index uint16, pin string, pan string) ([]byte, []byte, error) {
+	encrypt := func(data []byte) ([]byte, error) {
+		return pe.keys.EncryptBlock(index, common.PinPadKeyPIN, data)
+	}
+	var ksn []byte
+	if pe.keys.HasDukpt(index) {
+		var key []byte
+		var err error
+		key, ksn, err = pe.keys.NextDukptKey(index, common.PinPadKeyPIN)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer eraseKey(key)
+		encrypt = func(data []byte) ([]byte, error) {
+			block, err := NewKeyCipher(key, pe.keys.IsAes())
+			if err != nil {
+				return nil, err
+			}
+			return EncryptEcb(block, data)
+		}
+	}
+	if pe.getPinFormat() == PinFormat4 {
+		block, err := EncryptPinBlock4(encrypt, pin, pan)
+		return block, ksn, err
+	}
+	clear, err := MakePinBlock(pe.getPinFormat(), pin, pan)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer eraseKey(clear)
+	block, err := encrypt(clear)
+	return block, ksn, err
+}
+
+// Compare KCV of the key with expected one if it is set
+func checkKeyCheck(kcv []byte, expected []byte) error {
+	if len(expected) > 0 && !bytes.Equal(kcv, expected) {
+		return common.NewError(common.DevErrorBadKeyValue, "key check value mismatch")
+	}
+	return nil
+}
+
+
+////////////////////////////////////////////////////////////////
+
+func (pe *PinPadEngine) DevStartup() error {
+	cfg := pe.getPinPadConfig()
+	switch cfg.PinFormat {
+	case PinFormat0, PinFormat1, PinFormat3, PinFormat4:
+	default:
+		return common.NewError(common.DevErrorConfigFault, "PIN block format must be 0, 1, 3 or 4")
+	}
+	pe.keys = NewKeyStore(cfg.PinFormat == PinFormat4)
+	pe.keypad = NewScriptKeypad(cfg.KeyScript)
+	var err error
+	return err
+}
+
+func (pe *PinPadEngine) DevCleanup() error {
+	if pe.entry != nil {
+		pe.clearEntry()
+	}
+	if pe.keys != nil {
+		pe.keys.Clear()
+	}
+	pe.ClearMimic()
+	var err error
+	return err
+}
+
+func (pe *PinPadEngine) DevReset() error {
+	if pe.entry != nil {
+		pe.clearEntry()
+	}
+	pe.SetupMimic(ppdResetSteps)
+	var err error
+	for i := 0; i < 50; i++ {
+		time.Sleep(200 * time.Millisecond)
+		if pe.DevState == common.DevStateStandby {
+			break
+		}
+	}
+	return err
+}
+
+func (pe *PinPadEngine) DevStatus() error {
+	var err error
+	return err
+}
+
+// DevCancel stops PIN entry
+func (pe *PinPadEngine) DevCancel() error {
+	if pe.entry != nil {
+		pe.stopEntry(ppdEntryCancelSteps)
+	}
+	var err error
+	return err
+}
+
+func (pe *PinPadEngine) DevReadPIN(query *common.ReaderPinQuery) error {
+	if pe.keys == nil {
+		return common.NewError(common.DevErrorNotInitialized, "PIN pad is not started")
+	}
+	if pe.entry != nil {
+		return common.NewError(common.DevErrorNotAccepted, "entry is already running")
+	}
+	if !pe.keys.HasDukpt(query.KeyIndex) {
+		if _, err := pe.keys.TestWorkKey(query.KeyIndex, common.PinPadKeyPIN); err != nil {
+			return err
+		}
+	}
+	if pe.getPinFormat() != PinFormat1 {
+		if err := checkPan(query.CardPan); err != nil {
+			return err
+		}
+	}
+	entry := &padEntry{
+		command:   common.CmdReadPIN,
+		keyIndex:  query.KeyIndex,
+		cardPan:   query.CardPan,
+		minLength: pinMinLength,
+		maxLength: pe.getPinDigits(),
+		needEnter: pe.getPinPadConfig().NeedEnter,
+		allowKeys: allDigits,
+	}
+	pe.startEntry(entry, pe.getEntryTimeout(0))
+	var err error
+	return err
+}
+
+// DevReadData starts clear data entry, Enter key is always needed to finish it
+func (pe *PinPadEngine) DevReadData(query *common.ReaderDataQuery) error {
+	if pe.keys == nil {
+		return common.NewError(common.DevErrorNotInitialized, "PIN pad is not started")
+	}
+	if pe.entry != nil {
+		return common.NewError(common.DevErrorNotAccepted, "entry is already running")
+	}
+	entry := &padEntry{
+		command:   common.CmdReadData,
+		minLength: int(query.MinLength),
+		maxLength: int(query.MaxLength),
+		needEnter: true,
+		allowKeys: query.AllowKeys,
+	}
+	if entry.minLength <= 0 {
+		entry.minLength = 1
+	}
+	if entry.maxLength <= 0 {
+		entry.maxLength = dataMaxLength
+	}
+	if entry.minLength > entry.maxLength || entry.maxLength > dataMaxLength {
+		return common.NewError(common.DevErrorBadArgument, "entry length range is wrong")
+	}
+	if entry.allowKeys == "" {
+		entry.allowKeys = allDigits
+	}
+	for _, key := range entry.allowKeys {
+		if key < '0' || key > '9' {
+			return common.NewError(common.DevErrorBadArgument, "allowed keys must be digits")
+		}
+	}
+	pe.startEntry(entry, pe.getEntryTimeout(query.Timeout))
+	var err error
+	return err
+}
+
+func (pe *PinPadEngine) DevLoadMasterKey(query *common.ReaderPinQuery, reply *common.ReaderPinReply) error {
+	if pe.keys == nil {
+		return common.NewError(common.DevErrorNotInitialized, "PIN pad is not started")
+	}
+	var err error
+	reply.KeyCheck, err = pe.keys.LoadMasterKey(query.KeyIndex, query.KeyValue)
+	return err
+}
+
+func (pe *PinPadEngine) DevLoadWorkKey(query *common.ReaderPinQuery, reply *common.ReaderPinReply) error {
+	if pe.keys == nil {
+		return common.NewError(common.DevErrorNotInitialized, "PIN pad is not started")
+	}
+	var err error
+	reply.KeyCheck, err = pe.keys.LoadWorkKey(query.KeyIndex, query.KeyType, query.KeyValue)
+	return err
+}
+
+// DevTestMasterKey returns KCV of master key, key value of the query is expected KCV if set
+func (pe *PinPadEngine) DevTestMasterKey(query *common.ReaderPinQuery, reply *common.ReaderPinReply) error {
+	if pe.keys == nil {
+		return common.NewError(common.DevErrorNotInitialized, "PIN pad is not started")
+	}
+	var err error
+	reply.KeyCheck, err = pe.keys.TestMasterKey(query.KeyIndex)
+	if err == nil {
+		err = checkKeyCheck(reply.KeyCheck, query.KeyValue)
+	}
+	return err
+}
+
+// DevTestWorkKey returns KCV of working key, key value of the query is expected KCV if set
+func (pe *PinPadEngine) DevTestWorkKey(query *common.ReaderPinQuery, reply *common.ReaderPinReply) error {
+	if pe.keys == nil {
+		return common.NewError(common.DevErrorNotInitialized, "PIN pad is not started")
+	}
+	var err error
+	reply.KeyCheck, err = pe.keys.TestWorkKey(query.KeyIndex, query.KeyType)
+	if err == nil {
+		err = checkKeyCheck(reply.KeyCheck, query.KeyValue)
+	}
+	return err
+}
+
+// DevLoadDukptKey loads initial key encrypted under master key of the slot, returns its KCV and KSN
+func (pe *PinPadEngine) DevLoadDukptKey(query *common.ReaderPinQuery, reply *common.ReaderPinReply) error {
+	if pe.keys == nil {
+		return common.NewError(common.DevErrorNotInitialized, "PIN pad is not started")
+	}
+	var err error
+	reply.KeyCheck, reply.KeySerial, err = pe.keys.LoadDukptKey(query.KeyIndex, query.KeyValue, query.KeySerial)
+	return err
+}
+
+// DevGenerateMAC makes MAC by DUKPT MAC key of the slot if it is loaded, by MAC working key otherwise
+func (pe *PinPadEngine) DevGenerateMAC(query *common.ReaderPinQuery, reply *common.ReaderPinReply) error {
+	if pe.keys == nil {
+		return common.NewError(common.DevErrorNotInitialized, "PIN pad is not started")
+	}
+	if len(query.MacData) == 0 {
+		return common.NewError(common.DevErrorBadArgument, "MAC data is empty")
+	}
+	var err error
+	if !pe.keys.HasDukpt(query.KeyIndex) {
+		reply.MacBlock, err = pe.keys.GenerateMac(query.KeyIndex, query.MacData)
+		return err
+	}
+	var key []byte
+	key, reply.KeySerial, err = pe.keys.NextDukptKey(query.KeyIndex, common.PinPadKeyMAC)
+	if err != nil {
+		return err
+	}
+	defer eraseKey(key)
+	reply.MacBlock, err = generateMac(key, query.MacData, pe.keys.IsAes())
+	return err
+}
+
+
+////////////////////////////////////////////////////////////////
+
+func (pe *PinPadEngine) NextMimicStage() {
+	pe.checkEntry()
+	stage := pe.GetMimicStep()
+	if stage != nil {
+		_ = pe.ProcessStage(&pe.BaseEngine, stage)
+		switch stage.Value {
+		case StepEntryStarted:
+			pe.StepEntryStarted()
+		default:
+		}
+	}
+	return
+}
+
+func (pe *PinPadEngine) StepEntryStarted() {
+	if pe.entry != nil {
+		pe.entry.ticks = 0
+	}
+}
+
+// Feed keys of simulated customer and check entry timeout
+func (pe *PinPadEngine) checkEntry() {
+	if pe.entry == nil || pe.DevState != common.DevStateWaiting {
+		return
+	}
+	if time.Now().After(pe.entryWait) {
+		pe.reportKey(common.PadKeyTimeout)
+		pe.stopEntry(ppdEntryTimeoutSteps)
+		return
+	}
+	pe.entry.ticks++
+	if pe.entry.ticks < pinKeyDelay {
+		return
+	}
+	pe.entry.ticks = 0
+	if key, ok := pe.keypad.NextKey(); ok {
+		pe.processKey(key)
+	}
+}
+
+
+////////////////////////////////////////////////////////////////
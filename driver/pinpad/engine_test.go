@@ -0,0 +1,133 @@
+package pinpad
+
+import (
+	"fmt"
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/config"
+	"testing"
+)
+
+// Callback that keeps replies and key presses as text
+type testPadCallback struct {
+	replies []*common.ReaderPinReply
+	keys    []string
+}
+
+func (cb *testPadCallback) PinPadReply(name string, reply *common.ReaderPinReply) error {
+	cb.replies = append(cb.replies, reply)
+	return nil
+}
+
+func (cb *testPadCallback) PinPadKeyPress(name string, value *common.ReaderKeyPress) error {
+	cb.keys = append(cb.keys, fmt.Sprintf("%s %s %d '%s'", value.Command, value.Key, value.Length, value.Display))
+	return nil
+}
+
+func newTestEngine(t *testing.T, cfg *config.PinPadConfig) (*PinPadEngine, *testPadCallback) {
+	t.Helper()
+	pe := (&PinPadEngine{}).initEngine(&config.DeviceConfig{Pinpad: cfg})
+	if err := pe.DevStartup(); err != nil {
+		t.Fatal(err)
+	}
+	cb := &testPadCallback{}
+	pe.CbPinPad = cb
+	return pe, cb
+}
+
+// Run simulator ticks until the entry is replied
+func waitReply(t *testing.T, pe *PinPadEngine, cb *testPadCallback) *common.ReaderPinReply {
+	t.Helper()
+	for i := 0; i < 100 && len(cb.replies) == 0; i++ {
+		pe.NextMimicStage()
+	}
+	if len(cb.replies) != 1 {
+		t.Fatalf("entry gives %d replies", len(cb.replies))
+	}
+	return cb.replies[0]
+}
+
+func checkKeys(t *testing.T, cb *testPadCallback, want ...string) {
+	t.Helper()
+	if fmt.Sprint(cb.keys) != fmt.Sprint(want) {
+		t.Errorf("key presses %q, want %q", cb.keys, want)
+	}
+}
+
+func TestReadDataErrors(t *testing.T) {
+	pe := (&PinPadEngine{}).initEngine(nil)
+	if code, _ := common.CheckError(pe.DevReadData(&common.ReaderDataQuery{})); code != common.DevErrorNotInitialized {
+		t.Errorf("entry before startup gives %s", code)
+	}
+	pe, _ = newTestEngine(t, nil)
+	tests := []struct {
+		name  string
+		query common.ReaderDataQuery
+	}{
+		{"min over max", common.ReaderDataQuery{MinLength: 5, MaxLength: 4}},
+		{"min over default max", common.ReaderDataQuery{MinLength: dataMaxLength + 1}},
+		{"max too long", common.ReaderDataQuery{MaxLength: dataMaxLength + 1}},
+		{"not digit key", common.ReaderDataQuery{AllowKeys: "12E"}},
+	}
+	for _, tt := range tests {
+		if code, _ := common.CheckError(pe.DevReadData(&tt.query)); code != common.DevErrorBadArgument || pe.entry != nil {
+			t.Errorf("%s: %s", tt.name, code)
+		}
+	}
+	if err := pe.DevReadData(&common.ReaderDataQuery{}); err != nil {
+		t.Fatal(err)
+	}
+	if pe.entry.minLength != 1 || pe.entry.maxLength != dataMaxLength || pe.entry.allowKeys != allDigits {
+		t.Errorf("default entry is %d-%d of %s", pe.entry.minLength, pe.entry.maxLength, pe.entry.allowKeys)
+	}
+	if code, _ := common.CheckError(pe.DevReadData(&common.ReaderDataQuery{})); code != common.DevErrorNotAccepted {
+		t.Errorf("second entry gives %s", code)
+	}
+}
+
+func TestReadDataEntry(t *testing.T) {
+	// Not allowed key, clear, digits over max length and enter
+	pe, cb := newTestEngine(t, &config.PinPadConfig{KeyScript: []string{"1 3 C 4 5 6 3 E"}})
+	if err := pe.DevReadData(&common.ReaderDataQuery{MinLength: 2, MaxLength: 3, AllowKeys: "3456"}); err != nil {
+		t.Fatal(err)
+	}
+	reply := waitReply(t, pe, cb)
+	if reply.Command != common.CmdReadData || reply.EntryData != "456" || reply.PinLength != 3 ||
+		reply.ErrCode != common.DevErrorSuccess || reply.PinBlock != nil {
+		t.Errorf("data entry reply %s, data %s", reply, reply.EntryData)
+	}
+	checkKeys(t, cb, "ReadData Digit 1 '3'", "ReadData Clear 0 ''", "ReadData Digit 1 '4'",
+		"ReadData Digit 2 '45'", "ReadData Digit 3 '456'", "ReadData Enter 3 '456'")
+	if pe.entry != nil {
+		t.Error("entry is not cleared")
+	}
+
+	// Enter before min length is ignored, customer cancels the entry
+	pe, cb = newTestEngine(t, &config.PinPadConfig{KeyScript: []string{"1EX"}})
+	_ = pe.DevReadData(&common.ReaderDataQuery{MinLength: 2})
+	reply = waitReply(t, pe, cb)
+	if reply.ErrCode != common.DevErrorCanceled || reply.EntryData != "" {
+		t.Errorf("canceled entry reply %s", reply)
+	}
+	checkKeys(t, cb, "ReadData Digit 1 '1'", "ReadData Enter 1 '1'", "ReadData Cancel 1 '1'")
+}
+
+func TestReadPinDisplay(t *testing.T) {
+	pe, cb := newTestEngine(t, &config.PinPadConfig{NeedEnter: true, PinDigits: 4, KeyScript: []string{"12C12345E"}})
+	pe.keys = newTestStore(t)
+	if err := pe.DevReadPIN(&common.ReaderPinQuery{KeyIndex: 1, CardPan: testPan}); err != nil {
+		t.Fatal(err)
+	}
+	reply := waitReply(t, pe, cb)
+	// PIN digits are never shown, only their count
+	checkKeys(t, cb, "ReadPIN Digit 1 '*'", "ReadPIN Digit 2 '**'", "ReadPIN Clear 0 ''",
+		"ReadPIN Digit 1 '*'", "ReadPIN Digit 2 '**'", "ReadPIN Digit 3 '***'", "ReadPIN Digit 4 '****'",
+		"ReadPIN Enter 4 '****'")
+	if reply.ErrCode != common.DevErrorSuccess || reply.PinLength != 4 || reply.EntryData != "" {
+		t.Fatalf("PIN entry reply %s", reply)
+	}
+	work, _ := NewKeyCipher(mustHex(t, testWork), false)
+	block, _ := DecryptEcb(work, reply.PinBlock)
+	if pin, err := ParsePinBlock(block, testPan); err != nil || pin != "1234" {
+		t.Errorf("PIN block has PIN %s: %v", pin, err)
+	}
+}
@@ -0,0 +1,53 @@
+package pinpad
+
+import "strings"
+
+// Keys of scripted keypad, digits are pressed as they are
+const (
+	KeyEnter  byte = 'E'
+	KeyClear  byte = 'C'
+	KeyCancel byte = 'X'
+	KeyPause  byte = '.' // No key is pressed for a while
+)
+
+// Customer enters PIN 1234 and presses Enter if no script is set
+var defaultKeyScript = []string{"1234E"}
+
+// ScriptKeypad feeds key presses of simulated customer, each entry uses the next script in round
+type ScriptKeypad struct {
+	scripts []string
+	index   int
+	keys    string
+}
+
+func NewScriptKeypad(scripts []string) *ScriptKeypad {
+	sk := ScriptKeypad{scripts: scripts}
+	if len(sk.scripts) == 0 {
+		sk.scripts = defaultKeyScript
+	}
+	return &sk
+}
+
+// StartEntry takes the next script for new entry
+func (sk *ScriptKeypad) StartEntry() {
+	if sk.index >= len(sk.scripts) {
+		sk.index = 0
+	}
+	sk.keys = strings.ToUpper(strings.Replace(sk.scripts[sk.index], " ", "", -1))
+	sk.index++
+}
+
+// NextKey returns the next key of the script, false if the customer does not press keys any more
+func (sk *ScriptKeypad) NextKey() (byte, bool) {
+	if sk.keys == "" {
+		return 0, false
+	}
+	key := sk.keys[0]
+	sk.keys = sk.keys[1:]
+	return key, true
+}
+
+// StopEntry drops keys that are left
+func (sk *ScriptKeypad) StopEntry() {
+	sk.keys = ""
+}
@@ -0,0 +1,285 @@
+package pinpad
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/iftsoft/device/common"
+	"sync"
+)
+
+const pinKeyCount = 16 // Count of master key slots
+
+// KeyStore keeps master keys in slots and working keys of each type encrypted under the master key.
+// DUKPT initial key of the slot is encrypted under its master key as well.
+// All keys are TDES keys, or AES keys for PIN block format 4.
+type KeyStore struct {
+	isAes  bool
+	master [pinKeyCount][]byte
+	work   [pinKeyCount]map[common.EnumPinKeyType][]byte
+	dukpt  [pinKeyCount]*Dukpt
+	mutex  sync.Mutex
+}
+
+func NewKeyStore(isAes bool) *KeyStore {
+	ks := KeyStore{isAes: isAes}
+	return &ks
+}
+
+func (ks *KeyStore) IsAes() bool {
+	return ks.isAes
+}
+
+// LoadMasterKey puts clear master key to the slot, working keys of the slot are erased
+func (ks *KeyStore) LoadMasterKey(index uint16, value []byte) ([]byte, error) {
+	if err := checkKeyIndex(index); err != nil {
+		return nil, err
+	}
+	kcv, err := ks.checkKeyValue(value)
+	if err != nil {
+		return nil, err
+	}
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+	eraseKey(ks.master[index])
+	ks.master[index] = append([]byte{}, value...)
+	for _, key := range ks.work[index] {
+		eraseKey(key)
+	}
+	ks.work[index] = make(map[common.EnumPinKeyType][]byte)
+	ks.eraseDukpt(index)
+	return kcv, nil
+}
+
+// LoadWorkKey decrypts working key by the master key of the slot and keeps it for the key type
+func (ks *KeyStore) LoadWorkKey(index uint16, keyType common.EnumPinKeyType, value []byte) ([]byte, error) {
+	if err := checkKeyType(keyType); err != nil {
+		return nil, err
+	}
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+	master, err := ks.getMasterKey(index)
+	if err != nil {
+		return nil, err
+	}
+	block, err := NewKeyCipher(master, ks.isAes)
+	if err != nil {
+		return nil, err
+	}
+	if len(value) == 0 || len(value)%block.BlockSize() != 0 {
+		return nil, common.NewError(common.DevErrorBadKeyValue, "encrypted key length is wrong")
+	}
+	clear, err := DecryptEcb(block, value)
+	if err != nil {
+		return nil, err
+	}
+	kcv, err := ks.checkKeyValue(clear)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(clear, master) {
+		return nil, common.NewError(common.DevErrorBadKeyValue, "working key is equal to master key")
+	}
+	eraseKey(ks.work[index][keyType])
+	ks.work[index][keyType] = clear
+	return kcv, nil
+}
+
+// LoadDukptKey decrypts DUKPT initial key by the master key of the slot and derives its future keys.
+// KSN of 10 bytes is for TDES keys and KSN of 12 bytes is for AES keys.
+func (ks *KeyStore) LoadDukptKey(index uint16, value []byte, ksn []byte) ([]byte, []byte, error) {
+	size := KsnSizeTdes
+	if ks.isAes {
+		size = KsnSizeAes
+	}
+	if len(ksn) != size {
+		return nil, nil, common.NewError(common.DevErrorBadArgument,
+			fmt.Sprintf("KSN length must be %d bytes", size))
+	}
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+	master, err := ks.getMasterKey(index)
+	if err != nil {
+		return nil, nil, err
+	}
+	block, err := NewKeyCipher(master, ks.isAes)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(value) == 0 || len(value)%block.BlockSize() != 0 {
+		return nil, nil, common.NewError(common.DevErrorBadKeyValue, "encrypted key length is wrong")
+	}
+	clear, err := DecryptEcb(block, value)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer eraseKey(clear)
+	kcv, err := ks.checkKeyValue(clear)
+	if err != nil {
+		return nil, nil, err
+	}
+	dukpt, err := NewDukpt(clear, ksn)
+	if err != nil {
+		return nil, nil, err
+	}
+	ks.eraseDukpt(index)
+	ks.dukpt[index] = dukpt
+	return kcv, dukpt.GetKsn(), nil
+}
+
+// HasDukpt checks if DUKPT key is loaded to the slot
+func (ks *KeyStore) HasDukpt(index uint16) bool {
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+	return index < pinKeyCount && ks.dukpt[index] != nil
+}
+
+// NextDukptKey returns working key of the next DUKPT transaction and its KSN
+func (ks *KeyStore) NextDukptKey(index uint16, keyType common.EnumPinKeyType) ([]byte, []byte, error) {
+	if err := checkKeyType(keyType); err != nil {
+		return nil, nil, err
+	}
+	if err := checkKeyIndex(index); err != nil {
+		return nil, nil, err
+	}
+	ks.mutex.Lock()
+	dukpt := ks.dukpt[index]
+	ks.mutex.Unlock()
+	if dukpt == nil {
+		return nil, nil, common.NewError(common.DevErrorBadKeyIndex,
+			fmt.Sprintf("DUKPT key %d is not loaded", index))
+	}
+	return dukpt.NextKey(keyType)
+}
+
+// GenerateMac makes MAC of the data by MAC working key
+func (ks *KeyStore) GenerateMac(index uint16, data []byte) ([]byte, error) {
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+	key, err := ks.getWorkKey(index, common.PinPadKeyMAC)
+	if err != nil {
+		return nil, err
+	}
+	return generateMac(key, data, ks.isAes)
+}
+
+// TestMasterKey returns KCV of the master key
+func (ks *KeyStore) TestMasterKey(index uint16) ([]byte, error) {
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+	master, err := ks.getMasterKey(index)
+	if err != nil {
+		return nil, err
+	}
+	return KeyCheckValue(master, ks.isAes)
+}
+
+// TestWorkKey returns KCV of the working key
+func (ks *KeyStore) TestWorkKey(index uint16, keyType common.EnumPinKeyType) ([]byte, error) {
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+	key, err := ks.getWorkKey(index, keyType)
+	if err != nil {
+		return nil, err
+	}
+	return KeyCheckValue(key, ks.isAes)
+}
+
+// EncryptBlock encrypts data by the working key
+func (ks *KeyStore) EncryptBlock(index uint16, keyType common.EnumPinKeyType, data []byte) ([]byte, error) {
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+	key, err := ks.getWorkKey(index, keyType)
+	if err != nil {
+		return nil, err
+	}
+	block, err := NewKeyCipher(key, ks.isAes)
+	if err != nil {
+		return nil, err
+	}
+	return EncryptEcb(block, data)
+}
+
+// Clear erases all keys
+func (ks *KeyStore) Clear() {
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+	for i := range ks.master {
+		eraseKey(ks.master[i])
+		ks.master[i] = nil
+		for _, key := range ks.work[i] {
+			eraseKey(key)
+		}
+		ks.work[i] = nil
+		ks.eraseDukpt(uint16(i))
+	}
+}
+
+////////////////////////////////////////////////////////////////
+
+func (ks *KeyStore) getMasterKey(index uint16) ([]byte, error) {
+	if err := checkKeyIndex(index); err != nil {
+		return nil, err
+	}
+	if ks.master[index] == nil {
+		return nil, common.NewError(common.DevErrorBadKeyIndex,
+			fmt.Sprintf("master key %d is not loaded", index))
+	}
+	return ks.master[index], nil
+}
+
+func (ks *KeyStore) getWorkKey(index uint16, keyType common.EnumPinKeyType) ([]byte, error) {
+	if err := checkKeyType(keyType); err != nil {
+		return nil, err
+	}
+	if _, err := ks.getMasterKey(index); err != nil {
+		return nil, err
+	}
+	key, ok := ks.work[index][keyType]
+	if !ok {
+		return nil, common.NewError(common.DevErrorBadKeyIndex,
+			fmt.Sprintf("%s of master key %d is not loaded", keyType, index))
+	}
+	return key, nil
+}
+
+// Check key length and return its KCV
+func (ks *KeyStore) checkKeyValue(value []byte) ([]byte, error) {
+	kcv, err := KeyCheckValue(value, ks.isAes)
+	if err != nil {
+		return nil, err
+	}
+	if !ks.isAes && bytes.Equal(value[:8], value[8:16]) {
+		return nil, common.NewError(common.DevErrorBadKeyValue, "TDES key halves are equal")
+	}
+	return kcv, nil
+}
+
+func (ks *KeyStore) eraseDukpt(index uint16) {
+	if ks.dukpt[index] != nil {
+		ks.dukpt[index].Erase()
+		ks.dukpt[index] = nil
+	}
+}
+
+func checkKeyIndex(index uint16) error {
+	if index >= pinKeyCount {
+		return common.NewError(common.DevErrorBadKeyIndex,
+			fmt.Sprintf("key index %d is out of range 0-%d", index, pinKeyCount-1))
+	}
+	return nil
+}
+
+func checkKeyType(keyType common.EnumPinKeyType) error {
+	switch keyType {
+	case common.PinPadKeyPIN, common.PinPadKeyMAC, common.PinPadKeyData:
+		return nil
+	}
+	return common.NewError(common.DevErrorBadKeyIndex,
+		fmt.Sprintf("key type %d is unknown", keyType))
+}
+
+func eraseKey(key []byte) {
+	for i := range key {
+		key[i] = 0
+	}
+}
@@ -0,0 +1,127 @@
+package pinpad
+
+import (
+	"encoding/hex"
+	"github.com/iftsoft/device/common"
+	"testing"
+)
+
+const (
+	testMaster = "0123456789ABCDEFFEDCBA9876543210"
+	testWork   = "89ABCDEF0123456776543210FEDCBA98"
+)
+
+// Load master key and working key encrypted under it
+func newTestStore(t *testing.T) *KeyStore {
+	t.Helper()
+	ks := NewKeyStore(false)
+	kcv, err := ks.LoadMasterKey(1, mustHex(t, testMaster))
+	if err != nil || hex.EncodeToString(kcv) != "08d7b4" {
+		t.Fatalf("master key KCV %X: %v", kcv, err)
+	}
+	master, _ := NewKeyCipher(mustHex(t, testMaster), false)
+	value, _ := EncryptEcb(master, mustHex(t, testWork))
+	kcv, err = ks.LoadWorkKey(1, common.PinPadKeyPIN, value)
+	want, _ := KeyCheckValue(mustHex(t, testWork), false)
+	if err != nil || hex.EncodeToString(kcv) != hex.EncodeToString(want) {
+		t.Fatalf("working key KCV %X, want %X: %v", kcv, want, err)
+	}
+	return ks
+}
+
+func TestKeyStore(t *testing.T) {
+	ks := newTestStore(t)
+	if kcv, err := ks.TestMasterKey(1); err != nil || hex.EncodeToString(kcv) != "08d7b4" {
+		t.Errorf("master key KCV %X: %v", kcv, err)
+	}
+	work, _ := NewKeyCipher(mustHex(t, testWork), false)
+	want, _ := EncryptEcb(work, mustHex(t, "0412AC89ABCDEF67"))
+	if data, err := ks.EncryptBlock(1, common.PinPadKeyPIN, mustHex(t, "0412AC89ABCDEF67")); err != nil ||
+		hex.EncodeToString(data) != hex.EncodeToString(want) {
+		t.Errorf("encrypted block %X, want %X: %v", data, want, err)
+	}
+	// New master key erases working keys of the slot
+	if _, err := ks.LoadMasterKey(1, mustHex(t, testWork)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.TestWorkKey(1, common.PinPadKeyPIN); err == nil {
+		t.Error("working key is kept after new master key")
+	}
+	ks.Clear()
+	if _, err := ks.TestMasterKey(1); err == nil {
+		t.Error("master key is kept after clear")
+	}
+}
+
+func TestKeyStoreErrors(t *testing.T) {
+	ks := newTestStore(t)
+	master, _ := NewKeyCipher(mustHex(t, testMaster), false)
+	sameKey, _ := EncryptEcb(master, mustHex(t, testMaster))
+	tests := []struct {
+		name string
+		run  func() error
+		code common.EnumDevError
+	}{
+		{"master index", func() error {
+			_, err := ks.LoadMasterKey(pinKeyCount, mustHex(t, testMaster))
+			return err
+		}, common.DevErrorBadKeyIndex},
+		{"master length", func() error {
+			_, err := ks.LoadMasterKey(2, mustHex(t, "0123456789ABCDEF"))
+			return err
+		}, common.DevErrorBadKeyValue},
+		{"equal halves", func() error {
+			_, err := ks.LoadMasterKey(2, mustHex(t, "0123456789ABCDEF0123456789ABCDEF"))
+			return err
+		}, common.DevErrorBadKeyValue},
+		{"no master", func() error {
+			_, err := ks.LoadWorkKey(2, common.PinPadKeyPIN, mustHex(t, testWork))
+			return err
+		}, common.DevErrorBadKeyIndex},
+		{"key type", func() error {
+			_, err := ks.LoadWorkKey(1, common.EnumPinKeyType(7), mustHex(t, testWork))
+			return err
+		}, common.DevErrorBadKeyIndex},
+		{"work length", func() error {
+			_, err := ks.LoadWorkKey(1, common.PinPadKeyMAC, mustHex(t, "0123456789ABCD"))
+			return err
+		}, common.DevErrorBadKeyValue},
+		{"work equal master", func() error {
+			_, err := ks.LoadWorkKey(1, common.PinPadKeyMAC, sameKey)
+			return err
+		}, common.DevErrorBadKeyValue},
+		{"no work key", func() error {
+			_, err := ks.TestWorkKey(1, common.PinPadKeyMAC)
+			return err
+		}, common.DevErrorBadKeyIndex},
+		{"no MAC key", func() error {
+			_, err := ks.GenerateMac(1, []byte("data"))
+			return err
+		}, common.DevErrorBadKeyIndex},
+		{"no DUKPT", func() error {
+			_, _, err := ks.NextDukptKey(1, common.PinPadKeyPIN)
+			return err
+		}, common.DevErrorBadKeyIndex},
+		{"DUKPT index", func() error {
+			_, _, err := ks.NextDukptKey(pinKeyCount, common.PinPadKeyPIN)
+			return err
+		}, common.DevErrorBadKeyIndex},
+		{"KSN length", func() error {
+			_, _, err := ks.LoadDukptKey(1, sameKey, mustHex(t, testAesKsn))
+			return err
+		}, common.DevErrorBadArgument},
+		{"block length", func() error {
+			_, err := ks.EncryptBlock(1, common.PinPadKeyPIN, []byte{0x01})
+			return err
+		}, common.DevErrorBadKeyValue},
+	}
+	for _, tt := range tests {
+		if code, _ := common.CheckError(tt.run()); code != tt.code {
+			t.Errorf("%s: error %s, want %s", tt.name, code, tt.code)
+		}
+	}
+	// Failed loads do not change keys
+	if kcv, err := ks.TestWorkKey(1, common.PinPadKeyPIN); err != nil || len(kcv) != kcvSize {
+		t.Errorf("working key KCV %X: %v", kcv, err)
+	}
+}
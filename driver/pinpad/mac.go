@@ -0,0 +1,89 @@
+package pinpad
+
+import (
+	"crypto/aes"
+	"crypto/des"
+	"github.com/iftsoft/device/common"
+)
+
+// RetailMac makes ISO 9797-1 MAC algorithm 3 of double length TDES key, data is padded by zeros
+func RetailMac(key []byte, data []byte) ([]byte, error) {
+	if len(key) != 16 {
+		return nil, common.NewError(common.DevErrorBadKeyValue, "MAC key length is wrong")
+	}
+	left, err := des.NewCipher(key[:8])
+	if err != nil {
+		return nil, err
+	}
+	right, err := des.NewCipher(key[8:])
+	if err != nil {
+		return nil, err
+	}
+	padded := append([]byte{}, data...)
+	for len(padded) == 0 || len(padded)%8 != 0 {
+		padded = append(padded, 0)
+	}
+	mac := make([]byte, 8)
+	for i := 0; i < len(padded); i += 8 {
+		xorBytes(mac, mac, padded[i:i+8])
+		left.Encrypt(mac, mac)
+	}
+	right.Decrypt(mac, mac)
+	left.Encrypt(mac, mac)
+	return mac, nil
+}
+
+// AesCmac makes CMAC of NIST SP 800-38B by AES key
+func AesCmac(key []byte, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, common.NewError(common.DevErrorBadKeyValue, "MAC key length is wrong")
+	}
+	sub1 := make([]byte, 16)
+	block.Encrypt(sub1, sub1)
+	sub1 = shiftSubkey(sub1)
+	sub2 := shiftSubkey(sub1)
+	count := (len(data) + 15) / 16
+	last := make([]byte, 16)
+	if count > 0 && len(data)%16 == 0 {
+		xorBytes(last, data[(count-1)*16:], sub1)
+	} else {
+		if count == 0 {
+			count = 1
+		}
+		tail := data[(count-1)*16:]
+		copy(last, tail)
+		last[len(tail)] = 0x80
+		xorBytes(last, last, sub2)
+	}
+	mac := make([]byte, 16)
+	for i := 0; i < count-1; i++ {
+		xorBytes(mac, mac, data[i*16:i*16+16])
+		block.Encrypt(mac, mac)
+	}
+	xorBytes(mac, mac, last)
+	block.Encrypt(mac, mac)
+	return mac, nil
+}
+
+// Subkey is shifted left by one bit and XOR with Rb if its top bit is set
+func shiftSubkey(key []byte) []byte {
+	out := make([]byte, len(key))
+	var carry byte
+	for i := len(key) - 1; i >= 0; i-- {
+		out[i] = key[i]<<1 | carry
+		carry = key[i] >> 7
+	}
+	if carry != 0 {
+		out[len(out)-1] ^= 0x87
+	}
+	return out
+}
+
+// Generate MAC by TDES or AES key
+func generateMac(key []byte, data []byte, isAes bool) ([]byte, error) {
+	if isAes {
+		return AesCmac(key, data)
+	}
+	return RetailMac(key, data)
+}
@@ -0,0 +1,40 @@
+package pinpad
+
+import (
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/driver/generic"
+)
+
+const (
+	StepDoNothing int = iota
+	StepEntryStarted
+)
+
+var ppdResetSteps = generic.MimicSteps{
+	{ 0, 0, common.DevStateWorking, 0, common.DevPromptUnitWork, common.DevActionInitialization, "", "" },
+	{ 10, 0, common.DevStateReady, 0, 0, common.DevActionInitialization, "", "" },
+	{ 1, 0, common.DevStateStandby, 0, 0, common.DevActionDoNothing, "", "" },
+}
+
+var ppdEntrySteps = generic.MimicSteps{
+	{ 0, 0, common.DevStateWorking, 0, common.DevPromptPpadEntryPIN, common.DevActionPinEntering, "", "" },
+	{ 1, StepEntryStarted, common.DevStateWaiting, 0, 0, common.DevActionPinEntering, "", "" },
+}
+
+var ppdDataEntrySteps = generic.MimicSteps{
+	{ 0, 0, common.DevStateWorking, 0, common.DevPromptPpadEntryData, common.DevActionPinEntering, "", "" },
+	{ 1, StepEntryStarted, common.DevStateWaiting, 0, 0, common.DevActionPinEntering, "", "" },
+}
+
+var ppdEntryDoneSteps = generic.MimicSteps{
+	{ 0, 0, common.DevStateWorking, 0, common.DevPromptUnitDone, common.DevActionPinEntering, "", "" },
+	{ 5, 0, common.DevStateReady, 0, 0, common.DevActionDoNothing, "", "" },
+}
+
+var ppdEntryTimeoutSteps = generic.MimicSteps{
+	{ 0, 0, common.DevStateReady, common.DevErrorWaitTimeout, 0, common.DevActionDoNothing, "", "entry is not done in time" },
+}
+
+var ppdEntryCancelSteps = generic.MimicSteps{
+	{ 0, 0, common.DevStateReady, common.DevErrorCanceled, 0, common.DevActionDoNothing, "", "entry is canceled by customer" },
+}
@@ -0,0 +1,195 @@
+package pinpad
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/iftsoft/device/common"
+	"strings"
+)
+
+// ISO 9564 PIN block formats
+const (
+	PinFormat0 uint16 = 0 // PIN padded by F and XOR with PAN
+	PinFormat1 uint16 = 1 // PIN padded by random digits, no PAN
+	PinFormat3 uint16 = 3 // PIN padded by random A-F and XOR with PAN
+	PinFormat4 uint16 = 4 // AES block of PIN field and PAN field
+
+	pinMinLength = 4
+	pinMaxLength = 12
+)
+
+// MakePinBlock makes clear PIN block of format 0, 1 or 3
+func MakePinBlock(format uint16, pin string, pan string) ([]byte, error) {
+	if err := checkPin(pin); err != nil {
+		return nil, err
+	}
+	field := fmt.Sprintf("%X%X%s", format, len(pin), pin)
+	switch format {
+	case PinFormat0:
+		field += strings.Repeat("F", 16-len(field))
+	case PinFormat1:
+		field += randomNibbles(16-len(field), 0x0, 0xF)
+	case PinFormat3:
+		field += randomNibbles(16-len(field), 0xA, 0xF)
+	default:
+		return nil, common.NewError(common.DevErrorBadArgument,
+			fmt.Sprintf("PIN block format %d is not supported", format))
+	}
+	block, _ := hex.DecodeString(field)
+	if format == PinFormat1 {
+		return block, nil
+	}
+	panBlock, err := makePanBlock(pan)
+	if err != nil {
+		return nil, err
+	}
+	xorBytes(block, block, panBlock)
+	return block, nil
+}
+
+// ParsePinBlock extracts PIN of clear block of format 0, 1 or 3
+func ParsePinBlock(block []byte, pan string) (string, error) {
+	if len(block) != 8 {
+		return "", badPinBlock("block length is wrong")
+	}
+	field := append([]byte{}, block...)
+	format := uint16(field[0] >> 4)
+	if format == PinFormat0 || format == PinFormat3 {
+		panBlock, err := makePanBlock(pan)
+		if err != nil {
+			return "", err
+		}
+		xorBytes(field, field, panBlock)
+	} else if format != PinFormat1 {
+		return "", badPinBlock(fmt.Sprintf("format %d is not supported", format))
+	}
+	return parsePinField(strings.ToUpper(hex.EncodeToString(field)))
+}
+
+// EncryptPinBlock4 makes format 4 PIN block, the function encrypts 16 bytes by AES key
+func EncryptPinBlock4(encrypt func(data []byte) ([]byte, error), pin string, pan string) ([]byte, error) {
+	if err := checkPin(pin); err != nil {
+		return nil, err
+	}
+	panField, err := makePanField4(pan)
+	if err != nil {
+		return nil, err
+	}
+	field := fmt.Sprintf("4%X%s", len(pin), pin)
+	field += strings.Repeat("A", 16-len(field)) + randomNibbles(16, 0x0, 0xF)
+	pinField, _ := hex.DecodeString(field)
+	inter, err := encrypt(pinField)
+	if err != nil {
+		return nil, err
+	}
+	xorBytes(inter, inter, panField)
+	return encrypt(inter)
+}
+
+// DecryptPinBlock4 extracts PIN of format 4 block by AES key
+func DecryptPinBlock4(block cipher.Block, data []byte, pan string) (string, error) {
+	if len(data) != 16 || block.BlockSize() != 16 {
+		return "", badPinBlock("format 4 block length is wrong")
+	}
+	panField, err := makePanField4(pan)
+	if err != nil {
+		return "", err
+	}
+	inter := make([]byte, 16)
+	block.Decrypt(inter, data)
+	xorBytes(inter, inter, panField)
+	block.Decrypt(inter, inter)
+	if inter[0]>>4 != byte(PinFormat4) {
+		return "", badPinBlock("format 4 control field is wrong")
+	}
+	return parsePinField(strings.ToUpper(hex.EncodeToString(inter[:8])))
+}
+
+////////////////////////////////////////////////////////////////
+
+// PAN block of format 0 and 3 is four zeros and twelve rightmost digits of PAN without check digit
+func makePanBlock(pan string) ([]byte, error) {
+	if err := checkPan(pan); err != nil {
+		return nil, err
+	}
+	digits := pan[:len(pan)-1]
+	if len(digits) > 12 {
+		digits = digits[len(digits)-12:]
+	}
+	block, _ := hex.DecodeString(strings.Repeat("0", 16-len(digits)) + digits)
+	return block, nil
+}
+
+// PAN field of format 4 is length of PAN over twelve, PAN and zero padding
+func makePanField4(pan string) ([]byte, error) {
+	if err := checkPan(pan); err != nil {
+		return nil, err
+	}
+	field := ""
+	if len(pan) < 12 {
+		field = "0" + strings.Repeat("0", 12-len(pan)) + pan
+	} else {
+		field = fmt.Sprintf("%X%s", len(pan)-12, pan)
+	}
+	field += strings.Repeat("0", 32-len(field))
+	block, _ := hex.DecodeString(field)
+	return block, nil
+}
+
+// PIN of control, length and digits nibbles
+func parsePinField(field string) (string, error) {
+	size := int(field[1]-'0')
+	if field[1] >= 'A' {
+		size = int(field[1]-'A') + 10
+	}
+	if size < pinMinLength || size > pinMaxLength {
+		return "", badPinBlock("PIN length is wrong")
+	}
+	pin := field[2 : 2+size]
+	if err := checkPin(pin); err != nil {
+		return "", badPinBlock("PIN digits are wrong")
+	}
+	return pin, nil
+}
+
+func checkPin(pin string) error {
+	if len(pin) < pinMinLength || len(pin) > pinMaxLength {
+		return common.NewError(common.DevErrorBadArgument,
+			fmt.Sprintf("PIN length must be from %d to %d", pinMinLength, pinMaxLength))
+	}
+	for _, ch := range pin {
+		if ch < '0' || ch > '9' {
+			return common.NewError(common.DevErrorBadArgument, "PIN has not digit")
+		}
+	}
+	return nil
+}
+
+func checkPan(pan string) error {
+	if len(pan) < 2 || len(pan) > 19 {
+		return common.NewError(common.DevErrorBadArgument, "card PAN length is wrong")
+	}
+	for _, ch := range pan {
+		if ch < '0' || ch > '9' {
+			return common.NewError(common.DevErrorBadArgument, "card PAN has not digit")
+		}
+	}
+	return nil
+}
+
+// Random hex nibbles in the range
+func randomNibbles(count int, min, max byte) string {
+	buf := make([]byte, count)
+	_, _ = rand.Read(buf)
+	out := make([]byte, count)
+	for i, b := range buf {
+		out[i] = "0123456789ABCDEF"[min+b%(max-min+1)]
+	}
+	return string(out)
+}
+
+func badPinBlock(text string) error {
+	return common.NewError(common.DevErrorBadArgument, "PIN block "+text)
+}
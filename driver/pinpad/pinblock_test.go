@@ -0,0 +1,150 @@
+package pinpad
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/hex"
+	"github.com/iftsoft/device/common"
+	"strings"
+	"testing"
+)
+
+const (
+	testPin = "1234"
+	testPan = "43219876543210987"
+)
+
+func TestPinBlockFormat0(t *testing.T) {
+	// PIN field 041234FFFFFFFFFF XOR PAN field 0000987654321098
+	block, err := MakePinBlock(PinFormat0, testPin, testPan)
+	if err != nil || hex.EncodeToString(block) != "0412ac89abcdef67" {
+		t.Fatalf("format 0 block %X: %v", block, err)
+	}
+	if pin, err := ParsePinBlock(block, testPan); err != nil || pin != testPin {
+		t.Errorf("format 0 PIN %s: %v", pin, err)
+	}
+	// Long PIN, PAN field of short PAN is padded by zeros
+	block, err = MakePinBlock(PinFormat0, "123456789012", "12345")
+	if err != nil || hex.EncodeToString(block) != "0c123456789000cb" {
+		t.Errorf("format 0 block of long PIN %X: %v", block, err)
+	}
+}
+
+func TestPinBlockFormat1(t *testing.T) {
+	block, err := MakePinBlock(PinFormat1, testPin, "")
+	if err != nil || !bytes.HasPrefix(block, []byte{0x14, 0x12, 0x34}) {
+		t.Fatalf("format 1 block %X: %v", block, err)
+	}
+	if pin, err := ParsePinBlock(block, ""); err != nil || pin != testPin {
+		t.Errorf("format 1 PIN %s: %v", pin, err)
+	}
+}
+
+func TestPinBlockFormat3(t *testing.T) {
+	block, err := MakePinBlock(PinFormat3, testPin, testPan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	panBlock, _ := makePanBlock(testPan)
+	xorBytes(panBlock, panBlock, block)
+	field := strings.ToUpper(hex.EncodeToString(panBlock))
+	if !strings.HasPrefix(field, "341234") || strings.Trim(field[6:], "ABCDEF") != "" {
+		t.Errorf("format 3 PIN field %s", field)
+	}
+	if pin, err := ParsePinBlock(block, testPan); err != nil || pin != testPin {
+		t.Errorf("format 3 PIN %s: %v", pin, err)
+	}
+	// Wrong PAN makes wrong PIN field
+	if pin, err := ParsePinBlock(block, "5555555555554444"); err == nil && pin == testPin {
+		t.Error("format 3 PIN is parsed by other PAN")
+	}
+}
+
+func TestPinBlockFormat4(t *testing.T) {
+	key := mustHex(t, "00112233445566778899AABBCCDDEEFF")
+	block, _ := aes.NewCipher(key)
+	var inputs [][]byte
+	encrypt := func(data []byte) ([]byte, error) {
+		inputs = append(inputs, append([]byte{}, data...))
+		return EncryptEcb(block, data)
+	}
+	data, err := EncryptPinBlock4(encrypt, testPin, testPan)
+	if err != nil || len(data) != 16 || len(inputs) != 2 {
+		t.Fatalf("format 4 block %X: %v", data, err)
+	}
+	// PIN field is control, length, PIN, A fill and random fill
+	if field := hex.EncodeToString(inputs[0][:8]); field != "441234aaaaaaaaaa" {
+		t.Errorf("format 4 PIN field %s", field)
+	}
+	// PAN field is PAN length over twelve, PAN and zero padding
+	inter, _ := EncryptEcb(block, inputs[0])
+	xorBytes(inter, inter, inputs[1])
+	if field := hex.EncodeToString(inter); field != "54321987654321098700000000000000" {
+		t.Errorf("format 4 PAN field %s", field)
+	}
+	if pin, err := DecryptPinBlock4(block, data, testPan); err != nil || pin != testPin {
+		t.Errorf("format 4 PIN %s: %v", pin, err)
+	}
+	if _, err = DecryptPinBlock4(block, data, "5555555555554444"); err == nil {
+		t.Error("format 4 PIN is decrypted by other PAN")
+	}
+	if _, err = DecryptPinBlock4(block, data[:8], testPan); err == nil {
+		t.Error("short format 4 block is decrypted")
+	}
+}
+
+func TestPinBlockErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		format uint16
+		pin    string
+		pan    string
+	}{
+		{"short PIN", PinFormat0, "123", testPan},
+		{"long PIN", PinFormat0, "1234567890123", testPan},
+		{"PIN letter", PinFormat0, "12A4", testPan},
+		{"PAN letter", PinFormat0, testPin, "4321987654321098X"},
+		{"long PAN", PinFormat3, testPin, "43219876543210987654"},
+		{"no PAN", PinFormat3, testPin, ""},
+		{"format 2", 2, testPin, testPan},
+	}
+	for _, tt := range tests {
+		_, err := MakePinBlock(tt.format, tt.pin, tt.pan)
+		if code, _ := common.CheckError(err); code != common.DevErrorBadArgument {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+	}
+	for _, text := range []string{"0412AC89ABCDEF", "2412AC89ABCDEF67", "0212AC89ABCDEF67", "0412A289ABCDEF67"} {
+		if pin, err := ParsePinBlock(mustHex(t, text), testPan); err == nil {
+			t.Errorf("bad block %s is parsed to %s", text, pin)
+		}
+	}
+}
+
+func TestKeyCheckValue(t *testing.T) {
+	tests := []struct {
+		key   string
+		isAes bool
+		kcv   string
+	}{
+		{"0123456789ABCDEF0123456789ABCDEF", false, "d5d44f"},
+		{"0123456789ABCDEFFEDCBA9876543210", false, "08d7b4"},
+		{"0123456789ABCDEFFEDCBA98765432100123456789ABCDEF", false, "08d7b4"},
+		{"00000000000000000000000000000000", true, "66e94b"},
+		{"0000000000000000000000000000000000000000000000000000000000000000", true, "dc95c0"},
+	}
+	for _, tt := range tests {
+		kcv, err := KeyCheckValue(mustHex(t, tt.key), tt.isAes)
+		if err != nil || hex.EncodeToString(kcv) != tt.kcv {
+			t.Errorf("KCV of %s is %X, want %s: %v", tt.key, kcv, tt.kcv, err)
+		}
+	}
+	for _, size := range []int{8, 20} {
+		if _, err := KeyCheckValue(make([]byte, size), false); err == nil {
+			t.Errorf("KCV of TDES key of %d bytes", size)
+		}
+		if _, err := KeyCheckValue(make([]byte, size), true); err == nil {
+			t.Errorf("KCV of AES key of %d bytes", size)
+		}
+	}
+}
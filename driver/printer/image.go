@@ -0,0 +1,92 @@
+package printer
+
+import (
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RasterImage is monochrome bitmap, the most significant bit of the byte is the left dot
+type RasterImage struct {
+	Width  int
+	Height int
+	Stride int
+	Data   []byte
+}
+
+// ImagePath resolves image name of the document inside image directory.
+// Absolute paths and parent references are refused, without directory only the logo file is allowed.
+func ImagePath(name, imageDir, logoFile string) (string, error) {
+	if logoFile != "" && name == logoFile {
+		return logoFile, nil
+	}
+	if filepath.IsAbs(name) || strings.HasPrefix(name, "/") || strings.HasPrefix(name, "\\") ||
+		filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("image %s must be relative to image directory", name)
+	}
+	for _, part := range strings.FieldsFunc(name, isPathSeparator) {
+		if part == ".." {
+			return "", fmt.Errorf("image %s is out of image directory", name)
+		}
+	}
+	if imageDir == "" {
+		return "", fmt.Errorf("image %s is not allowed without image directory", name)
+	}
+	return filepath.Join(imageDir, name), nil
+}
+
+func isPathSeparator(r rune) bool {
+	return r == '/' || r == '\\'
+}
+
+// LoadImage reads PNG, JPEG or GIF file and converts it to raster image no wider than the width
+func LoadImage(fileName string, maxWidth int) (*RasterImage, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, err
+	}
+	return NewRasterImage(img, maxWidth), nil
+}
+
+// NewRasterImage makes raster image of dark dots, wide image is scaled down to the width
+func NewRasterImage(img image.Image, maxWidth int) *RasterImage {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if maxWidth > 0 && width > maxWidth {
+		height = height * maxWidth / width
+		width = maxWidth
+	}
+	ri := &RasterImage{Width: width, Height: height, Stride: (width + 7) / 8}
+	ri.Data = make([]byte, ri.Stride*height)
+	for y := 0; y < height; y++ {
+		srcY := bounds.Min.Y + y*bounds.Dy()/height
+		for x := 0; x < width; x++ {
+			srcX := bounds.Min.X + x*bounds.Dx()/width
+			r, g, b, a := img.At(srcX, srcY).RGBA()
+			// Transparent dots are white, luminance below half is black
+			lum := (299*r + 587*g + 114*b) / 1000
+			if a > 0x8000 && lum < 0x8000 {
+				ri.Data[y*ri.Stride+x/8] |= 0x80 >> uint(x%8)
+			}
+		}
+	}
+	return ri
+}
+
+// IsSet returns true for dark dot
+func (ri *RasterImage) IsSet(x, y int) bool {
+	if x < 0 || y < 0 || x >= ri.Width || y >= ri.Height {
+		return false
+	}
+	return ri.Data[y*ri.Stride+x/8]&(0x80>>uint(x%8)) != 0
+}
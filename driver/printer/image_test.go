@@ -0,0 +1,78 @@
+package printer
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestImagePath(t *testing.T) {
+	dir := filepath.Join("var", "images")
+	tests := []struct {
+		name string
+		dir  string
+		path string
+	}{
+		{"logo.png", dir, filepath.Join(dir, "logo.png")},
+		{"promo/spring.png", dir, filepath.Join(dir, "promo", "spring.png")},
+		{"a/../b.png", dir, ""},
+		{"../secret.png", dir, ""},
+		{`..\secret.png`, dir, ""},
+		{"promo/../../secret.png", dir, ""},
+		{"/etc/passwd", dir, ""},
+		{`\windows\logo.png`, dir, ""},
+		{"logo.png", "", ""},
+		// Logo file of config is allowed as is
+		{"/opt/logo.png", "", "/opt/logo.png"},
+	}
+	for _, tt := range tests {
+		path, err := ImagePath(tt.name, tt.dir, "/opt/logo.png")
+		if tt.path == "" {
+			if err == nil {
+				t.Errorf("image %s in '%s' is resolved to %s", tt.name, tt.dir, path)
+			}
+			continue
+		}
+		if err != nil || path != tt.path {
+			t.Errorf("image %s in '%s' is resolved to %s, want %s: %v", tt.name, tt.dir, path, tt.path, err)
+		}
+	}
+}
+
+func TestLoadImage(t *testing.T) {
+	// Black left half and transparent right half of 20x4 image
+	img := image.NewNRGBA(image.Rect(0, 0, 20, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 10; x++ {
+			img.Set(x, y, color.Black)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "logo.png")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = png.Encode(file, img); err != nil {
+		t.Fatal(err)
+	}
+	_ = file.Close()
+
+	ri, err := LoadImage(path, 0)
+	if err != nil || ri.Width != 20 || ri.Height != 4 || ri.Stride != 3 {
+		t.Fatalf("image %+v: %v", ri, err)
+	}
+	if !ri.IsSet(9, 3) || ri.IsSet(10, 0) || ri.IsSet(20, 0) || ri.Data[0] != 0xFF || ri.Data[1] != 0xC0 {
+		t.Errorf("image data % X", ri.Data)
+	}
+	// Wide image is scaled down
+	ri = NewRasterImage(img, 10)
+	if ri.Width != 10 || ri.Height != 2 || !ri.IsSet(4, 1) || ri.IsSet(5, 1) {
+		t.Errorf("scaled image %+v", ri)
+	}
+	if _, err = LoadImage(path+".none", 0); err == nil {
+		t.Error("missing image is loaded")
+	}
+}
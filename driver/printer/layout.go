@@ -0,0 +1,72 @@
+package printer
+
+import (
+	"github.com/iftsoft/device/common"
+	"strings"
+	"unicode/utf8"
+)
+
+// TextWidth returns count of printed characters of the text
+func TextWidth(text string) int {
+	return utf8.RuneCountInString(text)
+}
+
+// CutText truncates the text to the width in characters
+func CutText(text string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if TextWidth(text) <= width {
+		return text
+	}
+	return string([]rune(text)[:width])
+}
+
+// AlignText pads the text by spaces to the width, long text is truncated
+func AlignText(text string, width int, align common.EnumDocAlign) string {
+	text = CutText(text, width)
+	pad := width - TextWidth(text)
+	switch align {
+	case common.DocAlignCenter:
+		return strings.Repeat(" ", pad/2) + text + strings.Repeat(" ", pad-pad/2)
+	case common.DocAlignRight:
+		return strings.Repeat(" ", pad) + text
+	default:
+		return text + strings.Repeat(" ", pad)
+	}
+}
+
+// FormatColumns spreads columns over the line of the width.
+// The last column is right aligned and takes up to half of the line, the others share the rest equally.
+func FormatColumns(columns []string, width int) string {
+	switch len(columns) {
+	case 0:
+		return ""
+	case 1:
+		return CutText(columns[0], width)
+	}
+	last := columns[len(columns)-1]
+	lastWidth := TextWidth(last)
+	if lastWidth > width/2 {
+		lastWidth = width / 2
+	}
+	slot := (width - lastWidth) / (len(columns) - 1)
+	line := ""
+	for i, col := range columns[:len(columns)-1] {
+		if i == len(columns)-2 {
+			slot = width - lastWidth - TextWidth(line)
+		}
+		// Keep one space between columns
+		line += AlignText(CutText(col, slot-1), slot, common.DocAlignLeft)
+	}
+	return line + AlignText(last, lastWidth, common.DocAlignRight)
+}
+
+// Separator returns the line of repeated first character of the text, dash is default
+func Separator(text string, width int) string {
+	char := "-"
+	if r, size := utf8.DecodeRuneInString(text); size > 0 && r != utf8.RuneError {
+		char = string(r)
+	}
+	return strings.Repeat(char, width)
+}
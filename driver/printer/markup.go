@@ -0,0 +1,177 @@
+package printer
+
+import (
+	"fmt"
+	"github.com/iftsoft/device/common"
+	"strconv"
+	"strings"
+)
+
+////////////////////////////////////////////////////////////////
+// Receipt markup is line based. A line that starts with dot is a directive,
+// any other line is printed as text, a double dot prints a line that starts with dot.
+//   .left .center .right       alignment of the next lines
+//   .bold .nobold              bold text on and off
+//   .underline .nounderline    underlined text on and off
+//   .size W H                  character width and height multipliers
+//   .normal                    left alignment and plain style
+//   .cols A | B | C            columns, the last one is right aligned
+//   .line [C]                  separator line of character C, dash is default
+//   .image [FILE]              image file, printer logo is default
+//   .barcode TYPE DATA         barcode of type code128, ean13 or code39
+//   .qr DATA                   QR code
+//   .feed [N]                  N empty lines, one is default
+//   .page                      page break with paper cut
+
+// Text style of markup parser
+type markupStyle struct {
+	align     common.EnumDocAlign
+	bold      bool
+	underline bool
+	width     int32
+	height    int32
+}
+
+func (ms *markupStyle) newItem(kind common.EnumDocItem, text string) *common.DocumentItem {
+	return &common.DocumentItem{
+		Kind:      kind,
+		Text:      text,
+		Align:     ms.align,
+		Bold:      ms.bold,
+		Underline: ms.underline,
+		Width:     ms.width,
+		Height:    ms.height,
+	}
+}
+
+// ParseMarkup converts receipt markup to printer document
+func ParseMarkup(name string, markup string) (*common.PrinterDocument, error) {
+	doc := &common.PrinterDocument{DocName: name}
+	style := &markupStyle{}
+	lines := strings.Split(strings.TrimRight(strings.Replace(markup, "\r\n", "\n", -1), "\n"), "\n")
+	for i, line := range lines {
+		if !strings.HasPrefix(line, ".") || strings.HasPrefix(line, "..") {
+			if strings.HasPrefix(line, ".") {
+				line = line[1:]
+			}
+			doc.Items = append(doc.Items, style.newItem(common.DocItemText, line))
+			continue
+		}
+		item, err := parseDirective(style, line[1:])
+		if err != nil {
+			return nil, common.NewError(common.DevErrorBadArgument,
+				fmt.Sprintf("markup %s line %d: %s", name, i+1, err))
+		}
+		if item != nil {
+			doc.Items = append(doc.Items, item)
+		}
+	}
+	return doc, nil
+}
+
+// Parse directive line, style directives change the style and return no item
+func parseDirective(style *markupStyle, line string) (*common.DocumentItem, error) {
+	cmd, args := line, ""
+	if pos := strings.IndexAny(line, " \t"); pos >= 0 {
+		cmd, args = line[:pos], strings.TrimSpace(line[pos+1:])
+	}
+	switch strings.ToLower(cmd) {
+	case "left":
+		style.align = common.DocAlignLeft
+	case "center":
+		style.align = common.DocAlignCenter
+	case "right":
+		style.align = common.DocAlignRight
+	case "bold":
+		style.bold = true
+	case "nobold":
+		style.bold = false
+	case "underline":
+		style.underline = true
+	case "nounderline":
+		style.underline = false
+	case "normal":
+		*style = markupStyle{}
+	case "size":
+		fields := strings.Fields(args)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("size needs width and height")
+		}
+		width, err := parseNumber(fields[0], 1, 8)
+		if err != nil {
+			return nil, err
+		}
+		height, err := parseNumber(fields[1], 1, 8)
+		if err != nil {
+			return nil, err
+		}
+		style.width, style.height = width, height
+	case "cols":
+		item := style.newItem(common.DocItemColumns, "")
+		for _, col := range strings.Split(args, "|") {
+			item.Columns = append(item.Columns, strings.TrimSpace(col))
+		}
+		return item, nil
+	case "line":
+		return style.newItem(common.DocItemSeparator, args), nil
+	case "image":
+		return style.newItem(common.DocItemImage, args), nil
+	case "barcode":
+		pos := strings.IndexAny(args, " \t")
+		if pos < 0 {
+			return nil, fmt.Errorf("barcode needs type and data")
+		}
+		kind, err := parseBarcode(args[:pos])
+		if err != nil {
+			return nil, err
+		}
+		item := style.newItem(common.DocItemBarcode, strings.TrimSpace(args[pos+1:]))
+		item.Barcode = kind
+		item.Width, item.Height = 0, 0
+		return item, nil
+	case "qr":
+		if args == "" {
+			return nil, fmt.Errorf("qr needs data")
+		}
+		item := style.newItem(common.DocItemQRCode, args)
+		item.Width, item.Height = 0, 0
+		return item, nil
+	case "feed":
+		item := style.newItem(common.DocItemFeed, "")
+		item.Width = 1
+		if args != "" {
+			lines, err := parseNumber(args, 1, 255)
+			if err != nil {
+				return nil, err
+			}
+			item.Width = lines
+		}
+		return item, nil
+	case "page":
+		return &common.DocumentItem{Kind: common.DocItemPage}, nil
+	default:
+		return nil, fmt.Errorf("unknown directive '%s'", cmd)
+	}
+	return nil, nil
+}
+
+func parseNumber(text string, min, max int32) (int32, error) {
+	value, err := strconv.Atoi(text)
+	if err != nil || int32(value) < min || int32(value) > max {
+		return 0, fmt.Errorf("number '%s' is out of range %d..%d", text, min, max)
+	}
+	return int32(value), nil
+}
+
+func parseBarcode(text string) (common.EnumDocBarcode, error) {
+	switch strings.ToLower(text) {
+	case "code128":
+		return common.DocBarcodeCODE128, nil
+	case "ean13":
+		return common.DocBarcodeEAN13, nil
+	case "code39":
+		return common.DocBarcodeCODE39, nil
+	default:
+		return 0, fmt.Errorf("unknown barcode type '%s'", text)
+	}
+}
@@ -0,0 +1,97 @@
+package printer
+
+import (
+	"github.com/iftsoft/device/common"
+	"reflect"
+	"testing"
+)
+
+const testMarkup = `.center
+.bold
+SHOP
+.normal
+..dot line
+.size 2 1
+.cols Total | 100.00
+.right
+.line =
+.image logo.png
+.underline
+.barcode ean13 4820000000001
+.qr https://example.com/r?id=1
+.feed 3
+.page
+`
+
+func TestParseMarkup(t *testing.T) {
+	doc, err := ParseMarkup("receipt", testMarkup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []common.DocumentItem{
+		{Kind: common.DocItemText, Text: "SHOP", Align: common.DocAlignCenter, Bold: true},
+		{Kind: common.DocItemText, Text: ".dot line"},
+		{Kind: common.DocItemColumns, Columns: []string{"Total", "100.00"}, Width: 2, Height: 1},
+		{Kind: common.DocItemSeparator, Text: "=", Align: common.DocAlignRight, Width: 2, Height: 1},
+		{Kind: common.DocItemImage, Text: "logo.png", Align: common.DocAlignRight, Width: 2, Height: 1},
+		{Kind: common.DocItemBarcode, Text: "4820000000001", Align: common.DocAlignRight, Underline: true,
+			Barcode: common.DocBarcodeEAN13},
+		{Kind: common.DocItemQRCode, Text: "https://example.com/r?id=1", Align: common.DocAlignRight, Underline: true},
+		{Kind: common.DocItemFeed, Align: common.DocAlignRight, Underline: true, Width: 3, Height: 1},
+		{Kind: common.DocItemPage},
+	}
+	if doc.DocName != "receipt" || len(doc.Items) != len(want) {
+		t.Fatalf("document %s", doc)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(doc.Items[i], &want[i]) {
+			t.Errorf("item %d is %+v, want %+v", i, *doc.Items[i], want[i])
+		}
+	}
+}
+
+func TestParseMarkupErrors(t *testing.T) {
+	for _, markup := range []string{
+		".unknown",
+		".size 2",
+		".size 0 1",
+		".size 9 1",
+		".size a b",
+		".barcode ean13",
+		".barcode upc 123",
+		".qr",
+		".feed 0",
+		".feed 256",
+	} {
+		doc, err := ParseMarkup("bad", "Text\n"+markup)
+		if code, _ := common.CheckError(err); code != common.DevErrorBadArgument {
+			t.Errorf("markup '%s' is parsed to %s: %v", markup, doc, err)
+		}
+	}
+}
+
+func TestFormatColumns(t *testing.T) {
+	tests := []struct {
+		columns []string
+		width   int
+		line    string
+	}{
+		{nil, 10, ""},
+		{[]string{"Long single column"}, 10, "Long singl"},
+		{[]string{"Total", "100.00"}, 20, "Total         100.00"},
+		{[]string{"A", "B", "C"}, 10, "A   B    C"},
+		{[]string{"Item name", "1234567890"}, 10, "Item 12345"},
+		{[]string{"Кава", "25.00"}, 12, "Кава   25.00"},
+	}
+	for _, tt := range tests {
+		if line := FormatColumns(tt.columns, tt.width); line != tt.line {
+			t.Errorf("columns %q of width %d: '%s', want '%s'", tt.columns, tt.width, line, tt.line)
+		}
+	}
+	if text := AlignText("ab", 5, common.DocAlignCenter); text != " ab  " {
+		t.Errorf("centered text '%s'", text)
+	}
+	if text := Separator("", 3) + Separator("*-", 2); text != "---**" {
+		t.Errorf("separators '%s'", text)
+	}
+}
@@ -0,0 +1,116 @@
+package printer
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/iftsoft/device/common"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// ReceiptData is the usual data of receipt template, any other value can be used as well
+type ReceiptData struct {
+	Time   time.Time
+	Amount common.DevMoney
+	Batch  *common.ValidatorBatch
+	Fields map[string]string
+}
+
+// ReceiptTemplate fills receipt markup with data by text/template rules
+type ReceiptTemplate struct {
+	name string
+	tmpl *template.Template
+}
+
+var templateFuncs = template.FuncMap{
+	"money":  formatMoney,
+	"amount": formatAmount,
+	"iso":    formatIsoCode,
+	"date":   formatDate,
+	"notes":  batchNotes,
+	"pad":    padText,
+	"upper":  strings.ToUpper,
+	"lower":  strings.ToLower,
+}
+
+// NewReceiptTemplate parses template text
+func NewReceiptTemplate(name string, text string) (*ReceiptTemplate, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, common.NewError(common.DevErrorBadArgument, err.Error())
+	}
+	return &ReceiptTemplate{name: name, tmpl: tmpl}, nil
+}
+
+// LoadReceiptTemplate reads and parses template file, base name of the file is the template name
+func LoadReceiptTemplate(fileName string) (*ReceiptTemplate, error) {
+	text, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, common.NewError(common.DevErrorConfigFault, err.Error())
+	}
+	name := strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(fileName))
+	return NewReceiptTemplate(name, string(text))
+}
+
+// Execute fills the template with data and parses the result as receipt markup
+func (rt *ReceiptTemplate) Execute(data interface{}) (*common.PrinterDocument, error) {
+	var buf bytes.Buffer
+	err := rt.tmpl.Execute(&buf, data)
+	if err != nil {
+		return nil, common.NewError(common.DevErrorBadArgument, err.Error())
+	}
+	return ParseMarkup(rt.name, buf.String())
+}
+
+////////////////////////////////////////////////////////////////
+
+// Money value with currency code, locale is optional
+func formatMoney(value common.DevMoney, locale ...string) string {
+	if len(locale) > 0 {
+		return value.FormatLocale(locale[0])
+	}
+	return value.String()
+}
+
+// Amount in minor units as decimal text of the currency
+func formatAmount(value common.DevAmount, curr common.DevCurrency) string {
+	return value.Format(curr)
+}
+
+func formatIsoCode(curr common.DevCurrency) string {
+	return curr.IsoCode()
+}
+
+// Time in Go layout, date and time with seconds is default
+func formatDate(value time.Time, layout ...string) string {
+	if len(layout) > 0 {
+		return value.Format(layout[0])
+	}
+	return value.Format("2006-01-02 15:04:05")
+}
+
+// Notes of the batch that were counted
+func batchNotes(batch *common.ValidatorBatch) common.ValidNoteList {
+	list := make(common.ValidNoteList, 0)
+	if batch == nil {
+		return list
+	}
+	for _, note := range batch.Notes {
+		if note != nil && note.Count > 0 {
+			list = append(list, note)
+		}
+	}
+	return list
+}
+
+// Pad the value text by spaces to the width, negative width pads on the left
+func padText(width int, value interface{}) string {
+	text := fmt.Sprint(value)
+	if width < 0 {
+		return AlignText(text, -width, common.DocAlignRight)
+	}
+	return AlignText(text, width, common.DocAlignLeft)
+}
@@ -0,0 +1,86 @@
+package printer
+
+import (
+	"github.com/iftsoft/device/common"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testTemplate = `.center
+{{upper .Fields.shop}}
+.normal
+{{date .Time "02.01.2006 15:04"}}
+{{range notes .Batch}}.cols {{.Count}} x {{amount .Nominal .Currency}} | {{amount .Amount .Currency}}
+{{end}}.cols Total {{iso .Amount.Currency}} | {{money .Amount}}
+[{{pad 6 "ab"}}][{{pad -6 "ab"}}]
+`
+
+func TestReceiptTemplate(t *testing.T) {
+	tmpl, err := NewReceiptTemplate("receipt", testTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := &ReceiptData{
+		Time:   time.Date(2024, 3, 8, 14, 5, 0, 0, time.UTC),
+		Amount: common.NewDevMoney(25000, common.CurrencyUAH),
+		Batch: &common.ValidatorBatch{Notes: common.ValidNoteList{
+			{Currency: common.CurrencyUAH, Nominal: 5000, Count: 3, Amount: 15000},
+			{Currency: common.CurrencyUAH, Nominal: 10000, Count: 0},
+			{Currency: common.CurrencyUAH, Nominal: 10000, Count: 1, Amount: 10000},
+		}},
+		Fields: map[string]string{"shop": "Kiosk"},
+	}
+	doc, err := tmpl.Execute(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for _, item := range doc.Items {
+		if item.Kind == common.DocItemColumns {
+			lines = append(lines, strings.Join(item.Columns, "|"))
+		} else {
+			lines = append(lines, item.Text)
+		}
+	}
+	want := []string{"KIOSK", "08.03.2024 14:05", "3 x 50.00|150.00", "1 x 100.00|100.00",
+		"Total UAH|" + data.Amount.String(), "[ab    ][    ab]"}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("document lines %q, want %q", lines, want)
+	}
+	if doc.DocName != "receipt" || doc.Items[0].Align != common.DocAlignCenter || doc.Items[1].Align != common.DocAlignLeft {
+		t.Errorf("document %s", doc)
+	}
+}
+
+func TestReceiptTemplateErrors(t *testing.T) {
+	if _, err := NewReceiptTemplate("bad", "{{if}}"); err == nil {
+		t.Error("bad template is parsed")
+	}
+	// Result of the template must be valid markup
+	tmpl, _ := NewReceiptTemplate("size", ".size {{.}}")
+	if _, err := tmpl.Execute("9 9"); err == nil {
+		t.Error("bad markup of template is parsed")
+	}
+	tmpl, _ = NewReceiptTemplate("field", "{{.Missing}}")
+	if _, err := tmpl.Execute(&ReceiptData{}); err == nil {
+		t.Error("template with missing field is executed")
+	}
+
+	path := filepath.Join(t.TempDir(), "check.tmpl")
+	if err := os.WriteFile(path, []byte("Check {{.}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := LoadReceiptTemplate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc, err := tmpl.Execute(1); err != nil || doc.DocName != "check" || doc.Items[0].Text != "Check 1" {
+		t.Errorf("loaded template document %s: %v", doc, err)
+	}
+	if _, err = LoadReceiptTemplate(path + ".none"); err == nil {
+		t.Error("missing template file is loaded")
+	}
+}
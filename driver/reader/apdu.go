@@ -0,0 +1,219 @@
+package reader
+
+import (
+	"fmt"
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/core"
+)
+
+// Instructions of ISO 7816-4 and EMV commands
+const (
+	InsSelect         byte = 0xA4
+	InsReadRecord     byte = 0xB2
+	InsGetResponse    byte = 0xC0
+	InsGetData        byte = 0xCA
+	InsGetProcOptions byte = 0xA8
+
+	apduNoLe       = -1  // Command has no expected length
+	apduMaxChained = 32  // Limit of GET RESPONSE commands for one reply
+	apduMaxLength  = 256 // Max data size of short APDU
+)
+
+// Status words
+const (
+	SwSuccess        uint16 = 0x9000
+	SwWrongLength    uint16 = 0x6700
+	SwSecurityStatus uint16 = 0x6982
+	SwConditions     uint16 = 0x6985
+	SwFileNotFound   uint16 = 0x6A82
+	SwRecordNotFound uint16 = 0x6A83
+	SwWrongP1P2      uint16 = 0x6B00
+	SwInsNotSupport  uint16 = 0x6D00
+	SwClaNotSupport  uint16 = 0x6E00
+	sw1MoreData      byte   = 0x61 // SW2 is count of bytes for GET RESPONSE
+	sw1WrongLe       byte   = 0x6C // SW2 is exact Le to repeat the command
+)
+
+// ChipTransmitter exchanges APDU with the chip, it may be card reader driver or recorded card
+type ChipTransmitter interface {
+	Transmit(apdu []byte) ([]byte, error)
+}
+
+// Apdu is the short command APDU, Le is -1 if the command expects no data
+type Apdu struct {
+	Cla  byte
+	Ins  byte
+	P1   byte
+	P2   byte
+	Data []byte
+	Le   int
+}
+
+func NewApdu(cla, ins, p1, p2 byte, data []byte, le int) *Apdu {
+	return &Apdu{Cla: cla, Ins: ins, P1: p1, P2: p2, Data: data, Le: le}
+}
+
+func (a *Apdu) String() string {
+	if a == nil {
+		return ""
+	}
+	return fmt.Sprintf("CLA %02X, INS %02X, P1 %02X, P2 %02X, Le %d, Data %s",
+		a.Cla, a.Ins, a.P1, a.P2, a.Le, core.GetBinaryDump(a.Data))
+}
+
+// Bytes encodes command to short APDU of case 1 to 4
+func (a *Apdu) Bytes() []byte {
+	out := []byte{a.Cla, a.Ins, a.P1, a.P2}
+	if len(a.Data) > 0 {
+		out = append(out, byte(len(a.Data)))
+		out = append(out, a.Data...)
+	}
+	if a.Le >= 0 {
+		// Le of 256 is coded as zero
+		out = append(out, byte(a.Le))
+	}
+	return out
+}
+
+// ParseApdu decodes short command APDU
+func ParseApdu(data []byte) (*Apdu, error) {
+	if len(data) < 4 {
+		return nil, common.NewError(common.DevErrorBadArgument, "APDU is too short")
+	}
+	a := &Apdu{Cla: data[0], Ins: data[1], P1: data[2], P2: data[3], Le: apduNoLe}
+	body := data[4:]
+	switch {
+	case len(body) == 0:
+	case len(body) == 1:
+		a.Le = getLe(body[0])
+	case len(body) == 1+int(body[0]) && body[0] > 0:
+		a.Data = body[1:]
+	case len(body) == 2+int(body[0]) && body[0] > 0:
+		a.Data = body[1 : len(body)-1]
+		a.Le = getLe(body[len(body)-1])
+	default:
+		return nil, common.NewError(common.DevErrorBadArgument, "APDU length is wrong")
+	}
+	return a, nil
+}
+
+func getLe(b byte) int {
+	if b == 0 {
+		return apduMaxLength
+	}
+	return int(b)
+}
+
+////////////////////////////////////////////////////////////////
+
+// ApduReply is the response APDU
+type ApduReply struct {
+	Data []byte
+	SW1  byte
+	SW2  byte
+}
+
+// ParseApduReply splits response APDU to data and status word
+func ParseApduReply(data []byte) (*ApduReply, error) {
+	if len(data) < 2 {
+		return nil, common.NewError(common.DevErrorProtocolFault, "APDU reply is too short")
+	}
+	size := len(data) - 2
+	return &ApduReply{Data: data[:size], SW1: data[size], SW2: data[size+1]}, nil
+}
+
+func (r *ApduReply) String() string {
+	if r == nil {
+		return ""
+	}
+	return fmt.Sprintf("SW %04X - %s, Data %s", r.Status(), GetStatusText(r.Status()), core.GetBinaryDump(r.Data))
+}
+
+func (r *ApduReply) Status() uint16 {
+	return uint16(r.SW1)<<8 | uint16(r.SW2)
+}
+
+func (r *ApduReply) IsSuccess() bool {
+	return r.Status() == SwSuccess
+}
+
+// Bytes encodes response APDU
+func (r *ApduReply) Bytes() []byte {
+	out := append([]byte{}, r.Data...)
+	return append(out, r.SW1, r.SW2)
+}
+
+// GetError returns nil for success status, command fault error otherwise
+func (r *ApduReply) GetError() error {
+	if r.IsSuccess() {
+		return nil
+	}
+	return common.NewError(common.DevErrorCommandFault,
+		fmt.Sprintf("status %04X - %s", r.Status(), GetStatusText(r.Status())))
+}
+
+// ExchangeApdu sends the command and handles T=0 status words: 61xx is followed
+// by GET RESPONSE commands until all data is read, 6Cxx repeats the command with exact Le.
+func ExchangeApdu(tx ChipTransmitter, apdu *Apdu) (*ApduReply, error) {
+	reply, err := transmitApdu(tx, apdu.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if reply.SW1 == sw1WrongLe {
+		retry := *apdu
+		retry.Le = getLe(reply.SW2)
+		reply, err = transmitApdu(tx, retry.Bytes())
+		if err != nil {
+			return nil, err
+		}
+	}
+	data := reply.Data
+	for i := 0; reply.SW1 == sw1MoreData; i++ {
+		if i == apduMaxChained {
+			return nil, common.NewError(common.DevErrorProtocolFault, "too many GET RESPONSE commands")
+		}
+		get := NewApdu(apdu.Cla&0x80, InsGetResponse, 0, 0, nil, getLe(reply.SW2))
+		reply, err = transmitApdu(tx, get.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, reply.Data...)
+	}
+	reply.Data = data
+	return reply, nil
+}
+
+func transmitApdu(tx ChipTransmitter, cmd []byte) (*ApduReply, error) {
+	if tx == nil {
+		return nil, common.NewError(common.DevErrorNotInitialized, "chip transmitter is not set")
+	}
+	back, err := tx.Transmit(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return ParseApduReply(back)
+}
+
+func GetStatusText(sw uint16) string {
+	switch {
+	case sw == SwSuccess:
+		return "Success"
+	case sw>>8 == uint16(sw1MoreData):
+		return "More data available"
+	case sw>>8 == uint16(sw1WrongLe):
+		return "Wrong Le, exact length in SW2"
+	case sw>>8 == 0x62, sw>>8 == 0x63:
+		return "Warning, state of memory changed"
+	}
+	switch sw {
+	case SwWrongLength:			return "Wrong length"
+	case SwSecurityStatus:		return "Security status not satisfied"
+	case SwConditions:			return "Conditions of use not satisfied"
+	case SwFileNotFound:		return "File or application not found"
+	case SwRecordNotFound:		return "Record not found"
+	case SwWrongP1P2:			return "Wrong parameters P1-P2"
+	case SwInsNotSupport:		return "Instruction not supported"
+	case SwClaNotSupport:		return "Class not supported"
+	default:					return "Unknown status"
+	}
+}
@@ -0,0 +1,116 @@
+package reader
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/core"
+	"strings"
+)
+
+// SimCard is the card of simulator deck, APDU keys are commands in hex and values are replies in hex
+type SimCard struct {
+	Name     string            `yaml:"name"`
+	Track1   string            `yaml:"track1"`
+	Track2   string            `yaml:"track2"`
+	Track3   string            `yaml:"track3"`
+	Atr      string            `yaml:"atr"`      // Chip ATR in hex, empty for magnetic card
+	Apdu     map[string]string `yaml:"apdu"`     // Scripted chip replies
+	NoInsert bool              `yaml:"no_insert"` // Customer does not insert the card
+	NoTake   bool              `yaml:"no_take"`   // Customer does not take ejected card
+}
+
+func (sc *SimCard) String() string {
+	if sc == nil {
+		return ""
+	}
+	return fmt.Sprintf("Name %s, Chip %t, NoInsert %t, NoTake %t",
+		sc.Name, sc.HasChip(), sc.NoInsert, sc.NoTake)
+}
+
+func (sc *SimCard) HasChip() bool {
+	return sc.Atr != ""
+}
+
+// GetAtr decodes ATR of the card
+func (sc *SimCard) GetAtr() ([]byte, error) {
+	if !sc.HasChip() {
+		return nil, common.NewError(common.DevErrorNotAccepted, "card has no chip")
+	}
+	atr, err := hex.DecodeString(strings.Replace(sc.Atr, " ", "", -1))
+	if err != nil {
+		return nil, common.NewError(common.DevErrorConfigFault, "card ATR is not hex")
+	}
+	return atr, nil
+}
+
+// GetChip makes recorded chip of the card script
+func (sc *SimCard) GetChip() RecordedChip {
+	chip := RecordedChip{}
+	for cmd, reply := range sc.Apdu {
+		chip[strings.ToUpper(strings.Replace(cmd, " ", "", -1))] = reply
+	}
+	return chip
+}
+
+// CardDeck serves its cards one by one in round
+type CardDeck struct {
+	Cards []*SimCard `yaml:"cards"`
+	index int
+}
+
+// LoadCardDeck reads deck file, built-in test card is used if the file is not set
+func LoadCardDeck(fileName string) (*CardDeck, error) {
+	deck := &CardDeck{}
+	if fileName == "" {
+		card := *defaultCard
+		deck.Cards = []*SimCard{&card}
+		return deck, nil
+	}
+	err := core.ReadYamlFile(fileName, deck)
+	if err != nil {
+		return nil, common.ExtendError(common.DevErrorConfigFault, err)
+	}
+	if len(deck.Cards) == 0 {
+		return nil, common.NewError(common.DevErrorConfigFault,
+			fmt.Sprintf("card deck %s has no cards", fileName))
+	}
+	for i, card := range deck.Cards {
+		if card == nil {
+			return nil, common.NewError(common.DevErrorConfigFault,
+				fmt.Sprintf("card deck %s has empty card %d", fileName, i+1))
+		}
+		if _, err = card.GetAtr(); card.HasChip() && err != nil {
+			return nil, common.NewError(common.DevErrorConfigFault,
+				fmt.Sprintf("card %s has wrong ATR", card.Name))
+		}
+	}
+	return deck, nil
+}
+
+// NextCard returns the next card of the deck
+func (cd *CardDeck) NextCard() *SimCard {
+	if len(cd.Cards) == 0 {
+		return nil
+	}
+	if cd.index >= len(cd.Cards) {
+		cd.index = 0
+	}
+	card := cd.Cards[cd.index]
+	cd.index++
+	return card
+}
+
+// Visa test card with magnetic tracks and chip application
+var defaultCard = &SimCard{
+	Name:   "Visa test card",
+	Track1: "%B4111111111111111^TEST/CARD^2812201000000000000000000?",
+	Track2: ";4111111111111111=28122010000000000000?",
+	Atr:    "3B6800000073C84013009000",
+	Apdu: map[string]string{
+		"00A404000E325041592E5359532E444446303100": "6A82",
+		"00A4040007A000000003101000":               "6F118407A0000000031010A5065004564953419000",
+		"80A8000002830000":                         "80061000080101009000",
+		"00B2010C00":                               "702157134111111111111111D28122010000000000000F5F2009544553542F434152449000",
+	},
+}
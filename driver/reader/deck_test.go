@@ -0,0 +1,95 @@
+package reader
+
+import (
+	"bytes"
+	"github.com/iftsoft/device/common"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+const testDeck = `
+cards:
+  - name: Magnetic
+    track2: ";4111111111111111=2812201?"
+    no_take: true
+  - name: Chip
+    atr: "3B 68 00 00"
+    apdu:
+      "00 a4 04 00 07 a0 00 00 00 03 10 10 00": "6F 00 90 00"
+`
+
+func writeDeck(t *testing.T, text string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "deck.yaml")
+	if err := ioutil.WriteFile(path, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadCardDeck(t *testing.T) {
+	deck, err := LoadCardDeck(writeDeck(t, testDeck))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Cards are served in round
+	names := ""
+	for i := 0; i < 3; i++ {
+		names += deck.NextCard().Name + " "
+	}
+	if names != "Magnetic Chip Magnetic " {
+		t.Errorf("cards are served as %s", names)
+	}
+	magnetic, chip := deck.Cards[0], deck.Cards[1]
+	if magnetic.HasChip() || !magnetic.NoTake || magnetic.Track2 == "" {
+		t.Errorf("magnetic card is %s", magnetic)
+	}
+	if _, err = magnetic.GetAtr(); err == nil {
+		t.Error("magnetic card has ATR")
+	}
+	atr, err := chip.GetAtr()
+	if err != nil || !bytes.Equal(atr, []byte{0x3B, 0x68, 0x00, 0x00}) {
+		t.Errorf("chip ATR % X: %v", atr, err)
+	}
+	// Script commands are matched in upper case hex without spaces
+	reply, err := chip.GetChip().Transmit([]byte{0x00, 0xA4, 0x04, 0x00, 0x07, 0xA0, 0x00, 0x00, 0x00, 0x03, 0x10, 0x10, 0x00})
+	if err != nil || !bytes.Equal(reply, []byte{0x6F, 0x00, 0x90, 0x00}) {
+		t.Errorf("chip reply % X: %v", reply, err)
+	}
+
+	// Built-in card is used without deck file
+	deck, err = LoadCardDeck("")
+	if err != nil || len(deck.Cards) != 1 || !deck.NextCard().HasChip() {
+		t.Fatalf("default deck: %v", err)
+	}
+	deck.Cards[0].Name = "Changed"
+	if defaultCard.Name == "Changed" {
+		t.Error("default deck shares the built-in card")
+	}
+	if (&CardDeck{}).NextCard() != nil {
+		t.Error("empty deck serves a card")
+	}
+}
+
+func TestLoadCardDeckErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+	}{
+		{"not yaml", "cards: [name"},
+		{"no cards", "cards: []"},
+		{"empty card", "cards:\n  - name: One\n  -\n"},
+		{"wrong ATR", "cards:\n  - name: Chip\n    atr: 3B6X\n"},
+	}
+	for _, tt := range tests {
+		_, err := LoadCardDeck(writeDeck(t, tt.text))
+		if code, _ := common.CheckError(err); code != common.DevErrorConfigFault {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+	}
+	_, err := LoadCardDeck(filepath.Join(t.TempDir(), "missing.yaml"))
+	if code, _ := common.CheckError(err); code != common.DevErrorConfigFault {
+		t.Errorf("missing file: %v", err)
+	}
+}
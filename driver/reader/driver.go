@@ -0,0 +1,134 @@
+package reader
+
+import (
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/driver"
+	"time"
+)
+
+type ReaderDriver struct {
+	ReaderEngine
+	begTime int64
+}
+
+func NewReaderDriver() *ReaderDriver {
+	rd := ReaderDriver{}
+	return &rd
+}
+
+// Implementation of DeviceDriver interface
+func (rd *ReaderDriver) InitDevice(context *driver.Context) error {
+	rd.initEngine(context.Config)
+	rd.DevName = context.DevName
+	rd.begTime = time.Now().Unix()
+	rd.Log.Debug("ReaderDriver run cmd:%s", "InitDevice")
+
+	mask := common.ScopeFlagSystem
+	if device, ok := context.Manager.(common.DeviceCallback); ok {
+		rd.CbDevice = device
+		mask |= common.ScopeFlagDevice
+	}
+	if reader, ok := context.Manager.(common.ReaderCallback); ok {
+		rd.CbReader = reader
+		mask |= common.ScopeFlagReader
+	}
+	if context.Greeting != nil {
+		context.Greeting.DevType = common.DevTypeCardReader
+		context.Greeting.Required = mask
+	}
+	return nil
+}
+
+func (rd *ReaderDriver) StartDevice(query *common.SystemConfig) error {
+	rd.Log.Debug("ReaderDriver run cmd:%s", "StartDeviceLoop")
+	if rd.config != nil && query != nil {
+		rd.config.OverwriteConfig(query)
+	}
+	return rd.DevStartup()
+}
+func (rd *ReaderDriver) DeviceTimer(unix int64) error {
+	rd.Log.Trace("ReaderDriver run cmd:%s", "DeviceTimer")
+	rd.NextMimicStage()
+	return nil
+}
+func (rd *ReaderDriver) StopDevice() error {
+	rd.Log.Debug("ReaderDriver run cmd:%s", "StopDeviceLoop")
+	return rd.DevCleanup()
+}
+func (rd *ReaderDriver) CheckDevice(metrics *common.SystemMetrics) error {
+	rd.Log.Debug("ReaderDriver run cmd:%s", "CheckDevice")
+	if metrics != nil {
+		metrics.Uptime = time.Now().Unix() - rd.begTime
+		metrics.DevState = rd.DevState
+		metrics.DevError = rd.DevError
+		rd.checkCaptured(metrics)
+	}
+	return nil
+}
+
+// Implementation of common.DeviceManager
+//
+func (rd *ReaderDriver) Cancel(name string, query *common.DeviceQuery) error {
+	err := rd.DevCancel()
+	rd.DevError, rd.DevReply = common.CheckError(err)
+	return rd.RunDeviceReply(common.CmdDeviceCancel)
+}
+func (rd *ReaderDriver) Reset(name string, query *common.DeviceQuery) error {
+	err := rd.DevReset()
+	rd.DevError, rd.DevReply = common.CheckError(err)
+	return rd.RunDeviceReply(common.CmdDeviceReset)
+}
+func (rd *ReaderDriver) Status(name string, query *common.DeviceQuery) error {
+	err := rd.DevStatus()
+	rd.DevError, rd.DevReply = common.CheckError(err)
+	return rd.RunDeviceReply(common.CmdDeviceStatus)
+}
+func (rd *ReaderDriver) RunAction(name string, query *common.DeviceQuery) error {
+	err := rd.DevEnterCard()
+	rd.DevError, rd.DevReply = common.CheckError(err)
+	return rd.RunDeviceReply(common.CmdRunAction)
+}
+func (rd *ReaderDriver) StopAction(name string, query *common.DeviceQuery) error {
+	err := rd.DevCancel()
+	rd.DevError, rd.DevReply = common.CheckError(err)
+	return rd.RunDeviceReply(common.CmdStopAction)
+}
+
+
+// Implementation of common.ReaderManager
+//
+func (rd *ReaderDriver) EnterCard(name string, query *common.DeviceQuery) error {
+	err := rd.DevEnterCard()
+	rd.DevError, rd.DevReply = common.CheckError(err)
+	return rd.RunDeviceReply(common.CmdEnterCard)
+}
+func (rd *ReaderDriver) EjectCard(name string, query *common.DeviceQuery) error {
+	err := rd.DevEjectCard()
+	rd.DevError, rd.DevReply = common.CheckError(err)
+	return rd.RunDeviceReply(common.CmdEjectCard)
+}
+func (rd *ReaderDriver) CaptureCard(name string, query *common.DeviceQuery) error {
+	err := rd.DevCaptureCard()
+	rd.DevError, rd.DevReply = common.CheckError(err)
+	return rd.RunDeviceReply(common.CmdCaptureCard)
+}
+func (rd *ReaderDriver) ReadCard(name string, query *common.DeviceQuery) error {
+	err := rd.DevReadCard()
+	rd.DevError, rd.DevReply = common.CheckError(err)
+	return rd.RunDeviceReply(common.CmdReadCard)
+}
+func (rd *ReaderDriver) ChipGetATR(name string, query *common.DeviceQuery) error {
+	atr, err := rd.DevChipGetATR()
+	rd.DevError, rd.DevReply = common.CheckError(err)
+	return rd.RunChipResponse(common.CmdChipGetATR, 0, atr)
+}
+func (rd *ReaderDriver) ChipPowerOff(name string, query *common.DeviceQuery) error {
+	err := rd.DevChipPowerOff()
+	rd.DevError, rd.DevReply = common.CheckError(err)
+	return rd.RunDeviceReply(common.CmdChipPowerOff)
+}
+func (rd *ReaderDriver) ChipCommand(name string, query *common.ReaderChipQuery) error {
+	reply, err := rd.DevChipCommand(query.Query)
+	rd.DevError, rd.DevReply = common.CheckError(err)
+	return rd.RunChipResponse(common.CmdChipCommand, query.Protocol, reply)
+}
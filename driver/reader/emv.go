@@ -0,0 +1,278 @@
+package reader
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/core"
+	"sort"
+	"strings"
+)
+
+// EMV tags used to read card data
+const (
+	TagFci            uint32 = 0x6F
+	TagFciProprietary uint32 = 0xA5
+	TagFciIssuerData  uint32 = 0xBF0C
+	TagDirEntry       uint32 = 0x61
+	TagAid            uint32 = 0x4F
+	TagAppLabel       uint32 = 0x50
+	TagPriority       uint32 = 0x87
+	TagPdol           uint32 = 0x9F38
+	TagRecord         uint32 = 0x70
+	TagGpoFormat1     uint32 = 0x80
+	TagGpoFormat2     uint32 = 0x77
+	TagAip            uint32 = 0x82
+	TagAfl            uint32 = 0x94
+	TagCommandData    uint32 = 0x83
+	TagTrack2         uint32 = 0x57
+	TagPan            uint32 = 0x5A
+	TagHolderName     uint32 = 0x5F20
+	TagHolderNameExt  uint32 = 0x9F0B
+	TagExpiryDate     uint32 = 0x5F24
+	TagServiceCode    uint32 = 0x5F30
+)
+
+// Proximity payment system environment and known payment applications used if PPSE is absent
+var (
+	namePpse  = []byte("2PAY.SYS.DDF01")
+	knownAids = []string{
+		"A0000000031010",   // Visa
+		"A0000000041010",   // Mastercard
+		"A0000000043060",   // Maestro
+		"A00000002501",     // American Express
+		"A0000006581010",   // MIR
+		"A000000333010101", // UnionPay
+	}
+)
+
+// ChipApp is payment application of the card
+type ChipApp struct {
+	Aid      []byte
+	Label    string
+	Priority byte
+}
+
+// ReadChipCard selects payment application and reads its records, card data is put to card info
+func ReadChipCard(tx ChipTransmitter, info *common.ReaderCardInfo) error {
+	apps, err := SelectPpse(tx)
+	if err != nil {
+		return err
+	}
+	var fci []*Tlv
+	for _, app := range apps {
+		fci, err = SelectAid(tx, app.Aid)
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+	afl, records, err := GetProcessingOptions(tx, FindTlv(fci, TagPdol))
+	if err != nil {
+		return err
+	}
+	for i := 0; i+4 <= len(afl); i += 4 {
+		sfi := afl[i] >> 3
+		for rec := afl[i+1]; rec <= afl[i+2] && rec != 0; rec++ {
+			list, err := ReadRecord(tx, sfi, rec)
+			if err != nil {
+				return err
+			}
+			records = append(records, list...)
+		}
+	}
+	return FillCardInfo(records, info)
+}
+
+// SelectPpse reads application list of PPSE ordered by priority, known applications are returned if PPSE is absent
+func SelectPpse(tx ChipTransmitter) ([]*ChipApp, error) {
+	reply, err := ExchangeApdu(tx, NewApdu(0x00, InsSelect, 0x04, 0x00, namePpse, apduMaxLength))
+	if err != nil {
+		return nil, err
+	}
+	apps := make([]*ChipApp, 0)
+	if reply.Status() == SwFileNotFound {
+		for _, aid := range knownAids {
+			data, _ := hex.DecodeString(aid)
+			apps = append(apps, &ChipApp{Aid: data})
+		}
+		return apps, nil
+	}
+	if err = reply.GetError(); err != nil {
+		return nil, err
+	}
+	fci, err := DecodeTlv(reply.Data)
+	if err != nil {
+		return nil, err
+	}
+	issuer := FindTlv(fci, TagFciIssuerData)
+	if issuer == nil {
+		return nil, common.NewError(common.DevErrorBadCardData, "PPSE has no directory")
+	}
+	for _, entry := range issuer.Children {
+		if entry.Tag != TagDirEntry {
+			continue
+		}
+		app := &ChipApp{}
+		if aid := entry.Find(TagAid); aid != nil {
+			app.Aid = aid.Value
+		}
+		if label := entry.Find(TagAppLabel); label != nil {
+			app.Label = string(label.Value)
+		}
+		if prio := entry.Find(TagPriority); prio != nil && len(prio.Value) > 0 {
+			app.Priority = prio.Value[0] & 0x0F
+		}
+		if len(app.Aid) > 0 {
+			apps = append(apps, app)
+		}
+	}
+	if len(apps) == 0 {
+		return nil, common.NewError(common.DevErrorBadCardData, "PPSE has no applications")
+	}
+	// Priority 1 is the highest one, zero means no priority
+	sort.SliceStable(apps, func(i, j int) bool {
+		return apps[i].Priority-1 < apps[j].Priority-1
+	})
+	return apps, nil
+}
+
+// SelectAid selects application and returns its FCI
+func SelectAid(tx ChipTransmitter, aid []byte) ([]*Tlv, error) {
+	reply, err := ExchangeApdu(tx, NewApdu(0x00, InsSelect, 0x04, 0x00, aid, apduMaxLength))
+	if err == nil {
+		err = reply.GetError()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return DecodeTlv(reply.Data)
+}
+
+// GetProcessingOptions sends PDOL data of zeros and returns AFL and data objects of format 2 reply
+func GetProcessingOptions(tx ChipTransmitter, pdol *Tlv) ([]byte, []*Tlv, error) {
+	var data []byte
+	if pdol != nil {
+		size, err := getDolSize(pdol.Value)
+		if err != nil {
+			return nil, nil, err
+		}
+		data = make([]byte, size)
+	}
+	cmd := NewTlv(TagCommandData, data).Bytes()
+	reply, err := ExchangeApdu(tx, NewApdu(0x80, InsGetProcOptions, 0x00, 0x00, cmd, apduMaxLength))
+	if err == nil {
+		err = reply.GetError()
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	list, err := DecodeTlv(reply.Data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(list) == 1 && list[0].Tag == TagGpoFormat1 {
+		// AIP of two bytes is followed by AFL
+		if len(list[0].Value) < 2 {
+			return nil, nil, common.NewError(common.DevErrorBadCardData, "GPO reply is too short")
+		}
+		return list[0].Value[2:], nil, nil
+	}
+	var afl []byte
+	if item := FindTlv(list, TagAfl); item != nil {
+		afl = item.Value
+	}
+	return afl, list, nil
+}
+
+// ReadRecord reads record of short file identifier
+func ReadRecord(tx ChipTransmitter, sfi byte, record byte) ([]*Tlv, error) {
+	reply, err := ExchangeApdu(tx, NewApdu(0x00, InsReadRecord, record, sfi<<3|0x04, nil, apduMaxLength))
+	if err == nil {
+		err = reply.GetError()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return DecodeTlv(reply.Data)
+}
+
+// FillCardInfo extracts PAN, expiry date, service code and holder name from data objects
+func FillCardInfo(list []*Tlv, info *common.ReaderCardInfo) error {
+	if item := FindTlv(list, TagTrack2); item != nil {
+		track := strings.TrimRight(strings.ToUpper(hex.EncodeToString(item.Value)), "F")
+		info.Track2 = strings.Replace(track, "D", string(track2Separator), 1)
+		if parsed, err := ParseTrack2(info.Track2); err == nil {
+			info.CardPan, info.ExpDate, info.SvcCode = parsed.Pan, parsed.ExpDate, parsed.ServiceCode
+		}
+	}
+	if item := FindTlv(list, TagPan); item != nil {
+		info.CardPan = strings.TrimRight(strings.ToUpper(hex.EncodeToString(item.Value)), "F")
+	}
+	if item := FindTlv(list, TagExpiryDate); item != nil && len(item.Value) >= 2 {
+		info.ExpDate = fmt.Sprintf("%02X%02X", item.Value[0], item.Value[1])
+	}
+	if item := FindTlv(list, TagServiceCode); item != nil && len(item.Value) == 2 {
+		info.SvcCode = fmt.Sprintf("%03X", uint16(item.Value[0])<<8|uint16(item.Value[1]))
+	}
+	if item := FindTlv(list, TagHolderNameExt); item != nil {
+		info.Holder = strings.TrimSpace(string(item.Value))
+	} else if item = FindTlv(list, TagHolderName); item != nil {
+		info.Holder = strings.TrimSpace(string(item.Value))
+	}
+	if info.CardPan == "" {
+		return common.NewError(common.DevErrorBadCardData, "card has no PAN")
+	}
+	if !isDigits(info.CardPan) || !core.CheckCardPan(info.CardPan) {
+		return common.NewError(common.DevErrorBadCardData, "PAN check digit is wrong")
+	}
+	return nil
+}
+
+// Data size of DOL that is the list of tags with lengths
+func getDolSize(dol []byte) (int, error) {
+	size := 0
+	for pos := 0; pos < len(dol); {
+		_, tagSize, err := decodeTag(dol[pos:])
+		if err != nil {
+			return 0, err
+		}
+		pos += tagSize
+		length, lenSize, err := decodeLength(dol[pos:])
+		if err != nil {
+			return 0, err
+		}
+		pos += lenSize
+		size += length
+	}
+	return size, nil
+}
+
+////////////////////////////////////////////////////////////////
+
+// RecordedChip replays recorded card responses, keys are command APDU in hex and values are replies in hex
+type RecordedChip map[string]string
+
+// Transmit returns recorded reply of the command, unknown command gets 6D00 status
+func (rc RecordedChip) Transmit(apdu []byte) ([]byte, error) {
+	reply, ok := rc[strings.ToUpper(hex.EncodeToString(apdu))]
+	if !ok {
+		return []byte{0x6D, 0x00}, nil
+	}
+	data, err := hex.DecodeString(strings.Replace(reply, " ", "", -1))
+	if err != nil {
+		return nil, common.NewError(common.DevErrorBadArgument, "recorded reply is not hex")
+	}
+	return data, nil
+}
+
+// NewRecordedChip makes recorded card of command and reply pairs, spaces are allowed in hex strings
+func NewRecordedChip(pairs ...string) RecordedChip {
+	rc := RecordedChip{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		rc[strings.ToUpper(strings.Replace(pairs[i], " ", "", -1))] = pairs[i+1]
+	}
+	return rc
+}
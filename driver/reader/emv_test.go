@@ -0,0 +1,171 @@
+package reader
+
+import (
+	"bytes"
+	"encoding/hex"
+	"github.com/iftsoft/device/common"
+	"strings"
+	"testing"
+)
+
+const (
+	testAidVisa = "A0000000031010"
+	testAidMc   = "A0000000041010"
+	testPan     = "4111111111111111"
+)
+
+func mustHex(t *testing.T, text string) []byte {
+	t.Helper()
+	data, err := hex.DecodeString(strings.Replace(text, " ", "", -1))
+	if err != nil {
+		t.Fatalf("bad hex %s: %s", text, err)
+	}
+	return data
+}
+
+// Put reply of data and status word for the command to recorded card
+func addReply(rc RecordedChip, apdu *Apdu, data []byte, sw uint16) {
+	reply := &ApduReply{Data: data, SW1: byte(sw >> 8), SW2: byte(sw)}
+	rc[strings.ToUpper(hex.EncodeToString(apdu.Bytes()))] = hex.EncodeToString(reply.Bytes())
+}
+
+func getTestPpse(t *testing.T) []byte {
+	entry := func(aid string, label string, prio byte) *Tlv {
+		return NewConstructedTlv(TagDirEntry,
+			NewTlv(TagAid, mustHex(t, aid)),
+			NewTlv(TagAppLabel, []byte(label)),
+			NewTlv(TagPriority, []byte{prio}))
+	}
+	fci := NewConstructedTlv(TagFci,
+		NewTlv(0x84, namePpse),
+		NewConstructedTlv(TagFciProprietary,
+			NewConstructedTlv(TagFciIssuerData,
+				entry(testAidMc, "MASTERCARD", 2),
+				entry(testAidVisa, "VISA", 1))))
+	return fci.Bytes()
+}
+
+// Card with PPSE of Mastercard and Visa, Visa has the highest priority and is read.
+// PPSE comes by GET RESPONSE chain and record is read after wrong Le status.
+func getTestCard(t *testing.T) RecordedChip {
+	rc := RecordedChip{}
+	ppse := getTestPpse(t)
+	addReply(rc, NewApdu(0x00, InsSelect, 0x04, 0x00, namePpse, apduMaxLength), nil, 0x6100|uint16(20))
+	addReply(rc, NewApdu(0x00, InsGetResponse, 0, 0, nil, 20), ppse[:20], 0x6100|uint16(len(ppse)-20))
+	addReply(rc, NewApdu(0x00, InsGetResponse, 0, 0, nil, len(ppse)-20), ppse[20:], SwSuccess)
+
+	fci := NewConstructedTlv(TagFci,
+		NewTlv(0x84, mustHex(t, testAidVisa)),
+		NewConstructedTlv(TagFciProprietary,
+			NewTlv(TagAppLabel, []byte("VISA")),
+			NewTlv(TagPdol, mustHex(t, "9F66 04 9F02 06"))))
+	addReply(rc, NewApdu(0x00, InsSelect, 0x04, 0x00, mustHex(t, testAidVisa), apduMaxLength), fci.Bytes(), SwSuccess)
+
+	// AIP and AFL of SFI 1 records 1 and 2
+	gpo := NewTlv(TagGpoFormat1, mustHex(t, "1980 08 01 02 00"))
+	addReply(rc, NewApdu(0x80, InsGetProcOptions, 0, 0, NewTlv(TagCommandData, make([]byte, 10)).Bytes(), apduMaxLength),
+		gpo.Bytes(), SwSuccess)
+
+	rec1 := NewConstructedTlv(TagRecord,
+		NewTlv(TagTrack2, mustHex(t, testPan+"D25122010000012300000F")),
+		NewTlv(TagHolderName, []byte("CARDHOLDER/TEST ")))
+	addReply(rc, NewApdu(0x00, InsReadRecord, 1, 0x0C, nil, apduMaxLength), rec1.Bytes(), SwSuccess)
+	rec2 := NewConstructedTlv(TagRecord,
+		NewTlv(TagPan, mustHex(t, testPan)),
+		NewTlv(TagExpiryDate, mustHex(t, "251231")),
+		NewTlv(TagServiceCode, mustHex(t, "0201")))
+	addReply(rc, NewApdu(0x00, InsReadRecord, 2, 0x0C, nil, apduMaxLength), nil, 0x6C00|uint16(len(rec2.Bytes())))
+	addReply(rc, NewApdu(0x00, InsReadRecord, 2, 0x0C, nil, len(rec2.Bytes())), rec2.Bytes(), SwSuccess)
+	return rc
+}
+
+func TestTlvRoundTrip(t *testing.T) {
+	long := bytes.Repeat([]byte{0x5A}, 300)
+	list := []*Tlv{
+		NewConstructedTlv(TagFci,
+			NewTlv(0x84, namePpse),
+			NewConstructedTlv(TagFciProprietary,
+				NewTlv(TagPdol, mustHex(t, "9F66 04")),
+				NewTlv(0x9F4D, long))),
+		NewTlv(TagHolderNameExt, bytes.Repeat([]byte{'A'}, 130)),
+	}
+	data := EncodeTlv(list)
+	back, err := DecodeTlv(append(append([]byte{0x00}, data...), 0xFF, 0xFF))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(EncodeTlv(back), data) {
+		t.Errorf("decoded objects are encoded to % X", EncodeTlv(back))
+	}
+	if item := FindTlv(back, 0x9F4D); item == nil || !bytes.Equal(item.Value, long) {
+		t.Errorf("nested long object is %v", item)
+	}
+	if item := FindTlv(back, TagHolderNameExt); item == nil || len(item.Value) != 130 {
+		t.Errorf("object of two byte tag is %v", item)
+	}
+	for _, broken := range []string{"9F", "5A 05 41 11", "5A 84 00 00 00 01 00", "6F 04 5A 05 41 11"} {
+		if _, err = DecodeTlv(mustHex(t, broken)); err == nil {
+			t.Errorf("broken TLV %s is decoded", broken)
+		}
+	}
+}
+
+func TestExchangeApduChain(t *testing.T) {
+	ppse := getTestPpse(t)
+	reply, err := ExchangeApdu(getTestCard(t), NewApdu(0x00, InsSelect, 0x04, 0x00, namePpse, apduMaxLength))
+	if err != nil || !reply.IsSuccess() || !bytes.Equal(reply.Data, ppse) {
+		t.Errorf("chained reply %s: %v", reply, err)
+	}
+	// Card that asks GET RESPONSE for ever
+	endless := RecordedChip{}
+	addReply(endless, NewApdu(0x00, InsSelect, 0x04, 0x00, namePpse, apduMaxLength), nil, 0x6110)
+	addReply(endless, NewApdu(0x00, InsGetResponse, 0, 0, nil, 0x10), make([]byte, 0x10), 0x6110)
+	if _, err = ExchangeApdu(endless, NewApdu(0x00, InsSelect, 0x04, 0x00, namePpse, apduMaxLength)); err == nil {
+		t.Error("endless GET RESPONSE chain is accepted")
+	}
+}
+
+func TestSelectPpse(t *testing.T) {
+	apps, err := SelectPpse(getTestCard(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(apps) != 2 || hex.EncodeToString(apps[0].Aid) != strings.ToLower(testAidVisa) || apps[0].Label != "VISA" {
+		t.Errorf("applications are not ordered by priority: %+v", apps)
+	}
+	// Card without PPSE gets list of known applications
+	apps, err = SelectPpse(NewRecordedChip(
+		hex.EncodeToString(NewApdu(0x00, InsSelect, 0x04, 0x00, namePpse, apduMaxLength).Bytes()), "6A82"))
+	if err != nil || len(apps) != len(knownAids) {
+		t.Errorf("known applications %d: %v", len(apps), err)
+	}
+}
+
+func TestReadChipCard(t *testing.T) {
+	info := &common.ReaderCardInfo{}
+	if err := ReadChipCard(getTestCard(t), info); err != nil {
+		t.Fatal(err)
+	}
+	if info.CardPan != testPan || info.ExpDate != "2512" || info.SvcCode != "201" || info.Holder != "CARDHOLDER/TEST" {
+		t.Errorf("card info %+v", info)
+	}
+	if !strings.HasPrefix(info.Track2, testPan+"=2512") {
+		t.Errorf("track 2 %s", info.Track2)
+	}
+}
+
+func TestFillCardInfo(t *testing.T) {
+	info := &common.ReaderCardInfo{}
+	err := FillCardInfo([]*Tlv{NewTlv(TagPan, mustHex(t, "4111111111111112"))}, info)
+	if code, _ := common.CheckError(err); code != common.DevErrorBadCardData {
+		t.Errorf("PAN with wrong check digit: %v", err)
+	}
+	if err = FillCardInfo([]*Tlv{NewTlv(TagHolderName, []byte("NO PAN"))}, info); err == nil {
+		t.Error("card without PAN is accepted")
+	}
+	// Odd PAN is padded by F
+	err = FillCardInfo([]*Tlv{NewTlv(TagPan, mustHex(t, "4000000000000000006F"))}, info)
+	if err != nil || info.CardPan != "4000000000000000006" {
+		t.Errorf("padded PAN %s: %v", info.CardPan, err)
+	}
+}
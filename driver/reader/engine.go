@@ -0,0 +1,290 @@
+package reader
+
+import (
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/config"
+	"github.com/iftsoft/device/core"
+	"github.com/iftsoft/device/driver/generic"
+	"time"
+)
+
+const defaultWaitTimeout = 30 // Seconds to wait for card insert or take
+
+type ReaderEngine struct {
+	generic.BaseReader
+	generic.Simulator
+	config    *config.DeviceConfig
+	deck      *CardDeck
+	card      *SimCard
+	chip      RecordedChip
+	enterWait time.Time
+	takeWait  time.Time
+	captured  int32
+}
+
+func (re *ReaderEngine) initEngine(cfg *config.DeviceConfig) *ReaderEngine {
+	re.config = cfg
+	re.Log    = core.GetLogAgent(core.LogLevelTrace, "Engine")
+	return re
+}
+
+func (re *ReaderEngine) getReaderConfig() *config.ReaderConfig {
+	if re.config != nil {
+		return re.config.Reader
+	}
+	return nil
+}
+
+func (re *ReaderEngine) getWaitTime() time.Duration {
+	timeout := int32(defaultWaitTimeout)
+	if cfg := re.getReaderConfig(); cfg != nil && cfg.WaitTimeout > 0 {
+		timeout = cfg.WaitTimeout
+	}
+	return time.Duration(timeout) * time.Second
+}
+
+// Card is inside the reader and may be read or powered
+func (re *ReaderEngine) isCardInside() bool {
+	if re.card == nil {
+		return false
+	}
+	switch re.DevState {
+	case common.DevStateCardInside, common.DevStateCardInTrack, common.DevStateCardPowered:
+		return true
+	}
+	return false
+}
+
+// Stop waiting for card insert if the customer does not insert it in time
+func (re *ReaderEngine) checkEnterWait() {
+	if re.enterWait.IsZero() || time.Now().Before(re.enterWait) {
+		return
+	}
+	re.enterWait = time.Time{}
+	re.card = nil
+	re.Log.Debug("ReaderEngine card is not inserted in time")
+	re.SetupMimic(rdrEnterTimeoutSteps)
+}
+
+// Capture ejected card if the customer does not take it in time
+func (re *ReaderEngine) checkTakeWait() {
+	if re.takeWait.IsZero() || time.Now().Before(re.takeWait) {
+		return
+	}
+	re.takeWait = time.Time{}
+	re.Log.Debug("ReaderEngine card is not taken in time")
+	re.SetupMimic(rdrTakeTimeoutSteps)
+}
+
+// Put captured card counter to device metrics
+func (re *ReaderEngine) checkCaptured(metrics *common.SystemMetrics) {
+	metrics.Counts["captured_cards"] = uint32(re.captured)
+	if re.card != nil {
+		metrics.Topics["card"] = re.card.Name
+	}
+}
+
+
+////////////////////////////////////////////////////////////////
+
+func (re *ReaderEngine) DevStartup() error {
+	var err error
+	var deckFile string
+	if cfg := re.getReaderConfig(); cfg != nil {
+		deckFile = cfg.CardDeck
+	}
+	re.deck, err = LoadCardDeck(deckFile)
+	if err == nil {
+		re.Log.Debug("ReaderEngine card deck has %d cards", len(re.deck.Cards))
+	}
+	return err
+}
+
+func (re *ReaderEngine) DevCleanup() error {
+	re.enterWait = time.Time{}
+	re.takeWait = time.Time{}
+	re.ClearMimic()
+	var err error
+	return err
+}
+
+func (re *ReaderEngine) DevReset() error {
+	re.enterWait = time.Time{}
+	re.takeWait = time.Time{}
+	re.chip = nil
+	if re.card != nil {
+		// Reader retains the card that is left inside on reset
+		re.card = nil
+		re.captured++
+		_ = re.RunCardPosition(common.CardPositionCaptured)
+	}
+	re.SetupMimic(rdrResetSteps)
+	var err error
+	for i := 0; i < 50; i++ {
+		time.Sleep(200 * time.Millisecond)
+		if re.DevState == common.DevStateStandby {
+			break
+		}
+	}
+	return err
+}
+
+func (re *ReaderEngine) DevStatus() error {
+	var err error
+	return err
+}
+
+// DevCancel stops waiting for card insert
+func (re *ReaderEngine) DevCancel() error {
+	if !re.enterWait.IsZero() {
+		re.enterWait = time.Time{}
+		re.card = nil
+		re.SetupMimic(rdrStopWaitSteps)
+	}
+	var err error
+	return err
+}
+
+func (re *ReaderEngine) DevEnterCard() error {
+	if re.card != nil {
+		return common.NewError(common.DevErrorNotAccepted, "card is already in the reader")
+	}
+	if re.deck == nil {
+		return common.NewError(common.DevErrorNotInitialized, "card deck is not loaded")
+	}
+	re.card = re.deck.NextCard()
+	re.Log.Debug("ReaderEngine next card: %s", re.card.String())
+	re.enterWait = time.Now().Add(re.getWaitTime())
+	re.SetupMimic(rdrEnterWaitSteps)
+	var err error
+	return err
+}
+
+func (re *ReaderEngine) DevReadCard() error {
+	if !re.isCardInside() {
+		return common.NewError(common.DevErrorNotAccepted, "no card inside the reader")
+	}
+	re.DevAction = common.DevActionCardReading
+	_ = re.RunStateChanged(common.DevStateCardInTrack)
+	info, err := ParseCardTracks(re.getReaderConfig(), re.card.Track1, re.card.Track2, re.card.Track3)
+	re.DevAction = common.DevActionDoNothing
+	_ = re.RunStateChanged(common.DevStateCardInside)
+	if err != nil {
+		_ = re.RunActionPrompt(common.DevPromptCardFailure)
+		return err
+	}
+	re.CardInfo = *info
+	return re.RunCardDescription(&re.CardInfo)
+}
+
+func (re *ReaderEngine) DevChipGetATR() ([]byte, error) {
+	if !re.isCardInside() {
+		return nil, common.NewError(common.DevErrorNotAccepted, "no card inside the reader")
+	}
+	atr, err := re.card.GetAtr()
+	if err != nil {
+		_ = re.RunActionPrompt(common.DevPromptCardFailure)
+		return nil, err
+	}
+	re.chip = re.card.GetChip()
+	re.DevAction = common.DevActionCardProcessing
+	_ = re.RunStateChanged(common.DevStateCardPowered)
+	return atr, nil
+}
+
+func (re *ReaderEngine) DevChipCommand(apdu []byte) ([]byte, error) {
+	if re.DevState != common.DevStateCardPowered || re.chip == nil {
+		return nil, common.NewError(common.DevErrorNotAccepted, "chip is not powered")
+	}
+	return re.chip.Transmit(apdu)
+}
+
+func (re *ReaderEngine) DevChipPowerOff() error {
+	if re.DevState == common.DevStateCardPowered {
+		re.chip = nil
+		re.DevAction = common.DevActionDoNothing
+		_ = re.RunStateChanged(common.DevStateCardInside)
+	}
+	var err error
+	return err
+}
+
+func (re *ReaderEngine) DevEjectCard() error {
+	if !re.isCardInside() {
+		return common.NewError(common.DevErrorNotAccepted, "no card inside the reader")
+	}
+	re.chip = nil
+	re.SetupMimic(rdrEjectSteps)
+	var err error
+	return err
+}
+
+func (re *ReaderEngine) DevCaptureCard() error {
+	if !re.isCardInside() && re.DevState != common.DevStateCardInFront {
+		return common.NewError(common.DevErrorNotAccepted, "no card in the reader")
+	}
+	re.chip = nil
+	re.takeWait = time.Time{}
+	re.SetupMimic(rdrCaptureSteps)
+	var err error
+	return err
+}
+
+
+////////////////////////////////////////////////////////////////
+
+func (re *ReaderEngine) NextMimicStage() {
+	re.checkEnterWait()
+	re.checkTakeWait()
+	stage := re.GetMimicStep()
+	if stage != nil {
+		_ = re.ProcessStage(&re.BaseEngine, stage)
+		switch stage.Value {
+		case StepEnterWaitDone:
+			re.StepEnterWaitDone()
+		case StepInsertDone:
+			re.StepInsertDone()
+		case StepEjectDone:
+			re.StepEjectDone()
+		case StepTakeDone:
+			re.StepTakeDone()
+		case StepCaptureDone:
+			re.StepCaptureDone()
+		default:
+		}
+	}
+	return
+}
+
+func (re *ReaderEngine) StepEnterWaitDone() {
+	if re.card == nil || re.card.NoInsert {
+		// Keep waiting until the insert timeout
+		return
+	}
+	re.enterWait = time.Time{}
+	re.SetupMimic(rdrInsertSteps)
+}
+func (re *ReaderEngine) StepInsertDone() {
+	re.CardInfo = common.ReaderCardInfo{}
+	_ = re.RunCardPosition(common.CardPositionInside)
+}
+func (re *ReaderEngine) StepEjectDone() {
+	_ = re.RunCardPosition(common.CardPositionFront)
+	re.takeWait = time.Now().Add(re.getWaitTime())
+	if re.card != nil && !re.card.NoTake {
+		re.SetupMimic(rdrTakeSteps)
+	}
+}
+func (re *ReaderEngine) StepTakeDone() {
+	re.takeWait = time.Time{}
+	re.card = nil
+	_ = re.RunCardPosition(common.CardPositionNone)
+}
+func (re *ReaderEngine) StepCaptureDone() {
+	re.card = nil
+	re.captured++
+	_ = re.RunCardPosition(common.CardPositionCaptured)
+}
+
+
+////////////////////////////////////////////////////////////////
@@ -0,0 +1,60 @@
+package reader
+
+import (
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/driver/generic"
+)
+
+const (
+	StepDoNothing int = iota
+	StepEnterWaitDone
+	StepInsertDone
+	StepEjectDone
+	StepTakeDone
+	StepCaptureDone
+)
+
+var rdrResetSteps = generic.MimicSteps{
+	{ 0, 0, common.DevStateWorking, 0, common.DevPromptUnitWork, common.DevActionInitialization, "", "" },
+	{ 10, 0, common.DevStateReady, 0, 0, common.DevActionInitialization, "", "" },
+	{ 1, 0, common.DevStateStandby, 0, 0, common.DevActionDoNothing, "", "" },
+}
+
+var rdrEnterWaitSteps = generic.MimicSteps{
+	{ 0, 0, common.DevStateWorking, 0, common.DevPromptCardInsert, common.DevActionCardEntering, "", "" },
+	{ 1, 0, common.DevStateWaiting, 0, 0, common.DevActionCardEntering, "", "" },
+	{ 20, StepEnterWaitDone, common.DevStateWaiting, 0, 0, common.DevActionCardEntering, "", "" },
+}
+
+var rdrInsertSteps = generic.MimicSteps{
+	{ 0, 0, common.DevStateWorking, 0, 0, common.DevActionCardEntering, "", "" },
+	{ 10, StepInsertDone, common.DevStateCardInside, 0, 0, common.DevActionDoNothing, "", "" },
+}
+
+var rdrEnterTimeoutSteps = generic.MimicSteps{
+	{ 0, 0, common.DevStateReady, common.DevErrorWaitTimeout, 0, common.DevActionDoNothing, "", "card is not inserted in time" },
+}
+
+var rdrStopWaitSteps = generic.MimicSteps{
+	{ 0, 0, common.DevStateWorking, 0, common.DevPromptUnitDone, common.DevActionDeviceStopping, "", "" },
+	{ 5, 0, common.DevStateReady, 0, 0, common.DevActionDoNothing, "", "" },
+}
+
+var rdrEjectSteps = generic.MimicSteps{
+	{ 0, 0, common.DevStateWorking, 0, 0, common.DevActionCardEjecting, "", "" },
+	{ 10, StepEjectDone, common.DevStateCardInFront, 0, common.DevPromptCardRemove, common.DevActionCardEjecting, "", "" },
+}
+
+var rdrTakeSteps = generic.MimicSteps{
+	{ 30, StepTakeDone, common.DevStateReady, 0, 0, common.DevActionDoNothing, "", "" },
+}
+
+var rdrCaptureSteps = generic.MimicSteps{
+	{ 0, 0, common.DevStateWorking, 0, 0, common.DevActionCardCapturing, "", "" },
+	{ 10, StepCaptureDone, common.DevStateReady, 0, common.DevPromptCardCapture, common.DevActionDoNothing, "", "" },
+}
+
+var rdrTakeTimeoutSteps = generic.MimicSteps{
+	{ 0, 0, common.DevStateWorking, 0, 0, common.DevActionCardCapturing, "", "" },
+	{ 10, StepCaptureDone, common.DevStateReady, common.DevErrorWaitTimeout, common.DevPromptCardCapture, common.DevActionDoNothing, "", "card is not taken in time" },
+}
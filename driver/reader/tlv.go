@@ -0,0 +1,191 @@
+package reader
+
+import (
+	"fmt"
+	"github.com/iftsoft/device/common"
+	"strings"
+)
+
+// Tlv is BER-TLV data object, constructed object keeps decoded children
+type Tlv struct {
+	Tag      uint32
+	Value    []byte
+	Children []*Tlv
+}
+
+func NewTlv(tag uint32, value []byte) *Tlv {
+	return &Tlv{Tag: tag, Value: value}
+}
+
+// NewConstructedTlv makes constructed object of children, its value is encoded children
+func NewConstructedTlv(tag uint32, children ...*Tlv) *Tlv {
+	return &Tlv{Tag: tag, Value: EncodeTlv(children), Children: children}
+}
+
+// IsConstructed checks bit 6 of the first tag byte
+func (t *Tlv) IsConstructed() bool {
+	first := t.Tag
+	for first > 0xFF {
+		first >>= 8
+	}
+	return first&0x20 != 0
+}
+
+func (t *Tlv) String() string {
+	if t == nil {
+		return ""
+	}
+	return t.format(0)
+}
+
+func (t *Tlv) format(level int) string {
+	indent := strings.Repeat("  ", level)
+	if len(t.Children) == 0 {
+		return fmt.Sprintf("%s%X [%d] %X", indent, t.Tag, len(t.Value), t.Value)
+	}
+	list := []string{fmt.Sprintf("%s%X [%d]", indent, t.Tag, len(t.Value))}
+	for _, child := range t.Children {
+		list = append(list, child.format(level+1))
+	}
+	return strings.Join(list, "\n")
+}
+
+// Bytes encodes the object, value of constructed object is encoded from its children
+func (t *Tlv) Bytes() []byte {
+	value := t.Value
+	if t.IsConstructed() && len(t.Children) > 0 {
+		value = EncodeTlv(t.Children)
+	}
+	out := encodeTag(t.Tag)
+	out = append(out, encodeLength(len(value))...)
+	return append(out, value...)
+}
+
+// Find searches the object and its children depth first
+func (t *Tlv) Find(tag uint32) *Tlv {
+	if t.Tag == tag {
+		return t
+	}
+	return FindTlv(t.Children, tag)
+}
+
+// FindTlv searches the list depth first and returns the first object of the tag
+func FindTlv(list []*Tlv, tag uint32) *Tlv {
+	for _, item := range list {
+		if found := item.Find(tag); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// EncodeTlv encodes the list of objects
+func EncodeTlv(list []*Tlv) []byte {
+	out := make([]byte, 0)
+	for _, item := range list {
+		out = append(out, item.Bytes()...)
+	}
+	return out
+}
+
+// DecodeTlv decodes the list of objects, constructed objects are decoded recursively.
+// Zero and FF bytes between objects are padding and skipped.
+func DecodeTlv(data []byte) ([]*Tlv, error) {
+	list := make([]*Tlv, 0)
+	for pos := 0; pos < len(data); {
+		if data[pos] == 0x00 || data[pos] == 0xFF {
+			pos++
+			continue
+		}
+		tag, size, err := decodeTag(data[pos:])
+		if err != nil {
+			return nil, err
+		}
+		pos += size
+		length, size, err := decodeLength(data[pos:])
+		if err != nil {
+			return nil, err
+		}
+		pos += size
+		if length > len(data)-pos {
+			return nil, badTlvData(fmt.Sprintf("value of tag %X is out of data", tag))
+		}
+		item := NewTlv(tag, data[pos:pos+length])
+		pos += length
+		if item.IsConstructed() {
+			item.Children, err = DecodeTlv(item.Value)
+			if err != nil {
+				return nil, err
+			}
+		}
+		list = append(list, item)
+	}
+	return list, nil
+}
+
+////////////////////////////////////////////////////////////////
+
+// Tag of up to four bytes, the first byte with low five bits set is followed by bytes with bit 8 set but the last
+func decodeTag(data []byte) (uint32, int, error) {
+	tag := uint32(data[0])
+	size := 1
+	if data[0]&0x1F == 0x1F {
+		for {
+			if size >= len(data) || size >= 4 {
+				return 0, 0, badTlvData("tag is out of data")
+			}
+			tag = tag<<8 | uint32(data[size])
+			size++
+			if data[size-1]&0x80 == 0 {
+				break
+			}
+		}
+	}
+	return tag, size, nil
+}
+
+// Length of short form or long form of up to three bytes
+func decodeLength(data []byte) (int, int, error) {
+	if len(data) == 0 {
+		return 0, 0, badTlvData("length is out of data")
+	}
+	if data[0] < 0x80 {
+		return int(data[0]), 1, nil
+	}
+	count := int(data[0] & 0x7F)
+	if count == 0 || count > 3 || count >= len(data) {
+		return 0, 0, badTlvData("length is wrong")
+	}
+	length := 0
+	for _, b := range data[1 : 1+count] {
+		length = length<<8 | int(b)
+	}
+	return length, 1 + count, nil
+}
+
+func encodeTag(tag uint32) []byte {
+	out := make([]byte, 0, 4)
+	for shift := 24; shift > 0; shift -= 8 {
+		if b := byte(tag >> uint(shift)); b != 0 || len(out) > 0 {
+			out = append(out, b)
+		}
+	}
+	return append(out, byte(tag))
+}
+
+func encodeLength(length int) []byte {
+	switch {
+	case length < 0x80:
+		return []byte{byte(length)}
+	case length <= 0xFF:
+		return []byte{0x81, byte(length)}
+	case length <= 0xFFFF:
+		return []byte{0x82, byte(length >> 8), byte(length)}
+	default:
+		return []byte{0x83, byte(length >> 16), byte(length >> 8), byte(length)}
+	}
+}
+
+func badTlvData(text string) error {
+	return common.NewError(common.DevErrorBadCardData, "TLV "+text)
+}
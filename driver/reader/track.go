@@ -0,0 +1,256 @@
+package reader
+
+import (
+	"fmt"
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/config"
+	"github.com/iftsoft/device/core"
+	"strings"
+)
+
+// ISO 7813 sentinels and separators
+const (
+	track1Start     = '%'
+	track1Separator = '^'
+	track1Format    = 'B' // Format code of bank cards
+	track2Start     = ';'
+	track2Separator = '='
+	trackEnd        = '?'
+
+	track1MaxSize = 79
+	track2MaxSize = 40
+	track3MaxSize = 107
+	panMinSize    = 12
+	panMaxSize    = 19
+)
+
+// CardTrack keeps fields of magnetic track 1 or 2
+type CardTrack struct {
+	Pan           string
+	Holder        string // Track 1 only
+	ExpDate       string // YYMM, empty if absent
+	ServiceCode   string // Three digits, empty if absent
+	Discretionary string
+}
+
+func (ct *CardTrack) String() string {
+	if ct == nil {
+		return ""
+	}
+	return fmt.Sprintf("PAN %s, Holder %s, ExpDate %s, ServiceCode %s",
+		core.MaskPan(ct.Pan), ct.Holder, ct.ExpDate, ct.ServiceCode)
+}
+
+// HasChip checks the first digit of service code for integrated circuit card
+func (ct *CardTrack) HasChip() bool {
+	return len(ct.ServiceCode) == 3 && (ct.ServiceCode[0] == '2' || ct.ServiceCode[0] == '6')
+}
+
+// ParseTrack1 parses track 1 of format B: %B PAN ^ NAME ^ YYMM SVC DATA ? LRC.
+// Sentinels and LRC are optional as some readers strip them, LRC is checked if present.
+func ParseTrack1(data string) (*CardTrack, error) {
+	body, err := checkSentinels(data, track1Start, track1MaxSize, 0x20, 0x3F)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) == 0 || body[0] != track1Format {
+		return nil, badCardData("track 1 format code is not B")
+	}
+	fields := strings.SplitN(body[1:], string(track1Separator), 3)
+	if len(fields) != 3 {
+		return nil, badCardData("track 1 has no field separators")
+	}
+	name := strings.TrimSpace(fields[1])
+	if len(fields[1]) < 2 || len(fields[1]) > 26 {
+		return nil, badCardData("track 1 holder name size is out of range")
+	}
+	track := &CardTrack{Pan: fields[0], Holder: name}
+	err = track.parseTail(fields[2], track1Separator)
+	if err != nil {
+		return nil, err
+	}
+	return track, nil
+}
+
+// ParseTrack2 parses track 2: ; PAN = YYMM SVC DATA ? LRC.
+// Sentinels and LRC are optional as some readers strip them, LRC is checked if present.
+func ParseTrack2(data string) (*CardTrack, error) {
+	body, err := checkSentinels(data, track2Start, track2MaxSize, 0x30, 0x0F)
+	if err != nil {
+		return nil, err
+	}
+	pos := strings.IndexByte(body, track2Separator)
+	if pos < 0 {
+		return nil, badCardData("track 2 has no field separator")
+	}
+	for _, ch := range body {
+		if (ch < '0' || ch > '9') && ch != track2Separator {
+			return nil, badCardData("track 2 has wrong character")
+		}
+	}
+	track := &CardTrack{Pan: body[:pos]}
+	err = track.parseTail(body[pos+1:], track2Separator)
+	if err != nil {
+		return nil, err
+	}
+	return track, nil
+}
+
+// Check PAN and parse expiry date, service code and discretionary data, separator stands for absent field
+func (ct *CardTrack) parseTail(tail string, separator byte) error {
+	if len(ct.Pan) < panMinSize || len(ct.Pan) > panMaxSize || !isDigits(ct.Pan) {
+		return badCardData("PAN size or characters are wrong")
+	}
+	if !core.CheckCardPan(ct.Pan) {
+		return badCardData("PAN check digit is wrong")
+	}
+	if strings.HasPrefix(tail, string(separator)) {
+		tail = tail[1:]
+	} else {
+		if len(tail) < 4 || !isDigits(tail[:4]) {
+			return badCardData("expiry date is wrong")
+		}
+		if month := tail[2:4]; month < "01" || month > "12" {
+			return badCardData("expiry month is wrong")
+		}
+		ct.ExpDate, tail = tail[:4], tail[4:]
+	}
+	if strings.HasPrefix(tail, string(separator)) {
+		tail = tail[1:]
+	} else {
+		if len(tail) < 3 || !isDigits(tail[:3]) {
+			return badCardData("service code is wrong")
+		}
+		ct.ServiceCode, tail = tail[:3], tail[3:]
+	}
+	ct.Discretionary = tail
+	return nil
+}
+
+// ParseTrack3 checks sentinels of track 3 and returns its data without them
+func ParseTrack3(data string) (string, error) {
+	body, err := checkSentinels(data, track2Start, track3MaxSize, 0x30, 0x0F)
+	if err != nil {
+		return "", err
+	}
+	return body, nil
+}
+
+////////////////////////////////////////////////////////////////
+
+// ParseCardTracks skips reader prefix of the tracks, parses them and fills card info.
+// PAN and expiry date of track 2 are preferred, they must match track 1 if both tracks are read.
+func ParseCardTracks(cfg *config.ReaderConfig, tracks ...string) (*common.ReaderCardInfo, error) {
+	if cfg == nil {
+		cfg = config.GetDefaultReaderConfig()
+	}
+	info := &common.ReaderCardInfo{}
+	raw := make([]string, 3)
+	for i := 0; i < len(tracks) && i < len(raw); i++ {
+		raw[i] = skipPrefix(tracks[i], cfg.SkipPrefix)
+	}
+	info.Track1, info.Track2, info.Track3 = raw[0], raw[1], raw[2]
+	info.RawData = strings.Join(raw, "")
+	if cfg.CardAccept == config.CardAcceptSmart {
+		return info, common.NewError(common.DevErrorNotAccepted, "magnetic cards are not accepted")
+	}
+	var track1, track2 *CardTrack
+	var err error
+	if info.Track1 != "" {
+		track1, err = ParseTrack1(info.Track1)
+		if err != nil {
+			return info, err
+		}
+	}
+	if info.Track2 != "" {
+		track2, err = ParseTrack2(info.Track2)
+		if err != nil {
+			return info, err
+		}
+	}
+	if info.Track3 != "" {
+		_, err = ParseTrack3(info.Track3)
+		if err != nil {
+			return info, err
+		}
+	}
+	track := track2
+	switch {
+	case track1 == nil && track2 == nil:
+		return info, badCardData("no financial track is read")
+	case track2 == nil:
+		track = track1
+	case track1 != nil && (track1.Pan != track2.Pan || track1.ExpDate != track2.ExpDate):
+		return info, badCardData("track 1 does not match track 2")
+	}
+	info.CardPan, info.ExpDate, info.SvcCode = track.Pan, track.ExpDate, track.ServiceCode
+	if track1 != nil {
+		info.Holder = track1.Holder
+	}
+	if cfg.CardAccept == config.CardAcceptMagnetic && track.HasChip() {
+		return info, common.NewError(common.DevErrorNotAccepted, "chip card must be read by chip")
+	}
+	return info, nil
+}
+
+////////////////////////////////////////////////////////////////
+
+// Skip bytes that the reader puts before track data
+func skipPrefix(track string, skip config.EnumSkipPrefix) string {
+	count := 0
+	switch skip {
+	case config.SkipPrefixBite:
+		count = 1
+	case config.SkipPrefixTwo:
+		count = 2
+	}
+	if track == "" || len(track) <= count {
+		return ""
+	}
+	return track[count:]
+}
+
+// Check track size, sentinels and LRC, return data between sentinels.
+// Characters are coded by subtracting the base, LRC is XOR of codes masked by the mask.
+func checkSentinels(data string, start byte, maxSize int, base byte, mask byte) (string, error) {
+	if len(data) > maxSize+2 {
+		return "", badCardData("track is too long")
+	}
+	body := data
+	if strings.HasPrefix(body, string(start)) {
+		body = body[1:]
+	}
+	pos := strings.IndexByte(body, trackEnd)
+	if pos < 0 {
+		return body, nil
+	}
+	if pos+1 < len(body) {
+		if pos+2 != len(body) {
+			return "", badCardData("data after track LRC")
+		}
+		if !strings.HasPrefix(data, string(start)) {
+			return "", badCardData("track LRC without start sentinel")
+		}
+		var lrc byte
+		for i := 0; i <= pos+1; i++ {
+			lrc ^= (data[i] - base) & mask
+		}
+		if lrc != (body[pos+1]-base)&mask {
+			return "", badCardData("track LRC mismatch")
+		}
+	}
+	return body[:pos], nil
+}
+
+func isDigits(text string) bool {
+	for _, ch := range text {
+		if ch < '0' || ch > '9' {
+			return false
+		}
+	}
+	return text != ""
+}
+
+func badCardData(text string) error {
+	return common.NewError(common.DevErrorBadCardData, text)
+}
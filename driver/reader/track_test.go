@@ -0,0 +1,139 @@
+package reader
+
+import (
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/config"
+	"testing"
+)
+
+const (
+	testTrack1 = "%B" + testPan + "^CARDHOLDER/TEST^2512101123456789?"
+	testTrack2 = ";" + testPan + "=2512101123456789?"
+)
+
+// Append LRC of track, it is XOR of character codes from start to end sentinel
+func withLrc(track string, base byte, mask byte) string {
+	var lrc byte
+	for i := 0; i < len(track); i++ {
+		lrc ^= (track[i] - base) & mask
+	}
+	return track + string(base+lrc)
+}
+
+func TestParseTrack1(t *testing.T) {
+	good := withLrc(testTrack1, 0x20, 0x3F)
+	bad := good[:len(good)-1] + string(good[len(good)-1]^0x01)
+	tests := []struct {
+		name  string
+		data  string
+		track *CardTrack
+	}{
+		{"sentinels", testTrack1, &CardTrack{testPan, "CARDHOLDER/TEST", "2512", "101", "123456789"}},
+		{"LRC", good, &CardTrack{testPan, "CARDHOLDER/TEST", "2512", "101", "123456789"}},
+		{"no sentinels", "B" + testPan + "^DOE/JOHN  ^2512201", &CardTrack{testPan, "DOE/JOHN", "2512", "201", ""}},
+		{"no expiry", "%B" + testPan + "^DOE/JOHN^^101?", &CardTrack{testPan, "DOE/JOHN", "", "101", ""}},
+		{"no expiry and service", "%B" + testPan + "^DOE/JOHN^^^12?", &CardTrack{testPan, "DOE/JOHN", "", "", "12"}},
+		{"LRC mismatch", bad, nil},
+		{"LRC without start", good[1:], nil},
+		{"data after LRC", good + "0", nil},
+		{"format code", "%A" + testPan + "^DOE/JOHN^2512101?", nil},
+		{"no separator", "%B" + testPan + "^DOE/JOHN2512101?", nil},
+		{"short name", "%B" + testPan + "^D^2512101?", nil},
+		{"check digit", "%B4111111111111112^DOE/JOHN^2512101?", nil},
+		{"short PAN", "%B41111111111^DOE/JOHN^2512101?", nil},
+		{"expiry month", "%B" + testPan + "^DOE/JOHN^2513101?", nil},
+		{"service code", "%B" + testPan + "^DOE/JOHN^25121?", nil},
+	}
+	for _, tt := range tests {
+		track, err := ParseTrack1(tt.data)
+		checkTrack(t, tt.name, track, err, tt.track)
+	}
+}
+
+func TestParseTrack2(t *testing.T) {
+	good := withLrc(testTrack2, 0x30, 0x0F)
+	bad := good[:len(good)-1] + string(good[len(good)-1]^0x01)
+	tests := []struct {
+		name  string
+		data  string
+		track *CardTrack
+	}{
+		{"sentinels", testTrack2, &CardTrack{testPan, "", "2512", "101", "123456789"}},
+		{"LRC", good, &CardTrack{testPan, "", "2512", "101", "123456789"}},
+		{"no sentinels", testPan + "=2512201", &CardTrack{testPan, "", "2512", "201", ""}},
+		{"no expiry", testPan + "==101", &CardTrack{testPan, "", "", "101", ""}},
+		{"no expiry and service", ";" + testPan + "===12?", &CardTrack{testPan, "", "", "", "12"}},
+		{"LRC mismatch", bad, nil},
+		{"LRC without start", good[1:], nil},
+		{"data after LRC", good + "0", nil},
+		{"no separator", ";" + testPan + "2512101?", nil},
+		{"wrong character", ";" + testPan + "=2512101A?", nil},
+		{"check digit", "4111111111111112=2512101", nil},
+		{"expiry month", testPan + "=2500101", nil},
+		{"too long", ";" + testPan + "=2512101123456789012345678?", nil},
+	}
+	for _, tt := range tests {
+		track, err := ParseTrack2(tt.data)
+		checkTrack(t, tt.name, track, err, tt.track)
+	}
+}
+
+func checkTrack(t *testing.T, name string, track *CardTrack, err error, want *CardTrack) {
+	t.Helper()
+	if want == nil {
+		if code, _ := common.CheckError(err); code != common.DevErrorBadCardData {
+			t.Errorf("%s: track %v, error %v", name, track, err)
+		}
+		return
+	}
+	if err != nil || track == nil || *track != *want {
+		t.Errorf("%s: track %+v, want %+v: %v", name, track, want, err)
+	}
+}
+
+func TestParseCardTracks(t *testing.T) {
+	chip := "%B" + testPan + "^CARDHOLDER/TEST^2512201?"
+	tests := []struct {
+		name   string
+		skip   config.EnumSkipPrefix
+		accept config.EnumCardAccept
+		tracks []string
+		code   common.EnumDevError
+		svc    string
+	}{
+		{"both tracks", config.SkipPrefixNone, config.CardAcceptAnyCard,
+			[]string{testTrack1, testTrack2}, common.DevErrorSuccess, "101"},
+		{"track 1 only", config.SkipPrefixNone, config.CardAcceptAnyCard,
+			[]string{chip}, common.DevErrorSuccess, "201"},
+		{"skip byte", config.SkipPrefixBite, config.CardAcceptAnyCard,
+			[]string{"1" + testTrack1, "2" + testTrack2}, common.DevErrorSuccess, "101"},
+		{"skip two bytes", config.SkipPrefixTwo, config.CardAcceptAnyCard,
+			[]string{"01" + testTrack1, "02" + testTrack2, "03"}, common.DevErrorSuccess, "101"},
+		{"magnetic card", config.SkipPrefixNone, config.CardAcceptMagnetic,
+			[]string{testTrack1, testTrack2}, common.DevErrorSuccess, "101"},
+		{"chip card by magnetic", config.SkipPrefixNone, config.CardAcceptMagnetic,
+			[]string{chip}, common.DevErrorNotAccepted, "201"},
+		{"smart only", config.SkipPrefixNone, config.CardAcceptSmart,
+			[]string{testTrack1, testTrack2}, common.DevErrorNotAccepted, ""},
+		{"prefix is not skipped", config.SkipPrefixNone, config.CardAcceptAnyCard,
+			[]string{"1" + testTrack1}, common.DevErrorBadCardData, ""},
+		{"tracks mismatch", config.SkipPrefixNone, config.CardAcceptAnyCard,
+			[]string{testTrack1, ";5555555555554444=2512101?"}, common.DevErrorBadCardData, ""},
+		{"no financial track", config.SkipPrefixNone, config.CardAcceptAnyCard,
+			[]string{"", "", ";0123?"}, common.DevErrorBadCardData, ""},
+	}
+	for _, tt := range tests {
+		cfg := &config.ReaderConfig{SkipPrefix: tt.skip, CardAccept: tt.accept}
+		info, err := ParseCardTracks(cfg, tt.tracks...)
+		if code, _ := common.CheckError(err); code != tt.code {
+			t.Errorf("%s: error %v, want %s", tt.name, err, tt.code)
+		}
+		if info == nil || info.SvcCode != tt.svc {
+			t.Errorf("%s: card info %+v", tt.name, info)
+			continue
+		}
+		if tt.svc != "" && (info.CardPan != testPan || info.ExpDate != "2512" || info.Holder != "CARDHOLDER/TEST") {
+			t.Errorf("%s: card info %+v", tt.name, info)
+		}
+	}
+}
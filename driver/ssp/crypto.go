@@ -0,0 +1,161 @@
+package ssp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+const (
+	sspStex      byte   = 0x7E               // Start of encrypted data
+	sspFixedKey  uint64 = 0x0123456701234567 // Default fixed part of encryption key
+	sspPrimeBits        = 31                 // Bit size of key exchange numbers
+)
+
+// KeyExchange keeps Diffie-Hellman numbers of eSSP key negotiation
+type KeyExchange struct {
+	Generator uint64
+	Modulus   uint64
+	HostRnd   uint64
+	HostInter uint64
+}
+
+// NewKeyExchange makes random prime generator and modulus, generator is larger than modulus
+func NewKeyExchange() (*KeyExchange, error) {
+	gen, err := rand.Prime(rand.Reader, sspPrimeBits)
+	if err != nil {
+		return nil, err
+	}
+	mod, err := rand.Prime(rand.Reader, sspPrimeBits)
+	if err != nil {
+		return nil, err
+	}
+	if gen.Cmp(mod) == 0 {
+		return nil, errors.New("generator is equal to modulus")
+	}
+	if gen.Cmp(mod) < 0 {
+		gen, mod = mod, gen
+	}
+	rnd, err := rand.Int(rand.Reader, big.NewInt(1<<sspPrimeBits))
+	if err != nil {
+		return nil, err
+	}
+	return MakeKeyExchange(gen.Uint64(), mod.Uint64(), rnd.Uint64()), nil
+}
+
+// MakeKeyExchange calculates host intermediate key of the numbers
+func MakeKeyExchange(generator, modulus, hostRnd uint64) *KeyExchange {
+	kx := &KeyExchange{
+		Generator: generator,
+		Modulus:   modulus,
+		HostRnd:   hostRnd,
+	}
+	kx.HostInter = powMod(generator, hostRnd, modulus)
+	return kx
+}
+
+// GetKey calculates negotiated key of slave intermediate key
+func (kx *KeyExchange) GetKey(slaveInter uint64) uint64 {
+	return powMod(slaveInter, kx.HostRnd, kx.Modulus)
+}
+
+func powMod(base, exp, mod uint64) uint64 {
+	x := new(big.Int).SetUint64(base)
+	x.Exp(x, new(big.Int).SetUint64(exp), new(big.Int).SetUint64(mod))
+	return x.Uint64()
+}
+
+// ParseFixedKey converts hex text of fixed key, empty text gives default key
+func ParseFixedKey(text string) (uint64, error) {
+	if text == "" {
+		return sspFixedKey, nil
+	}
+	key, err := strconv.ParseUint(text, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad fixed key '%s'", text)
+	}
+	return key, nil
+}
+
+////////////////////////////////////////////////////////////////
+
+// SspCrypto encrypts packet data by AES-128 with fixed and negotiated key parts
+type SspCrypto struct {
+	block cipher.Block
+	count uint32
+}
+
+func NewSspCrypto(fixedKey, negotiated uint64) (*SspCrypto, error) {
+	key := make([]byte, 16)
+	binary.LittleEndian.PutUint64(key[0:8], fixedKey)
+	binary.LittleEndian.PutUint64(key[8:16], negotiated)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return &SspCrypto{block: block}, nil
+}
+
+// GetCount returns the packet counter of encrypted exchange
+func (sc *SspCrypto) GetCount() uint32 {
+	return sc.count
+}
+
+// Encrypt makes packet data: STEX, encrypted LEN, COUNT, DATA, PACKING, CRC16
+func (sc *SspCrypto) Encrypt(data []byte) ([]byte, error) {
+	size := 1 + 4 + len(data) + 2
+	if pad := size % aes.BlockSize; pad != 0 {
+		size += aes.BlockSize - pad
+	}
+	if len(data) > 0xFF || size+1 > sspMaxData {
+		return nil, errors.New("encrypted data is too long")
+	}
+	plain := make([]byte, size)
+	plain[0] = byte(len(data))
+	binary.LittleEndian.PutUint32(plain[1:5], sc.count)
+	copy(plain[5:], data)
+	if _, err := rand.Read(plain[5+len(data) : size-2]); err != nil {
+		return nil, err
+	}
+	crc := calcSspCRC(plain[:size-2])
+	plain[size-2], plain[size-1] = byte(crc), byte(crc>>8)
+
+	pack := make([]byte, size+1)
+	pack[0] = sspStex
+	for i := 0; i < size; i += aes.BlockSize {
+		sc.block.Encrypt(pack[1+i:1+i+aes.BlockSize], plain[i:i+aes.BlockSize])
+	}
+	return pack, nil
+}
+
+// Decrypt checks reply packet and returns its plain data, slave reply has the count
+// of host packet and both sides increment the count after successful exchange
+func (sc *SspCrypto) Decrypt(pack []byte) ([]byte, error) {
+	if len(pack) < 1+aes.BlockSize || pack[0] != sspStex || (len(pack)-1)%aes.BlockSize != 0 {
+		return nil, errors.New("wrong encrypted packet")
+	}
+	size := len(pack) - 1
+	plain := make([]byte, size)
+	for i := 0; i < size; i += aes.BlockSize {
+		sc.block.Decrypt(plain[i:i+aes.BlockSize], pack[1+i:1+i+aes.BlockSize])
+	}
+	crc1 := calcSspCRC(plain[:size-2])
+	crc2 := uint16(plain[size-2]) | uint16(plain[size-1])<<8
+	if crc1 != crc2 {
+		return nil, errors.New("encrypted CRC mismatch")
+	}
+	length := int(plain[0])
+	if 5+length > size-2 {
+		return nil, errors.New("wrong encrypted data length")
+	}
+	if count := binary.LittleEndian.Uint32(plain[1:5]); count != sc.count {
+		return nil, fmt.Errorf("encrypted count %d is not %d", count, sc.count)
+	}
+	sc.count++
+	return plain[5 : 5+length], nil
+}
@@ -0,0 +1,176 @@
+package ssp
+
+import (
+	"bytes"
+	"crypto/aes"
+	"github.com/iftsoft/device/common"
+	"testing"
+)
+
+func TestKeyExchange(t *testing.T) {
+	const slaveRnd = 0x1234567
+	host := MakeKeyExchange(982451653, 899809363, 0x2345678)
+	slaveInter := powMod(host.Generator, slaveRnd, host.Modulus)
+	slaveKey := powMod(host.HostInter, slaveRnd, host.Modulus)
+	if key := host.GetKey(slaveInter); key != slaveKey {
+		t.Errorf("host key %X, slave key %X", key, slaveKey)
+	}
+
+	kx, err := NewKeyExchange()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kx.Generator <= kx.Modulus {
+		t.Errorf("generator %d is not larger than modulus %d", kx.Generator, kx.Modulus)
+	}
+	if kx.HostInter != powMod(kx.Generator, kx.HostRnd, kx.Modulus) {
+		t.Errorf("wrong host intermediate key %X", kx.HostInter)
+	}
+}
+
+func TestParseFixedKey(t *testing.T) {
+	if key, err := ParseFixedKey(""); err != nil || key != sspFixedKey {
+		t.Errorf("default key %X: %v", key, err)
+	}
+	if key, err := ParseFixedKey("0123456701234567"); err != nil || key != 0x0123456701234567 {
+		t.Errorf("key %X: %v", key, err)
+	}
+	if _, err := ParseFixedKey("not a key"); err == nil {
+		t.Error("bad key is parsed")
+	}
+}
+
+// Host and slave keep their own counters, both count packets of successful exchanges
+func TestSspCrypto(t *testing.T) {
+	const negotiated = 0x5A5A5A5A
+	host, err := NewSspCrypto(sspFixedKey, negotiated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slave, _ := NewSspCrypto(sspFixedKey, negotiated)
+	for i, data := range [][]byte{{0x07}, {0x11, 0x7F, 0x00}, bytes.Repeat([]byte{0xA5}, 40)} {
+		pack, err := host.Encrypt(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pack[0] != sspStex || (len(pack)-1)%aes.BlockSize != 0 {
+			t.Fatalf("packet % X has wrong format", pack)
+		}
+		plain, err := slave.Decrypt(pack)
+		if err != nil || !bytes.Equal(plain, data) {
+			t.Fatalf("slave got % X: %v", plain, err)
+		}
+		// Reply goes with the count of host packet
+		slave.count--
+		reply, err := slave.Encrypt([]byte{0xF0})
+		slave.count++
+		if err != nil {
+			t.Fatal(err)
+		}
+		plain, err = host.Decrypt(reply)
+		if err != nil || !bytes.Equal(plain, []byte{0xF0}) {
+			t.Fatalf("host got % X: %v", plain, err)
+		}
+		if host.GetCount() != uint32(i+1) || slave.GetCount() != uint32(i+1) {
+			t.Fatalf("counts %d and %d, want %d", host.GetCount(), slave.GetCount(), i+1)
+		}
+		// Repeated reply has old count
+		if _, err = host.Decrypt(reply); err == nil {
+			t.Error("repeated reply is decrypted")
+		}
+	}
+
+	pack, _ := host.Encrypt([]byte{0x07})
+	pack[3] ^= 0x01
+	if _, err = slave.Decrypt(pack); err == nil {
+		t.Error("broken packet is decrypted")
+	}
+	other, _ := NewSspCrypto(sspFixedKey, negotiated+1)
+	pack, _ = host.Encrypt([]byte{0x07})
+	if _, err = other.Decrypt(pack); err == nil {
+		t.Error("packet is decrypted by other key")
+	}
+	if _, err = host.Encrypt(make([]byte, sspMaxData)); err == nil {
+		t.Error("too long data is encrypted")
+	}
+}
+
+func TestEncodeFrame(t *testing.T) {
+	// SYNC command to slave 0 with sequence flag set
+	sync := []byte{0x7F, 0x80, 0x01, 0x11, 0x65, 0x82}
+	if pack := encodeFrame(sspSeqFlag, []byte{0x11}); !bytes.Equal(pack, sync) {
+		t.Errorf("SYNC frame % X, want % X", pack, sync)
+	}
+	data := []byte{0x7F, 0x01, 0x7F, 0x7F}
+	pack := encodeFrame(0x00, data)
+	if n := bytes.Count(pack[1:], []byte{sspStx, sspStx}); n < 3 {
+		t.Errorf("frame % X has %d stuffed bytes", pack, n)
+	}
+	size, seq, back, err := decodeFrame(append(pack, 0x7F))
+	if err != nil || size != len(pack) || seq != 0x00 || !bytes.Equal(back, data) {
+		t.Errorf("decoded %d bytes, seq %02X, data % X: %v", size, seq, back, err)
+	}
+}
+
+func TestDecodeFrame(t *testing.T) {
+	pack := encodeFrame(sspSeqFlag|0x10, []byte{0xF0, 0x7F})
+	tests := []struct {
+		name string
+		dump []byte
+		size int
+		data []byte
+		fail bool
+	}{
+		{"garbage", append([]byte{0x01, 0x02}, pack...), 2, nil, false},
+		{"partial", pack[:len(pack)-1], 0, nil, false},
+		{"stuffing at end", pack[:5], 0, nil, false},
+		{"complete", pack, len(pack), []byte{0xF0, 0x7F}, false},
+		{"broken CRC", append(append([]byte{}, pack[:len(pack)-1]...), pack[len(pack)-1]^0xFF), len(pack), nil, true},
+		{"broken stuffing", []byte{0x7F, 0x80, 0x7F, 0x01}, 2, nil, true},
+	}
+	for _, tt := range tests {
+		size, _, data, err := decodeFrame(tt.dump)
+		if size != tt.size || !bytes.Equal(data, tt.data) || (err != nil) != tt.fail {
+			t.Errorf("%s: size %d, data % X, error %v", tt.name, size, data, err)
+		}
+	}
+}
+
+// Replies of other slaves are rejected by framer
+func TestSspFramer(t *testing.T) {
+	framer := &sspFramer{address: 0x10, seq: sspSeqFlag}
+	pack, err := framer.Encode([]byte{0x07})
+	if err != nil || pack[1] != 0x90 {
+		t.Fatalf("frame % X: %v", pack, err)
+	}
+	data, size, err := framer.Decode(encodeFrame(0x10, []byte{0xF0}))
+	if err != nil || size != 6 || !bytes.Equal(data, []byte{0xF0}) {
+		t.Errorf("reply % X of %d bytes: %v", data, size, err)
+	}
+	if _, _, err = framer.Decode(encodeFrame(0x11, []byte{0xF0})); err == nil {
+		t.Error("reply of other slave is taken")
+	}
+	if _, err = framer.Encode(make([]byte, sspMaxData+1)); err == nil {
+		t.Error("too long frame is encoded")
+	}
+}
+
+// Plain reply after key exchange is not taken, encrypted one is decrypted
+func TestOpenReply(t *testing.T) {
+	sp := GetSspProtocol(nil)
+	if back, err := sp.openReply([]byte{rspOk}); err != nil || !bytes.Equal(back, []byte{rspOk}) {
+		t.Errorf("plain reply before key exchange % X: %v", back, err)
+	}
+	sp.crypto, _ = NewSspCrypto(sspFixedKey, 0x5A5A5A5A)
+	slave, _ := NewSspCrypto(sspFixedKey, 0x5A5A5A5A)
+	for _, plain := range [][]byte{{rspOk, 0x01}, {rspKeyNotSet}, {}} {
+		back, err := sp.openReply(plain)
+		if code, _ := common.CheckError(err); code != common.DevErrorSecurityFault || back != nil {
+			t.Errorf("plain reply % X is taken as % X: %v", plain, back, err)
+		}
+	}
+	reply, _ := slave.Encrypt([]byte{rspOk, 0x01})
+	if back, err := sp.openReply(reply); err != nil || !bytes.Equal(back, []byte{rspOk, 0x01}) {
+		t.Errorf("encrypted reply % X: %v", back, err)
+	}
+}
@@ -0,0 +1,170 @@
+package ssp
+
+import (
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/dbase"
+	"github.com/iftsoft/device/dbase/dbvalid"
+	"github.com/iftsoft/device/driver"
+	"time"
+)
+
+type SspDriver struct {
+	SspEngine
+	storage dbase.DBaseLinker
+	begTime int64
+}
+
+func NewSspDriver() *SspDriver {
+	sd := SspDriver{}
+	return &sd
+}
+
+// Implementation of DeviceDriver interface
+func (sd *SspDriver) InitDevice(context *driver.Context) error {
+	sd.initEngine(context.Config)
+	sd.DevName = context.DevName
+	sd.begTime = time.Now().Unix()
+	sd.Log.Debug("SspDriver run cmd:%s", "InitDevice")
+
+	mask := common.ScopeFlagSystem
+	if device, ok := context.Manager.(common.DeviceCallback); ok {
+		sd.CbDevice = device
+		mask |= common.ScopeFlagDevice
+	}
+	if validator, ok := context.Manager.(common.ValidatorCallback); ok {
+		sd.CbValidator = validator
+		mask |= common.ScopeFlagValidator
+	}
+	if context.Storage != nil {
+		sd.storage = context.Storage
+		sd.Booker = dbvalid.NewDBaseValidator(sd.storage, sd.DevName)
+	}
+	if context.Greeting != nil {
+		context.Greeting.DevType = common.DevTypeCashValidator
+		context.Greeting.Required = mask
+	}
+	return nil
+}
+
+func (sd *SspDriver) StartDevice(query *common.SystemConfig) error {
+	sd.Log.Debug("SspDriver run cmd:%s", "StartDeviceLoop")
+	var err error
+	if sd.Config != nil && query != nil {
+		sd.Config.OverwriteConfig(query)
+	}
+	if sd.storage != nil {
+		err = sd.storage.Open()
+	}
+	if err == nil {
+		err = sd.DevStartup()
+	}
+	return err
+}
+func (sd *SspDriver) DeviceTimer(unix int64) error {
+	sd.Log.Trace("SspDriver run cmd:%s", "DeviceTimer")
+	sd.pollValidator()
+	return nil
+}
+func (sd *SspDriver) StopDevice() error {
+	sd.Log.Debug("SspDriver run cmd:%s", "StopDeviceLoop")
+	err := sd.DevCleanup()
+	if sd.storage != nil {
+		_ = sd.storage.Close()
+	}
+	return err
+}
+func (sd *SspDriver) CheckDevice(metrics *common.SystemMetrics) error {
+	sd.Log.Debug("SspDriver run cmd:%s", "CheckDevice")
+	if metrics != nil {
+		metrics.Uptime = time.Now().Unix() - sd.begTime
+		metrics.DevState = sd.DevState
+		metrics.DevError = sd.DevError
+		sd.checkStacker(metrics)
+		sd.protocol.ReportMetrics(metrics)
+	}
+	return nil
+}
+
+// Implementation of common.DeviceManager
+//
+func (sd *SspDriver) Cancel(name string, query *common.DeviceQuery) error {
+	err := sd.DevDisableBills()
+	sd.DevError, sd.DevReply = common.CheckError(err)
+	return sd.RunDeviceReply(common.CmdDeviceCancel)
+}
+func (sd *SspDriver) Reset(name string, query *common.DeviceQuery) error {
+	err := sd.DevReset()
+	sd.DevError, sd.DevReply = common.CheckError(err)
+	return sd.RunDeviceReply(common.CmdDeviceReset)
+}
+func (sd *SspDriver) Status(name string, query *common.DeviceQuery) error {
+	err := sd.DevStatus()
+	sd.DevError, sd.DevReply = common.CheckError(err)
+	return sd.RunDeviceReply(common.CmdDeviceStatus)
+}
+func (sd *SspDriver) RunAction(name string, query *common.DeviceQuery) error {
+	err := sd.DevEnableBills(common.CurrencyNOT, 0)
+	if err == nil {
+		err = sd.DevStatus()
+	}
+	sd.DevError, sd.DevReply = common.CheckError(err)
+	return sd.RunDeviceReply(common.CmdRunAction)
+}
+func (sd *SspDriver) StopAction(name string, query *common.DeviceQuery) error {
+	err := sd.DevDisableBills()
+	if err == nil {
+		err = sd.DevStatus()
+	}
+	sd.DevError, sd.DevReply = common.CheckError(err)
+	return sd.RunDeviceReply(common.CmdStopAction)
+}
+
+// Implementation of common.ValidatorManager
+//
+func (sd *SspDriver) InitValidator(name string, query *common.ValidatorQuery) error {
+	err := sd.DevReset()
+	if err == nil {
+		err = sd.DevInitBillList(query.Currency)
+	}
+	sd.DevError, sd.DevReply = common.CheckError(err)
+	return sd.RunValidatorStore(common.CmdInitValidator)
+}
+func (sd *SspDriver) DoValidate(name string, query *common.ValidatorQuery) error {
+	err := sd.DevEnableBills(query.Currency, query.Target)
+	if err == nil {
+		err = sd.DevStatus()
+	}
+	sd.DevError, sd.DevReply = common.CheckError(err)
+	return sd.RunValidatorStore(common.CmdDoValidate)
+}
+func (sd *SspDriver) NoteAccept(name string, query *common.ValidatorQuery) error {
+	err := sd.DevNoteAccept()
+	sd.DevError, sd.DevReply = common.CheckError(err)
+	return err
+}
+func (sd *SspDriver) NoteReturn(name string, query *common.ValidatorQuery) error {
+	err := sd.DevNoteReturn()
+	sd.DevError, sd.DevReply = common.CheckError(err)
+	return err
+}
+func (sd *SspDriver) StopValidate(name string, query *common.ValidatorQuery) error {
+	err := sd.DevDisableBills()
+	if err == nil {
+		err = sd.DevStatus()
+	}
+	sd.DevError, sd.DevReply = common.CheckError(err)
+	return sd.RunValidatorStore(common.CmdStopValidate)
+}
+func (sd *SspDriver) CheckValidator(name string, query *common.ValidatorQuery) error {
+	err := sd.DevCheckBatch()
+	sd.DevError, sd.DevReply = common.CheckError(err)
+	return sd.RunValidatorStore(common.CmdCheckValidator)
+}
+func (sd *SspDriver) ClearValidator(name string, query *common.ValidatorQuery) error {
+	err := sd.DevClearBatch()
+	if err == nil {
+		err = sd.DevCheckBatch()
+	}
+	sd.DevError, sd.DevReply = common.CheckError(err)
+	return sd.RunValidatorStore(common.CmdClearValidator)
+}
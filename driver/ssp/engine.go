@@ -0,0 +1,346 @@
+package ssp
+
+import (
+	"fmt"
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/config"
+	"github.com/iftsoft/device/driver/validator"
+	"time"
+)
+
+const (
+	sspHoldPeriod = 5 * time.Second // Validator credits escrowed note in 10 seconds without HOLD
+	sspSyncWait   = 25              // Sync count to wait for validator restart
+)
+
+type SspEngine struct {
+	validator.CashEngine
+	protocol *SspProtocol
+	setup    *SetupData
+	enabled  bool
+	holdTime time.Time
+}
+
+func (se *SspEngine) initEngine(cfg *config.DeviceConfig) *SspEngine {
+	se.InitCashEngine(cfg)
+	se.protocol = GetSspProtocol(se.GetLinkerConfig())
+	return se
+}
+
+// Currency by config or the first one of channel dataset
+func (se *SspEngine) getDefaultCurrency() common.DevCurrency {
+	if curr := se.GetValidatorConfig().CurrCode; curr != common.CurrencyNOT {
+		return curr
+	}
+	if se.setup != nil && len(se.setup.Channels) > 0 {
+		return se.setup.Channels[0].Currency
+	}
+	return common.CurrencyNOT
+}
+
+// Put stacker counters, alert and encryption mode to device metrics
+func (se *SspEngine) checkStacker(metrics *common.SystemMetrics) {
+	se.CheckStacker(metrics)
+	if se.protocol.IsEncrypted() {
+		metrics.Topics["encryption"] = "eSSP"
+	} else {
+		metrics.Topics["encryption"] = "none"
+	}
+}
+
+// Read channel dataset from validator and rebuild note tables and escrow policy
+func (se *SspEngine) loadChannels() error {
+	setup, err := se.protocol.SetupRequest()
+	if err != nil {
+		return err
+	}
+	se.Log.Debug(setup.String())
+	tables := validator.NoteTables{}
+	for _, ch := range setup.Channels {
+		if tables[ch.Currency] == nil {
+			tables[ch.Currency] = setup.Channels.GetNoteList(ch.Currency)
+		}
+	}
+	// Notes mask is applied to channels by validator inhibits
+	err = se.SetNoteTables(tables)
+	if err == nil {
+		se.setup = setup
+	}
+	return err
+}
+
+// Synchronize with validator, negotiate encryption key and set protocol version
+func (se *SspEngine) connect() error {
+	var err error
+	for i := 0; i < sspSyncWait; i++ {
+		if err = se.protocol.Sync(); err == nil {
+			break
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	if err != nil {
+		return err
+	}
+	err = se.protocol.KeyExchange()
+	if code, _ := common.CheckError(err); code == common.DevErrorCommandFault {
+		se.Log.Warn("SspEngine validator does not support encryption")
+		err = nil
+	}
+	if err == nil {
+		err = se.protocol.HostProtocolVersion(sspProtocolVersion)
+	}
+	if err == nil {
+		err = se.protocol.Disable()
+	}
+	se.enabled = false
+	return err
+}
+
+////////////////////////////////////////////////////////////////
+
+func (se *SspEngine) DevStartup() error {
+	if lnkCfg := se.GetLinkerConfig(); lnkCfg != nil {
+		key, err := ParseFixedKey(lnkCfg.CryptKey)
+		if err != nil {
+			return common.ExtendError(common.DevErrorConfigFault, err)
+		}
+		se.protocol.SetFixedKey(key)
+	}
+	err := se.protocol.OpenLink()
+	if err == nil {
+		err = se.connect()
+	}
+	if err == nil {
+		var serial uint32
+		serial, err = se.protocol.GetSerialNumber()
+		se.Log.Info("SSP validator serial number %d", serial)
+	}
+	if err == nil {
+		err = se.loadChannels()
+	}
+	if err == nil {
+		err = se.DevCheckBatch()
+	}
+	if err == nil {
+		_ = se.RunStateChanged(common.DevStateStandby)
+	}
+	se.Accept.Currency = se.getDefaultCurrency()
+	return err
+}
+
+func (se *SspEngine) DevCleanup() error {
+	if se.enabled {
+		_ = se.protocol.Disable()
+		se.enabled = false
+	}
+	return se.protocol.CloseLink()
+}
+
+// Reset restarts validator, so encryption key is negotiated again
+func (se *SspEngine) DevReset() error {
+	se.EscrowWait = time.Time{}
+	err := se.protocol.Reset()
+	if err == nil {
+		time.Sleep(time.Second)
+		err = se.connect()
+	}
+	return err
+}
+
+func (se *SspEngine) DevStatus() error {
+	if code := se.DevError; code != common.DevErrorSuccess {
+		return common.NewError(code, se.DevReply)
+	}
+	return nil
+}
+
+func (se *SspEngine) DevEnableBills(curr common.DevCurrency, target common.DevAmount) error {
+	if curr == common.CurrencyNOT {
+		curr = se.Accept.Currency
+	}
+	se.Log.Debug("SspEngine Set currency %d - %s, target %s", curr, curr.String(), target.Format(curr))
+	if se.setup == nil {
+		return common.NewError(common.DevErrorNotInitialized, "channel dataset is not loaded")
+	}
+	mask := se.setup.Channels.GetMask(curr, se.GetValidatorConfig().NotesMask)
+	if mask == 0 {
+		return common.NewError(common.DevErrorNoCurrency,
+			fmt.Sprintf("no enabled channels for currency %d (%s)", curr, curr.IsoCode()))
+	}
+	err := se.CheckSession(target)
+	if err == nil {
+		err = se.protocol.SetInhibits(mask)
+	}
+	if err == nil {
+		err = se.protocol.Enable()
+	}
+	if err == nil {
+		se.enabled = true
+		se.StartSession(curr, target)
+	}
+	return err
+}
+
+func (se *SspEngine) DevDisableBills() error {
+	if se.CancelEscrow() {
+		_ = se.protocol.RejectBanknote()
+	}
+	err := se.protocol.Disable()
+	if err == nil {
+		se.enabled = false
+	}
+	return err
+}
+
+func (se *SspEngine) DevInitBillList(curr common.DevCurrency) error {
+	err := se.loadChannels()
+	if err != nil {
+		return err
+	}
+	if curr == common.CurrencyNOT {
+		curr = se.getDefaultCurrency()
+	}
+	return se.InitNoteList(curr)
+}
+
+// Note in escrow is credited by the next poll
+func (se *SspEngine) DevNoteAccept() error {
+	return se.HostDecision(common.NoteDecisionAccept, "accepted by host")
+}
+
+func (se *SspEngine) DevNoteReturn() error {
+	err := se.HostDecision(common.NoteDecisionReturn, "returned by host")
+	if err == nil {
+		err = se.protocol.RejectBanknote()
+	}
+	return err
+}
+
+////////////////////////////////////////////////////////////////
+
+// Poll validator and process its events, escrowed note is held while the host is deciding
+func (se *SspEngine) pollValidator() {
+	if !se.protocol.IsOpen() {
+		return
+	}
+	if !se.EscrowWait.IsZero() {
+		se.holdEscrow()
+		if se.CheckEscrowWait() {
+			se.applyDecision()
+		}
+		return
+	}
+	events, err := se.protocol.Poll()
+	if err != nil {
+		code, text := common.CheckError(err)
+		if code != se.DevError {
+			_ = se.RunExecuteError(code, text)
+		}
+		// Validator lost encryption key after restart
+		if code == common.DevErrorSecurityFault {
+			_ = se.connect()
+		}
+		return
+	}
+	se.processEvents(events)
+}
+
+func (se *SspEngine) processEvents(events []sspEvent) {
+	failed := false
+	for _, ev := range events {
+		se.Log.Debug("SspEngine event %s", ev.String())
+		if state := ev.GetState(); state != common.DevStateUndefined {
+			_ = se.RunStateChanged(state)
+		}
+		if prompt := ev.GetPrompt(); prompt != common.DevPromptNone && prompt != se.DevPrompt {
+			_ = se.RunActionPrompt(prompt)
+		}
+		if code := ev.GetError(); code != common.DevErrorSuccess {
+			failed = true
+			if code != se.DevError {
+				_ = se.RunExecuteError(code, ev.String())
+			}
+		}
+		switch ev.code {
+		case evRead:
+			if ev.Channel() > 0 {
+				se.onNoteEscrowed(ev.Channel())
+			}
+		case evCredit:
+			se.onNoteStacked(ev.Channel(), "")
+		case evClearedToCashbox:
+			se.onNoteStacked(ev.Channel(), "cleared into cashbox")
+		case evRejected:
+			se.ReturnNote()
+		case evSlaveReset:
+			se.enabled = false
+		}
+	}
+	if !failed {
+		se.DevError = common.DevErrorSuccess
+	}
+	if len(events) == 0 && se.enabled {
+		_ = se.RunStateChanged(common.DevStateWaiting)
+		if se.DevPrompt != common.DevPromptCashInsertBill {
+			_ = se.RunActionPrompt(common.DevPromptCashInsertBill)
+		}
+	}
+}
+
+func (se *SspEngine) setAcceptNote(channel int) bool {
+	if se.setup == nil {
+		return false
+	}
+	ch := se.setup.Channels.GetChannel(channel)
+	if ch == nil {
+		return false
+	}
+	se.Accept.Currency = ch.Currency
+	se.Accept.Nominal = ch.Nominal
+	se.Accept.Count = 1
+	se.Accept.Amount = ch.Nominal
+	return true
+}
+
+func (se *SspEngine) onNoteEscrowed(channel int) {
+	if !se.setAcceptNote(channel) {
+		se.ClearNote()
+	}
+	se.holdTime = time.Time{}
+	se.DecideNote()
+	se.applyDecision()
+}
+
+// Accepted note is credited by the next poll, returned note is rejected
+func (se *SspEngine) applyDecision() {
+	if se.Accept.Decision != common.NoteDecisionReturn {
+		return
+	}
+	if err := se.protocol.RejectBanknote(); err != nil {
+		code, text := common.CheckError(err)
+		_ = se.RunExecuteError(code, text)
+	}
+}
+
+// Keep note in escrow while the host is deciding
+func (se *SspEngine) holdEscrow() {
+	if time.Since(se.holdTime) < sspHoldPeriod {
+		return
+	}
+	se.holdTime = time.Now()
+	if err := se.protocol.Hold(); err != nil {
+		code, text := common.CheckError(err)
+		_ = se.RunExecuteError(code, text)
+	}
+}
+
+func (se *SspEngine) onNoteStacked(channel int, reason string) {
+	if se.Accept.Nominal == 0 && !se.setAcceptNote(channel) {
+		se.Log.Warn("SspEngine unknown channel %d is stacked", channel)
+		return
+	}
+	if reason != "" {
+		se.Accept.Decision, se.Accept.Reason = common.NoteDecisionAccept, reason
+	}
+	se.StoreNote()
+}
@@ -0,0 +1,134 @@
+package ssp
+
+import (
+	"errors"
+	"fmt"
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/config"
+	"github.com/iftsoft/device/linker"
+)
+
+const (
+	sspStx     byte = 0x7F // Frame start byte, it is doubled inside the frame
+	sspSeqFlag byte = 0x80 // Sequence flag of SEQ/ID byte
+	sspMaxData      = 255
+)
+
+// sspFramer puts SEQ/ID byte of slave address and sequence flag, replies of other slaves are dropped.
+// Sequence flag is toggled by protocol for every new packet, retries go with the same flag.
+type sspFramer struct {
+	address byte
+	seq     byte
+}
+
+func newSspFramer(cfg *config.LinkerConfig) *sspFramer {
+	f := &sspFramer{}
+	if cfg != nil {
+		f.address = cfg.Address & 0x7F
+	}
+	return f
+}
+
+////////////////////////////////////////////////////////////////
+// Data flow:  STX, SEQ/ID, LEN, []DATA, CRC16 (LSB first)
+// Any STX byte after the first one is stuffed by another STX byte
+
+func (f *sspFramer) Encode(data []byte) ([]byte, error) {
+	if len(data) > sspMaxData {
+		return nil, common.NewError(common.DevErrorProtocolFault, "frame is too long")
+	}
+	return encodeFrame(f.seq|f.address, data), nil
+}
+
+func (f *sspFramer) Decode(dump []byte) ([]byte, int, error) {
+	size, seq, data, err := decodeFrame(dump)
+	if err != nil {
+		return nil, size, common.ExtendError(common.DevErrorProtocolFault, err)
+	}
+	if data != nil && seq&0x7F != f.address {
+		return nil, size, common.NewError(common.DevErrorProtocolFault,
+			fmt.Sprintf("reply from address %02X", seq&0x7F))
+	}
+	return data, size, nil
+}
+
+// Validator may miss a packet, it is repeated with the same sequence flag
+func getSspOptions() *linker.HalfDuplexOptions {
+	opts := linker.GetDefaultHalfDuplexOptions()
+	opts.Retries = 2
+	opts.Timeout = 500
+	return opts
+}
+
+////////////////////////////////////////////////////////////////
+
+// Make stuffed frame of SEQ/ID byte and data
+func encodeFrame(seq byte, data []byte) []byte {
+	body := append([]byte{seq, byte(len(data))}, data...)
+	crc := calcSspCRC(body)
+	body = append(body, byte(crc), byte(crc>>8))
+	pack := []byte{sspStx}
+	for _, b := range body {
+		pack = append(pack, b)
+		if b == sspStx {
+			pack = append(pack, sspStx)
+		}
+	}
+	return pack
+}
+
+// Parse stuffed frame from the head of dump. Returns count of bytes used,
+// SEQ/ID byte and frame data; nil data without error means frame is not complete.
+func decodeFrame(dump []byte) (int, byte, []byte, error) {
+	size := len(dump)
+	if size == 0 {
+		return 0, 0, nil, nil
+	}
+	// Looking for STX
+	if dump[0] != sspStx {
+		for i := 1; i < size; i++ {
+			if dump[i] == sspStx {
+				return i, 0, nil, nil
+			}
+		}
+		return size, 0, nil, nil
+	}
+	body := make([]byte, 0, size)
+	for i := 1; i < size; i++ {
+		if dump[i] == sspStx {
+			if i+1 == size {
+				return 0, 0, nil, nil
+			}
+			if dump[i+1] != sspStx {
+				return i, 0, nil, errors.New("frame is broken by STX")
+			}
+			i++
+		}
+		body = append(body, dump[i])
+		if len(body) >= 2 && len(body) == int(body[1])+4 {
+			crc1 := calcSspCRC(body[:len(body)-2])
+			crc2 := uint16(body[len(body)-2]) | uint16(body[len(body)-1])<<8
+			if crc1 != crc2 {
+				return i + 1, 0, nil, errors.New("CRC mismatch")
+			}
+			return i + 1, body[0], body[2 : len(body)-2], nil
+		}
+	}
+	return 0, 0, nil, nil
+}
+
+// SSP CRC16 has polynomial 0x8005 and seed 0xFFFF
+func calcSspCRC(data []byte) uint16 {
+	var crc uint16 = 0xFFFF
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x8005
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
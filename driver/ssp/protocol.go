@@ -0,0 +1,405 @@
+package ssp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/config"
+	"github.com/iftsoft/device/core"
+	"github.com/iftsoft/device/linker"
+	"strings"
+	"sync"
+)
+
+// SSP commands
+const (
+	cmdReset               byte = 0x01
+	cmdSetInhibits         byte = 0x02
+	cmdSetupRequest        byte = 0x05
+	cmdHostProtocolVersion byte = 0x06
+	cmdPoll                byte = 0x07
+	cmdRejectBanknote      byte = 0x08
+	cmdDisable             byte = 0x09
+	cmdEnable              byte = 0x0A
+	cmdGetSerialNumber     byte = 0x0C
+	cmdSync                byte = 0x11
+	cmdHold                byte = 0x18
+	cmdSetGenerator        byte = 0x4A
+	cmdSetModulus          byte = 0x4B
+	cmdRequestKeyExchange  byte = 0x4C
+
+	sspProtocolVersion = 6 // Version of setup data and poll events
+)
+
+// SSP generic responses
+const (
+	rspOk              byte = 0xF0
+	rspUnknownCommand  byte = 0xF2
+	rspWrongParameters byte = 0xF3
+	rspOutOfRange      byte = 0xF4
+	rspCannotProcess   byte = 0xF5
+	rspSoftwareError   byte = 0xF6
+	rspFail            byte = 0xF8
+	rspKeyNotSet       byte = 0xFA
+)
+
+// Channel describes the note channel of the dataset
+type Channel struct {
+	Channel  int
+	Currency common.DevCurrency
+	Nominal  common.DevAmount
+}
+
+func (ch *Channel) String() string {
+	if ch == nil {
+		return ""
+	}
+	return fmt.Sprintf("Channel %2d: %9s %s", ch.Channel, ch.Nominal.Format(ch.Currency), ch.Currency.IsoCode())
+}
+
+type ChannelTable []*Channel
+
+// SetupData is the reply of setup request of protocol version 6
+type SetupData struct {
+	UnitType byte
+	Firmware string
+	Country  string
+	Protocol byte
+	Channels ChannelTable
+}
+
+func (sd *SetupData) String() string {
+	if sd == nil {
+		return ""
+	}
+	str := fmt.Sprintf("SSP unit type %02X, firmware %s, country %s, protocol %d:",
+		sd.UnitType, sd.Firmware, sd.Country, sd.Protocol)
+	for _, ch := range sd.Channels {
+		str += "\n    " + ch.String()
+	}
+	return str
+}
+
+type SspProtocol struct {
+	*linker.HalfDuplex
+	log      *core.LogAgent
+	framer   *sspFramer
+	fixedKey uint64
+	crypto   *SspCrypto
+	lock     sync.Mutex
+}
+
+func GetSspProtocol(cfg *config.LinkerConfig) *SspProtocol {
+	framer := newSspFramer(cfg)
+	sp := &SspProtocol{
+		HalfDuplex: linker.NewHalfDuplex(cfg, framer, getSspOptions(), "SSP"),
+		log:        core.GetLogAgent(core.LogLevelDump, "SSP"),
+		framer:     framer,
+		fixedKey:   sspFixedKey,
+	}
+	return sp
+}
+
+// SetFixedKey sets fixed part of encryption key for next key exchange
+func (sp *SspProtocol) SetFixedKey(key uint64) {
+	sp.fixedKey = key
+}
+
+// IsEncrypted is true when encryption key is negotiated
+func (sp *SspProtocol) IsEncrypted() bool {
+	return sp.crypto != nil
+}
+
+////////////////////////////////////////////////////////////////
+
+// Sync resets sequence flag, encryption is dropped as slave does the same
+func (sp *SspProtocol) Sync() error {
+	sp.lock.Lock()
+	sp.crypto = nil
+	sp.framer.seq = 0
+	sp.lock.Unlock()
+	_, err := sp.command(cmdSync, nil)
+	sp.logError("Sync", err)
+	return err
+}
+
+func (sp *SspProtocol) Reset() error {
+	_, err := sp.command(cmdReset, nil)
+	sp.logError("Reset", err)
+	return err
+}
+
+func (sp *SspProtocol) Poll() ([]sspEvent, error) {
+	back, err := sp.command(cmdPoll, nil)
+	var events []sspEvent
+	if err == nil {
+		events, err = parsePollEvents(back)
+		if err != nil {
+			sp.log.Warn("SspProtocol.Poll %s", err)
+			err = nil
+		}
+	}
+	sp.logError("Poll", err)
+	return events, err
+}
+
+// Hold keeps the note in escrow, it is sent instead of poll
+func (sp *SspProtocol) Hold() error {
+	_, err := sp.command(cmdHold, nil)
+	sp.logError("Hold", err)
+	return err
+}
+
+func (sp *SspProtocol) RejectBanknote() error {
+	_, err := sp.command(cmdRejectBanknote, nil)
+	sp.logError("RejectBanknote", err)
+	return err
+}
+
+func (sp *SspProtocol) Enable() error {
+	_, err := sp.command(cmdEnable, nil)
+	sp.logError("Enable", err)
+	return err
+}
+
+func (sp *SspProtocol) Disable() error {
+	_, err := sp.command(cmdDisable, nil)
+	sp.logError("Disable", err)
+	return err
+}
+
+// SetInhibits enables channels by mask, bit 0 is channel 1
+func (sp *SspProtocol) SetInhibits(mask uint16) error {
+	_, err := sp.command(cmdSetInhibits, []byte{byte(mask), byte(mask >> 8)})
+	sp.logError("SetInhibits", err)
+	return err
+}
+
+func (sp *SspProtocol) HostProtocolVersion(version byte) error {
+	_, err := sp.command(cmdHostProtocolVersion, []byte{version})
+	sp.logError("HostProtocolVersion", err)
+	return err
+}
+
+func (sp *SspProtocol) GetSerialNumber() (uint32, error) {
+	back, err := sp.command(cmdGetSerialNumber, nil)
+	var serial uint32
+	if err == nil {
+		if len(back) != 4 {
+			err = common.NewError(common.DevErrorProtocolFault, "wrong serial number reply")
+		} else {
+			serial = binary.BigEndian.Uint32(back)
+		}
+	}
+	sp.logError("GetSerialNumber", err)
+	return serial, err
+}
+
+// SetupRequest reads unit setup and channel dataset
+func (sp *SspProtocol) SetupRequest() (*SetupData, error) {
+	back, err := sp.command(cmdSetupRequest, nil)
+	var setup *SetupData
+	if err == nil {
+		setup, err = parseSetupData(back)
+	}
+	sp.logError("SetupRequest", err)
+	return setup, err
+}
+
+// KeyExchange negotiates encryption key, next commands are encrypted
+func (sp *SspProtocol) KeyExchange() error {
+	kx, err := NewKeyExchange()
+	if err != nil {
+		return common.ExtendError(common.DevErrorSecurityFault, err)
+	}
+	_, err = sp.command(cmdSetGenerator, putUint64(kx.Generator))
+	if err == nil {
+		_, err = sp.command(cmdSetModulus, putUint64(kx.Modulus))
+	}
+	var back []byte
+	if err == nil {
+		back, err = sp.command(cmdRequestKeyExchange, putUint64(kx.HostInter))
+	}
+	if err == nil && len(back) != 8 {
+		err = common.NewError(common.DevErrorProtocolFault, "wrong key exchange reply")
+	}
+	if err == nil {
+		var crypto *SspCrypto
+		crypto, err = NewSspCrypto(sp.fixedKey, kx.GetKey(binary.LittleEndian.Uint64(back)))
+		if err == nil {
+			sp.lock.Lock()
+			sp.crypto = crypto
+			sp.lock.Unlock()
+		} else {
+			err = common.ExtendError(common.DevErrorSecurityFault, err)
+		}
+	}
+	sp.logError("KeyExchange", err)
+	return err
+}
+
+////////////////////////////////////////////////////////////////
+
+func (sp *SspProtocol) logError(cmd string, err error) {
+	code, text := common.CheckError(err)
+	sp.log.Trace("SspProtocol.%s return: %d - %s", cmd, code, text)
+}
+
+// Send command and check generic response, data after OK response is returned
+func (sp *SspProtocol) command(cmd byte, data []byte) ([]byte, error) {
+	back, err := sp.exchange(append([]byte{cmd}, data...))
+	if err != nil {
+		return nil, err
+	}
+	if len(back) == 0 {
+		return nil, common.NewError(common.DevErrorProtocolFault, "empty reply")
+	}
+	switch back[0] {
+	case rspOk:
+		return back[1:], nil
+	case rspUnknownCommand:
+		return nil, common.NewError(common.DevErrorCommandFault, fmt.Sprintf("unknown command %02X", cmd))
+	case rspWrongParameters, rspOutOfRange:
+		return nil, common.NewError(common.DevErrorBadArgument, fmt.Sprintf("wrong parameters of command %02X", cmd))
+	case rspCannotProcess:
+		return nil, common.NewError(common.DevErrorExecuteFault, fmt.Sprintf("command %02X cannot be processed", cmd))
+	case rspKeyNotSet:
+		return nil, common.NewError(common.DevErrorSecurityFault, "encryption key is not set")
+	case rspSoftwareError, rspFail:
+		return nil, common.NewError(common.DevErrorHardwareFault, fmt.Sprintf("command %02X failed", cmd))
+	default:
+		return nil, common.NewError(common.DevErrorProtocolFault, fmt.Sprintf("unexpected response %02X", back[0]))
+	}
+}
+
+// Send packet with retries on timeout, sequence flag is toggled for every new packet
+func (sp *SspProtocol) exchange(data []byte) ([]byte, error) {
+	sp.lock.Lock()
+	defer sp.lock.Unlock()
+	sp.log.Dump("SspProtocol writeData data : %s", core.GetBinaryDump(data))
+	var err error
+	if sp.crypto != nil {
+		data, err = sp.crypto.Encrypt(data)
+		if err != nil {
+			return nil, common.ExtendError(common.DevErrorSecurityFault, err)
+		}
+	}
+	sp.framer.seq ^= sspSeqFlag
+	back, err := sp.Exchange(data)
+	if err != nil {
+		return nil, err
+	}
+	return sp.openReply(back)
+}
+
+// Reply must be encrypted after key exchange, plain one is rejected and the key is negotiated again
+func (sp *SspProtocol) openReply(back []byte) ([]byte, error) {
+	if sp.crypto == nil {
+		return back, nil
+	}
+	if len(back) == 0 || back[0] != sspStex {
+		sp.log.Warn("SspProtocol unencrypted reply : %s", core.GetBinaryDump(back))
+		return nil, common.NewError(common.DevErrorSecurityFault, "unencrypted reply")
+	}
+	back, err := sp.crypto.Decrypt(back)
+	if err != nil {
+		return nil, common.ExtendError(common.DevErrorSecurityFault, err)
+	}
+	sp.log.Dump("SspProtocol decrypted data : %s", core.GetBinaryDump(back))
+	return back, nil
+}
+
+////////////////////////////////////////////////////////////////
+
+func putUint64(value uint64) []byte {
+	data := make([]byte, 8)
+	binary.LittleEndian.PutUint64(data, value)
+	return data
+}
+
+// Setup data: unit type, firmware (4 chars), country (3 chars), value multiplier (3 bytes),
+// channel count, channel values, channel security, real value multiplier (3 bytes), protocol,
+// then channel currencies (3 chars) and expanded channel values (4 bytes) for each channel
+func parseSetupData(data []byte) (*SetupData, error) {
+	if len(data) < 12 {
+		return nil, common.NewError(common.DevErrorProtocolFault, "setup data is too short")
+	}
+	count := int(data[11])
+	if count > 16 || len(data) < 16+9*count {
+		return nil, common.NewError(common.DevErrorProtocolFault,
+			fmt.Sprintf("setup data has no dataset of %d channels", count))
+	}
+	setup := &SetupData{
+		UnitType: data[0],
+		Firmware: strings.TrimSpace(string(data[1:5])),
+		Country:  strings.TrimSpace(string(data[5:8])),
+		Protocol: data[15+2*count],
+		Channels: make(ChannelTable, 0, count),
+	}
+	pos := 12 + 2*count
+	multiplier := int64(data[pos])<<16 | int64(data[pos+1])<<8 | int64(data[pos+2])
+	if multiplier == 0 {
+		multiplier = 1
+	}
+	currPos, valuePos := 16+2*count, 16+5*count
+	for i := 0; i < count; i++ {
+		curr, err := common.ParseCurrency(string(data[currPos+3*i : currPos+3*i+3]))
+		if err != nil || curr == common.CurrencyNOT {
+			return nil, common.NewError(common.DevErrorProtocolFault,
+				fmt.Sprintf("unknown currency of channel %d", i+1))
+		}
+		value := int64(binary.LittleEndian.Uint32(data[valuePos+4*i:]))
+		if value == 0 {
+			continue
+		}
+		setup.Channels = append(setup.Channels, &Channel{
+			Channel:  i + 1,
+			Currency: curr,
+			Nominal:  common.DevAmount(value * multiplier),
+		})
+	}
+	return setup, nil
+}
+
+// GetNoteList returns unique nominals of the currency in channel order
+func (ct ChannelTable) GetNoteList(curr common.DevCurrency) common.ValidNoteList {
+	list := make(common.ValidNoteList, 0)
+	for _, ch := range ct {
+		if ch.Currency != curr {
+			continue
+		}
+		dup := false
+		for _, note := range list {
+			dup = dup || note.Nominal == ch.Nominal
+		}
+		if !dup {
+			list = append(list, &common.ValidatorNote{Currency: curr, Nominal: ch.Nominal})
+		}
+	}
+	return list
+}
+
+// GetMask returns inhibit mask of the currency filtered by notes mask, zero notes mask enables all
+func (ct ChannelTable) GetMask(curr common.DevCurrency, notesMask int64) uint16 {
+	var mask uint16
+	for _, ch := range ct {
+		bit := uint(ch.Channel - 1)
+		if ch.Currency != curr || bit >= 16 {
+			continue
+		}
+		if notesMask == 0 || notesMask&(1<<bit) != 0 {
+			mask |= 1 << bit
+		}
+	}
+	return mask
+}
+
+// GetChannel returns the channel 1..16 or nil
+func (ct ChannelTable) GetChannel(channel int) *Channel {
+	for _, ch := range ct {
+		if ch.Channel == channel {
+			return ch
+		}
+	}
+	return nil
+}
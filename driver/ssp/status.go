@@ -0,0 +1,235 @@
+package ssp
+
+import (
+	"fmt"
+	"github.com/iftsoft/device/common"
+)
+
+// SSP poll events
+const (
+	evSlaveReset        byte = 0xF1
+	evRead              byte = 0xEF
+	evCredit            byte = 0xEE
+	evRejecting         byte = 0xED
+	evRejected          byte = 0xEC
+	evStacking          byte = 0xCC
+	evStacked           byte = 0xEB
+	evSafeJam           byte = 0xEA
+	evUnsafeJam         byte = 0xE9
+	evDisabled          byte = 0xE8
+	evStackerFull       byte = 0xE7
+	evFraudAttempt      byte = 0xE6
+	evTicketValidated   byte = 0xE5
+	evCashboxReplaced   byte = 0xE4
+	evCashboxRemoved    byte = 0xE3
+	evClearedToCashbox  byte = 0xE2
+	evClearedFromFront  byte = 0xE1
+	evNotePathOpen      byte = 0xE0
+	evCoinCredit        byte = 0xDF
+	evCashboxPaid       byte = 0xDE
+	evIncompleteFloat   byte = 0xDD
+	evIncompletePayout  byte = 0xDC
+	evNoteStored        byte = 0xDB
+	evDispensing        byte = 0xDA
+	evTimeout           byte = 0xD9
+	evFloated           byte = 0xD8
+	evFloating          byte = 0xD7
+	evHalted            byte = 0xD6
+	evJammed            byte = 0xD5
+	evDispensed         byte = 0xD2
+	evTicketAck         byte = 0xD1
+	evNoteInBezel       byte = 0xCE
+	evNoteToStacker     byte = 0xC9
+	evEmptied           byte = 0xC3
+	evEmptying          byte = 0xC2
+	evChannelDisable    byte = 0xB5
+	evInitialising      byte = 0xB6
+	evSmartEmptying     byte = 0xB3
+	evSmartEmptied      byte = 0xB4
+)
+
+// Event with its data, Channel is set for note events
+type sspEvent struct {
+	code byte
+	data []byte
+}
+
+func (ev sspEvent) Channel() int {
+	if len(ev.data) == 0 {
+		return 0
+	}
+	return int(ev.data[0])
+}
+
+func (ev sspEvent) String() string {
+	str := fmt.Sprintf("%02X - %s", ev.code, getEventText(ev.code))
+	switch ev.code {
+	case evRead, evCredit, evFraudAttempt, evClearedToCashbox, evClearedFromFront:
+		str += fmt.Sprintf(": channel %d", ev.Channel())
+	}
+	return str
+}
+
+// Get data size of the event of protocol version 6, negative size is for unknown event
+func getEventSize(code byte, data []byte) int {
+	switch code {
+	case evRead, evCredit, evFraudAttempt, evClearedToCashbox, evClearedFromFront:
+		return 1
+	case evCoinCredit, evNoteStored, evNoteInBezel, evNoteToStacker:
+		return 7
+	case evCashboxPaid, evDispensing, evTimeout, evFloated, evFloating, evHalted,
+		evJammed, evDispensed, evSmartEmptying, evSmartEmptied:
+		if len(data) == 0 {
+			return 1
+		}
+		return 1 + 7*int(data[0])
+	case evIncompleteFloat, evIncompletePayout:
+		if len(data) == 0 {
+			return 1
+		}
+		return 1 + 11*int(data[0])
+	case evSlaveReset, evRejecting, evRejected, evStacking, evStacked, evSafeJam, evUnsafeJam,
+		evDisabled, evStackerFull, evTicketValidated, evCashboxReplaced, evCashboxRemoved,
+		evNotePathOpen, evTicketAck, evEmptied, evEmptying, evChannelDisable, evInitialising:
+		return 0
+	default:
+		return -1
+	}
+}
+
+// Split poll reply data to events, parsing is stopped on unknown event
+func parsePollEvents(data []byte) ([]sspEvent, error) {
+	list := make([]sspEvent, 0)
+	for i := 0; i < len(data); {
+		code := data[i]
+		size := getEventSize(code, data[i+1:])
+		if size < 0 {
+			return list, fmt.Errorf("unknown event %02X", code)
+		}
+		if i+1+size > len(data) {
+			return list, fmt.Errorf("event %02X is truncated", code)
+		}
+		list = append(list, sspEvent{code: code, data: data[i+1 : i+1+size]})
+		i += 1 + size
+	}
+	return list, nil
+}
+
+// GetState maps the event to device state, undefined state is for events that do not change it
+func (ev sspEvent) GetState() common.EnumDevState {
+	switch ev.code {
+	case evSlaveReset, evInitialising:
+		return common.DevStateWorking
+	case evRead:
+		if ev.Channel() == 0 {
+			return common.DevStateCashAccepting
+		}
+		return common.DevStateCashEscrowed
+	case evCredit, evStacking:
+		return common.DevStateCashStacking
+	case evStacked:
+		return common.DevStateCashStacked
+	case evRejecting:
+		return common.DevStateCashRejecting
+	case evRejected:
+		return common.DevStateCashReturned
+	case evDisabled, evChannelDisable:
+		return common.DevStateStandby
+	case evStackerFull:
+		return common.DevStateCashStackerFull
+	case evSafeJam, evUnsafeJam:
+		return common.DevStateCashBillJammed
+	case evCashboxRemoved, evNotePathOpen:
+		return common.DevStateHardError
+	case evFraudAttempt:
+		return common.DevStateSoftError
+	default:
+		return common.DevStateUndefined
+	}
+}
+
+// GetError maps the event to device error, success is returned for normal events
+func (ev sspEvent) GetError() common.EnumDevError {
+	switch ev.code {
+	case evStackerFull:
+		return common.DevErrorStackerFull
+	case evCashboxRemoved:
+		return common.DevErrorCassetteMiss
+	case evSafeJam, evUnsafeJam:
+		return common.DevErrorBillJammed
+	case evFraudAttempt:
+		return common.DevErrorSecurityFault
+	case evNotePathOpen:
+		return common.DevErrorHardwareFault
+	default:
+		return common.DevErrorSuccess
+	}
+}
+
+// GetPrompt maps the event to customer prompt, none prompt is for events that do not change it
+func (ev sspEvent) GetPrompt() common.EnumDevPrompt {
+	switch ev.code {
+	case evRead:
+		if ev.Channel() == 0 {
+			return common.DevPromptCashAccepting
+		}
+		return common.DevPromptCashEscrowed
+	case evCredit, evStacking:
+		return common.DevPromptCashStacking
+	case evRejecting:
+		return common.DevPromptCashReturning
+	case evStackerFull:
+		return common.DevPromptCashStackerFull
+	case evSafeJam, evUnsafeJam:
+		return common.DevPromptCashBillJammed
+	case evCashboxRemoved, evNotePathOpen, evFraudAttempt:
+		return common.DevPromptCashFailure
+	default:
+		return common.DevPromptNone
+	}
+}
+
+func getEventText(code byte) string {
+	switch code {
+	case evSlaveReset:			return "Slave reset"
+	case evRead:				return "Read note"
+	case evCredit:				return "Credit note"
+	case evRejecting:			return "Note rejecting"
+	case evRejected:			return "Note rejected"
+	case evStacking:			return "Stacking"
+	case evStacked:				return "Stacked"
+	case evSafeJam:				return "Safe note jam"
+	case evUnsafeJam:			return "Unsafe note jam"
+	case evDisabled:			return "Disabled"
+	case evStackerFull:			return "Stacker full"
+	case evFraudAttempt:		return "Fraud attempt"
+	case evTicketValidated:		return "Barcode ticket validated"
+	case evCashboxReplaced:		return "Cashbox replaced"
+	case evCashboxRemoved:		return "Cashbox removed"
+	case evClearedToCashbox:	return "Note cleared into cashbox"
+	case evClearedFromFront:	return "Note cleared from front"
+	case evNotePathOpen:		return "Note path open"
+	case evCoinCredit:			return "Coin credit"
+	case evCashboxPaid:			return "Cashbox paid"
+	case evIncompleteFloat:		return "Incomplete float"
+	case evIncompletePayout:	return "Incomplete payout"
+	case evNoteStored:			return "Note stored in payout"
+	case evDispensing:			return "Dispensing"
+	case evTimeout:				return "Payout timeout"
+	case evFloated:				return "Floated"
+	case evFloating:			return "Floating"
+	case evHalted:				return "Halted"
+	case evJammed:				return "Payout jammed"
+	case evDispensed:			return "Dispensed"
+	case evTicketAck:			return "Barcode ticket acknowledge"
+	case evNoteInBezel:			return "Note held in bezel"
+	case evNoteToStacker:		return "Note transferred to stacker"
+	case evEmptied:				return "Emptied"
+	case evEmptying:			return "Emptying"
+	case evChannelDisable:		return "All channels inhibited"
+	case evInitialising:		return "Initialising"
+	case evSmartEmptying:		return "Smart emptying"
+	case evSmartEmptied:		return "Smart emptied"
+	default:					return "Unknown event"
+	}
+}
@@ -0,0 +1,242 @@
+package validator
+
+import (
+	"fmt"
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/config"
+	"github.com/iftsoft/device/core"
+	"github.com/iftsoft/device/driver/generic"
+	"time"
+)
+
+// CashEngine keeps stacker counters, escrow session and batch booking that are common for cash acceptors.
+// Engine of the device loads its note table to NoteTables and applies decisions by its protocol.
+type CashEngine struct {
+	generic.BaseValidator
+	Booker      common.ValidatorBooker
+	Config      *config.DeviceConfig
+	NoteTables  NoteTables
+	Policy      *EscrowPolicy
+	Session     EscrowSession
+	EscrowWait  time.Time // Moment when the host was asked for decision, zero if no note waits
+	Stacked     int32
+	StackerFull bool // Stacker is reported full by device
+}
+
+func (ce *CashEngine) InitCashEngine(cfg *config.DeviceConfig) {
+	ce.Config = cfg
+	ce.Log = core.GetLogAgent(core.LogLevelTrace, "Engine")
+	ce.Policy, _ = NewEscrowPolicy(nil, nil)
+}
+
+func (ce *CashEngine) GetValidatorConfig() *config.ValidatorConfig {
+	if ce.Config != nil && ce.Config.Validator != nil {
+		return ce.Config.Validator
+	}
+	return config.GetDefaultValidatorConfig()
+}
+
+// Linker config of the device or nil
+func (ce *CashEngine) GetLinkerConfig() *config.LinkerConfig {
+	if ce.Config != nil {
+		return ce.Config.Linker
+	}
+	return nil
+}
+
+// Rebuild escrow policy for note tables, notes mask is applied by device itself
+func (ce *CashEngine) SetNoteTables(tables NoteTables) error {
+	polCfg := *ce.GetValidatorConfig()
+	polCfg.NotesMask = 0
+	policy, err := NewEscrowPolicy(&polCfg, tables)
+	if err != nil {
+		return err
+	}
+	ce.NoteTables, ce.Policy = tables, policy
+	return nil
+}
+
+func (ce *CashEngine) IsStackerFull() bool {
+	limit := ce.GetValidatorConfig().NoteLimit
+	return ce.StackerFull || (limit > 0 && ce.Stacked >= limit)
+}
+
+func (ce *CashEngine) stackerFullError() error {
+	return common.NewError(common.DevErrorStackerFull,
+		fmt.Sprintf("stacker has %d notes of %d", ce.Stacked, ce.GetValidatorConfig().NoteLimit))
+}
+
+// Put stacker counters and alert to device metrics
+func (ce *CashEngine) CheckStacker(metrics *common.SystemMetrics) {
+	valCfg := ce.GetValidatorConfig()
+	metrics.Counts["stacker_count"] = uint32(ce.Stacked)
+	metrics.Counts["stacker_alert"] = uint32(valCfg.NoteAlert)
+	metrics.Counts["stacker_limit"] = uint32(valCfg.NoteLimit)
+	switch {
+	case ce.IsStackerFull() || ce.DevState == common.DevStateCashStackerFull:
+		metrics.Topics["stacker"] = common.DevErrorStackerFull.String()
+		metrics.DevState = common.DevStateCashStackerFull
+	case valCfg.NoteAlert > 0 && ce.Stacked >= valCfg.NoteAlert:
+		metrics.Topics["stacker"] = "Stacker is near full"
+	}
+}
+
+// Check that session may be started before notes are enabled
+func (ce *CashEngine) CheckSession(target common.DevAmount) error {
+	if target < 0 {
+		return common.NewError(common.DevErrorBadArgument, "negative session target")
+	}
+	if ce.IsStackerFull() {
+		return ce.stackerFullError()
+	}
+	return nil
+}
+
+func (ce *CashEngine) StartSession(curr common.DevCurrency, target common.DevAmount) {
+	ce.Accept.Currency = curr
+	ce.Session = EscrowSession{Currency: curr, Target: target}
+}
+
+// Session is over when stacker is full or session target is reached
+func (ce *CashEngine) IsSessionOver() (bool, error) {
+	if ce.IsStackerFull() {
+		return true, ce.stackerFullError()
+	}
+	return ce.Session.Target > 0 && ce.Session.Total >= ce.Session.Target, nil
+}
+
+// Start new batch with notes of loaded note tables
+func (ce *CashEngine) InitNoteList(curr common.DevCurrency) error {
+	if _, ok := ce.NoteTables[curr]; !ok {
+		return common.NewError(common.DevErrorNoCurrency,
+			fmt.Sprintf("no notes for currency %d (%s)", curr, curr.IsoCode()))
+	}
+	var err error
+	if ce.Booker != nil {
+		err = ce.Booker.CloseBatch(&ce.Batch)
+		if err == nil {
+			err = ce.Booker.InitNoteList(ce.NoteTables.GetNoteList())
+		}
+		if err == nil {
+			err = ce.Booker.ReadNoteList(&ce.Batch)
+		}
+	} else {
+		ce.Batch.Notes = ce.NoteTables.GetNoteList()
+	}
+	if err == nil {
+		ce.Stacked = 0
+	}
+	ce.Accept.Currency = curr
+	return err
+}
+
+func (ce *CashEngine) DevCheckBatch() error {
+	var err error
+	if ce.Booker != nil {
+		err = ce.Booker.ReadNoteList(&ce.Batch)
+		ce.Stacked = int32(ce.Batch.Count)
+	}
+	return err
+}
+
+func (ce *CashEngine) DevClearBatch() error {
+	var err error
+	if ce.Booker != nil {
+		err = ce.Booker.CloseBatch(&ce.Batch)
+	}
+	if err == nil {
+		ce.Stacked = 0
+		ce.StackerFull = false
+	}
+	return err
+}
+
+////////////////////////////////////////////////////////////////
+
+func (ce *CashEngine) ClearNote() {
+	ce.Accept.Currency = ce.Session.Currency
+	ce.Accept.Nominal = 0
+	ce.Accept.Count = 0
+	ce.Accept.Amount = 0
+	ce.Accept.Decision = common.NoteDecisionHost
+	ce.Accept.Reason = ""
+}
+
+// Decide what to do with the escrowed note, the host is asked if policy has no decision
+func (ce *CashEngine) DecideNote() {
+	if ce.Accept.Currency != ce.Session.Currency {
+		ce.Accept.Decision, ce.Accept.Reason = common.NoteDecisionReturn, "note currency is not enabled"
+	} else {
+		ce.Accept.Decision, ce.Accept.Reason = ce.Policy.Decide(ce.NoteTables, &ce.Session, &ce.Accept, ce.Stacked)
+	}
+	ce.Log.Debug("CashEngine escrow decision %s - %s", ce.Accept.Decision, ce.Accept.Reason)
+	if ce.Accept.Decision == common.NoteDecisionHost {
+		ce.EscrowWait = time.Now()
+	}
+	_ = ce.RunNoteAccepted(&ce.Accept)
+}
+
+// Take decision of the host for the note that waits for it
+func (ce *CashEngine) HostDecision(decision common.EnumNoteDecision, reason string) error {
+	if ce.EscrowWait.IsZero() {
+		return common.NewError(common.DevErrorNotAccepted, "no note waits for host decision")
+	}
+	ce.EscrowWait = time.Time{}
+	ce.Accept.Decision, ce.Accept.Reason = decision, reason
+	return nil
+}
+
+// Stop waiting for host decision, true is returned if the note has to be returned
+func (ce *CashEngine) CancelEscrow() bool {
+	if ce.EscrowWait.IsZero() {
+		return false
+	}
+	ce.EscrowWait = time.Time{}
+	return true
+}
+
+// Take default action if the host does not decide in time, true is returned if decision has to be applied
+func (ce *CashEngine) CheckEscrowWait() bool {
+	if ce.EscrowWait.IsZero() || !ce.Policy.IsExpired(ce.EscrowWait) {
+		return false
+	}
+	ce.EscrowWait = time.Time{}
+	ce.Accept.Decision, ce.Accept.Reason = ce.Policy.Fallback()
+	ce.Log.Debug("CashEngine escrow decision %s - %s", ce.Accept.Decision, ce.Accept.Reason)
+	_ = ce.RunNoteAccepted(&ce.Accept)
+	return true
+}
+
+// Count stacked note to session and batch
+func (ce *CashEngine) StoreNote() {
+	ce.EscrowWait = time.Time{}
+	ce.Stacked += int32(ce.Accept.Count)
+	if ce.Accept.Currency == ce.Session.Currency {
+		ce.Session.Total += ce.Accept.Amount
+	}
+	if ce.Booker != nil {
+		err := ce.Booker.DepositNote(0, &ce.Accept)
+		if err != nil {
+			_ = ce.RunExecuteError(common.DevErrorDatabaseFault, err.Error())
+		}
+	}
+	_ = ce.RunCashIsStored(&ce.Accept)
+	ce.ClearNote()
+}
+
+func (ce *CashEngine) ReturnNote() {
+	ce.EscrowWait = time.Time{}
+	_ = ce.RunCashReturned(&ce.Accept)
+	ce.ClearNote()
+}
+
+// Coins have no escrow, they are stored as soon as they pass the acceptor
+func (ce *CashEngine) CreditCoin(curr common.DevCurrency, nominal common.DevAmount) {
+	ce.Accept.Currency = curr
+	ce.Accept.Nominal = nominal
+	ce.Accept.Count = 1
+	ce.Accept.Amount = nominal
+	ce.Accept.Decision, ce.Accept.Reason = common.NoteDecisionAccept, "coin is credited"
+	_ = ce.RunNoteAccepted(&ce.Accept)
+	ce.StoreNote()
+}
@@ -37,7 +37,7 @@ func (vd *ValidatorDriver) InitDevice(context *driver.Context) error {
 	}
 	if context.Storage != nil {
 		vd.storage = context.Storage
-		vd.booker  = dbvalid.NewDBaseValidator(vd.storage, vd.DevName)
+		vd.Booker  = dbvalid.NewDBaseValidator(vd.storage, vd.DevName)
 	}
 	if context.Greeting != nil {
 		context.Greeting.DevType = common.DevTypeCashValidator
@@ -49,8 +49,8 @@ func (vd *ValidatorDriver) InitDevice(context *driver.Context) error {
 func (vd *ValidatorDriver) StartDevice(query *common.SystemConfig) error {
 	vd.Log.Debug("ValidatorDriver run cmd:%s", "StartDeviceLoop")
 	var err error
-	if vd.config != nil && query != nil {
-		vd.config.OverwriteConfig(query)
+	if vd.Config != nil && query != nil {
+		vd.Config.OverwriteConfig(query)
 	}
 	if vd.storage != nil {
 		err = vd.storage.Open()
@@ -79,6 +79,7 @@ func (vd *ValidatorDriver) CheckDevice(metrics *common.SystemMetrics) error {
 		metrics.Uptime = time.Now().Unix() - vd.begTime
 		metrics.DevState = vd.DevState
 		metrics.DevError = vd.DevError
+		vd.CheckStacker(metrics)
 	}
 	return nil
 }
@@ -101,7 +102,7 @@ func (vd *ValidatorDriver) Status(name string, query *common.DeviceQuery) error
 	return vd.RunDeviceReply(common.CmdDeviceStatus)
 }
 func (vd *ValidatorDriver) RunAction(name string, query *common.DeviceQuery) error {
-	err := vd.DevEnableBills(common.CurrencyUAH)
+	err := vd.DevEnableBills(common.CurrencyNOT, 0)
 	if err == nil {
 		err = vd.DevStatus()
 	}
@@ -123,13 +124,13 @@ func (vd *ValidatorDriver) StopAction(name string, query *common.DeviceQuery) er
 func (vd *ValidatorDriver) InitValidator(name string, query *common.ValidatorQuery) error {
 	err := vd.DevReset()
 	if err == nil {
-		err = vd.DevInitBillList()
+		err = vd.DevInitBillList(query.Currency)
 	}
 	vd.DevError, vd.DevReply = common.CheckError(err)
 	return vd.RunValidatorStore(common.CmdInitValidator)
 }
 func (vd *ValidatorDriver) DoValidate(name string, query *common.ValidatorQuery) error {
-	err := vd.DevEnableBills(query.Currency)
+	err := vd.DevEnableBills(query.Currency, query.Target)
 	if err == nil {
 		err = vd.DevStatus()
 	}
@@ -3,22 +3,18 @@ package validator
 import (
 	"github.com/iftsoft/device/common"
 	"github.com/iftsoft/device/config"
-	"github.com/iftsoft/device/core"
 	"github.com/iftsoft/device/driver/generic"
 	"time"
 )
 
 type ValidatorEngine struct {
-	generic.BaseValidator
+	CashEngine
 	generic.Simulator
-	booker      common.ValidatorBooker
-	config      *config.DeviceConfig
 	billIndex   int
 }
 
 func (ve *ValidatorEngine) initEngine(cfg *config.DeviceConfig) *ValidatorEngine {
-	ve.config    = cfg
-	ve.Log       = core.GetLogAgent(core.LogLevelTrace, "Engine")
+	ve.InitCashEngine(cfg)
 	ve.billIndex = 0
 	return ve
 }
@@ -32,8 +28,9 @@ func (ve *ValidatorEngine) enterNote() {
 		if ve.billIndex >= size {
 			ve.billIndex = 0
 		}
-		if ve.Accept.Currency == ve.Batch.Notes[index].Currency {
-			ve.Accept.Nominal = ve.Batch.Notes[index].Nominal
+		note := ve.Batch.Notes[index]
+		if ve.Accept.Currency == note.Currency {
+			ve.Accept.Nominal = note.Nominal
 			ve.Accept.Count   = 1
 			ve.Accept.Amount  = ve.Accept.Nominal
 			break
@@ -41,21 +38,56 @@ func (ve *ValidatorEngine) enterNote() {
 	}
 }
 
-func (ve *ValidatorEngine) clearNote() {
-	ve.Accept.Nominal = 0
-	ve.Accept.Count   = 0
-	ve.Accept.Amount  = 0
+// Currency by config or the first note table in config order
+func (ve *ValidatorEngine) getDefaultCurrency() common.DevCurrency {
+	valCfg := ve.GetValidatorConfig()
+	if valCfg.CurrCode != common.CurrencyNOT {
+		return valCfg.CurrCode
+	}
+	for _, item := range valCfg.NoteTables {
+		if item != nil {
+			return item.Currency
+		}
+	}
+	if _, ok := ve.NoteTables[common.CurrencyUAH]; ok {
+		return common.CurrencyUAH
+	}
+	return common.CurrencyNOT
+}
+
+// Run escrow policy for the note, simulator applies decision at once
+func (ve *ValidatorEngine) decideNote() {
+	ve.DecideNote()
+	ve.applyDecision()
+}
+
+func (ve *ValidatorEngine) applyDecision() {
+	switch ve.Accept.Decision {
+	case common.NoteDecisionAccept:
+		ve.SetupMimic(valStackingSteps)
+	case common.NoteDecisionReturn:
+		ve.SetupMimic(valReturningSteps)
+	default:
+	}
 }
 
 
 ////////////////////////////////////////////////////////////////
 
+// Simulator applies notes mask itself, so policy is built with mask of config
 func (ve *ValidatorEngine) DevStartup() error {
-	var err error
-	if ve.booker != nil {
-		err = ve.booker.ReadNoteList(&ve.Batch)
+	valCfg := ve.GetValidatorConfig()
+	tables, err := loadNoteTables(valCfg)
+	if err != nil {
+		return err
 	}
-	ve.Accept.Currency = 980
+	policy, err := NewEscrowPolicy(valCfg, tables)
+	if err != nil {
+		return err
+	}
+	ve.NoteTables, ve.Policy = tables, policy
+	err = ve.DevCheckBatch()
+	ve.Accept.Currency = ve.getDefaultCurrency()
 	return err
 }
 
@@ -81,71 +113,67 @@ func (ve *ValidatorEngine) DevStatus() error {
 	return err
 }
 
-func (ve *ValidatorEngine) DevEnableBills(curr common.DevCurrency) error {
-	ve.Log.Debug("ValidatorEngine Set currency %d - %s", curr, curr.String())
+func (ve *ValidatorEngine) DevEnableBills(curr common.DevCurrency, target common.DevAmount) error {
+	if curr == common.CurrencyNOT {
+		curr = ve.Accept.Currency
+	}
+	ve.Log.Debug("ValidatorEngine Set currency %d - %s, target %s", curr, curr.String(), target.Format(curr))
+	_, err := ve.NoteTables.GetTable(curr, ve.GetValidatorConfig().NotesMask)
+	if err != nil {
+		return err
+	}
+	err = ve.CheckSession(target)
+	if ve.IsStackerFull() {
+		ve.SetupMimic(valStackerFullSteps)
+	}
+	if err != nil {
+		return err
+	}
+	ve.StartSession(curr, target)
 	ve.SetupMimic(valWaitNoteSteps)
-	var err error
-	return err
+	return nil
 }
 
 func (ve *ValidatorEngine) DevDisableBills() error {
+	_ = ve.CancelEscrow()
 	ve.SetupMimic(valStopWaitSteps)
 	var err error
 	return err
 }
 
-func (ve *ValidatorEngine) DevInitBillList() error {
-	var err error
-	if ve.booker != nil {
-		err = ve.booker.CloseBatch(&ve.Batch)
-		if err == nil {
-			err = ve.booker.InitNoteList(valNoteListUah)
-		}
-		if err == nil {
-			err = ve.booker.ReadNoteList(&ve.Batch)
-		}
+func (ve *ValidatorEngine) DevInitBillList(curr common.DevCurrency) error {
+	if curr == common.CurrencyNOT {
+		curr = ve.getDefaultCurrency()
 	}
-	ve.Accept.Currency = 980
-//	ve.Log.Debug(ve.Batch.String())
-	return err
+	_, err := ve.NoteTables.GetTable(curr, ve.GetValidatorConfig().NotesMask)
+	if err != nil {
+		return err
+	}
+	return ve.InitNoteList(curr)
 }
 
 func (ve *ValidatorEngine) DevNoteAccept() error {
-	ve.SetupMimic(valStackingSteps)
-	var err error
-	if ve.booker != nil {
-		err = ve.booker.DepositNote(0, &ve.Accept)
+	err := ve.HostDecision(common.NoteDecisionAccept, "accepted by host")
+	if err == nil {
+		ve.applyDecision()
 	}
 	return err
 }
 
 func (ve *ValidatorEngine) DevNoteReturn() error {
-	ve.SetupMimic(valReturningSteps)
-	var err error
-	return err
-}
-
-func (ve *ValidatorEngine) DevCheckBatch() error {
-	var err error
-	if ve.booker != nil {
-		err = ve.booker.ReadNoteList(&ve.Batch)
+	err := ve.HostDecision(common.NoteDecisionReturn, "returned by host")
+	if err == nil {
+		ve.applyDecision()
 	}
 	return err
 }
 
-func (ve *ValidatorEngine) DevClearBatch() error {
-	var err error
-	if ve.booker != nil {
-		err = ve.booker.CloseBatch(&ve.Batch)
-	}
-	return err
-}
-
-
-
 ////////////////////////////////////////////////////////////////
 
 func (ve *ValidatorEngine) NextMimicStage() {
+	if ve.CheckEscrowWait() {
+		ve.applyDecision()
+	}
 	stage := ve.GetMimicStep()
 	if stage != nil {
 		_ = ve.ProcessStage(&ve.BaseEngine, stage)
@@ -174,21 +202,28 @@ func (ve *ValidatorEngine) StepWaitNoteDone() {
 func (ve *ValidatorEngine) StepAcceptingDone() {
 	ve.SetupMimic(valEscrowedSteps)
 	ve.enterNote()
-	_ = ve.RunNoteAccepted(&ve.Accept)
+	ve.decideNote()
 }
 func (ve *ValidatorEngine) StepEscrowedDone() {
+	if !ve.EscrowWait.IsZero() {
+		// Keep note in escrow while the host is deciding
+		ve.SetupMimic(valEscrowedSteps)
+		return
+	}
 	ve.SetupMimic(valRejectingSteps)
-	ve.clearNote()
+	ve.ClearNote()
 }
 func (ve *ValidatorEngine) StepStackingDone() {
-	ve.SetupMimic(valWaitNoteSteps)
-	_ = ve.RunCashIsStored(&ve.Accept)
-	ve.clearNote()
+	ve.StoreNote()
+	if ve.IsStackerFull() {
+		ve.SetupMimic(valStackerFullSteps)
+	} else {
+		ve.SetupMimic(valWaitNoteSteps)
+	}
 }
 func (ve *ValidatorEngine) StepReturningDone() {
 	ve.SetupMimic(valWaitNoteSteps)
-	_ = ve.RunCashReturned(&ve.Accept)
-	ve.clearNote()
+	ve.ReturnNote()
 }
 func (ve *ValidatorEngine) StepRejectingDone() {
 	ve.SetupMimic(valWaitNoteSteps)
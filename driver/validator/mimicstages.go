@@ -57,6 +57,10 @@ var valRejectingSteps = generic.MimicSteps{
 	{ 10, StepRejectingDone, common.DevStateCashRejecting, 0, 0, 0, "", "" },
 }
 
+var valStackerFullSteps = generic.MimicSteps{
+	{ 0, 0, common.DevStateCashStackerFull, 0, common.DevPromptCashStackerFull, common.DevActionDoNothing, "", "" },
+}
+
 var valStopWaitSteps = generic.MimicSteps{
 	{ 0, 0, common.DevStateWorking, 0, common.DevPromptUnitDone, common.DevActionDeviceStopping, "", "" },
 	{ 5, 0, common.DevStateReady, 0, 0, common.DevActionDeviceStopping, "", "" },
@@ -64,15 +68,15 @@ var valStopWaitSteps = generic.MimicSteps{
 }
 
 var valNoteListUah = common.ValidNoteList {
-	{"", 980, 0, 1.0, 0.0, },
-	{"", 980, 0, 2.0, 0.0, },
-	{"", 980, 0, 5.0, 0.0, },
-	{"", 980, 0, 10.0, 0.0, },
-	{"", 980, 0, 20.0, 0.0, },
-	{"", 980, 0, 50.0, 0.0, },
-	{"", 980, 0, 100.0, 0.0, },
-	{"", 980, 0, 200.0, 0.0, },
-	{"", 980, 0, 500.0, 0.0, },
-	{"", 980, 0, 1000.0, 0.0, },
+	{"", 980, 0, 100, 0, },
+	{"", 980, 0, 200, 0, },
+	{"", 980, 0, 500, 0, },
+	{"", 980, 0, 1000, 0, },
+	{"", 980, 0, 2000, 0, },
+	{"", 980, 0, 5000, 0, },
+	{"", 980, 0, 10000, 0, },
+	{"", 980, 0, 20000, 0, },
+	{"", 980, 0, 50000, 0, },
+	{"", 980, 0, 100000, 0, },
 }
 
@@ -0,0 +1,87 @@
+package validator
+
+import (
+	"fmt"
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/config"
+	"sort"
+)
+
+// NoteTables keeps validator note denominations by currency
+type NoteTables map[common.DevCurrency]common.ValidNoteList
+
+// Load note tables from validator config, built-in UAH table is used if config has no tables
+func loadNoteTables(cfg *config.ValidatorConfig) (NoteTables, error) {
+	tables := NoteTables{}
+	if cfg == nil || len(cfg.NoteTables) == 0 {
+		tables[common.CurrencyUAH] = valNoteListUah
+		return tables, nil
+	}
+	for _, item := range cfg.NoteTables {
+		if item == nil {
+			continue
+		}
+		list, err := item.GetNoteList()
+		if err != nil {
+			return nil, common.ExtendError(common.DevErrorConfigFault, err)
+		}
+		if _, ok := tables[item.Currency]; ok {
+			return nil, common.NewError(common.DevErrorConfigFault,
+				fmt.Sprintf("duplicate note table for %s", item.Currency.IsoCode()))
+		}
+		tables[item.Currency] = list
+	}
+	if cfg.CurrCode != common.CurrencyNOT {
+		if _, ok := tables[cfg.CurrCode]; !ok {
+			return nil, common.NewError(common.DevErrorConfigFault,
+				fmt.Sprintf("no note table for currency %s", cfg.CurrCode.IsoCode()))
+		}
+	}
+	return tables, nil
+}
+
+// GetTable returns note table of the currency checked against notes mask
+func (nt NoteTables) GetTable(curr common.DevCurrency, mask int64) (common.ValidNoteList, error) {
+	list, ok := nt[curr]
+	if !ok {
+		return nil, common.NewError(common.DevErrorNoCurrency,
+			fmt.Sprintf("no note table for currency %d (%s)", curr, curr.IsoCode()))
+	}
+	if len(list) < 64 && mask>>uint(len(list)) != 0 {
+		return nil, common.NewError(common.DevErrorConfigFault,
+			fmt.Sprintf("notes mask %X exceeds %d notes of %s", mask, len(list), curr.IsoCode()))
+	}
+	return list, nil
+}
+
+// IsEnabled checks that the note is allowed by notes mask, zero mask enables all notes
+func (nt NoteTables) IsEnabled(curr common.DevCurrency, nominal common.DevAmount, mask int64) bool {
+	if mask == 0 {
+		return true
+	}
+	for i, note := range nt[curr] {
+		if note.Nominal == nominal {
+			return mask&(1<<uint(i)) != 0
+		}
+	}
+	return false
+}
+
+// GetNoteList returns note rows of all currencies for the validator storage
+func (nt NoteTables) GetNoteList() common.ValidNoteList {
+	codes := make([]int, 0, len(nt))
+	for curr := range nt {
+		codes = append(codes, int(curr))
+	}
+	sort.Ints(codes)
+	out := make(common.ValidNoteList, 0)
+	for _, code := range codes {
+		for _, note := range nt[common.DevCurrency(code)] {
+			out = append(out, &common.ValidatorNote{
+				Currency: note.Currency,
+				Nominal:  note.Nominal,
+			})
+		}
+	}
+	return out
+}
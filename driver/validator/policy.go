@@ -0,0 +1,95 @@
+package validator
+
+import (
+	"fmt"
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/config"
+	"time"
+)
+
+// EscrowPolicy decides what to do with the escrowed note before the host is asked
+type EscrowPolicy struct {
+	notesMask  int64
+	noteLimit  int32
+	maxAmount  map[common.DevCurrency]common.DevAmount
+	actDefault config.EnumBillAction
+	storeWait  time.Duration
+}
+
+// EscrowSession keeps money stacked since the last DoValidate command
+type EscrowSession struct {
+	Currency common.DevCurrency
+	Target   common.DevAmount
+	Total    common.DevAmount
+}
+
+// NewEscrowPolicy makes escrow policy from validator config, max amount is parsed for every currency of note tables
+func NewEscrowPolicy(cfg *config.ValidatorConfig, tables NoteTables) (*EscrowPolicy, error) {
+	ep := &EscrowPolicy{
+		maxAmount: make(map[common.DevCurrency]common.DevAmount),
+	}
+	if cfg == nil {
+		return ep, nil
+	}
+	ep.notesMask = cfg.NotesMask
+	ep.noteLimit = cfg.NoteLimit
+	ep.actDefault = cfg.ActDefault
+	ep.storeWait = time.Duration(cfg.StoreWait) * time.Second
+	if cfg.MaxAmount == "" {
+		return ep, nil
+	}
+	for curr := range tables {
+		amount, err := common.ParseAmount(cfg.MaxAmount, curr)
+		if err != nil || amount < 0 {
+			return nil, common.NewError(common.DevErrorConfigFault,
+				fmt.Sprintf("bad max amount %s for %s", cfg.MaxAmount, curr.IsoCode()))
+		}
+		ep.maxAmount[curr] = amount
+	}
+	return ep, nil
+}
+
+// Decide checks the escrowed note against policy rules and returns decision with its reason
+func (ep *EscrowPolicy) Decide(tables NoteTables, sess *EscrowSession,
+	note *common.ValidatorAccept, stacked int32) (common.EnumNoteDecision, string) {
+	if note.Nominal <= 0 {
+		return common.NoteDecisionReturn, "note is not recognized"
+	}
+	if !tables.IsEnabled(note.Currency, note.Nominal, ep.notesMask) {
+		return common.NoteDecisionReturn, "note is disabled by notes mask"
+	}
+	if ep.noteLimit > 0 && stacked+int32(note.Count) > ep.noteLimit {
+		return common.NoteDecisionReturn, "stacker is full"
+	}
+	total := sess.Total + note.Amount
+	if limit, ok := ep.maxAmount[note.Currency]; ok && limit > 0 && total > limit {
+		return common.NoteDecisionReturn, fmt.Sprintf("transaction maximum %s is exceeded",
+			limit.Format(note.Currency))
+	}
+	if sess.Target > 0 {
+		if total > sess.Target {
+			return common.NoteDecisionReturn, fmt.Sprintf("session target %s is exceeded",
+				sess.Target.Format(note.Currency))
+		}
+		return common.NoteDecisionAccept, "note fits session target"
+	}
+	return common.NoteDecisionHost, "waiting for host decision"
+}
+
+// Fallback returns default action decision when the host does not answer in time
+func (ep *EscrowPolicy) Fallback() (common.EnumNoteDecision, string) {
+	return ep.getDefault("host decision timeout")
+}
+
+// IsExpired checks that the host has not decided in time, zero store wait lets the host wait forever
+func (ep *EscrowPolicy) IsExpired(since time.Time) bool {
+	return ep.storeWait > 0 && time.Since(since) >= ep.storeWait
+}
+
+func (ep *EscrowPolicy) getDefault(cause string) (common.EnumNoteDecision, string) {
+	reason := fmt.Sprintf("%s - %s", cause, ep.actDefault)
+	if ep.actDefault == config.BillActionReturn {
+		return common.NoteDecisionReturn, reason
+	}
+	return common.NoteDecisionAccept, reason
+}
@@ -0,0 +1,155 @@
+package virtual
+
+import (
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/driver/printer"
+	"image"
+)
+
+const (
+	cellCols = 6 // Character cell of 5x7 glyph with spacing
+	cellRows = 9
+)
+
+// Canvas is the paper tape of fixed width that grows while printing, non zero dot is black
+type Canvas struct {
+	width  int
+	height int
+	scale  int
+	pix    []byte
+}
+
+func newCanvas(width, scale int) *Canvas {
+	if scale < 1 {
+		scale = 1
+	}
+	return &Canvas{width: width, scale: scale}
+}
+
+// CharWidth returns dot width of character cell for width multiplier
+func (c *Canvas) CharWidth(mul int) int {
+	return cellCols * c.scale * mul
+}
+
+// LineHeight returns dot height of text line for height multiplier
+func (c *Canvas) LineHeight(mul int) int {
+	return cellRows * c.scale * mul
+}
+
+// CharsPerLine returns count of characters that fit the paper width
+func (c *Canvas) CharsPerLine(mul int) int {
+	return c.width / c.CharWidth(mul)
+}
+
+// Image returns the tape as gray image, white is background
+func (c *Canvas) Image() *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, c.width, c.height))
+	for i, dot := range c.pix {
+		if dot == 0 {
+			img.Pix[i] = 0xFF
+		}
+	}
+	return img
+}
+
+// Feed adds empty rows to the tape and returns the first of them
+func (c *Canvas) Feed(rows int) int {
+	top := c.height
+	if rows > 0 {
+		c.pix = append(c.pix, make([]byte, rows*c.width)...)
+		c.height += rows
+	}
+	return top
+}
+
+func (c *Canvas) set(x, y int) {
+	if x >= 0 && x < c.width && y >= 0 && y < c.height {
+		c.pix[y*c.width+x] = 1
+	}
+}
+
+func (c *Canvas) fill(x, y, w, h int) {
+	for j := y; j < y+h; j++ {
+		for i := x; i < x+w; i++ {
+			c.set(i, j)
+		}
+	}
+}
+
+// Left offset of the block of the width
+func (c *Canvas) offset(width int, align common.EnumDocAlign) int {
+	switch {
+	case width >= c.width:
+		return 0
+	case align == common.DocAlignCenter:
+		return (c.width - width) / 2
+	case align == common.DocAlignRight:
+		return c.width - width
+	default:
+		return 0
+	}
+}
+
+// DrawText prints one line of text with width and height multipliers
+func (c *Canvas) DrawText(text string, align common.EnumDocAlign, bold, underline bool, wMul, hMul int) {
+	text = printer.CutText(text, c.CharsPerLine(wMul))
+	left := c.offset(printer.TextWidth(text)*c.CharWidth(wMul), align)
+	top := c.Feed(c.LineHeight(hMul))
+	c.drawGlyphs(text, left, top, bold, wMul, hMul)
+	if underline && text != "" {
+		c.fill(left, top+8*c.scale*hMul, printer.TextWidth(text)*c.CharWidth(wMul), c.scale)
+	}
+}
+
+// Draw glyphs of the text from the top left dot
+func (c *Canvas) drawGlyphs(text string, left, top int, bold bool, wMul, hMul int) {
+	dotW, dotH := c.scale*wMul, c.scale*hMul
+	for n, r := range []rune(text) {
+		x0 := left + n*c.CharWidth(wMul)
+		for col, bits := range getGlyph(r) {
+			for row := 0; row < 7; row++ {
+				if bits&(1<<uint(row)) == 0 {
+					continue
+				}
+				c.fill(x0+col*dotW, top+row*dotH, dotW, dotH)
+				if bold {
+					c.fill(x0+col*dotW+1, top+row*dotH, dotW, dotH)
+				}
+			}
+		}
+	}
+}
+
+// DrawImage prints raster image
+func (c *Canvas) DrawImage(img *printer.RasterImage, align common.EnumDocAlign) {
+	if img == nil {
+		return
+	}
+	left := c.offset(img.Width, align)
+	top := c.Feed(img.Height)
+	for y := 0; y < img.Height; y++ {
+		for x := 0; x < img.Width; x++ {
+			if img.IsSet(x, y) {
+				c.set(left+x, top+y)
+			}
+		}
+	}
+}
+
+// DrawFrame prints frame of the size with the label inside it, it stands for codes the printer can't draw
+func (c *Canvas) DrawFrame(label string, width, height int, align common.EnumDocAlign) {
+	if width > c.width {
+		width = c.width
+	}
+	left := c.offset(width, align)
+	top := c.Feed(height)
+	c.fill(left, top, width, c.scale)
+	c.fill(left, top+height-c.scale, width, c.scale)
+	c.fill(left, top, c.scale, height)
+	c.fill(left+width-c.scale, top, c.scale, height)
+	// Label is printed over the middle of the frame
+	label = printer.CutText(label, (width-4*c.scale)/c.CharWidth(1))
+	x := left + (width-printer.TextWidth(label)*c.CharWidth(1))/2
+	y := top + (height-7*c.scale)/2
+	c.drawGlyphs(label, x, y, false, 1, 1)
+}
@@ -0,0 +1,87 @@
+package virtual
+
+import (
+	"github.com/iftsoft/device/common"
+	"testing"
+)
+
+func isBlack(c *Canvas, x, y int) bool {
+	return c.pix[y*c.width+x] != 0
+}
+
+func TestGetGlyph(t *testing.T) {
+	if getGlyph('О') != getGlyph('O') || getGlyph('і') != getGlyph('i') || getGlyph('№') != getGlyph('N') {
+		t.Error("Cyrillic letters are not printed by Latin glyphs")
+	}
+	if getGlyph('Ж') != fontMissing || getGlyph('\t') != fontMissing {
+		t.Error("characters out of the font are not printed as box")
+	}
+	if getGlyph(' ') != [5]byte{} {
+		t.Errorf("space glyph is % X", getGlyph(' '))
+	}
+}
+
+func TestCanvasText(t *testing.T) {
+	c := newCanvas(30, 1)
+	if c.CharsPerLine(1) != 5 || c.CharsPerLine(2) != 2 || c.LineHeight(2) != 18 {
+		t.Fatalf("canvas of 30 dots: %d, %d chars, %d rows", c.CharsPerLine(1), c.CharsPerLine(2), c.LineHeight(2))
+	}
+	c.DrawText("I", common.DocAlignRight, false, true, 1, 1)
+	if c.height != 9 {
+		t.Fatalf("text line is %d rows", c.height)
+	}
+	// Glyph goes to the last character cell
+	glyph := getGlyph('I')
+	for col := 0; col < cellCols; col++ {
+		for row := 0; row < 7; row++ {
+			want := col < 5 && glyph[col]&(1<<uint(row)) != 0
+			if isBlack(c, 24+col, row) != want {
+				t.Errorf("dot %d:%d is %t", 24+col, row, !want)
+			}
+		}
+	}
+	for x := 0; x < c.width; x++ {
+		if isBlack(c, x, 7) || isBlack(c, x, 8) != (x >= 24) {
+			t.Errorf("underline dot %d is wrong", x)
+		}
+	}
+	// Long text is cut by paper width
+	c = newCanvas(30, 1)
+	c.DrawText("Hello, World", common.DocAlignLeft, false, false, 2, 1)
+	if x := c.CharWidth(2) * 2; isBlack(c, x, 0) || isBlack(c, x+1, 0) {
+		t.Error("text is printed out of the line")
+	}
+
+	img := c.Image()
+	if img.Rect.Dx() != 30 || img.Rect.Dy() != 9 {
+		t.Fatalf("image size %s", img.Rect)
+	}
+	if img.GrayAt(29, 8).Y != 0xFF || img.GrayAt(0, 0).Y != 0 {
+		t.Error("image is not black on white")
+	}
+}
+
+func TestCanvasFrame(t *testing.T) {
+	c := newCanvas(60, 1)
+	if top := c.Feed(0); top != 0 || c.height != 0 {
+		t.Errorf("zero feed gives %d rows", c.height)
+	}
+	c.DrawFrame("", 20, 10, common.DocAlignCenter)
+	if c.height != 10 {
+		t.Fatalf("frame is %d rows", c.height)
+	}
+	for _, dot := range [][2]int{{20, 0}, {39, 0}, {20, 9}, {39, 9}, {30, 0}, {20, 5}} {
+		if !isBlack(c, dot[0], dot[1]) {
+			t.Errorf("frame dot %d:%d is white", dot[0], dot[1])
+		}
+	}
+	if isBlack(c, 19, 0) || isBlack(c, 40, 0) || isBlack(c, 30, 5) {
+		t.Error("frame is wider than its size")
+	}
+	// Frame is not wider than paper
+	top := c.Feed(0)
+	c.DrawFrame("", 100, 10, common.DocAlignRight)
+	if !isBlack(c, 0, top+5) || !isBlack(c, 59, top+5) {
+		t.Error("wide frame is not cut by paper")
+	}
+}
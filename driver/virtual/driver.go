@@ -0,0 +1,116 @@
+package virtual
+
+import (
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/driver"
+	"time"
+)
+
+type VirtualDriver struct {
+	VirtualEngine
+	begTime int64
+}
+
+func NewVirtualDriver() *VirtualDriver {
+	vd := VirtualDriver{}
+	return &vd
+}
+
+// Implementation of DeviceDriver interface
+func (vd *VirtualDriver) InitDevice(context *driver.Context) error {
+	vd.initEngine(context.Config)
+	vd.DevName = context.DevName
+	vd.begTime = time.Now().Unix()
+	vd.Log.Debug("VirtualDriver run cmd:%s", "InitDevice")
+
+	mask := common.ScopeFlagSystem
+	if device, ok := context.Manager.(common.DeviceCallback); ok {
+		vd.CbDevice = device
+		mask |= common.ScopeFlagDevice
+	}
+	if printer, ok := context.Manager.(common.PrinterCallback); ok {
+		vd.CbPrinter = printer
+		mask |= common.ScopeFlagPrinter
+	}
+	if context.Greeting != nil {
+		context.Greeting.DevType = common.DevTypePrinter
+		context.Greeting.Required = mask
+	}
+	return nil
+}
+
+func (vd *VirtualDriver) StartDevice(query *common.SystemConfig) error {
+	vd.Log.Debug("VirtualDriver run cmd:%s", "StartDeviceLoop")
+	if vd.config != nil && query != nil {
+		vd.config.OverwriteConfig(query)
+	}
+	return vd.DevStartup()
+}
+func (vd *VirtualDriver) DeviceTimer(unix int64) error {
+	return nil
+}
+func (vd *VirtualDriver) StopDevice() error {
+	vd.Log.Debug("VirtualDriver run cmd:%s", "StopDeviceLoop")
+	return vd.DevCleanup()
+}
+func (vd *VirtualDriver) CheckDevice(metrics *common.SystemMetrics) error {
+	vd.Log.Debug("VirtualDriver run cmd:%s", "CheckDevice")
+	if metrics != nil {
+		metrics.Uptime = time.Now().Unix() - vd.begTime
+		metrics.DevState = vd.DevState
+		metrics.DevError = vd.DevError
+		vd.checkPaper(metrics)
+	}
+	return nil
+}
+
+// Implementation of common.DeviceManager
+//
+func (vd *VirtualDriver) Cancel(name string, query *common.DeviceQuery) error {
+	err := vd.DevStatus()
+	vd.DevError, vd.DevReply = common.CheckError(err)
+	return vd.RunDeviceReply(common.CmdDeviceCancel)
+}
+func (vd *VirtualDriver) Reset(name string, query *common.DeviceQuery) error {
+	err := vd.DevInitPrinter(nil)
+	vd.DevError, vd.DevReply = common.CheckError(err)
+	return vd.RunDeviceReply(common.CmdDeviceReset)
+}
+func (vd *VirtualDriver) Status(name string, query *common.DeviceQuery) error {
+	err := vd.DevStatus()
+	vd.DevError, vd.DevReply = common.CheckError(err)
+	return vd.RunDeviceReply(common.CmdDeviceStatus)
+}
+func (vd *VirtualDriver) RunAction(name string, query *common.DeviceQuery) error {
+	err := vd.DevStatus()
+	vd.DevError, vd.DevReply = common.CheckError(err)
+	return vd.RunDeviceReply(common.CmdRunAction)
+}
+func (vd *VirtualDriver) StopAction(name string, query *common.DeviceQuery) error {
+	err := vd.DevStatus()
+	vd.DevError, vd.DevReply = common.CheckError(err)
+	return vd.RunDeviceReply(common.CmdStopAction)
+}
+
+// Implementation of common.PrinterManager
+//
+func (vd *VirtualDriver) InitPrinter(name string, query *common.PrinterSetup) error {
+	err := vd.DevInitPrinter(query)
+	vd.DevError, vd.DevReply = common.CheckError(err)
+	return vd.RunDeviceReply(common.CmdInitPrinter)
+}
+func (vd *VirtualDriver) PrintText(name string, query *common.PrinterQuery) error {
+	var err error
+	if query == nil {
+		err = common.NewError(common.DevErrorBadArgument, "print query is empty")
+	} else {
+		err = vd.DevPrintText(query.Text)
+	}
+	vd.DevError, vd.DevReply = common.CheckError(err)
+	return vd.RunDeviceReply(common.CmdPrintText)
+}
+func (vd *VirtualDriver) PrintDocument(name string, query *common.PrinterDocument) error {
+	err := vd.DevPrintDocument(query)
+	vd.DevError, vd.DevReply = common.CheckError(err)
+	return vd.RunDeviceReply(common.CmdPrintDocument)
+}
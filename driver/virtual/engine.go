@@ -0,0 +1,324 @@
+package virtual
+
+import (
+	"fmt"
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/config"
+	"github.com/iftsoft/device/core"
+	"github.com/iftsoft/device/driver/generic"
+	"github.com/iftsoft/device/driver/printer"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	virtualPaperWidth    = 576 // Printable width of 80 mm paper in dots
+	virtualResolution    = 203
+	virtualBarcodeHeight = 80
+	virtualQRCodeSize    = 6
+)
+
+type VirtualEngine struct {
+	generic.BasePrinter
+	config    *config.DeviceConfig
+	logo      *printer.RasterImage
+	showImage config.EnumShowImage
+	printed   int32 // Receipts printed since paper was loaded
+	fileNum   int32
+}
+
+func (ve *VirtualEngine) initEngine(cfg *config.DeviceConfig) *VirtualEngine {
+	ve.config = cfg
+	ve.Log = core.GetLogAgent(core.LogLevelTrace, "Engine")
+	return ve
+}
+
+func (ve *VirtualEngine) getPrinterConfig() *config.PrinterConfig {
+	if ve.config != nil && ve.config.Printer != nil {
+		return ve.config.Printer
+	}
+	return config.GetDefaultPrinterConfig()
+}
+
+func (ve *VirtualEngine) getPaperWidth() int {
+	if width := ve.getPrinterConfig().PaperWidth; width > 0 {
+		return int(width)
+	}
+	return virtualPaperWidth
+}
+
+func (ve *VirtualEngine) getResolution() int {
+	if dpi := ve.getPrinterConfig().Resolution; dpi > 0 {
+		return int(dpi)
+	}
+	return virtualResolution
+}
+
+// Font dot size grows with resolution, 203 DPI gives 12x18 dots character cell
+func (ve *VirtualEngine) newCanvas() *Canvas {
+	return newCanvas(ve.getPaperWidth(), ve.getResolution()/100)
+}
+
+func (ve *VirtualEngine) isPaperOut() bool {
+	limit := ve.getPrinterConfig().PaperLimit
+	return limit > 0 && ve.printed >= limit
+}
+
+// Put paper counters and alert to device metrics, the last tenth of paper limit is near end
+func (ve *VirtualEngine) checkPaper(metrics *common.SystemMetrics) {
+	limit := ve.getPrinterConfig().PaperLimit
+	metrics.Counts["paper_count"] = uint32(ve.printed)
+	metrics.Counts["paper_limit"] = uint32(limit)
+	switch {
+	case ve.isPaperOut():
+		metrics.Topics["paper"] = common.DevErrorPaperOut.String()
+	case limit > 0 && (limit-ve.printed)*10 <= limit:
+		metrics.Topics["paper"] = "Paper is near end"
+	}
+}
+
+// Check output type and directory, load logo image by printer config
+func (ve *VirtualEngine) setupPrinter(showImage config.EnumShowImage) error {
+	prnCfg := ve.getPrinterConfig()
+	switch strings.ToLower(prnCfg.OutputType) {
+	case "", OutputPNG, OutputPDF:
+	default:
+		return common.NewError(common.DevErrorConfigFault,
+			fmt.Sprintf("unknown output type '%s'", prnCfg.OutputType))
+	}
+	if prnCfg.OutputDir != "" {
+		err := os.MkdirAll(prnCfg.OutputDir, 0755)
+		if err != nil {
+			return common.NewError(common.DevErrorConfigFault, err.Error())
+		}
+	}
+	ve.logo, ve.showImage = nil, showImage
+	if showImage != config.ShowImageNone && prnCfg.ImageFile != "" {
+		var err error
+		ve.logo, err = printer.LoadImage(prnCfg.ImageFile, ve.getPaperWidth())
+		if err != nil {
+			return common.NewError(common.DevErrorConfigFault, err.Error())
+		}
+	}
+	return nil
+}
+
+// Report paper state, error is returned if there is no paper
+func (ve *VirtualEngine) checkStatus() error {
+	if ve.isPaperOut() {
+		_ = ve.RunStateChanged(common.DevStatePrnPaperOut)
+		if ve.DevError != common.DevErrorPaperOut {
+			_ = ve.RunExecuteError(common.DevErrorPaperOut, "paper limit is reached")
+		}
+		return common.NewError(common.DevErrorPaperOut, "paper limit is reached")
+	}
+	_ = ve.RunStateChanged(common.DevStateReady)
+	ve.DevError = common.DevErrorSuccess
+	return nil
+}
+
+////////////////////////////////////////////////////////////////
+
+func (ve *VirtualEngine) DevStartup() error {
+	err := ve.setupPrinter(ve.getPrinterConfig().ShowImage)
+	if err == nil {
+		err = ve.checkStatus()
+	}
+	return err
+}
+
+func (ve *VirtualEngine) DevCleanup() error {
+	_ = ve.RunStateChanged(common.DevStateOffLine)
+	return nil
+}
+
+func (ve *VirtualEngine) DevStatus() error {
+	return ve.checkStatus()
+}
+
+// DevInitPrinter loads new paper roll, show image of the setup overrides the config
+func (ve *VirtualEngine) DevInitPrinter(setup *common.PrinterSetup) error {
+	showImage := ve.getPrinterConfig().ShowImage
+	if setup != nil && setup.ShowImage > 0 {
+		showImage = config.EnumShowImage(setup.ShowImage)
+	}
+	err := ve.setupPrinter(showImage)
+	if err == nil {
+		ve.printed = 0
+		err = ve.checkStatus()
+	}
+	return err
+}
+
+// DevPrintText prints the text, form feed splits it to pages
+func (ve *VirtualEngine) DevPrintText(text string) error {
+	texts := strings.Split(strings.TrimRight(text, "\f"), "\f")
+	pages := make([]*Canvas, 0, len(texts))
+	for i, page := range texts {
+		items := make([]*common.DocumentItem, 0)
+		if ve.showImage == config.ShowImagePage || (ve.showImage == config.ShowImageOnes && i == 0) {
+			items = append(items, &common.DocumentItem{Kind: common.DocItemImage, Align: common.DocAlignCenter})
+		}
+		for _, line := range strings.Split(strings.TrimRight(page, "\n"), "\n") {
+			items = append(items, &common.DocumentItem{Kind: common.DocItemText, Text: strings.TrimRight(line, "\r")})
+		}
+		canvas, err := ve.renderPage(items)
+		if err != nil {
+			return err
+		}
+		pages = append(pages, canvas)
+	}
+	return ve.printPages(common.CmdPrintText, pages)
+}
+
+// DevPrintDocument renders the document and prints it
+func (ve *VirtualEngine) DevPrintDocument(doc *common.PrinterDocument) error {
+	if doc == nil {
+		return common.NewError(common.DevErrorBadArgument, "document is empty")
+	}
+	pages := make([]*Canvas, 0)
+	for _, items := range doc.GetPages() {
+		canvas, err := ve.renderPage(items)
+		if err != nil {
+			return err
+		}
+		pages = append(pages, canvas)
+	}
+	return ve.printPages(doc.DocName, pages)
+}
+
+// Save pages to files while there is paper and report progress
+func (ve *VirtualEngine) printPages(docName string, pages []*Canvas) error {
+	err := ve.checkStatus()
+	if err != nil {
+		return err
+	}
+	_ = ve.RunActionPrompt(common.DevPromptPrintText)
+	_ = ve.RunStateChanged(common.DevStateWorking)
+	ve.fileNum++
+	ve.Progress = common.PrinterProgress{DocName: docName, PagesAll: int32(len(pages))}
+	images := make([]*image.Gray, 0, len(pages))
+	for i, page := range pages {
+		err = ve.checkStatus()
+		if err != nil {
+			break
+		}
+		images = append(images, page.Image())
+		if ve.getOutputType() == OutputPNG {
+			err = writePNG(ve.getFileName(docName, i+1), images[i])
+			if err != nil {
+				err = common.ExtendError(common.DevErrorHardwareFault, err)
+				break
+			}
+		}
+		ve.printed++
+		ve.Progress.PageDone = int32(i + 1)
+		_ = ve.RunPrinterProgress(&ve.Progress)
+	}
+	// PDF file keeps pages printed before paper out
+	if ve.getOutputType() == OutputPDF && len(images) > 0 {
+		errPdf := writePDF(ve.getFileName(docName, 0), images, ve.getResolution())
+		if err == nil {
+			err = common.ExtendError(common.DevErrorHardwareFault, errPdf)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	_ = ve.RunActionPrompt(common.DevPromptNone)
+	_ = ve.checkStatus()
+	return nil
+}
+
+func (ve *VirtualEngine) getOutputType() string {
+	if strings.ToLower(ve.getPrinterConfig().OutputType) == OutputPDF {
+		return OutputPDF
+	}
+	return OutputPNG
+}
+
+// File name is made of document name, document number and page number for PNG files
+func (ve *VirtualEngine) getFileName(docName string, page int) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' {
+			return r
+		}
+		return '_'
+	}, docName)
+	if name == "" {
+		name = "receipt"
+	}
+	name = fmt.Sprintf("%s_%04d", name, ve.fileNum)
+	if page > 0 {
+		name = fmt.Sprintf("%s_%02d", name, page)
+	}
+	return filepath.Join(ve.getPrinterConfig().OutputDir, name+"."+ve.getOutputType())
+}
+
+////////////////////////////////////////////////////////////////
+
+// Render page items of the document to paper tape, the tape is fed by eject length before cut
+func (ve *VirtualEngine) renderPage(items []*common.DocumentItem) (*Canvas, error) {
+	canvas := ve.newCanvas()
+	for _, item := range items {
+		err := ve.renderItem(canvas, item)
+		if err != nil {
+			return nil, err
+		}
+	}
+	canvas.Feed(int(ve.getPrinterConfig().EjectLength) * canvas.LineHeight(1))
+	return canvas, nil
+}
+
+func (ve *VirtualEngine) renderItem(canvas *Canvas, item *common.DocumentItem) error {
+	width, height := int(item.Width), int(item.Height)
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+	chars := canvas.CharsPerLine(width)
+	switch item.Kind {
+	case common.DocItemText:
+		canvas.DrawText(item.Text, item.Align, item.Bold, item.Underline, width, height)
+	case common.DocItemColumns:
+		canvas.DrawText(printer.FormatColumns(item.Columns, chars), item.Align, item.Bold, item.Underline, width, height)
+	case common.DocItemSeparator:
+		canvas.DrawText(printer.Separator(item.Text, chars), item.Align, item.Bold, false, width, height)
+	case common.DocItemImage:
+		img := ve.logo
+		if item.Text != "" {
+			prnCfg := ve.getPrinterConfig()
+			path, err := printer.ImagePath(item.Text, prnCfg.ImageDir, prnCfg.ImageFile)
+			if err == nil {
+				img, err = printer.LoadImage(path, ve.getPaperWidth())
+			}
+			if err != nil {
+				return common.NewError(common.DevErrorBadArgument, err.Error())
+			}
+		}
+		canvas.DrawImage(img, item.Align)
+	case common.DocItemBarcode:
+		if item.Height > 0 {
+			height = int(item.Height)
+		} else {
+			height = virtualBarcodeHeight
+		}
+		canvas.DrawFrame(item.Barcode.String(), canvas.width*3/4, height, item.Align)
+		canvas.DrawText(item.Text, item.Align, false, false, 1, 1)
+	case common.DocItemQRCode:
+		size := int(item.Width)
+		if size == 0 {
+			size = virtualQRCodeSize
+		}
+		// Version 3 QR code is 29 modules wide
+		canvas.DrawFrame("QR", 29*size, 29*size, item.Align)
+		canvas.DrawText(item.Text, item.Align, false, false, 1, 1)
+	case common.DocItemFeed:
+		canvas.Feed(width * canvas.LineHeight(1))
+	}
+	return nil
+}
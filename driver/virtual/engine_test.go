@@ -0,0 +1,118 @@
+package virtual
+
+import (
+	"bytes"
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/config"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestEngine(t *testing.T, prnCfg *config.PrinterConfig) *VirtualEngine {
+	t.Helper()
+	prnCfg.OutputDir = filepath.Join(t.TempDir(), "out")
+	ve := (&VirtualEngine{}).initEngine(&config.DeviceConfig{Printer: prnCfg})
+	if err := ve.DevStartup(); err != nil {
+		t.Fatal(err)
+	}
+	return ve
+}
+
+func TestVirtualPrintText(t *testing.T) {
+	ve := newTestEngine(t, &config.PrinterConfig{EjectLength: 1})
+	if err := ve.DevPrintText("Hello\r\nWorld\n\fPage 2"); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(ve.getPrinterConfig().OutputDir, "*.png"))
+	if len(files) != 2 || filepath.Base(files[0]) != "PrintText_0001_01.png" {
+		t.Fatalf("printed files %v", files)
+	}
+	file, err := os.Open(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	img, err := png.Decode(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 203 DPI gives 18 rows per line, two lines of text and one line of eject
+	if img.Bounds().Dx() != virtualPaperWidth || img.Bounds().Dy() != 3*18 {
+		t.Errorf("page image size %s", img.Bounds())
+	}
+	if ve.printed != 2 || ve.Progress.PageDone != 2 || ve.DevState != common.DevStateReady {
+		t.Errorf("printed %d pages, done %d, state %s", ve.printed, ve.Progress.PageDone, ve.DevState)
+	}
+}
+
+func TestVirtualPaperLimit(t *testing.T) {
+	ve := newTestEngine(t, &config.PrinterConfig{OutputType: "PDF", PaperLimit: 1})
+	err := ve.DevPrintText("Page 1\fPage 2")
+	if code, _ := common.CheckError(err); code != common.DevErrorPaperOut || ve.DevState != common.DevStatePrnPaperOut {
+		t.Fatalf("printing over paper limit: %v, state %s", err, ve.DevState)
+	}
+	// PDF file keeps the page printed before paper out
+	data, err := ioutil.ReadFile(ve.getFileName(common.CmdPrintText, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(data, []byte("%%EOF\n")) ||
+		!bytes.Contains(data, []byte("/Count 1 ")) {
+		t.Error("PDF file is not complete")
+	}
+	if err = ve.DevPrintDocument(&common.PrinterDocument{DocName: "Receipt"}); err == nil {
+		t.Error("document is printed without paper")
+	}
+	// New paper roll resets the limit
+	if err = ve.DevInitPrinter(nil); err != nil || ve.printed != 0 || ve.DevState != common.DevStateReady {
+		t.Errorf("paper is not loaded: %v", err)
+	}
+}
+
+func TestVirtualSetup(t *testing.T) {
+	ve := (&VirtualEngine{}).initEngine(&config.DeviceConfig{Printer: &config.PrinterConfig{OutputType: "bmp"}})
+	if code, _ := common.CheckError(ve.DevStartup()); code != common.DevErrorConfigFault {
+		t.Errorf("unknown output type gives %s", code)
+	}
+	ve = (&VirtualEngine{}).initEngine(&config.DeviceConfig{Printer: &config.PrinterConfig{
+		ShowImage: config.ShowImageOnes, ImageFile: filepath.Join(t.TempDir(), "logo.png")}})
+	if code, _ := common.CheckError(ve.DevStartup()); code != common.DevErrorConfigFault {
+		t.Errorf("missing logo file gives %s", code)
+	}
+
+	ve.fileNum = 7
+	if name := filepath.Base(ve.getFileName("../Чек 1", 2)); name != "_______1_0007_02.png" {
+		t.Errorf("file name %s", name)
+	}
+	if name := filepath.Base(ve.getFileName("", 0)); name != "receipt_0007.png" {
+		t.Errorf("file name %s", name)
+	}
+}
+
+func TestVirtualRenderPage(t *testing.T) {
+	ve := (&VirtualEngine{}).initEngine(&config.DeviceConfig{Printer: &config.PrinterConfig{EjectLength: 2}})
+	items := []*common.DocumentItem{
+		{Kind: common.DocItemText, Text: "Receipt", Height: 2},
+		{Kind: common.DocItemSeparator, Text: "="},
+		{Kind: common.DocItemBarcode, Text: "4820000000001", Height: 60},
+		{Kind: common.DocItemQRCode, Text: "AB", Width: 4},
+		{Kind: common.DocItemFeed, Width: 3},
+		// Image without logo is skipped
+		{Kind: common.DocItemImage},
+	}
+	canvas, err := ve.renderPage(items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := 2*18 + 18 + 60 + 18 + 29*4 + 18 + 3*18 + 2*18
+	if canvas.height != want {
+		t.Errorf("page is %d rows, want %d", canvas.height, want)
+	}
+	items = append(items, &common.DocumentItem{Kind: common.DocItemImage, Text: "../logo.png"})
+	if _, err = ve.renderPage(items); err == nil {
+		t.Error("image out of image directory is printed")
+	}
+}
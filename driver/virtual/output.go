@@ -0,0 +1,93 @@
+package virtual
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+)
+
+// Output file types
+const (
+	OutputPNG = "png"
+	OutputPDF = "pdf"
+)
+
+// writePNG saves the page image to PNG file
+func writePNG(fileName string, img *image.Gray) error {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fileName, buf.Bytes(), 0644)
+}
+
+// writePDF saves page images to PDF file, page size is taken from image size and resolution
+func writePDF(fileName string, pages []*image.Gray, dpi int) error {
+	pdf := &pdfWriter{}
+	pdf.printf("%%PDF-1.4\n")
+	// Objects: 1 - catalog, 2 - page tree, then page, content and image of every page
+	pdf.object(1, "<< /Type /Catalog /Pages 2 0 R >>")
+	kids := ""
+	for i := range pages {
+		kids += fmt.Sprintf("%d 0 R ", 3+3*i)
+	}
+	pdf.object(2, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids, len(pages)))
+	for i, img := range pages {
+		page, content, image := 3+3*i, 4+3*i, 5+3*i
+		w := float64(img.Rect.Dx()) * 72 / float64(dpi)
+		h := float64(img.Rect.Dy()) * 72 / float64(dpi)
+		pdf.object(page, fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] "+
+			"/Resources << /XObject << /Im0 %d 0 R >> >> /Contents %d 0 R >>", w, h, image, content))
+		draw := fmt.Sprintf("q %.2f 0 0 %.2f 0 0 cm /Im0 Do Q", w, h)
+		pdf.stream(content, "", []byte(draw))
+		var data bytes.Buffer
+		zw := zlib.NewWriter(&data)
+		_, err := zw.Write(img.Pix)
+		if err == nil {
+			err = zw.Close()
+		}
+		if err != nil {
+			return err
+		}
+		pdf.stream(image, fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d "+
+			"/ColorSpace /DeviceGray /BitsPerComponent 8 /Filter /FlateDecode ",
+			img.Rect.Dx(), img.Rect.Dy()), data.Bytes())
+	}
+	pdf.finish(2 + 3*len(pages))
+	return ioutil.WriteFile(fileName, pdf.buf.Bytes(), 0644)
+}
+
+// Minimal PDF writer that keeps object offsets for cross-reference table
+type pdfWriter struct {
+	buf     bytes.Buffer
+	offsets []int
+}
+
+func (pw *pdfWriter) printf(format string, args ...interface{}) {
+	_, _ = fmt.Fprintf(&pw.buf, format, args...)
+}
+
+func (pw *pdfWriter) object(num int, body string) {
+	pw.offsets = append(pw.offsets, pw.buf.Len())
+	pw.printf("%d 0 obj\n%s\nendobj\n", num, body)
+}
+
+func (pw *pdfWriter) stream(num int, dict string, data []byte) {
+	pw.offsets = append(pw.offsets, pw.buf.Len())
+	pw.printf("%d 0 obj\n<< %s/Length %d >>\nstream\n", num, dict, len(data))
+	pw.buf.Write(data)
+	pw.printf("\nendstream\nendobj\n")
+}
+
+func (pw *pdfWriter) finish(count int) {
+	start := pw.buf.Len()
+	pw.printf("xref\n0 %d\n0000000000 65535 f \n", count+1)
+	for _, offset := range pw.offsets {
+		pw.printf("%010d 00000 n \n", offset)
+	}
+	pw.printf("trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", count+1, start)
+}
@@ -87,7 +87,7 @@ func NewPacket(scope PacketScope, name string, cmd string, data []byte) *Packet
 func (p *Packet) Print(log *core.LogAgent, text string) {
 	if p != nil && log != nil {
 		log.Dump("%s packet Device:%s, Scope:%d(%s), Command:%s, Data len:%d, Content:%s",
-			text, p.DevName, p.Scope, GetScopeName(p.Scope), p.Command, len(p.Content), string(p.Content))
+			text, p.DevName, p.Scope, GetScopeName(p.Scope), p.Command, len(p.Content), core.RedactJson(p.Content))
 	}
 }
 
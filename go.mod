@@ -1,5 +1,9 @@
 module github.com/iftsoft/device
 
-go 1.17
+go 1.21
 
-require gopkg.in/yaml.v2 v2.4.0 // indirect
+require (
+	github.com/karalabe/hid v1.0.1-0.20240306101548-573246063e52
+	github.com/mattn/go-sqlite3 v1.14.52
+	gopkg.in/yaml.v2 v2.4.0
+)
@@ -21,6 +21,7 @@ type DeviceHandler struct {
 	readerCbk    []common.ReaderCallback
 	validatorCbk []common.ValidatorCallback
 	pinpadCbk    []common.PinPadCallback
+	dispenserCbk []common.DispenserCallback
 	isRunning    bool
 	log          *core.LogAgent
 	done         chan struct{}
@@ -37,6 +38,7 @@ func NewDeviceHandler(name string, cfg *config.HandlerConfig, log *core.LogAgent
 		readerCbk:    make([]common.ReaderCallback, 0),
 		validatorCbk: make([]common.ValidatorCallback, 0),
 		pinpadCbk:    make([]common.PinPadCallback, 0),
+		dispenserCbk: make([]common.DispenserCallback, 0),
 		isRunning:    false,
 		log:          log,
 		done:         make(chan struct{}),
@@ -75,6 +77,9 @@ func (dh *DeviceHandler) AttachReflex(name string, reflex interface{}) error {
 	if pinpad, ok := reflex.(common.PinPadCallback); ok {
 		dh.pinpadCbk = append(dh.pinpadCbk, pinpad)
 	}
+	if dispenser, ok := reflex.(common.DispenserCallback); ok {
+		dh.dispenserCbk = append(dh.dispenserCbk, dispenser)
+	}
 	return nil
 }
 
@@ -287,7 +292,7 @@ func (dh *DeviceHandler) CardPosition(name string, reply *common.ReaderCardPos)
 func (dh *DeviceHandler) CardDescription(name string, reply *common.ReaderCardInfo) error {
 	if dh.log != nil {
 		dh.log.Debug("DeviceHandler.CardDescription dev:%s, CardPAN:%s, ExpDate:%s",
-			name, reply.CardPan, reply.ExpDate)
+			name, core.MaskPan(reply.CardPan), reply.ExpDate)
 	}
 	for _, cb := range dh.readerCbk {
 		go func(callback common.ReaderCallback) {
@@ -379,4 +384,44 @@ func (dh *DeviceHandler) PinPadReply(name string, reply *common.ReaderPinReply)
 	}
 	return nil
 }
+func (dh *DeviceHandler) PinPadKeyPress(name string, value *common.ReaderKeyPress) error {
+	if dh.log != nil {
+		dh.log.Debug("DeviceHandler.PinPadKeyPress dev:%s, Value: %s",
+			name, value.String())
+	}
+	for _, cb := range dh.pinpadCbk {
+		go func(callback common.PinPadCallback) {
+			defer dh.panicRecover()
+			_ = callback.PinPadKeyPress(name, value)
+		}(cb)
+	}
+	return nil
+}
 
+// Implementation of common.DispenserCallback
+func (dh *DeviceHandler) CashDispensed(name string, reply *common.DispenserPayout) error {
+	if dh.log != nil {
+		dh.log.Debug("DeviceHandler.CashDispensed dev:%s, Reply: %s",
+			name, reply.String())
+	}
+	for _, cb := range dh.dispenserCbk {
+		go func(callback common.DispenserCallback) {
+			defer dh.panicRecover()
+			_ = callback.CashDispensed(name, reply)
+		}(cb)
+	}
+	return nil
+}
+func (dh *DeviceHandler) DispenserStore(name string, reply *common.DispenserStore) error {
+	if dh.log != nil {
+		dh.log.Debug("DeviceHandler.DispenserStore dev:%s, Reply: %s",
+			name, reply.String())
+	}
+	for _, cb := range dh.dispenserCbk {
+		go func(callback common.DispenserCallback) {
+			defer dh.panicRecover()
+			_ = callback.DispenserStore(name, reply)
+		}(cb)
+	}
+	return nil
+}
@@ -14,6 +14,7 @@ type HandlerProxy struct {
 	readerSrv    *proxy.ReaderServer
 	validatorSrv *proxy.ValidatorServer
 	pinpadSrv    *proxy.PinPadServer
+	dispenserSrv *proxy.DispenserServer
 }
 
 
@@ -24,6 +25,7 @@ func (hp *HandlerProxy) initProxy() {
 	hp.readerSrv    = proxy.NewReaderServer()
 	hp.validatorSrv = proxy.NewValidatorServer()
 	hp.pinpadSrv    = proxy.NewPinPadServer()
+	hp.dispenserSrv = proxy.NewDispenserServer()
 }
 
 func (hp *HandlerProxy) setupProxy(server duplex.ServerManager, hr *HandlerRouter) {
@@ -34,6 +36,7 @@ func (hp *HandlerProxy) setupProxy(server duplex.ServerManager, hr *HandlerRoute
 	hp.readerSrv.Init(server, hr, hr.log)
 	hp.validatorSrv.Init(server, hr, hr.log)
 	hp.pinpadSrv.Init(server, hr, hr.log)
+	hp.dispenserSrv.Init(server, hr, hr.log)
 }
 
 
@@ -79,6 +82,9 @@ func (hp *HandlerProxy) InitPrinter(name string, query *common.PrinterSetup) err
 func (hp *HandlerProxy) PrintText(name string, query *common.PrinterQuery) error {
 	return hp.printerSrv.SendPrinterCommand(name, common.CmdPrintText, query)
 }
+func (hp *HandlerProxy) PrintDocument(name string, query *common.PrinterDocument) error {
+	return hp.printerSrv.SendPrinterCommand(name, common.CmdPrintDocument, query)
+}
 
 // Implementation of common.ReaderManager
 func (hp *HandlerProxy) EnterCard(name string, query *common.DeviceQuery) error {
@@ -142,3 +148,26 @@ func (hp *HandlerProxy) TestMasterKey(name string, query *common.ReaderPinQuery)
 func (hp *HandlerProxy) TestWorkKey(name string, query *common.ReaderPinQuery) error {
 	return hp.pinpadSrv.SendPinPadCommand(name, common.CmdTestWorkKey, query)
 }
+func (hp *HandlerProxy) LoadDukptKey(name string, query *common.ReaderPinQuery) error {
+	return hp.pinpadSrv.SendPinPadCommand(name, common.CmdLoadDukptKey, query)
+}
+func (hp *HandlerProxy) GenerateMAC(name string, query *common.ReaderPinQuery) error {
+	return hp.pinpadSrv.SendPinPadCommand(name, common.CmdGenerateMAC, query)
+}
+func (hp *HandlerProxy) ReadData(name string, query *common.ReaderDataQuery) error {
+	return hp.pinpadSrv.SendPinPadCommand(name, common.CmdReadData, query)
+}
+
+// Implementation of common.DispenserManager
+func (hp *HandlerProxy) InitDispenser(name string, query *common.DispenserQuery) error {
+	return hp.dispenserSrv.SendDispenserCommand(name, common.CmdInitDispenser, query)
+}
+func (hp *HandlerProxy) DispenseCash(name string, query *common.DispenserQuery) error {
+	return hp.dispenserSrv.SendDispenserCommand(name, common.CmdDispenseCash, query)
+}
+func (hp *HandlerProxy) StopDispense(name string, query *common.DispenserQuery) error {
+	return hp.dispenserSrv.SendDispenserCommand(name, common.CmdStopDispense, query)
+}
+func (hp *HandlerProxy) CheckDispenser(name string, query *common.DispenserQuery) error {
+	return hp.dispenserSrv.SendDispenserCommand(name, common.CmdCheckDispenser, query)
+}
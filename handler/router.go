@@ -221,3 +221,26 @@ func (hr *HandlerRouter) PinPadReply(name string, reply *common.ReaderPinReply)
 	}
 	return nil
 }
+func (hr *HandlerRouter) PinPadKeyPress(name string, value *common.ReaderKeyPress) error {
+	handler := hr.getDeviceHandler(name)
+	if handler != nil {
+		return handler.PinPadKeyPress(name, value)
+	}
+	return nil
+}
+
+// Implementation of common.DispenserCallback
+func (hr *HandlerRouter) CashDispensed(name string, reply *common.DispenserPayout) error {
+	handler := hr.getDeviceHandler(name)
+	if handler != nil {
+		return handler.CashDispensed(name, reply)
+	}
+	return nil
+}
+func (hr *HandlerRouter) DispenserStore(name string, reply *common.DispenserStore) error {
+	handler := hr.getDeviceHandler(name)
+	if handler != nil {
+		return handler.DispenserStore(name, reply)
+	}
+	return nil
+}
@@ -59,6 +59,7 @@ type DeviceTester struct {
 	readerMng    common.ReaderManager
 	validatorMng common.ValidatorManager
 	pinpadMng    common.PinPadManager
+	dispenserMng common.DispenserManager
 	log          *core.LogAgent
 	done         chan struct{}
 	tests        []*TestItem
@@ -84,6 +85,9 @@ func (dt *DeviceTester) InitPlugin(proxy interface{}) error {
 	if pinpad, ok := proxy.(common.PinPadManager); ok {
 		dt.pinpadMng = pinpad
 	}
+	if dispenser, ok := proxy.(common.DispenserManager); ok {
+		dt.dispenserMng = dispenser
+	}
 	return nil
 }
 
@@ -282,7 +286,7 @@ func (oh *DeviceTester) CardPosition(name string, reply *common.ReaderCardPos) e
 func (oh *DeviceTester) CardDescription(name string, reply *common.ReaderCardInfo) error {
 	if oh.log != nil {
 		oh.log.Debug("DeviceTester.CardDescription dev:%s, CardPAN:%s, ExpDate:%s",
-			name, reply.CardPan, reply.ExpDate)
+			name, core.MaskPan(reply.CardPan), reply.ExpDate)
 	}
 	return nil
 }
@@ -299,7 +303,7 @@ func (oh *DeviceTester) NoteAccepted(name string, reply *common.ValidatorAccept)
 	if oh.log != nil {
 		oh.log.Debug("DeviceTester.NoteAccepted dev:%s, Reply: %s",
 			name, reply.String())
-		query := common.ValidatorQuery{ reply.Currency, 0 }
+		query := common.ValidatorQuery{Currency: reply.Currency}
 		_ = oh.validatorMng.NoteAccept(name, &query)
 	}
 	return nil
@@ -334,4 +338,26 @@ func (oh *DeviceTester) PinPadReply(name string, reply *common.ReaderPinReply) e
 	}
 	return nil
 }
+func (oh *DeviceTester) PinPadKeyPress(name string, value *common.ReaderKeyPress) error {
+	if oh.log != nil {
+		oh.log.Debug("DeviceTester.PinPadKeyPress dev:%s, Value: %s",
+			name, value.String())
+	}
+	return nil
+}
 
+// Implementation of common.DispenserCallback
+func (oh *DeviceTester) CashDispensed(name string, reply *common.DispenserPayout) error {
+	if oh.log != nil {
+		oh.log.Debug("DeviceTester.CashDispensed dev:%s, Reply: %s",
+			name, reply.String())
+	}
+	return nil
+}
+func (oh *DeviceTester) DispenserStore(name string, reply *common.DispenserStore) error {
+	if oh.log != nil {
+		oh.log.Debug("DeviceTester.DispenserStore dev:%s, Reply: %s",
+			name, reply.String())
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package linker
+
+import (
+	"errors"
+	"github.com/iftsoft/device/config"
+	"github.com/iftsoft/device/core"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var errPortLost = errors.New("port disappeared")
+
+// ChaosStats counts injected faults
+type ChaosStats struct {
+	Dropped    uint32
+	Duplicated uint32
+	Corrupted  uint32
+	Splits     uint32
+	Silenced   uint32 // Chunks lost in silence periods
+	OpenFails  uint32
+}
+
+// ChaosLink injects faults into data that wrapped port receives.
+// Faults are set by config and may be changed by test at any moment.
+type ChaosLink struct {
+	log     *core.LogAgent
+	port    PortLinker
+	reader  PortReader
+	lock    sync.Mutex // Guards settings, random and stats
+	config  config.ChaosConfig
+	random  *rand.Rand
+	lost    bool
+	silence time.Time
+	stats   ChaosStats
+	feed    sync.Mutex // Guards rest of received data
+	rest    []byte
+}
+
+// NewChaosLink wraps port that is made by create function for chaos link as its reader
+func NewChaosLink(cfg *config.ChaosConfig, call PortReader, create func(reader PortReader) PortLinker) *ChaosLink {
+	c := &ChaosLink{
+		log:    core.GetLogAgent(core.LogLevelDebug, "Chaos"),
+		reader: call,
+	}
+	if cfg == nil {
+		cfg = &config.ChaosConfig{}
+	}
+	c.SetConfig(*cfg)
+	c.port = create(c)
+	return c
+}
+
+// SetConfig changes faults and restarts random sequence
+func (c *ChaosLink) SetConfig(cfg config.ChaosConfig) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	c.config = cfg
+	c.random = rand.New(rand.NewSource(seed))
+}
+
+// SetLost simulates port disappearance, Open and Write fail and data are lost
+func (c *ChaosLink) SetLost(lost bool) {
+	c.lock.Lock()
+	c.lost = lost
+	c.lock.Unlock()
+	c.log.Debug("ChaosLink port lost: %t", lost)
+}
+
+// StartSilence loses all received data for given period
+func (c *ChaosLink) StartSilence(period time.Duration) {
+	c.lock.Lock()
+	c.silence = time.Now().Add(period)
+	c.lock.Unlock()
+}
+
+func (c *ChaosLink) GetStats() ChaosStats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.stats
+}
+
+////////////////////////////////////////////////////////////////
+
+func (c *ChaosLink) Open() error {
+	c.lock.Lock()
+	fail := c.lost || c.chance(c.config.OpenFail)
+	if fail {
+		c.stats.OpenFails++
+	}
+	c.lock.Unlock()
+	if fail {
+		c.log.Debug("ChaosLink fails Open")
+		return errPortLost
+	}
+	c.feed.Lock()
+	c.rest = nil
+	c.feed.Unlock()
+	return c.port.Open()
+}
+
+func (c *ChaosLink) Close() error {
+	return c.port.Close()
+}
+
+func (c *ChaosLink) Flash() error {
+	c.feed.Lock()
+	c.rest = nil
+	c.feed.Unlock()
+	return c.port.Flash()
+}
+
+func (c *ChaosLink) IsOpen() bool {
+	c.lock.Lock()
+	lost := c.lost
+	c.lock.Unlock()
+	return !lost && c.port.IsOpen()
+}
+
+func (c *ChaosLink) Write(data []byte) (int, error) {
+	c.lock.Lock()
+	lost := c.lost
+	c.lock.Unlock()
+	if lost {
+		return 0, errPortLost
+	}
+	return c.port.Write(data)
+}
+
+// implementation of PortReader interface, wrapped port gets all data consumed
+
+func (c *ChaosLink) OnRead(dump []byte) int {
+	parts, delay := c.spoil(dump)
+	c.feed.Lock()
+	defer c.feed.Unlock()
+	for _, part := range parts {
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+		c.log.Dump("ChaosLink read data : %s", core.GetBinaryDump(part))
+		c.rest = feedReader(c.reader, append(append([]byte{}, c.rest...), part...))
+	}
+	return len(dump)
+}
+
+// Apply faults to received chunk, it may come in several parts with delay before each
+func (c *ChaosLink) spoil(dump []byte) ([][]byte, time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	cfg := &c.config
+	now := time.Now()
+	if c.chance(cfg.SilenceRate) {
+		c.silence = now.Add(time.Duration(cfg.Silence) * time.Millisecond)
+	}
+	if c.lost || now.Before(c.silence) {
+		c.stats.Silenced++
+		return nil, 0
+	}
+	data := make([]byte, 0, len(dump))
+	for _, b := range dump {
+		if c.chance(cfg.DropRate) {
+			c.stats.Dropped++
+			continue
+		}
+		if c.chance(cfg.CorruptRate) {
+			c.stats.Corrupted++
+			b ^= byte(1 << uint(c.random.Intn(8)))
+		}
+		data = append(data, b)
+		if c.chance(cfg.DupRate) {
+			c.stats.Duplicated++
+			data = append(data, b)
+		}
+	}
+	var delay time.Duration
+	if cfg.Latency > 0 {
+		delay = time.Duration(c.random.Intn(int(cfg.Latency)+1)) * time.Millisecond
+	}
+	if len(data) > 1 && c.chance(cfg.SplitRate) {
+		c.stats.Splits++
+		pos := 1 + c.random.Intn(len(data)-1)
+		return [][]byte{data[:pos], data[pos:]}, delay
+	}
+	return [][]byte{data}, delay
+}
+
+func (c *ChaosLink) chance(rate float64) bool {
+	return rate > 0 && c.random.Float64() < rate
+}
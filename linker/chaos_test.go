@@ -0,0 +1,159 @@
+package linker
+
+import (
+	"bytes"
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/config"
+	"testing"
+	"time"
+)
+
+// Half duplex engine talks to echo device through chaos link
+func newChaosDuplex(cfg *config.ChaosConfig, retries int) (*HalfDuplex, *ChaosLink, *testPort) {
+	opts := GetDefaultHalfDuplexOptions()
+	opts.Retries = retries
+	hd := NewHalfDuplex(&config.LinkerConfig{Timeout: 30}, testFramer, opts, "Test")
+	var port *testPort
+	chaos := NewChaosLink(cfg, hd, func(reader PortReader) PortLinker {
+		port = &testPort{reader: reader, answer: func(n int, data []byte) [][]byte {
+			return [][]byte{data}
+		}}
+		return port
+	})
+	hd.port = chaos
+	return hd, chaos, port
+}
+
+// Run exchanges and check that no reply of other request is taken, count of good exchanges is returned
+func runChaosExchanges(t *testing.T, hd *HalfDuplex, count int) int {
+	t.Helper()
+	good := 0
+	for i := 0; i < count; i++ {
+		data := []byte{0x30, byte(i)}
+		back, err := hd.Exchange(data)
+		if err != nil {
+			continue
+		}
+		if !bytes.Equal(back, data) {
+			t.Fatalf("exchange %d got reply % X", i, back)
+		}
+		good++
+	}
+	return good
+}
+
+// Engine must work again as soon as faults are over
+func checkRecovery(t *testing.T, hd *HalfDuplex, chaos *ChaosLink) {
+	t.Helper()
+	chaos.SetConfig(config.ChaosConfig{Seed: 1})
+	if good := runChaosExchanges(t, hd, 5); good != 5 {
+		t.Errorf("%d of 5 exchanges after faults", good)
+	}
+}
+
+func TestChaosFaults(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   config.ChaosConfig
+		count func(stats ChaosStats) uint32
+		good  int // Minimal count of good exchanges of 40
+	}{
+		{"drop", config.ChaosConfig{DropRate: 0.02, Seed: 11},
+			func(stats ChaosStats) uint32 { return stats.Dropped }, 36},
+		{"dup", config.ChaosConfig{DupRate: 0.02, Seed: 12},
+			func(stats ChaosStats) uint32 { return stats.Duplicated }, 36},
+		{"corrupt", config.ChaosConfig{CorruptRate: 0.02, Seed: 13},
+			func(stats ChaosStats) uint32 { return stats.Corrupted }, 36},
+		{"split", config.ChaosConfig{SplitRate: 0.5, Seed: 14},
+			func(stats ChaosStats) uint32 { return stats.Splits }, 40},
+		{"silence", config.ChaosConfig{SilenceRate: 0.1, Silence: 20, Seed: 15},
+			func(stats ChaosStats) uint32 { return stats.Silenced }, 36},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			hd, chaos, _ := newChaosDuplex(&cfg, 3)
+			if err := hd.OpenLink(); err != nil {
+				t.Fatal(err)
+			}
+			good := runChaosExchanges(t, hd, 40)
+			if n := tt.count(chaos.GetStats()); n == 0 {
+				t.Errorf("no faults are injected: %+v", chaos.GetStats())
+			}
+			if good < tt.good {
+				t.Errorf("%d good exchanges of 40, want at least %d", good, tt.good)
+			}
+			if tt.name != "split" && hd.GetStats().Retries == 0 {
+				t.Errorf("faults are not retried: %+v", hd.GetStats())
+			}
+			checkRecovery(t, hd, chaos)
+		})
+	}
+}
+
+// The same seed makes the same faults
+func TestChaosSeed(t *testing.T) {
+	cfg := config.ChaosConfig{DropRate: 0.1, CorruptRate: 0.1, SplitRate: 0.3, Seed: 42}
+	var stats []ChaosStats
+	for i := 0; i < 2; i++ {
+		reader := &testReader{}
+		chaos := NewChaosLink(&cfg, reader, func(reader PortReader) PortLinker { return NewDummyLink(reader) })
+		for j := 0; j < 20; j++ {
+			chaos.OnRead([]byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08})
+		}
+		stats = append(stats, chaos.GetStats())
+	}
+	if stats[0] != stats[1] || stats[0].Dropped == 0 || stats[0].Corrupted == 0 || stats[0].Splits == 0 {
+		t.Errorf("stats of the same seed %+v and %+v", stats[0], stats[1])
+	}
+}
+
+// Silence period loses replies until it is over
+func TestChaosSilence(t *testing.T) {
+	hd, chaos, _ := newChaosDuplex(&config.ChaosConfig{Seed: 1}, 0)
+	if err := hd.OpenLink(); err != nil {
+		t.Fatal(err)
+	}
+	chaos.StartSilence(100 * time.Millisecond)
+	_, err := hd.Exchange([]byte{0x30})
+	if code, _ := common.CheckError(err); code != common.DevErrorLinkerTimeout {
+		t.Errorf("exchange in silence: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	checkRecovery(t, hd, chaos)
+}
+
+// Port that fails to open or disappears is restored by the next open
+func TestChaosOpenFail(t *testing.T) {
+	hd, chaos, port := newChaosDuplex(&config.ChaosConfig{OpenFail: 1, Seed: 1}, 0)
+	if err := hd.OpenLink(); err == nil {
+		t.Fatal("open does not fail")
+	}
+	if stats := chaos.GetStats(); stats.OpenFails != 1 {
+		t.Errorf("stats %+v", stats)
+	}
+	chaos.SetConfig(config.ChaosConfig{Seed: 1})
+	if err := hd.OpenLink(); err != nil {
+		t.Fatal(err)
+	}
+
+	chaos.SetLost(true)
+	if chaos.IsOpen() {
+		t.Error("lost port is open")
+	}
+	_, err := hd.Exchange([]byte{0x30})
+	if code, _ := common.CheckError(err); code != common.DevErrorLinkerFault {
+		t.Errorf("exchange with lost port: %v", err)
+	}
+	if err = hd.OpenLink(); err == nil {
+		t.Error("lost port is opened")
+	}
+	if writes := port.getWrites(); len(writes) != 0 {
+		t.Errorf("lost port has writes % X", writes)
+	}
+	chaos.SetLost(false)
+	if err = hd.OpenLink(); err != nil {
+		t.Fatal(err)
+	}
+	checkRecovery(t, hd, chaos)
+}
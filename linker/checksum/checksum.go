@@ -0,0 +1,121 @@
+package checksum
+
+import (
+	"fmt"
+	"github.com/iftsoft/device/common"
+	"hash"
+	"sort"
+	"strings"
+)
+
+// Algorithm is checksum that protocol driver selects by name from config
+type Algorithm struct {
+	Name   string
+	Size   int    // Byte count of check value
+	Check  uint32 // Check value of "123456789" string
+	create func() hash.Hash32
+}
+
+// New returns streaming calculator of the algorithm
+func (a *Algorithm) New() hash.Hash32 {
+	h := a.create()
+	h.Reset()
+	return h
+}
+
+// Calc returns check value of data
+func (a *Algorithm) Calc(data []byte) uint32 {
+	h := a.New()
+	_, _ = h.Write(data)
+	return h.Sum32()
+}
+
+// Bytes returns check value of data in wire order
+func (a *Algorithm) Bytes(data []byte, lsbFirst bool) []byte {
+	return appendValue(nil, a.Calc(data), a.Size, lsbFirst)
+}
+
+func newCrcAlgorithm(name string, check uint32, params CrcParams) *Algorithm {
+	table := newCrcTable(params)
+	return &Algorithm{
+		Name:   name,
+		Size:   int(params.Width / 8),
+		Check:  check,
+		create: func() hash.Hash32 { return &crcHash{table: table} },
+	}
+}
+
+var algorithms = []*Algorithm{
+	newCrcAlgorithm("crc8", 0xF4, CrcParams{Width: 8, Poly: 0x07}),
+	newCrcAlgorithm("crc8-maxim", 0xA1, CrcParams{Width: 8, Poly: 0x31, Reflect: true}),
+	newCrcAlgorithm("crc16-kermit", 0x2189, CrcParams{Width: 16, Poly: 0x1021, Reflect: true}),
+	newCrcAlgorithm("crc16-xmodem", 0x31C3, CrcParams{Width: 16, Poly: 0x1021}),
+	newCrcAlgorithm("crc16-ccitt-false", 0x29B1, CrcParams{Width: 16, Poly: 0x1021, Init: 0xFFFF}),
+	newCrcAlgorithm("crc16-modbus", 0x4B37, CrcParams{Width: 16, Poly: 0x8005, Init: 0xFFFF, Reflect: true}),
+	newCrcAlgorithm("crc16-arc", 0xBB3D, CrcParams{Width: 16, Poly: 0x8005, Reflect: true}),
+	newCrcAlgorithm("crc32", 0xCBF43926, CrcParams{Width: 32, Poly: 0x04C11DB7, Init: 0xFFFFFFFF, Reflect: true, XorOut: 0xFFFFFFFF}),
+	{Name: "bcc", Size: 1, Check: 0x31, create: func() hash.Hash32 { return &bccHash{} }},
+	{Name: "simple", Size: 1, Check: 0x23, create: func() hash.Hash32 { return &simpleHash{} }},
+	{Name: "fletcher16", Size: 2, Check: 0x1EDE, create: func() hash.Hash32 { return &fletcherHash{} }},
+}
+
+// Other names of the algorithms
+var aliases = map[string]string{
+	"crc16-ccitt": "crc16-kermit",
+	"kermit":      "crc16-kermit",
+	"xmodem":      "crc16-xmodem",
+	"modbus":      "crc16-modbus",
+	"crc16-ibm":   "crc16-arc",
+	"lrc":         "bcc",
+	"xor":         "bcc",
+	"cctalk":      "simple",
+}
+
+// Get returns algorithm by its name or alias, the name is case insensitive
+func Get(name string) (*Algorithm, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if alias, ok := aliases[name]; ok {
+		name = alias
+	}
+	for _, alg := range algorithms {
+		if alg.Name == name {
+			return alg, nil
+		}
+	}
+	return nil, common.NewError(common.DevErrorConfigFault, fmt.Sprintf("unknown checksum %s", name))
+}
+
+// Names returns sorted list of algorithm names and aliases
+func Names() []string {
+	list := make([]string, 0, len(algorithms)+len(aliases))
+	for _, alg := range algorithms {
+		list = append(list, alg.Name)
+	}
+	for alias := range aliases {
+		list = append(list, alias)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// Verify checks all algorithms against their check values
+func Verify() error {
+	data := []byte("123456789")
+	for _, alg := range algorithms {
+		if value := alg.Calc(data); value != alg.Check {
+			return fmt.Errorf("checksum %s returns %X instead of %X", alg.Name, value, alg.Check)
+		}
+	}
+	return nil
+}
+
+func appendValue(b []byte, value uint32, size int, lsbFirst bool) []byte {
+	for i := 0; i < size; i++ {
+		shift := uint(8 * (size - 1 - i))
+		if lsbFirst {
+			shift = uint(8 * i)
+		}
+		b = append(b, byte(value>>shift))
+	}
+	return b
+}
@@ -0,0 +1,76 @@
+package checksum
+
+import (
+	"bytes"
+	"testing"
+)
+
+var testData = []byte("123456789")
+
+func TestVerify(t *testing.T) {
+	if err := Verify(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Streaming calculator fed by parts must give check value of the whole data
+func TestStreaming(t *testing.T) {
+	for _, alg := range algorithms {
+		for split := 0; split <= len(testData); split++ {
+			h := alg.New()
+			_, _ = h.Write(testData[:split])
+			_, _ = h.Write(testData[split:])
+			if value := h.Sum32(); value != alg.Check {
+				t.Errorf("%s split at %d: %X, want %X", alg.Name, split, value, alg.Check)
+			}
+		}
+		h := alg.New()
+		_, _ = h.Write([]byte("garbage"))
+		h.Reset()
+		_, _ = h.Write(testData)
+		if value := h.Sum32(); value != alg.Check {
+			t.Errorf("%s after reset: %X, want %X", alg.Name, value, alg.Check)
+		}
+		if h.Size() != alg.Size {
+			t.Errorf("%s hash size %d, want %d", alg.Name, h.Size(), alg.Size)
+		}
+		if sum := h.Sum([]byte{0xAA}); !bytes.Equal(sum, append([]byte{0xAA}, alg.Bytes(testData, false)...)) {
+			t.Errorf("%s sum % X", alg.Name, sum)
+		}
+	}
+}
+
+func TestBytes(t *testing.T) {
+	alg, err := Get("crc16-kermit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msb := alg.Bytes(testData, false); !bytes.Equal(msb, []byte{0x21, 0x89}) {
+		t.Errorf("MSB first % X", msb)
+	}
+	if lsb := alg.Bytes(testData, true); !bytes.Equal(lsb, []byte{0x89, 0x21}) {
+		t.Errorf("LSB first % X", lsb)
+	}
+}
+
+func TestGet(t *testing.T) {
+	for alias, name := range aliases {
+		alg, err := Get(alias)
+		if err != nil || alg.Name != name {
+			t.Errorf("alias %s: %v, %v", alias, alg, err)
+			continue
+		}
+		if value := alg.Calc(testData); value != alg.Check {
+			t.Errorf("alias %s: %X, want %X", alias, value, alg.Check)
+		}
+	}
+	if alg, err := Get(" CRC16-Modbus "); err != nil || alg.Name != "crc16-modbus" {
+		t.Errorf("name is not case insensitive: %v, %v", alg, err)
+	}
+	if _, err := Get("md5"); err == nil {
+		t.Error("unknown checksum is found")
+	}
+	if names := Names(); len(names) != len(algorithms)+len(aliases) {
+		t.Errorf("names %v", names)
+	}
+}
@@ -0,0 +1,114 @@
+package checksum
+
+// CrcParams describe CRC algorithm in terms of Rocksoft model.
+// Reflect means reflected both input bytes and output value.
+type CrcParams struct {
+	Width   uint8
+	Poly    uint32
+	Init    uint32
+	Reflect bool
+	XorOut  uint32
+}
+
+type crcTable struct {
+	params CrcParams
+	mask   uint32
+	table  [256]uint32
+}
+
+func newCrcTable(p CrcParams) *crcTable {
+	t := &crcTable{params: p, mask: uint32(1<<p.Width - 1)}
+	if p.Width == 32 {
+		t.mask = 0xFFFFFFFF
+	}
+	if p.Reflect {
+		poly := reflectBits(p.Poly, p.Width)
+		for i := range t.table {
+			crc := uint32(i)
+			for j := 0; j < 8; j++ {
+				if crc&1 != 0 {
+					crc = crc>>1 ^ poly
+				} else {
+					crc >>= 1
+				}
+			}
+			t.table[i] = crc
+		}
+	} else {
+		top := uint32(1) << (p.Width - 1)
+		for i := range t.table {
+			crc := uint32(i) << (p.Width - 8)
+			for j := 0; j < 8; j++ {
+				if crc&top != 0 {
+					crc = crc<<1 ^ p.Poly
+				} else {
+					crc <<= 1
+				}
+			}
+			t.table[i] = crc & t.mask
+		}
+	}
+	return t
+}
+
+func (t *crcTable) init() uint32 {
+	if t.params.Reflect {
+		return reflectBits(t.params.Init, t.params.Width)
+	}
+	return t.params.Init
+}
+
+func (t *crcTable) update(crc uint32, data []byte) uint32 {
+	if t.params.Reflect {
+		for _, b := range data {
+			crc = t.table[byte(crc)^b] ^ crc>>8
+		}
+		return crc
+	}
+	shift := t.params.Width - 8
+	for _, b := range data {
+		crc = (t.table[byte(crc>>shift)^b] ^ crc<<8) & t.mask
+	}
+	return crc
+}
+
+func reflectBits(val uint32, width uint8) uint32 {
+	var out uint32
+	for i := uint8(0); i < width; i++ {
+		if val&(1<<i) != 0 {
+			out |= 1 << (width - 1 - i)
+		}
+	}
+	return out
+}
+
+// Streaming CRC calculator
+type crcHash struct {
+	table *crcTable
+	crc   uint32
+}
+
+func (h *crcHash) Write(p []byte) (int, error) {
+	h.crc = h.table.update(h.crc, p)
+	return len(p), nil
+}
+
+func (h *crcHash) Reset() {
+	h.crc = h.table.init()
+}
+
+func (h *crcHash) Size() int {
+	return int(h.table.params.Width / 8)
+}
+
+func (h *crcHash) BlockSize() int {
+	return 1
+}
+
+func (h *crcHash) Sum32() uint32 {
+	return h.crc ^ h.table.params.XorOut
+}
+
+func (h *crcHash) Sum(b []byte) []byte {
+	return appendValue(b, h.Sum32(), h.Size(), false)
+}
@@ -0,0 +1,63 @@
+package checksum
+
+// Block check character is XOR of all bytes
+type bccHash struct {
+	sum byte
+}
+
+func (h *bccHash) Write(p []byte) (int, error) {
+	for _, b := range p {
+		h.sum ^= b
+	}
+	return len(p), nil
+}
+
+func (h *bccHash) Reset()         { h.sum = 0 }
+func (h *bccHash) Size() int      { return 1 }
+func (h *bccHash) BlockSize() int { return 1 }
+func (h *bccHash) Sum32() uint32  { return uint32(h.sum) }
+func (h *bccHash) Sum(b []byte) []byte {
+	return append(b, h.sum)
+}
+
+// ccTalk simple checksum makes the sum of all frame bytes zero
+type simpleHash struct {
+	sum byte
+}
+
+func (h *simpleHash) Write(p []byte) (int, error) {
+	for _, b := range p {
+		h.sum += b
+	}
+	return len(p), nil
+}
+
+func (h *simpleHash) Reset()         { h.sum = 0 }
+func (h *simpleHash) Size() int      { return 1 }
+func (h *simpleHash) BlockSize() int { return 1 }
+func (h *simpleHash) Sum32() uint32  { return uint32(-h.sum) }
+func (h *simpleHash) Sum(b []byte) []byte {
+	return append(b, -h.sum)
+}
+
+// Fletcher-16 checksum, the second sum goes in high byte
+type fletcherHash struct {
+	sum1 uint16
+	sum2 uint16
+}
+
+func (h *fletcherHash) Write(p []byte) (int, error) {
+	for _, b := range p {
+		h.sum1 = (h.sum1 + uint16(b)) % 255
+		h.sum2 = (h.sum2 + h.sum1) % 255
+	}
+	return len(p), nil
+}
+
+func (h *fletcherHash) Reset()         { h.sum1, h.sum2 = 0, 0 }
+func (h *fletcherHash) Size() int      { return 2 }
+func (h *fletcherHash) BlockSize() int { return 1 }
+func (h *fletcherHash) Sum32() uint32  { return uint32(h.sum2)<<8 | uint32(h.sum1) }
+func (h *fletcherHash) Sum(b []byte) []byte {
+	return appendValue(b, h.Sum32(), 2, false)
+}
@@ -0,0 +1,258 @@
+package linker
+
+import (
+	"bytes"
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/linker/checksum"
+)
+
+// CheckFunc returns checksum bytes of the frame, checksum size must not depend on data
+type CheckFunc func(data []byte) []byte
+
+// CheckLRC is XOR checksum of one byte
+func CheckLRC(data []byte) []byte {
+	return []byte{CalcLRC(data)}
+}
+
+// CheckCRC16 is CRC16 of polynomial 0x8408 put LSB first
+func CheckCRC16(data []byte) []byte {
+	crc := CalcCRC16(data)
+	return []byte{byte(crc), byte(crc >> 8)}
+}
+
+// GetCheckFunc returns checksum of frames selected by name from config
+func GetCheckFunc(name string, lsbFirst bool) (CheckFunc, error) {
+	alg, err := checksum.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	check := func(data []byte) []byte {
+		return alg.Bytes(data, lsbFirst)
+	}
+	return check, nil
+}
+
+func getCheckSize(check CheckFunc) int {
+	if check == nil {
+		return 0
+	}
+	return len(check(nil))
+}
+
+// Framer packs payload to link frames and finds frames in received data
+type Framer interface {
+	Encode(data []byte) ([]byte, error)
+	// Decode looks for the first frame in dump and returns its payload and count of used bytes.
+	// Zero count means that frame is not complete, nil payload with nonzero count skips garbage.
+	Decode(dump []byte) (data []byte, count int, err error)
+}
+
+var errFrameCheck = common.NewError(common.DevErrorProtocolFault, "frame checksum mismatch")
+
+////////////////////////////////////////////////////////////////
+// Data flow:  STX, []DATA, ETX, CHECK
+// STX, ETX and DLE bytes of data and checksum are prefixed by DLE,
+// checksum is calculated over unstuffed data and ETX
+
+type StxEtxFramer struct {
+	Check CheckFunc
+}
+
+func NewStxEtxFramer(check CheckFunc) *StxEtxFramer {
+	return &StxEtxFramer{Check: check}
+}
+
+func (f *StxEtxFramer) Encode(data []byte) ([]byte, error) {
+	pack := []byte{STX}
+	pack = stuffBytes(pack, data)
+	pack = append(pack, ETX)
+	if f.Check != nil {
+		body := append(append([]byte{}, data...), ETX)
+		pack = stuffBytes(pack, f.Check(body))
+	}
+	return pack, nil
+}
+
+func (f *StxEtxFramer) Decode(dump []byte) ([]byte, int, error) {
+	size := len(dump)
+	if size == 0 {
+		return nil, 0, nil
+	}
+	// Looking for STX
+	if dump[0] != STX {
+		for i := 1; i < size; i++ {
+			if dump[i] == STX {
+				return nil, i, nil
+			}
+		}
+		return nil, size, nil
+	}
+	// Collecting data up to ETX
+	data := make([]byte, 0, size)
+	i := 1
+	for ; i < size; i++ {
+		b := dump[i]
+		if b == DLE {
+			if i++; i == size {
+				return nil, 0, nil
+			}
+			data = append(data, dump[i])
+			continue
+		}
+		if b == ETX {
+			break
+		}
+		if b == STX {
+			// Broken frame is followed by the next one
+			return nil, i, nil
+		}
+		data = append(data, b)
+	}
+	if i == size {
+		return nil, 0, nil
+	}
+	i++
+	// Collecting checksum
+	want := getCheckSize(f.Check)
+	sum := make([]byte, 0, want)
+	for ; len(sum) < want; i++ {
+		if i < size && dump[i] == DLE {
+			i++
+		}
+		if i >= size {
+			return nil, 0, nil
+		}
+		sum = append(sum, dump[i])
+	}
+	if want > 0 && !bytes.Equal(sum, f.Check(append(append([]byte{}, data...), ETX))) {
+		return nil, i, errFrameCheck
+	}
+	return data, i, nil
+}
+
+func stuffBytes(pack, data []byte) []byte {
+	for _, b := range data {
+		if b == STX || b == ETX || b == DLE {
+			pack = append(pack, DLE)
+		}
+		pack = append(pack, b)
+	}
+	return pack
+}
+
+////////////////////////////////////////////////////////////////
+// Data flow:  SYNC, LEN, []DATA, CHECK, []TAIL
+// LEN is the size of data, checksum is calculated over SYNC, LEN and DATA
+
+type LengthFramer struct {
+	Sync  byte
+	Check CheckFunc
+	Tail  []byte
+}
+
+func NewLengthFramer(sync byte, check CheckFunc, tail ...byte) *LengthFramer {
+	return &LengthFramer{Sync: sync, Check: check, Tail: tail}
+}
+
+func (f *LengthFramer) Encode(data []byte) ([]byte, error) {
+	if len(data) > 0xFF {
+		return nil, common.NewError(common.DevErrorProtocolFault, "frame is too long")
+	}
+	pack := []byte{f.Sync, byte(len(data))}
+	pack = append(pack, data...)
+	if f.Check != nil {
+		pack = append(pack, f.Check(pack)...)
+	}
+	pack = append(pack, f.Tail...)
+	return pack, nil
+}
+
+func (f *LengthFramer) Decode(dump []byte) ([]byte, int, error) {
+	size := len(dump)
+	if size < 2 {
+		return nil, 0, nil
+	}
+	// Looking for SYNC
+	if dump[0] != f.Sync {
+		for i := 1; i < size; i++ {
+			if dump[i] == f.Sync {
+				return nil, i, nil
+			}
+		}
+		return nil, size, nil
+	}
+	// Checking full size
+	sz := int(dump[1]) + 2
+	full := sz + getCheckSize(f.Check) + len(f.Tail)
+	if size < full {
+		return nil, 0, nil
+	}
+	// Wrong tail means that SYNC byte is found inside other data
+	if !bytes.Equal(dump[full-len(f.Tail):full], f.Tail) {
+		return nil, 1, nil
+	}
+	if f.Check != nil && !bytes.Equal(dump[sz:full-len(f.Tail)], f.Check(dump[:sz])) {
+		return nil, full, errFrameCheck
+	}
+	data := make([]byte, sz-2)
+	copy(data, dump[2:sz])
+	return data, full, nil
+}
+
+////////////////////////////////////////////////////////////////
+// Data flow:  []HEAD, LEN, []DATA, CHECK
+// LEN is the full frame size including HEAD and checksum, checksum is calculated over HEAD, LEN and DATA
+
+type SizeFramer struct {
+	Head  []byte
+	Check CheckFunc
+}
+
+func NewSizeFramer(check CheckFunc, head ...byte) *SizeFramer {
+	return &SizeFramer{Head: head, Check: check}
+}
+
+func (f *SizeFramer) Encode(data []byte) ([]byte, error) {
+	size := len(f.Head) + 1 + len(data) + getCheckSize(f.Check)
+	if size > 0xFF {
+		return nil, common.NewError(common.DevErrorProtocolFault, "frame is too long")
+	}
+	pack := append(append([]byte{}, f.Head...), byte(size))
+	pack = append(pack, data...)
+	if f.Check != nil {
+		pack = append(pack, f.Check(pack)...)
+	}
+	return pack, nil
+}
+
+func (f *SizeFramer) Decode(dump []byte) ([]byte, int, error) {
+	size := len(dump)
+	head := len(f.Head) + 1
+	if size < head {
+		return nil, 0, nil
+	}
+	// Looking for HEAD
+	if !bytes.Equal(dump[:head-1], f.Head) {
+		for i := 1; i < size; i++ {
+			if dump[i] == f.Head[0] {
+				return nil, i, nil
+			}
+		}
+		return nil, size, nil
+	}
+	// Checking full size
+	full := int(dump[head-1])
+	sz := full - getCheckSize(f.Check)
+	if sz < head {
+		return nil, 1, nil
+	}
+	if size < full {
+		return nil, 0, nil
+	}
+	if f.Check != nil && !bytes.Equal(dump[sz:full], f.Check(dump[:sz])) {
+		return nil, full, errFrameCheck
+	}
+	data := make([]byte, sz-head)
+	copy(data, dump[head:sz])
+	return data, full, nil
+}
@@ -0,0 +1,160 @@
+package linker
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Decode frames of dump in the way port linkers feed reader, the rest is not complete frame
+func decodeAll(framer Framer, dump []byte) (frames [][]byte, bad int, rest []byte) {
+	for len(dump) > 0 {
+		data, count, err := framer.Decode(dump)
+		if err != nil {
+			bad++
+		}
+		if data != nil {
+			frames = append(frames, data)
+		}
+		if count <= 0 {
+			break
+		}
+		dump = dump[count:]
+	}
+	return frames, bad, dump
+}
+
+func checkFrames(t *testing.T, name string, frames [][]byte, want ...[]byte) {
+	t.Helper()
+	if len(frames) != len(want) {
+		t.Errorf("%s: %d frames % X, want %d", name, len(frames), frames, len(want))
+		return
+	}
+	for i := range want {
+		if !bytes.Equal(frames[i], want[i]) {
+			t.Errorf("%s: frame %d is % X, want % X", name, i, frames[i], want[i])
+		}
+	}
+}
+
+func TestStxEtxFramer(t *testing.T) {
+	framer := NewStxEtxFramer(CheckLRC)
+	data := []byte{0x31, STX, ETX, DLE, 0x33}
+	pack, _ := framer.Encode(data)
+	// LRC of data and ETX is 0x10 that is stuffed too
+	want := []byte{STX, 0x31, DLE, STX, DLE, ETX, DLE, DLE, 0x33, ETX, DLE, 0x10}
+	if !bytes.Equal(pack, want) {
+		t.Fatalf("frame % X, want % X", pack, want)
+	}
+	// Partial frame waits for more bytes
+	for i := 1; i < len(pack); i++ {
+		if back, count, err := framer.Decode(pack[:i]); back != nil || count != 0 || err != nil {
+			t.Errorf("partial frame of %d bytes: % X, %d, %v", i, back, count, err)
+		}
+	}
+	broken := append(append([]byte{}, pack[:len(pack)-1]...), 0x11)
+	tests := []struct {
+		name   string
+		dump   []byte
+		frames [][]byte
+		bad    int
+		rest   int
+	}{
+		{"frame", pack, [][]byte{data}, 0, 0},
+		{"garbage", append([]byte{0x00, ETX, DLE}, pack...), [][]byte{data}, 0, 0},
+		{"broken frame", append([]byte{STX, 0x31, 0x32}, pack...), [][]byte{data}, 0, 0},
+		{"bad check", append(broken, pack...), [][]byte{data}, 1, 0},
+		{"two frames", append(append([]byte{}, pack...), pack[:4]...), [][]byte{data}, 0, 4},
+	}
+	for _, tt := range tests {
+		frames, bad, rest := decodeAll(framer, tt.dump)
+		checkFrames(t, tt.name, frames, tt.frames...)
+		if bad != tt.bad || len(rest) != tt.rest {
+			t.Errorf("%s: %d bad frames, %d bytes rest", tt.name, bad, len(rest))
+		}
+	}
+	// Frame without checksum
+	plain := NewStxEtxFramer(nil)
+	pack, _ = plain.Encode(data)
+	if back, count, err := plain.Decode(pack); err != nil || count != len(pack) || !bytes.Equal(back, data) {
+		t.Errorf("frame without check % X, %d: %v", back, count, err)
+	}
+}
+
+func TestLengthFramer(t *testing.T) {
+	framer := NewLengthFramer(0xAA, CheckLRC, 0x0D)
+	data := []byte{0x01, 0xAA, 0x02}
+	pack, _ := framer.Encode(data)
+	want := []byte{0xAA, 0x03, 0x01, 0xAA, 0x02, 0x03 ^ 0x01 ^ 0x02, 0x0D}
+	if !bytes.Equal(pack, want) {
+		t.Fatalf("frame % X, want % X", pack, want)
+	}
+	for i := 1; i < len(pack); i++ {
+		if back, count, err := framer.Decode(pack[:i]); back != nil || count != 0 || err != nil {
+			t.Errorf("partial frame of %d bytes: % X, %d, %v", i, back, count, err)
+		}
+	}
+	broken := append([]byte{}, pack...)
+	broken[5] ^= 0xFF
+	tests := []struct {
+		name   string
+		dump   []byte
+		frames [][]byte
+		bad    int
+	}{
+		{"frame", pack, [][]byte{data}, 0},
+		{"garbage", append([]byte{0x01, 0x02}, pack...), [][]byte{data}, 0},
+		// SYNC of garbage takes wrong length, wrong tail moves to the next SYNC
+		{"sync in garbage", append([]byte{0xAA, 0x01, 0x00, 0x0E, 0x00}, pack...), [][]byte{data}, 0},
+		{"bad check", append(broken, pack...), [][]byte{data}, 1},
+	}
+	for _, tt := range tests {
+		frames, bad, rest := decodeAll(framer, tt.dump)
+		checkFrames(t, tt.name, frames, tt.frames...)
+		if bad != tt.bad || len(rest) != 0 {
+			t.Errorf("%s: %d bad frames, %d bytes rest", tt.name, bad, len(rest))
+		}
+	}
+	if _, err := framer.Encode(make([]byte, 0x100)); err == nil {
+		t.Error("too long frame is encoded")
+	}
+}
+
+func TestSizeFramer(t *testing.T) {
+	framer := NewSizeFramer(CheckCRC16, 0x02, 0x03)
+	data := []byte{0x33}
+	pack, _ := framer.Encode(data)
+	// CCNET poll of bill validator
+	want := []byte{0x02, 0x03, 0x06, 0x33, 0xDA, 0x81}
+	if !bytes.Equal(pack, want) {
+		t.Fatalf("frame % X, want % X", pack, want)
+	}
+	for i := 1; i < len(pack); i++ {
+		if back, count, err := framer.Decode(pack[:i]); back != nil || count != 0 || err != nil {
+			t.Errorf("partial frame of %d bytes: % X, %d, %v", i, back, count, err)
+		}
+	}
+	broken := append([]byte{}, pack...)
+	broken[3] = 0x34
+	tests := []struct {
+		name   string
+		dump   []byte
+		frames [][]byte
+		bad    int
+	}{
+		{"frame", pack, [][]byte{data}, 0},
+		{"garbage", append([]byte{0x00, 0x03, 0xFF}, pack...), [][]byte{data}, 0},
+		{"head in garbage", append([]byte{0x02, 0x02}, pack...), [][]byte{data}, 0},
+		{"too short size", append([]byte{0x02, 0x03, 0x02}, pack...), [][]byte{data}, 0},
+		{"bad check", append(broken, pack...), [][]byte{data}, 1},
+	}
+	for _, tt := range tests {
+		frames, bad, rest := decodeAll(framer, tt.dump)
+		checkFrames(t, tt.name, frames, tt.frames...)
+		if bad != tt.bad || len(rest) != 0 {
+			t.Errorf("%s: %d bad frames, %d bytes rest", tt.name, bad, len(rest))
+		}
+	}
+	if _, err := framer.Encode(make([]byte, 0xFF)); err == nil {
+		t.Error("too long frame is encoded")
+	}
+}
@@ -0,0 +1,327 @@
+package linker
+
+import (
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/config"
+	"github.com/iftsoft/device/core"
+	"sync"
+	"time"
+)
+
+// HalfDuplexOptions tune transaction flow of the protocol engine
+type HalfDuplexOptions struct {
+	Retries    int    // Retransmissions after NAK or timeout
+	Timeout    uint16 // Time (ms) to wait reply if config has no timeout
+	AckTimeout uint16 // Time (ms) to wait ACK of sent frame
+	UseAck     bool   // Frames are confirmed by ACK and rejected by NAK
+	UseEnq     bool   // ENQ asks device to repeat lost reply instead of frame retransmission
+}
+
+func GetDefaultHalfDuplexOptions() *HalfDuplexOptions {
+	opts := &HalfDuplexOptions{
+		Retries:    2,
+		Timeout:    250,
+		AckTimeout: 50,
+		UseAck:     false,
+		UseEnq:     false,
+	}
+	return opts
+}
+
+// ExchangeStats counts transactions of the protocol engine
+type ExchangeStats struct {
+	Exchanges   uint32
+	Retries     uint32
+	Timeouts    uint32
+	Rejects     uint32 // Frames rejected by NAK of the device
+	BadFrames   uint32
+	Unsolicited uint32
+}
+
+// HalfDuplex sends one frame at a time and waits the device answer.
+// Frames that come while no exchange is running go to unsolicited handler.
+type HalfDuplex struct {
+	config  *config.LinkerConfig
+	options HalfDuplexOptions
+	log     *core.LogAgent
+	port    PortLinker
+	framer  Framer
+	timeout uint16
+	reply   chan []byte
+	control chan byte
+	notify  func(data []byte)
+	lock    sync.Mutex // Serializes exchanges
+	mutex   sync.Mutex // Guards waiting flag and stats
+	waiting bool
+	stats   ExchangeStats
+}
+
+func NewHalfDuplex(cfg *config.LinkerConfig, framer Framer, opts *HalfDuplexOptions, title string) *HalfDuplex {
+	if opts == nil {
+		opts = GetDefaultHalfDuplexOptions()
+	}
+	hd := &HalfDuplex{
+		config:  cfg,
+		options: *opts,
+		log:     core.GetLogAgent(core.LogLevelDump, title),
+		framer:  framer,
+		timeout: 0,
+		reply:   make(chan []byte, 1),
+		control: make(chan byte, 1),
+	}
+	hd.port = GetPortLinker(cfg, hd)
+	if cfg != nil {
+		hd.timeout = cfg.Timeout
+	}
+	if hd.timeout == 0 {
+		hd.timeout = opts.Timeout
+	}
+	if hd.timeout == 0 {
+		hd.timeout = 250
+	}
+	return hd
+}
+
+// SetUnsolicited sets handler of frames that come out of exchange, it runs in own goroutine
+func (hd *HalfDuplex) SetUnsolicited(handler func(data []byte)) {
+	hd.notify = handler
+}
+
+func (hd *HalfDuplex) OpenLink() error {
+	if hd.port == nil {
+		return common.NewError(common.DevErrorConfigFault, "port not set")
+	}
+	err := hd.port.Open()
+	hd.log.Trace("HalfDuplex OpenLink return : %s", core.GetErrorText(err))
+	return common.ExtendError(common.DevErrorLinkerFault, err)
+}
+
+func (hd *HalfDuplex) CloseLink() error {
+	if hd.port == nil {
+		return common.NewError(common.DevErrorConfigFault, "port not set")
+	}
+	err := hd.port.Close()
+	hd.log.Trace("HalfDuplex CloseLink return : %s", core.GetErrorText(err))
+	return common.ExtendError(common.DevErrorLinkerFault, err)
+}
+
+func (hd *HalfDuplex) IsOpen() bool {
+	return hd.port != nil && hd.port.IsOpen()
+}
+
+func (hd *HalfDuplex) GetStats() ExchangeStats {
+	hd.mutex.Lock()
+	defer hd.mutex.Unlock()
+	return hd.stats
+}
+
+// Put exchange counters to device metrics
+func (hd *HalfDuplex) ReportMetrics(metrics *common.SystemMetrics) {
+	stats := hd.GetStats()
+	metrics.Counts["link_exchanges"] = stats.Exchanges
+	metrics.Counts["link_retries"] = stats.Retries
+	metrics.Counts["link_timeouts"] = stats.Timeouts
+	metrics.Counts["link_rejects"] = stats.Rejects
+	metrics.Counts["link_bad_frames"] = stats.BadFrames
+	metrics.Counts["link_unsolicited"] = stats.Unsolicited
+}
+
+////////////////////////////////////////////////////////////////
+
+// Exchange sends data frame and returns reply frame of the device
+func (hd *HalfDuplex) Exchange(data []byte) ([]byte, error) {
+	return hd.transact(data, true, hd.options.Retries)
+}
+
+// ExchangeOnce does not retransmit the frame, it is used for commands that must not be repeated
+func (hd *HalfDuplex) ExchangeOnce(data []byte) ([]byte, error) {
+	return hd.transact(data, true, 0)
+}
+
+// Send sends data frame that is not answered by data
+func (hd *HalfDuplex) Send(data []byte) error {
+	_, err := hd.transact(data, false, hd.options.Retries)
+	return err
+}
+
+// WriteRaw writes bytes to the port as is, out of framing and exchange
+func (hd *HalfDuplex) WriteRaw(data []byte) error {
+	return hd.writeToPort(data)
+}
+
+func (hd *HalfDuplex) transact(data []byte, wait bool, retries int) ([]byte, error) {
+	pack, err := hd.framer.Encode(data)
+	if err != nil {
+		return nil, err
+	}
+	hd.lock.Lock()
+	defer hd.lock.Unlock()
+	defer hd.setWaiting(false)
+	hd.countStat(&hd.stats.Exchanges)
+
+	send := pack
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			hd.countStat(&hd.stats.Retries)
+			hd.log.Debug("HalfDuplex retry %d of %d", attempt, retries)
+		}
+		// Late answers of previous attempt must not be taken as reply of this one
+		hd.clearReply()
+		hd.setWaiting(wait)
+		err = hd.writeToPort(send)
+		if err != nil {
+			return nil, err
+		}
+		// ENQ is answered by the reply itself
+		if hd.options.UseAck && len(send) > 1 {
+			err = hd.waitAck()
+			if err != nil {
+				send = pack
+				continue
+			}
+		}
+		if !wait {
+			return nil, nil
+		}
+		var back []byte
+		back, err = hd.waitReply()
+		if err == nil {
+			return back, nil
+		}
+		if hd.options.UseEnq {
+			send = []byte{ENQ}
+		}
+	}
+	return nil, err
+}
+
+func (hd *HalfDuplex) waitAck() error {
+	timer := time.NewTimer(time.Duration(hd.options.AckTimeout) * time.Millisecond)
+	defer timer.Stop()
+	select {
+	case b := <-hd.control:
+		if b == ACK {
+			return nil
+		}
+		hd.countStat(&hd.stats.Rejects)
+		hd.log.Warn("HalfDuplex frame is rejected by NAK")
+		return common.NewError(common.DevErrorProtocolFault, "frame is not acknowledged")
+	case <-timer.C:
+		hd.countStat(&hd.stats.Timeouts)
+		hd.log.Warn("HalfDuplex acknowledge timeout (ms): %d", hd.options.AckTimeout)
+		return common.NewError(common.DevErrorLinkerTimeout, "acknowledge timeout")
+	}
+}
+
+func (hd *HalfDuplex) waitReply() ([]byte, error) {
+	timer := time.NewTimer(time.Duration(hd.timeout) * time.Millisecond)
+	defer timer.Stop()
+	select {
+	case dump := <-hd.reply:
+		hd.log.Dump("HalfDuplex check data : %s", core.GetBinaryDump(dump))
+		return dump, nil
+	case <-timer.C:
+		hd.countStat(&hd.stats.Timeouts)
+		hd.log.Warn("HalfDuplex timeout (ms): %d", hd.timeout)
+		return nil, common.NewError(common.DevErrorLinkerTimeout, "linker timeout")
+	}
+}
+
+func (hd *HalfDuplex) writeToPort(data []byte) error {
+	if hd.port == nil {
+		return common.NewError(common.DevErrorConfigFault, "port not set")
+	}
+	hd.log.Dump("HalfDuplex writeToPort data : %s", core.GetBinaryDump(data))
+	n, err := hd.port.Write(data)
+	if err == nil && n != len(data) {
+		return common.NewError(common.DevErrorLinkerFault, "wrong byte count")
+	}
+	return common.ExtendError(common.DevErrorLinkerFault, err)
+}
+
+// Drop replies and control bytes that came after timeout of previous exchange
+func (hd *HalfDuplex) clearReply() {
+	for {
+		select {
+		case dump := <-hd.reply:
+			hd.log.Warn("HalfDuplex drop late reply : %s", core.GetBinaryDump(dump))
+		case <-hd.control:
+		default:
+			return
+		}
+	}
+}
+
+func (hd *HalfDuplex) setWaiting(waiting bool) {
+	hd.mutex.Lock()
+	hd.waiting = waiting
+	hd.mutex.Unlock()
+}
+
+func (hd *HalfDuplex) takeWaiting() bool {
+	hd.mutex.Lock()
+	defer hd.mutex.Unlock()
+	waiting := hd.waiting
+	hd.waiting = false
+	return waiting
+}
+
+func (hd *HalfDuplex) countStat(counter *uint32) {
+	hd.mutex.Lock()
+	*counter++
+	hd.mutex.Unlock()
+}
+
+// implementation of PortReader interface
+
+func (hd *HalfDuplex) OnRead(dump []byte) int {
+	hd.log.Dump("HalfDuplex OnRead data : %s", core.GetBinaryDump(dump))
+	if len(dump) == 0 {
+		return 0
+	}
+	if hd.options.UseAck {
+		switch dump[0] {
+		case ACK, NAK:
+			select {
+			case hd.control <- dump[0]:
+			default:
+				hd.log.Warn("HalfDuplex OnRead unexpected control : %02X", dump[0])
+			}
+			return 1
+		case ENQ:
+			// Device asks to repeat acknowledge of its last frame
+			_ = hd.writeToPort([]byte{ACK})
+			return 1
+		}
+	}
+	data, count, err := hd.framer.Decode(dump)
+	if err != nil {
+		hd.countStat(&hd.stats.BadFrames)
+		hd.log.Warn("HalfDuplex OnRead bad frame : %s", err)
+		if hd.options.UseAck {
+			_ = hd.writeToPort([]byte{NAK})
+		}
+		return count
+	}
+	if data != nil {
+		if hd.options.UseAck {
+			_ = hd.writeToPort([]byte{ACK})
+		}
+		hd.routeFrame(data)
+	}
+	return count
+}
+
+// The first frame of exchange is its reply, the rest are unsolicited
+func (hd *HalfDuplex) routeFrame(data []byte) {
+	if hd.takeWaiting() {
+		hd.reply <- data
+		return
+	}
+	hd.countStat(&hd.stats.Unsolicited)
+	if hd.notify == nil {
+		hd.log.Warn("HalfDuplex OnRead unsolicited frame : %s", core.GetBinaryDump(data))
+		return
+	}
+	go hd.notify(data)
+}
@@ -0,0 +1,274 @@
+package linker
+
+import (
+	"bytes"
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/config"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Port of scripted device, answer returns chunks that device sends back on n-th write
+type testPort struct {
+	reader  PortReader
+	answer  func(n int, data []byte) [][]byte
+	delay   time.Duration
+	lock    sync.Mutex // Guards writes
+	feed    sync.Mutex // Serializes data of device, reader may write to the port
+	writes  [][]byte
+	pending []byte
+}
+
+func (p *testPort) Open() error  { return nil }
+func (p *testPort) Close() error { return nil }
+func (p *testPort) Flash() error { return nil }
+func (p *testPort) IsOpen() bool { return true }
+
+func (p *testPort) Write(data []byte) (int, error) {
+	p.lock.Lock()
+	n := len(p.writes)
+	p.writes = append(p.writes, append([]byte{}, data...))
+	p.lock.Unlock()
+	var chunks [][]byte
+	if p.answer != nil {
+		chunks = p.answer(n, data)
+	}
+	if len(chunks) > 0 {
+		go func() {
+			time.Sleep(p.delay)
+			for _, chunk := range chunks {
+				p.send(chunk)
+			}
+		}()
+	}
+	return len(data), nil
+}
+
+// Device sends data out of exchange
+func (p *testPort) send(chunk []byte) {
+	p.feed.Lock()
+	defer p.feed.Unlock()
+	p.pending = feedReader(p.reader, append(p.pending, chunk...))
+}
+
+func (p *testPort) getWrites() [][]byte {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return append([][]byte{}, p.writes...)
+}
+
+var testFramer = NewSizeFramer(CheckCRC16, 0x02, 0x03)
+
+func testFrame(data ...byte) []byte {
+	pack, _ := testFramer.Encode(data)
+	return pack
+}
+
+func newTestDuplex(opts *HalfDuplexOptions, answer func(n int, data []byte) [][]byte) (*HalfDuplex, *testPort) {
+	hd := NewHalfDuplex(&config.LinkerConfig{Timeout: 50}, testFramer, opts, "Test")
+	port := &testPort{reader: hd, answer: answer}
+	hd.port = port
+	return hd, port
+}
+
+func checkWrites(t *testing.T, port *testPort, want ...[]byte) {
+	t.Helper()
+	writes := port.getWrites()
+	if len(writes) != len(want) {
+		t.Errorf("%d writes % X, want %d", len(writes), writes, len(want))
+		return
+	}
+	for i := range want {
+		if !bytes.Equal(writes[i], want[i]) {
+			t.Errorf("write %d is % X, want % X", i, writes[i], want[i])
+		}
+	}
+}
+
+func TestHalfDuplexExchange(t *testing.T) {
+	opts := GetDefaultHalfDuplexOptions()
+	// Reply comes by parts with garbage ahead
+	hd, port := newTestDuplex(opts, func(n int, data []byte) [][]byte {
+		reply := testFrame(0x80, 0x01)
+		return [][]byte{{0xFF}, reply[:3], reply[3:]}
+	})
+	back, err := hd.Exchange([]byte{0x33})
+	if err != nil || !bytes.Equal(back, []byte{0x80, 0x01}) {
+		t.Fatalf("reply % X: %v", back, err)
+	}
+	checkWrites(t, port, testFrame(0x33))
+	if stats := hd.GetStats(); stats != (ExchangeStats{Exchanges: 1}) {
+		t.Errorf("stats %+v", stats)
+	}
+}
+
+// Every attempt waits reply for the whole timeout, late reply of failed exchange is unsolicited
+func TestHalfDuplexTimeout(t *testing.T) {
+	opts := GetDefaultHalfDuplexOptions()
+	opts.Retries = 2
+	hd, port := newTestDuplex(opts, nil)
+	start := time.Now()
+	_, err := hd.Exchange([]byte{0x33})
+	if code, _ := common.CheckError(err); code != common.DevErrorLinkerTimeout {
+		t.Fatalf("exchange error %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("three attempts take %s", elapsed)
+	}
+	checkWrites(t, port, testFrame(0x33), testFrame(0x33), testFrame(0x33))
+	if stats := hd.GetStats(); stats.Retries != 2 || stats.Timeouts != 3 {
+		t.Errorf("stats %+v", stats)
+	}
+
+	port.send(testFrame(0x80))
+	// The second attempt is answered
+	port.answer = func(n int, data []byte) [][]byte {
+		if n < 4 {
+			return nil
+		}
+		return [][]byte{testFrame(0x81)}
+	}
+	back, err := hd.Exchange([]byte{0x33})
+	if err != nil || !bytes.Equal(back, []byte{0x81}) {
+		t.Fatalf("reply % X: %v", back, err)
+	}
+	if stats := hd.GetStats(); stats.Retries != 3 || stats.Unsolicited != 1 {
+		t.Errorf("stats %+v", stats)
+	}
+	// No retransmission
+	port.answer = nil
+	if _, err = hd.ExchangeOnce([]byte{0x35}); err == nil {
+		t.Error("exchange without reply")
+	}
+	if writes := port.getWrites(); len(writes) != 6 {
+		t.Errorf("%d writes for exchange once", len(writes)-5)
+	}
+}
+
+// Frame is repeated after NAK and lost ACK up to retry count
+func TestHalfDuplexAck(t *testing.T) {
+	opts := GetDefaultHalfDuplexOptions()
+	opts.UseAck = true
+	opts.Retries = 2
+	hd, port := newTestDuplex(opts, func(n int, data []byte) [][]byte {
+		switch {
+		case len(data) == 1:
+			return nil
+		case n == 0:
+			return [][]byte{{NAK}}
+		case n == 1:
+			return nil
+		}
+		return [][]byte{{ACK}, testFrame(0x80)}
+	})
+	back, err := hd.Exchange([]byte{0x33})
+	if err != nil || !bytes.Equal(back, []byte{0x80}) {
+		t.Fatalf("reply % X: %v", back, err)
+	}
+	// Good reply is confirmed by ACK
+	waitFor(t, "reply ACK", func() bool { return len(port.getWrites()) == 4 })
+	checkWrites(t, port, testFrame(0x33), testFrame(0x33), testFrame(0x33), []byte{ACK})
+	if stats := hd.GetStats(); stats.Rejects != 1 || stats.Timeouts != 1 || stats.Retries != 2 {
+		t.Errorf("stats %+v", stats)
+	}
+
+	// Device rejects all attempts
+	opts.Retries = 1
+	hd, port = newTestDuplex(opts, func(n int, data []byte) [][]byte {
+		return [][]byte{{NAK}}
+	})
+	if _, err = hd.Exchange([]byte{0x33}); err == nil {
+		t.Fatal("rejected frame is sent")
+	}
+	checkWrites(t, port, testFrame(0x33), testFrame(0x33))
+
+	// Bad frame is answered by NAK, ENQ of device by ACK
+	hd, port = newTestDuplex(opts, nil)
+	bad := testFrame(0x80)
+	bad[len(bad)-1] ^= 0xFF
+	port.send(bad)
+	port.send([]byte{ENQ})
+	checkWrites(t, port, []byte{NAK}, []byte{ACK})
+	if stats := hd.GetStats(); stats.BadFrames != 1 {
+		t.Errorf("stats %+v", stats)
+	}
+}
+
+// Reply of attempt that is not acknowledged is dropped, the next attempt waits its own reply
+func TestHalfDuplexStaleReply(t *testing.T) {
+	opts := GetDefaultHalfDuplexOptions()
+	opts.UseAck = true
+	opts.Retries = 1
+	hd, port := newTestDuplex(opts, func(n int, data []byte) [][]byte {
+		switch {
+		case len(data) == 1:
+			return nil
+		case n == 0:
+			return [][]byte{testFrame(0x80)}
+		}
+		return [][]byte{{ACK}, testFrame(0x81)}
+	})
+	back, err := hd.Exchange([]byte{0x33})
+	if err != nil || !bytes.Equal(back, []byte{0x81}) {
+		t.Fatalf("reply % X: %v", back, err)
+	}
+	waitFor(t, "reply ACK", func() bool { return len(port.getWrites()) == 4 })
+	checkWrites(t, port, testFrame(0x33), []byte{ACK}, testFrame(0x33), []byte{ACK})
+	if stats := hd.GetStats(); stats.Unsolicited != 0 || stats.Retries != 1 {
+		t.Errorf("stats %+v", stats)
+	}
+}
+
+// Lost reply is asked by ENQ instead of the frame
+func TestHalfDuplexEnq(t *testing.T) {
+	opts := GetDefaultHalfDuplexOptions()
+	opts.UseEnq = true
+	hd, port := newTestDuplex(opts, func(n int, data []byte) [][]byte {
+		if n < 2 {
+			return nil
+		}
+		return [][]byte{testFrame(0x80)}
+	})
+	back, err := hd.Exchange([]byte{0x33})
+	if err != nil || !bytes.Equal(back, []byte{0x80}) {
+		t.Fatalf("reply % X: %v", back, err)
+	}
+	checkWrites(t, port, testFrame(0x33), []byte{ENQ}, []byte{ENQ})
+}
+
+// Frames out of exchange and extra frames of exchange go to unsolicited handler
+func TestHalfDuplexUnsolicited(t *testing.T) {
+	hd, port := newTestDuplex(nil, func(n int, data []byte) [][]byte {
+		return [][]byte{testFrame(0x80), testFrame(0x90)}
+	})
+	frames := make(chan []byte, 4)
+	hd.SetUnsolicited(func(data []byte) { frames <- data })
+
+	port.send(testFrame(0xA0))
+	back, err := hd.Exchange([]byte{0x33})
+	if err != nil || !bytes.Equal(back, []byte{0x80}) {
+		t.Fatalf("reply % X: %v", back, err)
+	}
+	got := map[byte]bool{}
+	for i := 0; i < 2; i++ {
+		select {
+		case data := <-frames:
+			got[data[0]] = true
+		case <-time.After(testWait):
+			t.Fatal("no unsolicited frame")
+		}
+	}
+	if !got[0xA0] || !got[0x90] {
+		t.Errorf("unsolicited frames %v", got)
+	}
+	if stats := hd.GetStats(); stats.Unsolicited != 2 {
+		t.Errorf("stats %+v", stats)
+	}
+
+	// Frame that is not answered by data
+	if err = hd.Send([]byte{0x35}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "answer of send", func() bool { return hd.GetStats().Unsolicited == 4 })
+}
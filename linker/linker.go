@@ -25,7 +25,29 @@ type PortLinker interface {
 	Write(data []byte) (int, error)
 }
 
+// GetPortLinker returns port of config link type, it may be recorded, faulted or replaced by trace replay
 func GetPortLinker(cfg *config.LinkerConfig, call PortReader) PortLinker {
+	if cfg != nil && cfg.Replay != "" {
+		return NewReplayLink(cfg.Replay, call)
+	}
+	if cfg != nil && cfg.Record != "" {
+		return NewRecordLink(cfg.Record, call, func(reader PortReader) PortLinker {
+			return getChaosLinker(cfg, reader)
+		})
+	}
+	return getChaosLinker(cfg, call)
+}
+
+func getChaosLinker(cfg *config.LinkerConfig, call PortReader) PortLinker {
+	if cfg != nil && cfg.Chaos != nil {
+		return NewChaosLink(cfg.Chaos, call, func(reader PortReader) PortLinker {
+			return getPortLinker(cfg, reader)
+		})
+	}
+	return getPortLinker(cfg, call)
+}
+
+func getPortLinker(cfg *config.LinkerConfig, call PortReader) PortLinker {
 	dummy := NewDummyLink(call)
 	if cfg == nil {
 		return dummy
@@ -37,6 +59,8 @@ func GetPortLinker(cfg *config.LinkerConfig, call PortReader) PortLinker {
 		return NewSerialLink(cfg.Serial, call)
 	case config.LinkTypeHidUsb:
 		return NewDummyLinker(cfg.HidUsb, call)
+	case config.LinkTypeTcp:
+		return NewTcpLink(cfg.Tcp, cfg.Serial, call)
 	}
 	return dummy
 }
@@ -53,3 +77,17 @@ func GetLinkerPorts(out *core.LogAgent) error {
 	return err
 }
 
+// Feed data to reader while it takes frames, the rest waits for more bytes
+func feedReader(reader PortReader, data []byte) []byte {
+	for reader != nil && len(data) > 0 {
+		k := reader.OnRead(data)
+		if k <= 0 {
+			return data
+		}
+		if k >= len(data) {
+			return nil
+		}
+		data = data[k:]
+	}
+	return nil
+}
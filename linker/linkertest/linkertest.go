@@ -0,0 +1,90 @@
+// Package linkertest keeps helpers of driver tests that run protocol engines against replayed traces
+package linkertest
+
+import (
+	"fmt"
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/driver/generic"
+	"github.com/iftsoft/device/linker"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Step of scripted exchange, TX is written by the engine and RX is answered by device.
+// Data is the frame payload, it is encoded by protocol framer when trace is written.
+type Step = linker.TraceRecord
+
+func TX(data ...byte) *Step { return &Step{Dir: "TX", Data: data} }
+func RX(data ...byte) *Step { return &Step{Dir: "RX", Data: data} }
+
+// WriteTrace encodes the steps by framer to trace file in temporary dir of the test
+func WriteTrace(t testing.TB, framer linker.Framer, steps ...[]*Step) string {
+	t.Helper()
+	var text strings.Builder
+	for _, list := range steps {
+		for _, step := range list {
+			pack, err := framer.Encode(step.Data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rec := linker.TraceRecord{Dir: step.Dir, Data: pack}
+			_, _ = fmt.Fprintln(&text, rec.String())
+		}
+	}
+	path := filepath.Join(t.TempDir(), "device.trace")
+	if err := os.WriteFile(path, []byte(text.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// RawFramer writes payload of the steps as is, it is used for frames that are built by the test
+type RawFramer struct{}
+
+func (RawFramer) Encode(data []byte) ([]byte, error)      { return data, nil }
+func (RawFramer) Decode(dump []byte) ([]byte, int, error) { return dump, len(dump), nil }
+
+// OpenLink opens replay link of the protocol and closes it at the end of the test
+func OpenLink(t testing.TB, link *linker.HalfDuplex) {
+	t.Helper()
+	if err := link.OpenLink(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = link.CloseLink() })
+}
+
+////////////////////////////////////////////////////////////////
+
+// ValidatorCallback records validator callbacks of the engine
+type ValidatorCallback struct {
+	Events []string
+}
+
+func (vc *ValidatorCallback) NoteAccepted(name string, value *common.ValidatorAccept) error {
+	vc.Events = append(vc.Events, fmt.Sprintf("accepted %d %s", value.Nominal, value.Decision))
+	return nil
+}
+func (vc *ValidatorCallback) CashIsStored(name string, value *common.ValidatorAccept) error {
+	vc.Events = append(vc.Events, fmt.Sprintf("stored %d", value.Amount))
+	return nil
+}
+func (vc *ValidatorCallback) CashReturned(name string, value *common.ValidatorAccept) error {
+	vc.Events = append(vc.Events, fmt.Sprintf("returned %d", value.Nominal))
+	return nil
+}
+func (vc *ValidatorCallback) ValidatorStore(name string, reply *common.ValidatorStore) error {
+	return nil
+}
+
+// CheckEvents checks that engine has no error and callbacks are got in the order
+func CheckEvents(t testing.TB, engine *generic.BaseEngine, cb *ValidatorCallback, want ...string) {
+	t.Helper()
+	if engine.DevError != common.DevErrorSuccess {
+		t.Errorf("engine error %s: %s", engine.DevError, engine.DevReply)
+	}
+	if strings.Join(cb.Events, ", ") != strings.Join(want, ", ") {
+		t.Errorf("events %q, want %q", cb.Events, want)
+	}
+}
@@ -0,0 +1,103 @@
+package linker
+
+import (
+	"fmt"
+	"github.com/iftsoft/device/core"
+	"os"
+	"sync"
+	"time"
+)
+
+// RecordLink writes timestamped traffic of wrapped port to trace file
+type RecordLink struct {
+	log    *core.LogAgent
+	port   PortLinker
+	reader PortReader
+	path   string
+	lock   sync.Mutex // Guards trace file
+	file   *os.File
+	start  time.Time
+	feed   sync.Mutex // Guards rest of received data
+	rest   []byte
+}
+
+// NewRecordLink wraps port that is made by create function for recorder as its reader
+func NewRecordLink(path string, call PortReader, create func(reader PortReader) PortLinker) *RecordLink {
+	r := &RecordLink{
+		log:    core.GetLogAgent(core.LogLevelDebug, "Record"),
+		reader: call,
+		path:   path,
+	}
+	r.port = create(r)
+	return r
+}
+
+func (r *RecordLink) Open() error {
+	r.lock.Lock()
+	if r.file == nil {
+		file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			r.lock.Unlock()
+			r.log.Warn("RecordLink can't open trace %s: %s", r.path, err)
+			return err
+		}
+		r.file = file
+	}
+	r.start = time.Now()
+	_, _ = fmt.Fprintf(r.file, "# Trace started %s\n", r.start.Format("2006-01-02 15:04:05.000"))
+	r.lock.Unlock()
+	r.feed.Lock()
+	r.rest = nil
+	r.feed.Unlock()
+	return r.port.Open()
+}
+
+func (r *RecordLink) Close() error {
+	err := r.port.Close()
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.file != nil {
+		_ = r.file.Close()
+		r.file = nil
+	}
+	return err
+}
+
+func (r *RecordLink) Flash() error {
+	r.feed.Lock()
+	r.rest = nil
+	r.feed.Unlock()
+	return r.port.Flash()
+}
+
+func (r *RecordLink) IsOpen() bool {
+	return r.port.IsOpen()
+}
+
+func (r *RecordLink) Write(data []byte) (int, error) {
+	r.writeRecord(traceTX, data)
+	return r.port.Write(data)
+}
+
+// implementation of PortReader interface, wrapped port gets all data consumed
+
+func (r *RecordLink) OnRead(dump []byte) int {
+	r.writeRecord(traceRX, dump)
+	r.feed.Lock()
+	defer r.feed.Unlock()
+	r.rest = feedReader(r.reader, append(append([]byte{}, r.rest...), dump...))
+	return len(dump)
+}
+
+func (r *RecordLink) writeRecord(dir string, data []byte) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.file == nil || len(data) == 0 {
+		return
+	}
+	rec := &TraceRecord{Moment: time.Since(r.start), Dir: dir, Data: data}
+	_, err := fmt.Fprintln(r.file, rec.String())
+	if err != nil {
+		r.log.Warn("RecordLink can't write trace %s: %s", r.path, err)
+	}
+}
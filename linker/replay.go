@@ -0,0 +1,197 @@
+package linker
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/iftsoft/device/core"
+	"sync"
+	"time"
+)
+
+// ReplayLink plays recorded trace to the driver instead of real port.
+// RX chunks go to the reader with original delays, writes of the driver must match TX chunks.
+type ReplayLink struct {
+	log     *core.LogAgent
+	reader  PortReader
+	path    string
+	lock    sync.Mutex
+	records []*TraceRecord
+	index   int // Record that is played now
+	offset  int // Matched bytes of TX record
+	failure error
+	isOpen  bool
+	written chan struct{}
+	closing chan struct{}
+	done    chan struct{}
+}
+
+func NewReplayLink(path string, call PortReader) *ReplayLink {
+	r := &ReplayLink{
+		log:    core.GetLogAgent(core.LogLevelDebug, "Replay"),
+		reader: call,
+		path:   path,
+	}
+	return r
+}
+
+// Open loads trace and starts replay from the beginning
+func (r *ReplayLink) Open() error {
+	records, err := LoadTrace(r.path)
+	if err != nil {
+		r.log.Warn("ReplayLink can't load trace %s: %s", r.path, err)
+		return err
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.isOpen {
+		return nil
+	}
+	r.records = records
+	r.index, r.offset = 0, 0
+	r.failure = nil
+	r.isOpen = true
+	r.written = make(chan struct{}, 1)
+	r.closing = make(chan struct{})
+	r.done = make(chan struct{})
+	go r.playing(r.written, r.closing, r.done)
+	r.log.Trace("ReplayLink is opened with %d records of %s", len(records), r.path)
+	return nil
+}
+
+func (r *ReplayLink) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.isOpen {
+		close(r.closing)
+		r.isOpen = false
+	}
+	return nil
+}
+
+func (r *ReplayLink) Flash() error {
+	return nil
+}
+
+func (r *ReplayLink) IsOpen() bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.isOpen
+}
+
+// Write checks driver data against TX records of the trace
+func (r *ReplayLink) Write(data []byte) (int, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if !r.isOpen {
+		return 0, errPortNotOpen
+	}
+	if r.failure != nil {
+		return 0, r.failure
+	}
+	rest := data
+	for len(rest) > 0 {
+		if r.index >= len(r.records) {
+			return 0, r.setFailure(fmt.Errorf("write % X after the end of trace", rest))
+		}
+		rec := r.records[r.index]
+		if rec.Dir != traceTX {
+			return 0, r.setFailure(fmt.Errorf("write % X while trace waits RX % X", rest, rec.Data))
+		}
+		want := rec.Data[r.offset:]
+		size := len(rest)
+		if size > len(want) {
+			size = len(want)
+		}
+		if !bytes.Equal(rest[:size], want[:size]) {
+			return 0, r.setFailure(fmt.Errorf("write % X does not match TX % X", data, rec.Data))
+		}
+		rest = rest[size:]
+		r.offset += size
+		if r.offset == len(rec.Data) {
+			r.index++
+			r.offset = 0
+		}
+	}
+	r.wakePlaying()
+	return len(data), nil
+}
+
+func (r *ReplayLink) wakePlaying() {
+	select {
+	case r.written <- struct{}{}:
+	default:
+	}
+}
+
+// Done is closed when trace is played to the end or replay is failed
+func (r *ReplayLink) Done() <-chan struct{} {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.done
+}
+
+// Verify returns mismatch of the replay or error if trace is not played to the end
+func (r *ReplayLink) Verify() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.failure != nil {
+		return r.failure
+	}
+	if r.index < len(r.records) {
+		return fmt.Errorf("%d of %d trace records are not played", len(r.records)-r.index, len(r.records))
+	}
+	return nil
+}
+
+func (r *ReplayLink) setFailure(err error) error {
+	if r.failure == nil {
+		r.failure = err
+		r.log.Warn("ReplayLink mismatch: %s", err)
+		r.wakePlaying()
+	}
+	return r.failure
+}
+
+func (r *ReplayLink) playing(written, closing, done chan struct{}) {
+	defer close(done)
+	started := time.Now()
+	var played time.Duration // Trace moment of the last played record
+	var rest []byte
+	for {
+		r.lock.Lock()
+		failed := r.failure != nil
+		index := r.index
+		var rec *TraceRecord
+		if index < len(r.records) {
+			rec = r.records[index]
+		}
+		r.lock.Unlock()
+		if failed || rec == nil {
+			return
+		}
+		if rec.Dir == traceTX {
+			// Wait for the driver to write expected data
+			select {
+			case <-closing:
+				return
+			case <-written:
+			}
+			played = rec.Moment
+			started = time.Now()
+			continue
+		}
+		wait := rec.Moment - played - time.Since(started)
+		if wait > 0 {
+			select {
+			case <-closing:
+				return
+			case <-time.After(wait):
+			}
+		}
+		r.lock.Lock()
+		r.index++
+		r.lock.Unlock()
+		r.log.Dump("ReplayLink read data : %s", core.GetBinaryDump(rec.Data))
+		rest = feedReader(r.reader, append(rest, rec.Data...))
+	}
+}
@@ -0,0 +1,131 @@
+package linker
+
+import (
+	"bytes"
+	"github.com/iftsoft/device/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testTrace = "testdata/poll.trace"
+
+func TestLoadTrace(t *testing.T) {
+	records, err := LoadTrace(testTrace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 5 {
+		t.Fatalf("%d records, want 5", len(records))
+	}
+	rec := records[1]
+	if rec.Dir != traceRX || rec.Moment != 3500*time.Microsecond || !bytes.Equal(rec.Data, []byte{0x02, 0x03, 0x07, 0x80}) {
+		t.Errorf("record %s", rec)
+	}
+	back, err := parseTraceLine(rec.String())
+	if err != nil || back.Dir != rec.Dir || back.Moment != rec.Moment || !bytes.Equal(back.Data, rec.Data) {
+		t.Errorf("record %s is parsed to %s: %v", rec, back, err)
+	}
+	for _, line := range []string{"12.5", "x TX 01", "1 XX 01", "1 RX 0G"} {
+		if _, err = parseTraceLine(line); err == nil {
+			t.Errorf("bad line '%s' is parsed", line)
+		}
+	}
+}
+
+// Protocol engine exchanges frames with replayed device
+func TestReplayExchange(t *testing.T) {
+	opts := GetDefaultHalfDuplexOptions()
+	opts.Retries = 0
+	hd := NewHalfDuplex(&config.LinkerConfig{Replay: testTrace}, NewSizeFramer(CheckCRC16, 0x02, 0x03), opts, "Test")
+	if err := hd.OpenLink(); err != nil {
+		t.Fatal(err)
+	}
+	defer hd.CloseLink()
+	back, err := hd.Exchange([]byte{0x33})
+	if err != nil || !bytes.Equal(back, []byte{0x80, 0x02}) {
+		t.Fatalf("poll reply % X: %v", back, err)
+	}
+	back, err = hd.Exchange([]byte{0x35})
+	if err != nil || !bytes.Equal(back, []byte{0x00}) {
+		t.Fatalf("stack reply % X: %v", back, err)
+	}
+	replay := hd.port.(*ReplayLink)
+	select {
+	case <-replay.Done():
+	case <-time.After(time.Second):
+		t.Fatal("trace is not played to the end")
+	}
+	if err = replay.Verify(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReplayMismatch(t *testing.T) {
+	replay := NewReplayLink(testTrace, &testReader{})
+	if err := replay.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer replay.Close()
+	// TX record may be written by parts
+	if _, err := replay.Write([]byte{0x02, 0x03, 0x06}); err != nil {
+		t.Fatal(err)
+	}
+	if err := replay.Verify(); err == nil {
+		t.Error("replay is verified before the trace is played")
+	}
+	if _, err := replay.Write([]byte{0x33, 0xDA, 0x00}); err == nil {
+		t.Fatal("wrong data is written")
+	}
+	if err := replay.Verify(); err == nil {
+		t.Error("replay with wrong data is verified")
+	}
+	if _, err := replay.Write([]byte{0x02}); err == nil {
+		t.Error("write after mismatch")
+	}
+	select {
+	case <-replay.Done():
+	case <-time.After(time.Second):
+		t.Error("replay is not stopped by mismatch")
+	}
+}
+
+// Recorded traffic of replayed device makes the same trace
+func TestRecordReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "record.trace")
+	reader := &testReader{}
+	var replay *ReplayLink
+	record := NewRecordLink(path, reader, func(call PortReader) PortLinker {
+		replay = NewReplayLink(testTrace, call)
+		return replay
+	})
+	if err := record.Open(); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = record.Write([]byte{0x02, 0x03, 0x06, 0x33, 0xDA, 0x81})
+	waitFor(t, "poll reply", func() bool { return len(reader.get()) == 7 })
+	_, _ = record.Write([]byte{0x02, 0x03, 0x06, 0x35, 0xEC, 0xE4})
+	<-replay.Done()
+	if err := replay.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	_ = record.Close()
+
+	want, _ := LoadTrace(testTrace)
+	got, err := LoadTrace(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("%d records are recorded, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Dir != want[i].Dir || !bytes.Equal(got[i].Data, want[i].Data) {
+			t.Errorf("record %d is %s, want %s", i, got[i], want[i])
+		}
+	}
+	if data, _ := os.ReadFile(path); !bytes.HasPrefix(data, []byte("# Trace started")) {
+		t.Errorf("trace has no header: %s", data)
+	}
+}
@@ -0,0 +1,354 @@
+package linker
+
+import (
+	"errors"
+	"fmt"
+	"github.com/iftsoft/device/config"
+	"github.com/iftsoft/device/core"
+	"net"
+	"sync"
+	"time"
+)
+
+// Telnet commands and options of RFC 854 and RFC 2217
+const (
+	telnetIAC  byte = 255
+	telnetDONT byte = 254
+	telnetDO   byte = 253
+	telnetWONT byte = 252
+	telnetWILL byte = 251
+	telnetSB   byte = 250
+	telnetSE   byte = 240
+
+	optBinary  byte = 0
+	optSGA     byte = 3
+	optComPort byte = 44
+
+	comSetBaudRate byte = 1
+	comSetDataSize byte = 2
+	comSetParity   byte = 3
+	comSetStopSize byte = 4
+	comPurgeData   byte = 12
+)
+
+const tcpDialTimeout = 3 * time.Second
+
+// TcpLink works with device behind serial-to-Ethernet converter.
+// Raw TCP mode passes data as is, telnet mode sets remote serial port by RFC 2217.
+type TcpLink struct {
+	config  *config.TcpConfig
+	serial  *config.SerialConfig
+	log     *core.LogAgent
+	reader  PortReader
+	lock    sync.Mutex
+	conn    net.Conn
+	isOpen  bool
+	closing chan struct{}
+}
+
+func NewTcpLink(cfg *config.TcpConfig, ser *config.SerialConfig, call PortReader) *TcpLink {
+	t := TcpLink{
+		config: cfg,
+		serial: ser,
+		log:    core.GetLogAgent(core.LogLevelDebug, "Tcp"),
+		reader: call,
+		conn:   nil,
+		isOpen: false,
+	}
+	return &t
+}
+
+func (t *TcpLink) address() string {
+	return net.JoinHostPort(t.config.Host, fmt.Sprint(t.config.Port))
+}
+
+func (t *TcpLink) Open() (err error) {
+	defer core.PanicRecover(&err, t.log)
+	if t.config == nil {
+		return errors.New("tcp config is not set")
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.closing != nil {
+		return nil
+	}
+	conn, err := t.connect()
+	if err == nil {
+		t.conn = conn
+		t.isOpen = true
+		t.closing = make(chan struct{})
+		go t.readingLoop(conn, t.closing)
+	}
+	t.log.Trace("Open tcp link %s return %s", t.address(), core.GetErrorText(err))
+	return err
+}
+
+func (t *TcpLink) Close() (err error) {
+	defer core.PanicRecover(&err, t.log)
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.closing == nil {
+		return err
+	}
+	close(t.closing)
+	t.closing = nil
+	if t.conn != nil {
+		err = t.conn.Close()
+		t.conn = nil
+	}
+	t.isOpen = false
+	t.log.Trace("Close tcp link %s return %s", t.address(), core.GetErrorText(err))
+	return err
+}
+
+// Flash purges buffers of remote serial port in telnet mode
+func (t *TcpLink) Flash() (err error) {
+	defer core.PanicRecover(&err, t.log)
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.conn == nil {
+		err = errPortNotOpen
+	}
+	if err == nil && t.config.Telnet {
+		_, err = t.conn.Write(comPortCommand(comPurgeData, 3))
+	}
+	t.log.Trace("Flash tcp link %s return %s", t.address(), core.GetErrorText(err))
+	return err
+}
+
+func (t *TcpLink) IsOpen() bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.isOpen
+}
+
+func (t *TcpLink) Write(data []byte) (n int, err error) {
+	defer core.PanicRecover(&err, t.log)
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.conn == nil {
+		return 0, errPortNotOpen
+	}
+	t.log.Dump("Tcp write data : %s", core.GetBinaryDump(data))
+	pack := data
+	if t.config.Telnet {
+		pack = escapeIAC(data)
+	}
+	n, err = t.conn.Write(pack)
+	if n == len(pack) {
+		n = len(data)
+	}
+	t.log.Trace("Write to tcp link %s return %s", t.address(), core.GetErrorText(err))
+	return n, err
+}
+
+////////////////////////////////////////////////////////////////
+
+func (t *TcpLink) connect() (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", t.address(), tcpDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if t.config.Telnet {
+		_, err = conn.Write(t.negotiation())
+		if err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// Telnet options and serial port settings are sent right after connection
+func (t *TcpLink) negotiation() []byte {
+	pack := []byte{
+		telnetIAC, telnetWILL, optComPort,
+		telnetIAC, telnetWILL, optBinary,
+		telnetIAC, telnetDO, optBinary,
+		telnetIAC, telnetWILL, optSGA,
+		telnetIAC, telnetDO, optSGA,
+	}
+	if t.serial == nil {
+		return pack
+	}
+	baud := t.serial.BaudRate
+	pack = append(pack, comPortCommand(comSetBaudRate,
+		byte(baud>>24), byte(baud>>16), byte(baud>>8), byte(baud))...)
+	pack = append(pack, comPortCommand(comSetDataSize, byte(t.serial.DataBits))...)
+	pack = append(pack, comPortCommand(comSetParity, byte(t.serial.Parity)+1)...)
+	var stop byte
+	switch t.serial.StopBits {
+	case config.OneStopBit:
+		stop = 1
+	case config.TwoStopBits:
+		stop = 2
+	case config.OneHalfStopBits:
+		stop = 3
+	}
+	pack = append(pack, comPortCommand(comSetStopSize, stop)...)
+	return pack
+}
+
+func comPortCommand(cmd byte, data ...byte) []byte {
+	pack := []byte{telnetIAC, telnetSB, optComPort, cmd}
+	pack = append(pack, escapeIAC(data)...)
+	return append(pack, telnetIAC, telnetSE)
+}
+
+func escapeIAC(data []byte) []byte {
+	pack := make([]byte, 0, len(data))
+	for _, b := range data {
+		if b == telnetIAC {
+			pack = append(pack, telnetIAC)
+		}
+		pack = append(pack, b)
+	}
+	return pack
+}
+
+func (t *TcpLink) readingLoop(conn net.Conn, closing chan struct{}) {
+	t.log.Trace("Tcp reading loop is started")
+	defer t.log.Trace("Tcp reading loop is stopped")
+
+	filter := &telnetFilter{}
+	rest := []byte{}
+	for {
+		buff := make([]byte, linkerBufferSize)
+		n, err := conn.Read(buff)
+		if n > 0 {
+			t.log.Dump("Tcp read data : %s", core.GetBinaryDump(buff[0:n]))
+			dump := buff[0:n]
+			if t.config.Telnet {
+				var answer []byte
+				dump, answer = filter.process(dump)
+				if len(answer) > 0 {
+					_, _ = t.writeRaw(answer)
+				}
+			}
+			data := append(append([]byte{}, rest...), dump...)
+			rest = feedReader(t.reader, data)
+		}
+		if err == nil {
+			continue
+		}
+		t.log.Warn("Tcp ReadData error: %s", err)
+		conn = t.reconnect(conn, closing)
+		if conn == nil {
+			return
+		}
+		filter = &telnetFilter{}
+		rest = []byte{}
+	}
+}
+
+// Restore dropped connection until the link is closed
+func (t *TcpLink) reconnect(lost net.Conn, closing chan struct{}) net.Conn {
+	t.lock.Lock()
+	if t.conn == lost {
+		_ = t.conn.Close()
+		t.conn = nil
+		t.isOpen = false
+	}
+	t.lock.Unlock()
+
+	delay := time.Duration(t.config.Reconnect) * time.Millisecond
+	if delay == 0 {
+		delay = time.Second
+	}
+	for {
+		select {
+		case <-closing:
+			return nil
+		case <-time.After(delay):
+		}
+		conn, err := t.connect()
+		if err != nil {
+			t.log.Debug("Reconnect tcp link %s return %s", t.address(), core.GetErrorText(err))
+			continue
+		}
+		t.lock.Lock()
+		select {
+		case <-closing:
+			t.lock.Unlock()
+			_ = conn.Close()
+			return nil
+		default:
+		}
+		t.conn = conn
+		t.isOpen = true
+		t.lock.Unlock()
+		t.log.Info("Tcp link %s is restored", t.address())
+		return conn
+	}
+}
+
+func (t *TcpLink) writeRaw(data []byte) (int, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.conn == nil {
+		return 0, errPortNotOpen
+	}
+	return t.conn.Write(data)
+}
+
+////////////////////////////////////////////////////////////////
+
+// telnetFilter removes telnet commands from received data and answers option requests.
+// It keeps state between reads as command may be split across TCP segments.
+type telnetFilter struct {
+	state  byte // Zero for data, IAC or option command that waits option byte
+	inSub  bool
+	subIAC bool
+}
+
+func (f *telnetFilter) process(dump []byte) (data []byte, answer []byte) {
+	data = make([]byte, 0, len(dump))
+	for _, b := range dump {
+		switch {
+		case f.inSub:
+			// Replies of the server on port settings are skipped
+			if f.subIAC {
+				f.subIAC = false
+				if b == telnetSE {
+					f.inSub = false
+				}
+			} else if b == telnetIAC {
+				f.subIAC = true
+			}
+		case f.state == telnetIAC:
+			f.state = 0
+			switch b {
+			case telnetIAC:
+				data = append(data, b)
+			case telnetSB:
+				f.inSub = true
+			case telnetWILL, telnetWONT, telnetDO, telnetDONT:
+				f.state = b
+			}
+		case f.state != 0:
+			answer = append(answer, answerOption(f.state, b)...)
+			f.state = 0
+		case b == telnetIAC:
+			f.state = telnetIAC
+		default:
+			data = append(data, b)
+		}
+	}
+	return data, answer
+}
+
+// Options that we ask for are confirmed by the server, the rest are refused
+func answerOption(cmd byte, opt byte) []byte {
+	known := opt == optBinary || opt == optSGA || opt == optComPort
+	switch cmd {
+	case telnetDO:
+		if !known {
+			return []byte{telnetIAC, telnetWONT, opt}
+		}
+	case telnetWILL:
+		if !known {
+			return []byte{telnetIAC, telnetDONT, opt}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package linker
+
+import (
+	"bytes"
+	"github.com/iftsoft/device/config"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testWait = 2 * time.Second
+
+// Reader that collects all received data
+type testReader struct {
+	lock sync.Mutex
+	data []byte
+}
+
+func (r *testReader) OnRead(dump []byte) int {
+	r.lock.Lock()
+	r.data = append(r.data, dump...)
+	r.lock.Unlock()
+	return len(dump)
+}
+
+func (r *testReader) get() []byte {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]byte{}, r.data...)
+}
+
+func waitFor(t *testing.T, what string, check func() bool) {
+	t.Helper()
+	for start := time.Now(); !check(); time.Sleep(5 * time.Millisecond) {
+		if time.Since(start) > testWait {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+	}
+}
+
+// Local server that hands accepted connections to the test
+func startTestServer(t *testing.T) (*config.TcpConfig, chan net.Conn) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	conns := make(chan net.Conn, 4)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns <- conn
+		}
+	}()
+	cfg := &config.TcpConfig{
+		Host:      "127.0.0.1",
+		Port:      uint16(ln.Addr().(*net.TCPAddr).Port),
+		Reconnect: 50,
+	}
+	return cfg, conns
+}
+
+func acceptConn(t *testing.T, conns chan net.Conn) net.Conn {
+	t.Helper()
+	select {
+	case conn := <-conns:
+		t.Cleanup(func() { _ = conn.Close() })
+		return conn
+	case <-time.After(testWait):
+		t.Fatal("no connection to server")
+	}
+	return nil
+}
+
+func readBytes(t *testing.T, conn net.Conn, size int) []byte {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(testWait))
+	data := make([]byte, size)
+	for n := 0; n < size; {
+		k, err := conn.Read(data[n:])
+		if err != nil {
+			t.Fatalf("server read %d of %d bytes: %s", n, size, err)
+		}
+		n += k
+	}
+	return data
+}
+
+func openTcpLink(t *testing.T, cfg *config.TcpConfig, ser *config.SerialConfig) (*TcpLink, *testReader) {
+	t.Helper()
+	reader := &testReader{}
+	link := NewTcpLink(cfg, ser, reader)
+	if err := link.Open(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = link.Close() })
+	return link, reader
+}
+
+// Raw mode passes data as is in both directions
+func TestTcpRaw(t *testing.T) {
+	cfg, conns := startTestServer(t)
+	link, reader := openTcpLink(t, cfg, nil)
+	conn := acceptConn(t, conns)
+
+	data := []byte{0x01, telnetIAC, 0x02}
+	if n, err := link.Write(data); err != nil || n != len(data) {
+		t.Fatalf("write %d bytes: %v", n, err)
+	}
+	if got := readBytes(t, conn, len(data)); !bytes.Equal(got, data) {
+		t.Errorf("server got % X", got)
+	}
+	reply := []byte{0x10, telnetIAC, telnetDO, 0x11}
+	_, _ = conn.Write(reply)
+	waitFor(t, "reply", func() bool { return bytes.Equal(reader.get(), reply) })
+}
+
+// Telnet mode negotiates serial port settings, escapes IAC and answers options
+func TestTcpTelnet(t *testing.T) {
+	cfg, conns := startTestServer(t)
+	cfg.Telnet = true
+	ser := &config.SerialConfig{BaudRate: 9600, DataBits: 8, StopBits: config.OneStopBit, Parity: config.EvenParity}
+	link, reader := openTcpLink(t, cfg, ser)
+	conn := acceptConn(t, conns)
+
+	want := []byte{
+		telnetIAC, telnetWILL, optComPort,
+		telnetIAC, telnetWILL, optBinary,
+		telnetIAC, telnetDO, optBinary,
+		telnetIAC, telnetWILL, optSGA,
+		telnetIAC, telnetDO, optSGA,
+		telnetIAC, telnetSB, optComPort, comSetBaudRate, 0x00, 0x00, 0x25, 0x80, telnetIAC, telnetSE,
+		telnetIAC, telnetSB, optComPort, comSetDataSize, 8, telnetIAC, telnetSE,
+		telnetIAC, telnetSB, optComPort, comSetParity, 3, telnetIAC, telnetSE,
+		telnetIAC, telnetSB, optComPort, comSetStopSize, 1, telnetIAC, telnetSE,
+	}
+	if got := readBytes(t, conn, len(want)); !bytes.Equal(got, want) {
+		t.Errorf("negotiation % X\nwant % X", got, want)
+	}
+
+	if n, err := link.Write([]byte{0x01, telnetIAC, 0x02}); err != nil || n != 3 {
+		t.Fatalf("write %d bytes: %v", n, err)
+	}
+	if got := readBytes(t, conn, 4); !bytes.Equal(got, []byte{0x01, telnetIAC, telnetIAC, 0x02}) {
+		t.Errorf("server got % X", got)
+	}
+
+	// Escaped data, unknown option request and server reply on port settings
+	_, _ = conn.Write([]byte{
+		0x05, telnetIAC, telnetIAC, 0x06,
+		telnetIAC, telnetDO, 24,
+		telnetIAC, telnetSB, optComPort, 101, 0x00, 0x00, 0x25, 0x80, telnetIAC, telnetSE,
+		telnetIAC, telnetWILL, optBinary,
+		0x07,
+	})
+	waitFor(t, "data", func() bool { return bytes.Equal(reader.get(), []byte{0x05, telnetIAC, 0x06, 0x07}) })
+	if got := readBytes(t, conn, 3); !bytes.Equal(got, []byte{telnetIAC, telnetWONT, 24}) {
+		t.Errorf("option answer % X", got)
+	}
+
+	if err := link.Flash(); err != nil {
+		t.Fatal(err)
+	}
+	purge := []byte{telnetIAC, telnetSB, optComPort, comPurgeData, 3, telnetIAC, telnetSE}
+	if got := readBytes(t, conn, len(purge)); !bytes.Equal(got, purge) {
+		t.Errorf("purge command % X", got)
+	}
+}
+
+// Dropped connection is restored until the link is closed
+func TestTcpReconnect(t *testing.T) {
+	cfg, conns := startTestServer(t)
+	link, reader := openTcpLink(t, cfg, nil)
+	_ = acceptConn(t, conns).Close()
+
+	conn := acceptConn(t, conns)
+	waitFor(t, "reconnect", link.IsOpen)
+	if _, err := link.Write([]byte{0x42}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readBytes(t, conn, 1); got[0] != 0x42 {
+		t.Errorf("server got % X", got)
+	}
+	_, _ = conn.Write([]byte{0x43})
+	waitFor(t, "reply", func() bool { return bytes.Equal(reader.get(), []byte{0x43}) })
+
+	if err := link.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if link.IsOpen() {
+		t.Error("link is open after close")
+	}
+	if _, err := link.Write([]byte{0x44}); err == nil {
+		t.Error("write to closed link")
+	}
+}
+
+// Telnet commands may be split across TCP segments
+func TestTelnetFilter(t *testing.T) {
+	parts := [][]byte{
+		{0x01, telnetIAC},
+		{telnetDO},
+		{24, 0x02, telnetIAC, telnetSB, optComPort},
+		{101, 0x00, telnetIAC},
+		{telnetSE, telnetIAC},
+		{telnetIAC, 0x03},
+	}
+	filter := &telnetFilter{}
+	var data, answer []byte
+	for _, part := range parts {
+		d, a := filter.process(part)
+		data = append(data, d...)
+		answer = append(answer, a...)
+	}
+	if !bytes.Equal(data, []byte{0x01, 0x02, telnetIAC, 0x03}) {
+		t.Errorf("data % X", data)
+	}
+	if !bytes.Equal(answer, []byte{telnetIAC, telnetWONT, 24}) {
+		t.Errorf("answer % X", answer)
+	}
+}
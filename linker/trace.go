@@ -0,0 +1,79 @@
+package linker
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Trace file has a line per data chunk:  milliseconds from start, TX or RX, hex bytes.
+// Lines starting with # are comments.
+const (
+	traceTX = "TX"
+	traceRX = "RX"
+)
+
+type TraceRecord struct {
+	Moment time.Duration
+	Dir    string
+	Data   []byte
+}
+
+func (tr *TraceRecord) String() string {
+	if tr == nil {
+		return ""
+	}
+	ms := float64(tr.Moment) / float64(time.Millisecond)
+	return fmt.Sprintf("%10.3f %s % X", ms, tr.Dir, tr.Data)
+}
+
+// LoadTrace reads records of trace file
+func LoadTrace(path string) ([]*TraceRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	list := make([]*TraceRecord, 0)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		rec, err := parseTraceLine(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("trace %s line %d: %s", path, line, err)
+		}
+		if rec != nil {
+			list = append(list, rec)
+		}
+	}
+	return list, scanner.Err()
+}
+
+func parseTraceLine(text string) (*TraceRecord, error) {
+	text = strings.TrimSpace(text)
+	if text == "" || strings.HasPrefix(text, "#") {
+		return nil, nil
+	}
+	fields := strings.Fields(text)
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("too few fields")
+	}
+	ms, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return nil, err
+	}
+	rec := &TraceRecord{
+		Moment: time.Duration(ms * float64(time.Millisecond)),
+		Dir:    strings.ToUpper(fields[1]),
+	}
+	if rec.Dir != traceTX && rec.Dir != traceRX {
+		return nil, fmt.Errorf("wrong direction %s", fields[1])
+	}
+	rec.Data, err = hex.DecodeString(strings.Join(fields[2:], ""))
+	return rec, err
+}
@@ -83,7 +83,7 @@ func (dc *DeviceClient) EvalPacket(pack *duplex.Packet) error {
 
 func (dc *DeviceClient) decodeQuery(name string, cmd string, dump []byte, query interface{}) error {
 	if dc.log != nil {
-		dc.log.Dump("DeviceClient dev:%s take cmd:%s, pack:%s", name, cmd, string(dump))
+		dc.log.Dump("DeviceClient dev:%s take cmd:%s, pack:%s", name, cmd, core.RedactJson(dump))
 	}
 	return json.Unmarshal(dump, query)
 }
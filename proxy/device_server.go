@@ -86,7 +86,7 @@ func (ss *DeviceServer) EvalPacket(pack *duplex.Packet) error {
 
 func (ss *DeviceServer) decodeReply(name string, cmd string, dump []byte, reply interface{}) (err error) {
 	if ss.log != nil {
-		ss.log.Dump("DeviceServer dev:%s take cmd:%s, pack:%s", name, cmd, string(dump))
+		ss.log.Dump("DeviceServer dev:%s take cmd:%s, pack:%s", name, cmd, core.RedactJson(dump))
 	}
 	err = json.Unmarshal(dump, reply)
 	return err
@@ -105,7 +105,7 @@ func (ss *DeviceServer) SendDeviceCommand(name string, cmd string, query interfa
 		return err
 	}
 	if ss.log != nil {
-		ss.log.Dump("DeviceServer dev:%s send cmd:%s, pack:%s", name, cmd, string(dump))
+		ss.log.Dump("DeviceServer dev:%s send cmd:%s, pack:%s", name, cmd, core.RedactJson(dump))
 	}
 	pack := duplex.NewPacket(duplex.ScopeDevice, name, cmd, dump)
 	err = transport.SendPacket(pack)
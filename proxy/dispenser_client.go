@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/core"
+	"github.com/iftsoft/device/duplex"
+)
+
+type DispenserClient struct {
+	commands  common.DispenserManager
+	log       *core.LogAgent
+}
+
+func NewDispenserClient() *DispenserClient {
+	dc := DispenserClient{
+		commands:  nil,
+		log:       nil,
+	}
+	return &dc
+}
+
+func (dc *DispenserClient) GetDispatcher() duplex.Dispatcher {
+	return dc
+}
+
+func (dc *DispenserClient) Init(command common.DispenserManager, log *core.LogAgent) {
+	dc.log = log
+	dc.commands = command
+}
+
+func (dc *DispenserClient) EvalPacket(pack *duplex.Packet) error {
+	if pack == nil {
+		return errors.New("duplex Packet is nil")
+	}
+	switch pack.Command {
+	case common.CmdInitDispenser:
+		query := &common.DispenserQuery{}
+		err := dc.decodeQuery(pack.DevName, pack.Command, pack.Content, query)
+		if err == nil && dc.commands != nil {
+			err = dc.commands.InitDispenser(pack.DevName, query)
+		}
+		return err
+
+	case common.CmdDispenseCash:
+		query := &common.DispenserQuery{}
+		err := dc.decodeQuery(pack.DevName, pack.Command, pack.Content, query)
+		if err == nil && dc.commands != nil {
+			err = dc.commands.DispenseCash(pack.DevName, query)
+		}
+		return err
+
+	case common.CmdStopDispense:
+		query := &common.DispenserQuery{}
+		err := dc.decodeQuery(pack.DevName, pack.Command, pack.Content, query)
+		if err == nil && dc.commands != nil {
+			err = dc.commands.StopDispense(pack.DevName, query)
+		}
+		return err
+
+	case common.CmdCheckDispenser:
+		query := &common.DispenserQuery{}
+		err := dc.decodeQuery(pack.DevName, pack.Command, pack.Content, query)
+		if err == nil && dc.commands != nil {
+			err = dc.commands.CheckDispenser(pack.DevName, query)
+		}
+		return err
+
+	default:
+		dc.log.Warn("DispenserClient EvalPacket: Unknown  command - %s", pack.Command)
+		return errors.New("duplex Packet unknown command")
+	}
+}
+
+func (dc *DispenserClient) decodeQuery(name string, cmd string, dump []byte, query interface{}) error {
+	if dc.log != nil {
+		dc.log.Dump("DispenserClient dev:%s take cmd:%s, pack:%s", name, cmd, core.RedactJson(dump))
+	}
+	return json.Unmarshal(dump, query)
+}
@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/iftsoft/device/common"
+	"github.com/iftsoft/device/core"
+	"github.com/iftsoft/device/duplex"
+)
+
+type DispenserServer struct {
+	server    duplex.ServerManager
+	callback  common.DispenserCallback
+	log       *core.LogAgent
+}
+
+func NewDispenserServer() *DispenserServer {
+	ds := DispenserServer{
+		server:    nil,
+		callback:  nil,
+		log:       nil,
+	}
+	return &ds
+}
+
+
+func (ds *DispenserServer) Init(server duplex.ServerManager, callback common.DispenserCallback, log *core.LogAgent) {
+	ds.log = log
+	ds.server = server
+	ds.callback = callback
+	if ds.server != nil {
+		ds.server.AddDispatcher(duplex.ScopeDispenser, ds)
+	}
+}
+
+func (ds *DispenserServer) EvalPacket(pack *duplex.Packet) error {
+	if pack == nil {
+		return errors.New("duplex Packet is nil")
+	}
+	switch pack.Command {
+	case common.CmdCashDispensed:
+		reply := &common.DispenserPayout{}
+		err := ds.decodeReply(pack.DevName, pack.Command, pack.Content, reply)
+		if err == nil && ds.callback != nil {
+			err = ds.callback.CashDispensed(pack.DevName, reply)
+		}
+		return err
+
+	case common.CmdDispenserStore:
+		reply := &common.DispenserStore{}
+		err := ds.decodeReply(pack.DevName, pack.Command, pack.Content, reply)
+		if err == nil && ds.callback != nil {
+			err = ds.callback.DispenserStore(pack.DevName, reply)
+		}
+		return err
+
+	default:
+		ds.log.Warn("DispenserServer EvalPacket: Unknown  command - %s", pack.Command)
+		return errors.New("duplex Packet unknown command")
+	}
+}
+
+func (ds *DispenserServer) decodeReply(name string, cmd string, dump []byte, reply interface{}) (err error) {
+	if ds.log != nil {
+		ds.log.Dump("DispenserServer dev:%s take cmd:%s, pack:%s", name, cmd, core.RedactJson(dump))
+	}
+	err = json.Unmarshal(dump, reply)
+	return err
+}
+
+func (ds *DispenserServer) SendDispenserCommand(name string, cmd string, query interface{}) error {
+	if ds.server == nil {
+		return errors.New("ServerManager is not set for DispenserServer")
+	}
+	transport := ds.server.GetTransporter(name)
+	if transport == nil {
+		return errors.New("DispenserServer can't get transport to device")
+	}
+	dump, err := json.Marshal(query)
+	if err != nil {
+		return err
+	}
+	if ds.log != nil {
+		ds.log.Dump("DispenserServer dev:%s send cmd:%s, pack:%s", name, cmd, core.RedactJson(dump))
+	}
+	pack := duplex.NewPacket(duplex.ScopeDispenser, name, cmd, dump)
+	err = transport.SendPacket(pack)
+	return err
+}
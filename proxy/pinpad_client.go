@@ -75,6 +75,30 @@ func (ppc *PinPadClient) EvalPacket(pack *duplex.Packet) error {
 		}
 		return err
 
+	case common.CmdLoadDukptKey:
+		query := &common.ReaderPinQuery{}
+		err := ppc.decodeQuery(pack.DevName, pack.Command, pack.Content, query)
+		if err == nil && ppc.commands != nil {
+			err = ppc.commands.LoadDukptKey(pack.DevName, query)
+		}
+		return err
+
+	case common.CmdGenerateMAC:
+		query := &common.ReaderPinQuery{}
+		err := ppc.decodeQuery(pack.DevName, pack.Command, pack.Content, query)
+		if err == nil && ppc.commands != nil {
+			err = ppc.commands.GenerateMAC(pack.DevName, query)
+		}
+		return err
+
+	case common.CmdReadData:
+		query := &common.ReaderDataQuery{}
+		err := ppc.decodeQuery(pack.DevName, pack.Command, pack.Content, query)
+		if err == nil && ppc.commands != nil {
+			err = ppc.commands.ReadData(pack.DevName, query)
+		}
+		return err
+
 	default:
 		ppc.log.Warn("PinPadClient EvalPacket: Unknown  command - %s", pack.Command)
 		return errors.New("duplex Packet unknown command")
@@ -83,7 +107,7 @@ func (ppc *PinPadClient) EvalPacket(pack *duplex.Packet) error {
 
 func (ppc *PinPadClient) decodeQuery(name string, cmd string, dump []byte, query interface{}) error {
 	if ppc.log != nil {
-		ppc.log.Dump("PinPadClient dev:%s take cmd:%s, pack:%s", name, cmd, string(dump))
+		ppc.log.Dump("PinPadClient dev:%s take cmd:%s, pack:%s", name, cmd, core.RedactJson(dump))
 	}
 	return json.Unmarshal(dump, query)
 }
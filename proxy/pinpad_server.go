@@ -45,6 +45,14 @@ func (pps *PinPadServer) EvalPacket(pack *duplex.Packet) error {
 		}
 		return err
 
+	case common.CmdPinPadKeyPress:
+		reply := &common.ReaderKeyPress{}
+		err := pps.decodeReply(pack.DevName, pack.Command, pack.Content, reply)
+		if err == nil && pps.callback != nil {
+			err = pps.callback.PinPadKeyPress(pack.DevName, reply)
+		}
+		return err
+
 	default:
 		pps.log.Warn("PinPadServer EvalPacket: Unknown  command - %s", pack.Command)
 		return errors.New("duplex Packet unknown command")
@@ -53,7 +61,7 @@ func (pps *PinPadServer) EvalPacket(pack *duplex.Packet) error {
 
 func (pps *PinPadServer) decodeReply(name string, cmd string, dump []byte, reply interface{}) (err error) {
 	if pps.log != nil {
-		pps.log.Dump("PinPadServer dev:%s take cmd:%s, pack:%s", name, cmd, string(dump))
+		pps.log.Dump("PinPadServer dev:%s take cmd:%s, pack:%s", name, cmd, core.RedactJson(dump))
 	}
 	err = json.Unmarshal(dump, reply)
 	return err
@@ -72,7 +80,7 @@ func (pps *PinPadServer) SendPinPadCommand(name string, cmd string, query interf
 		return err
 	}
 	if pps.log != nil {
-		pps.log.Dump("ReaderServer dev:%s send cmd:%s, pack:%s", name, cmd, string(dump))
+		pps.log.Dump("ReaderServer dev:%s send cmd:%s, pack:%s", name, cmd, core.RedactJson(dump))
 	}
 	pack := duplex.NewPacket(duplex.ScopePinPad, name, cmd, dump)
 	err = transport.SendPacket(pack)
@@ -51,6 +51,14 @@ func (pc *PrinterClient) EvalPacket(pack *duplex.Packet) error {
 		}
 		return err
 
+	case common.CmdPrintDocument:
+		query := &common.PrinterDocument{}
+		err := pc.decodeQuery(pack.DevName, pack.Command, pack.Content, query)
+		if err == nil && pc.commands != nil {
+			err = pc.commands.PrintDocument(pack.DevName, query)
+		}
+		return err
+
 	default:
 		pc.log.Warn("PrinterClient EvalPacket: Unknown  command - %s", pack.Command)
 		return errors.New("duplex Packet unknown command")
@@ -59,7 +67,7 @@ func (pc *PrinterClient) EvalPacket(pack *duplex.Packet) error {
 
 func (pc *PrinterClient) decodeQuery(name string, cmd string, dump []byte, query interface{}) error {
 	if pc.log != nil {
-		pc.log.Dump("PrinterClient dev:%s take cmd:%s, pack:%s", name, cmd, string(dump))
+		pc.log.Dump("PrinterClient dev:%s take cmd:%s, pack:%s", name, cmd, core.RedactJson(dump))
 	}
 	return json.Unmarshal(dump, query)
 }
@@ -54,7 +54,7 @@ func (ps *PrinterServer) EvalPacket(pack *duplex.Packet) error {
 
 func (ps *PrinterServer) decodeReply(name string, cmd string, dump []byte, reply interface{}) (err error) {
 	if ps.log != nil {
-		ps.log.Dump("PrinterServer dev:%s take cmd:%s, pack:%s", name, cmd, string(dump))
+		ps.log.Dump("PrinterServer dev:%s take cmd:%s, pack:%s", name, cmd, core.RedactJson(dump))
 	}
 	err = json.Unmarshal(dump, reply)
 	return err
@@ -73,7 +73,7 @@ func (ps *PrinterServer) SendPrinterCommand(name string, cmd string, query inter
 		return err
 	}
 	if ps.log != nil {
-		ps.log.Dump("PrinterServer dev:%s send cmd:%s, pack:%s", name, cmd, string(dump))
+		ps.log.Dump("PrinterServer dev:%s send cmd:%s, pack:%s", name, cmd, core.RedactJson(dump))
 	}
 	pack := duplex.NewPacket(duplex.ScopePrinter, name, cmd, dump)
 	err = transport.SendPacket(pack)
@@ -99,7 +99,7 @@ func (rc *ReaderClient) EvalPacket(pack *duplex.Packet) error {
 
 func (rc *ReaderClient) decodeQuery(name string, cmd string, dump []byte, query interface{}) error {
 	if rc.log != nil {
-		rc.log.Dump("ReaderClient dev:%s take cmd:%s, pack:%s", name, cmd, string(dump))
+		rc.log.Dump("ReaderClient dev:%s take cmd:%s, pack:%s", name, cmd, core.RedactJson(dump))
 	}
 	return json.Unmarshal(dump, query)
 }
@@ -69,7 +69,7 @@ func (rs *ReaderServer) EvalPacket(pack *duplex.Packet) error {
 
 func (rs *ReaderServer) decodeReply(name string, cmd string, dump []byte, reply interface{}) (err error) {
 	if rs.log != nil {
-		rs.log.Dump("ReaderServer dev:%s take cmd:%s, pack:%s", name, cmd, string(dump))
+		rs.log.Dump("ReaderServer dev:%s take cmd:%s, pack:%s", name, cmd, core.RedactJson(dump))
 	}
 	err = json.Unmarshal(dump, reply)
 	return err
@@ -88,7 +88,7 @@ func (rs *ReaderServer) SendReaderCommand(name string, cmd string, query interfa
 		return err
 	}
 	if rs.log != nil {
-		rs.log.Dump("ReaderServer dev:%s send cmd:%s, pack:%s", name, cmd, string(dump))
+		rs.log.Dump("ReaderServer dev:%s send cmd:%s, pack:%s", name, cmd, core.RedactJson(dump))
 	}
 	pack := duplex.NewPacket(duplex.ScopeReader, name, cmd, dump)
 	err = transport.SendPacket(pack)
@@ -85,7 +85,7 @@ func (sc *SystemClient) EvalPacket(pack *duplex.Packet) error {
 
 func (sc *SystemClient) decodeQuery(name string, cmd string, dump []byte, query interface{}) error {
 	if sc.log != nil {
-		sc.log.Dump("SystemClient dev:%s take cmd:%s, pack:%s", name, cmd, string(dump))
+		sc.log.Dump("SystemClient dev:%s take cmd:%s, pack:%s", name, cmd, core.RedactJson(dump))
 	}
 	return json.Unmarshal(dump, query)
 }
@@ -61,7 +61,7 @@ func (ss *SystemServer) EvalPacket(pack *duplex.Packet) error {
 
 func (ss *SystemServer) decodeReply(name string, cmd string, dump []byte, reply interface{}) error {
 	if ss.log != nil {
-		ss.log.Dump("SystemServer dev:%s get cmd:%s, pack:%s", name, cmd, string(dump))
+		ss.log.Dump("SystemServer dev:%s get cmd:%s, pack:%s", name, cmd, core.RedactJson(dump))
 	}
 	return json.Unmarshal(dump, reply)
 }
@@ -79,7 +79,7 @@ func (ss *SystemServer) SendSystemCommand(name string, cmd string, query interfa
 		return err
 	}
 	if ss.log != nil {
-		ss.log.Dump("SystemServer dev:%s send cmd:%s, pack:%s", name, cmd, string(dump))
+		ss.log.Dump("SystemServer dev:%s send cmd:%s, pack:%s", name, cmd, core.RedactJson(dump))
 	}
 	pack := duplex.NewPacket(duplex.ScopeSystem, name, cmd, dump)
 	err = transport.SendPacket(pack)
@@ -99,7 +99,7 @@ func (vc *ValidatorClient) EvalPacket(pack *duplex.Packet) error {
 
 func (vc *ValidatorClient) decodeQuery(name string, cmd string, dump []byte, query interface{}) error {
 	if vc.log != nil {
-		vc.log.Dump("ValidatorClient dev:%s take cmd:%s, pack:%s", name, cmd, string(dump))
+		vc.log.Dump("ValidatorClient dev:%s take cmd:%s, pack:%s", name, cmd, core.RedactJson(dump))
 	}
 	return json.Unmarshal(dump, query)
 }
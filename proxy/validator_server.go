@@ -78,7 +78,7 @@ func (vs *ValidatorServer) EvalPacket(pack *duplex.Packet) error {
 
 func (vs *ValidatorServer) decodeReply(name string, cmd string, dump []byte, reply interface{}) (err error) {
 	if vs.log != nil {
-		vs.log.Dump("ValidatorServer dev:%s take cmd:%s, pack:%s", name, cmd, string(dump))
+		vs.log.Dump("ValidatorServer dev:%s take cmd:%s, pack:%s", name, cmd, core.RedactJson(dump))
 	}
 	err = json.Unmarshal(dump, reply)
 	return err
@@ -97,7 +97,7 @@ func (vs *ValidatorServer) SendValidatorCommand(name string, cmd string, query i
 		return err
 	}
 	if vs.log != nil {
-		vs.log.Dump("ValidatorServer dev:%s send cmd:%s, pack:%s", name, cmd, string(dump))
+		vs.log.Dump("ValidatorServer dev:%s send cmd:%s, pack:%s", name, cmd, core.RedactJson(dump))
 	}
 	pack := duplex.NewPacket(duplex.ScopeValidator, name, cmd, dump)
 	err = transport.SendPacket(pack)
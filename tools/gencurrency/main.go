@@ -0,0 +1,113 @@
+// Command gencurrency builds the ISO 4217 currency registry of the common package.
+//
+// Source data is a CSV file with columns: alpha, numeric, minor, withdrawn, name.
+// Minor units "N.A." are stored as zero, non empty withdrawn column marks retired currency.
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type currency struct {
+	alpha     string
+	numeric   int
+	minor     int
+	withdrawn string
+	name      string
+}
+
+func main() {
+	in := flag.String("in", "iso4217.csv", "source CSV file")
+	out := flag.String("out", "currency_table.go", "generated Go file")
+	flag.Parse()
+
+	list, err := readCurrencies(*in)
+	if err == nil {
+		err = writeRegistry(*out, list)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gencurrency:", err)
+		os.Exit(1)
+	}
+}
+
+func readCurrencies(name string) ([]*currency, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*currency, 0, len(rows))
+	codes := make(map[int]string)
+	for i, row := range rows {
+		if i == 0 {
+			continue
+		}
+		if len(row) != 5 {
+			return nil, fmt.Errorf("line %d: wrong column count", i+1)
+		}
+		curr := &currency{
+			alpha:     strings.ToUpper(strings.TrimSpace(row[0])),
+			withdrawn: strings.TrimSpace(row[3]),
+			name:      strings.TrimSpace(row[4]),
+		}
+		curr.numeric, err = strconv.Atoi(strings.TrimSpace(row[1]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", i+1, err)
+		}
+		if minor := strings.TrimSpace(row[2]); minor != "N.A." {
+			curr.minor, err = strconv.Atoi(minor)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", i+1, err)
+			}
+		}
+		if len(curr.alpha) != 3 {
+			return nil, fmt.Errorf("line %d: bad alpha code %s", i+1, curr.alpha)
+		}
+		if prev, ok := codes[curr.numeric]; ok {
+			return nil, fmt.Errorf("line %d: numeric code %03d is used by %s", i+1, curr.numeric, prev)
+		}
+		codes[curr.numeric] = curr.alpha
+		list = append(list, curr)
+	}
+	return list, nil
+}
+
+func writeRegistry(name string, list []*currency) error {
+	buf := &bytes.Buffer{}
+	fmt.Fprintln(buf, "// Code generated by tools/gencurrency from iso4217.csv. DO NOT EDIT.")
+	fmt.Fprintln(buf)
+	fmt.Fprintln(buf, "package common")
+	fmt.Fprintln(buf)
+	fmt.Fprintln(buf, "// ISO 4217 currency codes")
+	fmt.Fprintln(buf, "const (")
+	for _, curr := range list {
+		fmt.Fprintf(buf, "\tCurrency%s DevCurrency = %d // %s\n", curr.alpha, curr.numeric, curr.name)
+	}
+	fmt.Fprintln(buf, ")")
+	fmt.Fprintln(buf)
+	fmt.Fprintln(buf, "var currencyTable = []CurrencyInfo{")
+	for _, curr := range list {
+		fmt.Fprintf(buf, "\t{Currency%s, %q, %q, %d, %t},\n",
+			curr.alpha, curr.alpha, curr.name, curr.minor, curr.withdrawn != "")
+	}
+	fmt.Fprintln(buf, "}")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(name, src, 0644)
+}